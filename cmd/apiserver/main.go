@@ -1,14 +1,23 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/honganh1206/tinker/internal/apiserver"
 	"github.com/honganh1206/tinker/internal/eventbus"
 	"github.com/honganh1206/tinker/internal/logger"
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/secret"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tracing"
+	"github.com/honganh1206/tinker/internal/users"
 	"github.com/honganh1206/tinker/internal/web"
 )
 
@@ -16,14 +25,47 @@ func main() {
 	var addr string
 	var eventBusURL string
 	var sessionDir string
+	var corsOrigins string
+	var workspace string
+	var provider string
+	var modelName string
+	var geminiSafety string
+	var jobsRoot string
+	var jobWorkers int
+	var tinkerPath string
+	var limits apiserver.Limits
 
 	flag.StringVar(&addr, "addr", ":11435", "Listen address")
 	flag.StringVar(&eventBusURL, "event-bus-url", os.Getenv("NATS_LOCAL_PORT"), "NATS event bus URL")
 	flag.StringVar(&sessionDir, "store-dir", "", "Session store directory (default ~/.tinker/sessions)")
+	flag.StringVar(&corsOrigins, "cors-origins", "", "Comma-separated origins allowed to call the API from a browser, * for any")
+	flag.StringVar(&workspace, "workspace", "", "Directory agent turns run in; enables POST /api/sessions/{id}/messages")
+	flag.StringVar(&provider, "provider", "anthropic", "LLM provider of agent turns (anthropic, gemini)")
+	flag.StringVar(&modelName, "model", string(model.Claude46Sonnet), "LLM model of agent turns")
+	flag.StringVar(&geminiSafety, "gemini-safety", "", "Threshold of Gemini's safety filters ("+strings.Join(model.SafetyThresholds, ", ")+")")
+	flag.StringVar(&jobsRoot, "jobs-root", "", "Directory holding the repositories jobs may run in; enables /api/jobs")
+	flag.IntVar(&jobWorkers, "job-workers", 2, "Number of jobs run at once")
+	flag.StringVar(&tinkerPath, "tinker", "tinker", "tinker executable jobs run")
+	flag.IntVar(&limits.RequestsPerMinute, "rate-limit", 600, "API requests per minute of each client, 0 for no limit")
+	flag.IntVar(&limits.Burst, "rate-burst", 60, "API requests a client can make at once above -rate-limit")
+	flag.IntVar(&limits.MaxConcurrent, "max-concurrent", 64, "API requests served at once, 0 for no limit")
 	flag.Parse()
 
 	log := logger.NewLogger(os.Stderr, true)
 
+	shutdownTracing, err := tracing.Setup(context.Background(), "tinker-apiserver")
+	if err != nil {
+		log.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("failed to export traces", "error", err)
+		}
+	}()
+
 	if sessionDir == "" {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -32,11 +74,26 @@ func main() {
 		}
 		sessionDir = filepath.Join(home, ".tinker", "sessions")
 	}
+	// Agent turns change the working directory of the server, and jobs run
+	// in their repository
+	dir, err := filepath.Abs(sessionDir)
+	if err != nil {
+		log.Error("invalid store dir", "error", err)
+		os.Exit(1)
+	}
+	sessionDir = dir
 
-	mcpDir := ""
+	// Without a terminal the passphrase of encrypted sessions comes from TINKER_PASSPHRASE
+	if err := secret.Configure(nil); err != nil {
+		log.Error("failed to load encryption config", "error", err)
+		os.Exit(1)
+	}
+
+	mcpDir, configPath := "", ""
 	home, err := os.UserHomeDir()
 	if err == nil {
 		mcpDir = filepath.Join(home, ".tinker", "mcp", "servers")
+		configPath = filepath.Join(home, ".tinker", "config.json")
 	}
 
 	frontendFS, err := fs.Sub(web.Dist, "dist")
@@ -53,7 +110,71 @@ func main() {
 		bus = natsbus
 	}
 
-	srv := apiserver.NewServer(bus, log, sessionDir, mcpDir)
+	srv := apiserver.NewServer(bus, log, sessionDir, mcpDir, configPath)
+	srv.SetLimits(limits)
+	if corsOrigins != "" {
+		var origins []string
+		for _, o := range strings.Split(corsOrigins, ",") {
+			origins = append(origins, strings.TrimSpace(o))
+		}
+		srv.AllowOrigins(origins...)
+	}
+
+	if workspace != "" {
+		dir, err := filepath.Abs(workspace)
+		if err != nil {
+			log.Error("invalid workspace", "error", err)
+			os.Exit(1)
+		}
+		llm, err := model.New(model.ProviderName(provider), model.ModelVersion(modelName))
+		if err != nil {
+			log.Error("failed to create model", "error", err)
+			os.Exit(1)
+		}
+		if f, ok := llm.(model.SafetyFilter); ok && geminiSafety != "" {
+			if err := f.SetSafetyThreshold(geminiSafety); err != nil {
+				log.Error("invalid -gemini-safety", "error", err)
+				os.Exit(1)
+			}
+		}
+		srv.SetAgent(llm, model.ResponseStyle{Verbosity: model.VerbosityStandard}, dir)
+		log.Info("Agent turns enabled", "workspace", dir, "model", modelName)
+	}
+
+	if jobsRoot != "" {
+		root, err := filepath.Abs(jobsRoot)
+		if err != nil {
+			log.Error("invalid jobs root", "error", err)
+			os.Exit(1)
+		}
+		tinker, err := exec.LookPath(tinkerPath)
+		if err != nil {
+			log.Error("failed to find the tinker executable", "error", err)
+			os.Exit(1)
+		}
+		srv.EnableJobs(tinker, root, jobWorkers)
+		log.Info("Jobs enabled", "root", root, "workers", jobWorkers)
+
+		// Schedules run as jobs, so they need them
+		if home != "" {
+			db, err := storage.OpenSchedules(filepath.Join(home, ".tinker", storage.SchedulesFile))
+			if err != nil {
+				log.Error("failed to open schedules", "error", err)
+				os.Exit(1)
+			}
+			srv.EnableSchedules(db)
+			log.Info("Schedules enabled")
+		}
+	}
+
+	if path, err := users.DefaultPath(); err == nil {
+		u, err := users.Load(path)
+		if err != nil {
+			log.Error("failed to load users", "error", err)
+			os.Exit(1)
+		}
+		srv.SetUsers(u)
+	}
 
 	if err := srv.Start(addr, frontendFS); err != nil {
 		log.Error("api server failed", "error", err)
@@ -200,7 +200,7 @@ func (dc *DiscordChannel) messageCreate(s *discordgo.Session, m *discordgo.Messa
 	}
 
 	if err := dc.PublishInbound(context.Background(), msg); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to publish inbound: %v\n", err)
+		dc.log.Error("failed to publish inbound", "error", err)
 	}
 }
 
@@ -208,8 +208,7 @@ func (dc *DiscordChannel) messageCreate(s *discordgo.Session, m *discordgo.Messa
 func (dc *DiscordChannel) handleOutbound(ctx context.Context) {
 	events, err := dc.SubscribeOutbound(ctx)
 	if err != nil {
-		// Format and write to stderr
-		fmt.Fprintf(os.Stderr, "failed to subscribe to outbound: %v\n", err)
+		dc.log.Error("failed to subscribe to outbound", "error", err)
 		return
 	}
 
@@ -220,13 +219,14 @@ func (dc *DiscordChannel) handleOutbound(ctx context.Context) {
 		case event := <-events:
 			var msg channel.OutboundMessage
 			if err := json.Unmarshal(event.Data, &msg); err != nil {
+				dc.log.Debug("skipping malformed outbound message", "error", err)
 				continue
 			}
 			if msg.Channel != "discord" {
 				continue
 			}
 			if err := dc.sendMessage(msg); err != nil {
-				fmt.Fprintf(os.Stderr, "failed to send discord message: %v\n", err)
+				dc.log.Error("failed to send discord message", "error", err)
 			}
 		}
 	}
@@ -238,7 +238,12 @@ func (dc *DiscordChannel) sendMessage(msg channel.OutboundMessage) error {
 	if msg.ThreadID != "" {
 		target = msg.ThreadID
 	}
-	_, err := dc.session.ChannelMessageSend(target, msg.Text)
+	text := msg.Text
+	if msg.Footer != "" {
+		// Discord subtext keeps the footer visually subtle
+		text += "\n-# " + msg.Footer
+	}
+	_, err := dc.session.ChannelMessageSend(target, text)
 	return err
 }
 
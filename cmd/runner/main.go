@@ -3,33 +3,55 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
-	"fmt"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/honganh1206/tinker/internal/agent"
 	"github.com/honganh1206/tinker/internal/channel"
+	"github.com/honganh1206/tinker/internal/crash"
 	"github.com/honganh1206/tinker/internal/eventbus"
 	"github.com/honganh1206/tinker/internal/logger"
 	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/secret"
 	"github.com/honganh1206/tinker/internal/storage"
-	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/honganh1206/tinker/internal/tracing"
+	"github.com/honganh1206/tinker/internal/users"
 )
 
 func main() {
 	var provider string
 	var modelName string
 	var eventBusURL string
+	var language string
+	var verbosity string
 
 	flag.StringVar(&provider, "provider", "anthropic", "LLM provider (anthropic, gemini)")
 	flag.StringVar(&modelName, "model", string(model.Claude46Sonnet), "LLM model name")
 	flag.StringVar(&eventBusURL, "event-bus-url", os.Getenv("NATS_LOCAL_PORT"), "Event bus URL")
+	flag.StringVar(&language, "language", "", "Language the agent answers in (default: the prompt's)")
+	flag.StringVar(&verbosity, "verbosity", string(model.VerbosityStandard), "Answer verbosity: concise, standard or explanatory")
 	flag.Parse()
 
 	log := logger.NewLogger(os.Stderr, true)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "tinker-runner")
+	if err != nil {
+		log.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("failed to export traces", "error", err)
+		}
+	}()
 	log.Info("runner starting...")
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -37,14 +59,27 @@ func main() {
 
 	home, err := os.UserHomeDir()
 	if err != nil {
+		log.Error("failed to get home directory", "error", err)
 		os.Exit(1)
 	}
 	sessionDir := filepath.Join(home, ".tinker", "sessions")
 
-	// TODO: Add a unified model client here instead of hardcoding Claude
-	// or maybe we hardcode Claude for implementation? :)
-	llm, err := model.NewClaudeModel(model.ModelVersion(modelName))
+	// Without a terminal the passphrase of encrypted sessions comes from TINKER_PASSPHRASE
+	if err := secret.Configure(nil); err != nil {
+		log.Error("failed to load encryption config", "error", err)
+		os.Exit(1)
+	}
+
+	v, err := model.ParseVerbosity(verbosity)
 	if err != nil {
+		log.Error("invalid verbosity", "error", err)
+		os.Exit(1)
+	}
+	style := model.ResponseStyle{Language: language, Verbosity: v}
+
+	llm, err := model.New(model.ProviderName(provider), model.ModelVersion(modelName))
+	if err != nil {
+		log.Error("failed to create model", "error", err)
 		os.Exit(1)
 	}
 
@@ -94,12 +129,69 @@ func main() {
 				"sender", msg.SenderName,
 				"text", truncateForLog(msg.Text, 80))
 
-			finalMessage, err := handleMessage(eventCtx, llm, sessionDir, msg.ThreadID, msg.Text, log)
-			if err != nil {
-				log.Error("agent run failed", "error", err)
-				continue
+			// Conversations of a registered sender are kept with that user's
+			// sessions, and their events tagged so only they see the stream
+			threadDir, meta := sessionDir, event.Metadata
+			user := senderUser(msg, log)
+			if user != "" {
+				threadDir = users.SessionsDir(sessionDir, user)
+				meta = maps.Clone(event.Metadata)
+				if meta == nil {
+					meta = map[string]string{}
+				}
+				meta["user"] = user
+			}
+
+			onEvent := func(ev model.Event) {
+				var topic string
+				var data any
+				switch ev.Kind {
+				case model.EventToolInput:
+					d := ev.ToolInput
+					topic, data = eventbus.TopicAgentStreamChunk, channel.AgentToolInput{
+						ThreadID:     msg.ThreadID,
+						ToolUseID:    d.ToolUseID,
+						Tool:         d.Name,
+						PartialInput: d.PartialInput,
+						Done:         d.Done,
+					}
+				case model.EventFileEdit:
+					topic, data = eventbus.TopicAgentFileEdit, channel.AgentFileEdit{
+						ThreadID: msg.ThreadID,
+						Tool:     ev.Tool,
+						FileEdit: ev.Edit,
+					}
+				default:
+					return
+				}
+				streamEvent, err := eventbus.NewEvent(topic, meta, data)
+				if err != nil {
+					log.Error("failed to create stream event", "error", err, "topic", topic)
+					return
+				}
+				if err := bus.Publish(eventCtx, topic, streamEvent); err != nil {
+					log.Error("failed to publish stream event", "error", err, "topic", topic)
+				}
 			}
 
+			var finalMessage string
+			var stats storage.TurnStats
+			// A panic in a tool or provider must not stop the runner for every thread
+			err := crash.Guard(crash.DefaultDir(), func() (err error) {
+				finalMessage, stats, err = agent.RunTurn(eventCtx, agent.Turn{
+					Model:       llm,
+					Style:       style,
+					SessionsDir: threadDir,
+					ThreadID:    msg.ThreadID,
+					Prompt:      msg.Text,
+					Logger:      log,
+					OnEvent:     onEvent,
+					BlobsDir:    filepath.Join(home, ".tinker", storage.BlobsDir),
+					Owner:       user,
+				})
+				return err
+			})
+
 			completed := channel.AgentRunCompleted{
 				Channel:      msg.Channel,
 				ChatID:       msg.ChatID,
@@ -107,9 +199,27 @@ func main() {
 				ReplyTo:      msg.Metadata["messageId"],
 				FinalMessage: finalMessage,
 				Status:       "success",
+				Stats:        stats.String(),
 			}
 
-			doneEvent, err := eventbus.NewEvent(eventbus.TopicAgentRunCompleted, event.Metadata, completed)
+			if err != nil {
+				publishFailed(eventCtx, bus, meta, msg, err, log)
+			}
+
+			var perr *crash.PanicError
+			switch {
+			case errors.As(err, &perr):
+				log.Error("agent run panicked", "error", perr.Value, "report", perr.Report)
+				// The session kept everything up to the crash, so the thread can carry on
+				completed.Status = "error"
+				completed.FinalMessage = "Tinker crashed during this turn. The conversation is saved, reply in this thread to resume it."
+				completed.Stats = ""
+			case err != nil:
+				log.Error("agent run failed", "error", err)
+				continue
+			}
+
+			doneEvent, err := eventbus.NewEvent(eventbus.TopicAgentRunCompleted, meta, completed)
 			if err != nil {
 				log.Error("failed to create completed event", "error", err)
 				continue
@@ -122,60 +232,38 @@ func main() {
 	}
 }
 
-func handleMessage(ctx context.Context, llm model.Model, sessionDir, threadID, prompt string, log *logger.Logger) (string, error) {
-	db, err := storage.OpenSession(sessionDir, threadID)
-	if err != nil {
-		// Could there be any error that is not related to no session?
-		db, err = storage.NewSession(sessionDir, threadID)
-		if err != nil {
-			return "", fmt.Errorf("creating new session: %w", err)
-		}
-	}
-
-	cw, err := model.NewContextWindow(db, llm, threadID)
+// publishFailed reports the failed turn of msg, for webhooks to pick up.
+func publishFailed(ctx context.Context, bus eventbus.EventBus, meta map[string]string, msg channel.InboundMessage, runErr error, log *logger.Logger) {
+	ev, err := eventbus.NewEvent(eventbus.TopicAgentRunFailed, meta, channel.AgentRunFailed{
+		Channel:  msg.Channel,
+		ChatID:   msg.ChatID,
+		ThreadID: msg.ThreadID,
+		Error:    runErr.Error(),
+	})
 	if err != nil {
-		if err = db.Close(); err != nil {
-			return "", fmt.Errorf("closing db: %w", err)
-		}
-		return "", err
+		log.Error("failed to create failed event", "error", err)
+		return
 	}
-	defer cw.Close()
-
-	builtinTools := []tools.ToolDefinition{
-		tools.ReadFileDefinition,
-		tools.ListFilesDefinition,
-		tools.EditFileDefinition,
-		tools.GrepSearchDefinition,
-		tools.FinderDefinition,
-		tools.BashDefinition,
-		tools.WebSearchDefinition,
-		tools.ReadWebPageDefinition,
+	if err := bus.Publish(ctx, eventbus.TopicAgentRunFailed, ev); err != nil {
+		log.Error("failed to publish failed event", "error", err)
 	}
+}
 
-	exists, err := cw.HasContext()
+// senderUser returns the user the sender of msg is registered to, empty for
+// unknown senders. Users are read for each message, so added ones take
+// effect without a restart.
+func senderUser(msg channel.InboundMessage, log *logger.Logger) string {
+	path, err := users.DefaultPath()
 	if err != nil {
-		return "", err
+		return ""
 	}
-	if !exists {
-		for _, t := range builtinTools {
-			// Insert tool context to the session and load the tools to the memory
-			if err := cw.RegisterTool(t); err != nil {
-				return "", err
-			}
-		}
-	} else {
-		for _, t := range builtinTools {
-			cw.LoadTool(t)
-			// Only load the tools into memory
-		}
+	u, err := users.Load(path)
+	if err != nil {
+		log.Error("failed to load users", "error", err)
+		return ""
 	}
-
-	a := agent.New(&agent.Config{
-		ContextWindow: cw,
-		Logger:        log,
-	})
-
-	return a.Run(ctx, prompt)
+	user, _ := u.BySender(msg.Channel, msg.SenderID)
+	return user.Name
 }
 
 func truncateForLog(s string, n int) string {
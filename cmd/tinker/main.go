@@ -3,15 +3,44 @@ package main
 import (
 	"context"
 	_ "embed"
+	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/honganh1206/tinker/internal/cli"
+	"github.com/honganh1206/tinker/internal/crash"
+	"github.com/honganh1206/tinker/internal/tracing"
 )
 
 func main() {
+	shutdown, err := tracing.Setup(context.Background(), "tinker")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tracing disabled: %v\n", err)
+		shutdown = func(context.Context) error { return nil }
+	}
+
 	root := cli.NewCLI()
-	err := root.ExecuteContext(context.Background())
+	err = crash.Guard(crash.DefaultDir(), func() error {
+		return root.ExecuteContext(context.Background())
+	})
+	flushTraces(shutdown)
+
+	var perr *crash.PanicError
+	if errors.As(err, &perr) {
+		fmt.Fprintf(os.Stderr, "tinker crashed: %v\nThe crash report is at %s\n", perr.Value, perr.Report)
+	}
 	if err != nil {
 		os.Exit(1)
 	}
 }
+
+// flushTraces exports the spans left before exiting, without holding up
+// the exit for long when the collector is down.
+func flushTraces(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "export traces: %v\n", err)
+	}
+}
@@ -2,12 +2,16 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/honganh1206/tinker/internal/logger"
 	"github.com/honganh1206/tinker/internal/mcp"
 	"github.com/honganh1206/tinker/internal/model"
 	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/honganh1206/tinker/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Agent struct {
@@ -52,13 +56,22 @@ func (a *Agent) StartMCP(ctx context.Context, configs []mcp.ServerConfig) error
 
 	for _, t := range mcpTools {
 		// runner := &tools.MCPToolRunner{Manager: a.MCP, Name: t.Name}
-		if err := a.CW.RegisterTool(tools.ToolDefinition{
+		annotations := tools.Annotations{
+			ReadOnly:    t.Annotations.ReadOnly(),
+			Destructive: t.Annotations.Destructive(),
+			Idempotent:  t.Annotations.Idempotent(),
+			OpenWorld:   t.Annotations.OpenWorld(),
+		}
+		if err := a.CW.RegisterTool(ctx, tools.ToolDefinition{
 			Name:        t.Name,
 			Description: t.Description,
 			InputSchema: t.InputSchema,
+			Annotations: annotations,
 		}); err != nil {
 			return fmt.Errorf("register MCP tool %s: %w", t.Name, err)
 		}
+		tools.RegisterFormatter(t.Name, tools.MCPFormatter(t.Name))
+		tools.RegisterAnnotations(t.Name, annotations)
 	}
 
 	return nil
@@ -66,9 +79,12 @@ func (a *Agent) StartMCP(ctx context.Context, configs []mcp.ServerConfig) error
 
 // Run handles a single user message, invokes the model (which handles the
 // tool-use loop internally via ContextWindow as ToolExecutor), persists all
-// returned records, and returns the final text response.
-func (a *Agent) Run(ctx context.Context, userInput string) (string, error) {
-	if err := a.CW.AddPrompt(userInput); err != nil {
+// returned records, and returns the final text response normalized for display.
+func (a *Agent) Run(ctx context.Context, userInput string) (answer string, err error) {
+	ctx, span := tracing.Start(ctx, "agent.turn")
+	defer func() { tracing.End(span, err) }()
+
+	if err := a.CW.AddPrompt(ctx, userInput); err != nil {
 		return "", fmt.Errorf("add prompt: %w", err)
 	}
 
@@ -77,5 +93,40 @@ func (a *Agent) Run(ctx context.Context, userInput string) (string, error) {
 		return "", fmt.Errorf("model call: %w", err)
 	}
 
-	return response, nil
+	return Normalize(response), nil
+}
+
+// RunStructured is like Run but constrains the final answer to a JSON schema,
+// for callers that consume the answer programmatically. The answer is returned
+// as is, since normalizing whitespace could alter string values.
+func (a *Agent) RunStructured(ctx context.Context, userInput string, schema map[string]any) (answer json.RawMessage, err error) {
+	ctx, span := tracing.Start(ctx, "agent.turn", attribute.Bool("tinker.structured", true))
+	defer func() { tracing.End(span, err) }()
+
+	if err := a.CW.SetOutputSchema(schema); err != nil {
+		return nil, err
+	}
+	defer a.CW.SetOutputSchema(nil)
+
+	if err := a.CW.AddPrompt(ctx, userInput); err != nil {
+		return nil, fmt.Errorf("add prompt: %w", err)
+	}
+
+	response, err := a.CW.CallModel(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("model call: %w", err)
+	}
+
+	answer = json.RawMessage(strings.TrimSpace(response))
+	if !json.Valid(answer) {
+		return nil, fmt.Errorf("model answer is not valid JSON: %q", truncate(response, 80))
+	}
+	return answer, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
 }
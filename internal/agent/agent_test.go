@@ -24,13 +24,23 @@ func (m *mockModel) Call(ctx context.Context, inputs []storage.Record) ([]storag
 	return m.callFn(ctx, inputs)
 }
 
-func newTestAgent(t *testing.T, mm *mockModel) *Agent {
+// structuredModel is a mockModel that supports structured output
+type structuredModel struct {
+	mockModel
+	schema map[string]any
+}
+
+func (m *structuredModel) SetOutputSchema(schema map[string]any) {
+	m.schema = schema
+}
+
+func newTestAgent(t *testing.T, mm model.Model) *Agent {
 	t.Helper()
 
 	db, err := storage.NewSession(":memory:", "")
 	require.NoError(t, err)
 
-	cw, err := model.NewContextWindow(db, mm, "test")
+	cw, err := model.NewContextWindow(t.Context(), db, mm, "test")
 	require.NoError(t, err)
 
 	testRunner := &mockToolRunner{output: "test result"}
@@ -39,7 +49,7 @@ func newTestAgent(t *testing.T, mm *mockModel) *Agent {
 		Description: "A test tool",
 		Function:    testRunner.Run,
 	}
-	require.NoError(t, cw.RegisterTool(testDef))
+	require.NoError(t, cw.RegisterTool(t.Context(), testDef))
 
 	return New(&Config{
 		ContextWindow: cw,
@@ -74,7 +84,7 @@ func TestNew(t *testing.T) {
 					return nil, 0, nil
 				},
 			}
-			cw, err := model.NewContextWindow(db, mm, "default")
+			cw, err := model.NewContextWindow(t.Context(), db, mm, "default")
 			require.NoError(t, err)
 
 			mcpConfigs := make([]mcp.ServerConfig, tt.mcpCount)
@@ -142,3 +152,43 @@ func TestAgent_Run_ModelError(t *testing.T) {
 	assert.Empty(t, result)
 	assert.Contains(t, err.Error(), "model call")
 }
+
+func TestAgent_RunStructured(t *testing.T) {
+	schema := map[string]any{"type": "object"}
+	sm := &structuredModel{}
+	sm.callFn = func(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+		assert.Equal(t, schema, sm.schema)
+		return []storage.Record{
+			{Source: storage.ModelResp, Content: `{"fixed": true}`},
+		}, 100, nil
+	}
+	a := newTestAgent(t, sm)
+
+	answer, err := a.RunStructured(context.Background(), "Fix it", schema)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"fixed": true}`, string(answer))
+	assert.Nil(t, sm.schema, "schema should be lifted after the run")
+}
+
+func TestAgent_RunStructured_InvalidJSON(t *testing.T) {
+	sm := &structuredModel{}
+	sm.callFn = func(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+		return []storage.Record{
+			{Source: storage.ModelResp, Content: "Sure! Here you go"},
+		}, 100, nil
+	}
+	a := newTestAgent(t, sm)
+
+	_, err := a.RunStructured(context.Background(), "Fix it", map[string]any{"type": "object"})
+
+	assert.ErrorContains(t, err, "not valid JSON")
+}
+
+func TestAgent_RunStructured_Unsupported(t *testing.T) {
+	a := newTestAgent(t, &mockModel{})
+
+	_, err := a.RunStructured(context.Background(), "Fix it", map[string]any{"type": "object"})
+
+	assert.ErrorContains(t, err, "structured output")
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"strings"
+)
+
+// Normalize cleans up model output the same way regardless of provider:
+// line endings become \n, trailing whitespace is stripped except for the two
+// spaces of a markdown hard line break, runs of blank lines collapse into a
+// single paragraph break, fenced code blocks sit on their own paragraphs, and
+// the result is trimmed.
+// Lines inside fenced code blocks are left untouched apart from line endings.
+func Normalize(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var out []string
+	inFence := false
+	// Whether the last emitted line is a paragraph break
+	blank := false
+	// Whether the last emitted line closed a fence
+	closed := false
+
+	paragraph := func() {
+		if len(out) > 0 && !blank {
+			out = append(out, "")
+			blank = true
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if inFence {
+			if isFence(line) {
+				inFence = false
+				closed = true
+				out = append(out, strings.TrimRight(line, " \t"))
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+
+		line = trimTrailing(line)
+
+		if isFence(line) {
+			paragraph()
+			inFence = true
+			closed = false
+			blank = false
+			out = append(out, line)
+			continue
+		}
+
+		if line == "" {
+			paragraph()
+			closed = false
+			continue
+		}
+
+		if closed {
+			paragraph()
+			closed = false
+		}
+		blank = false
+		out = append(out, line)
+	}
+
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// trimTrailing strips the trailing whitespace of line, keeping two spaces
+// when it ends in a hard line break.
+func trimTrailing(line string) string {
+	trimmed := strings.TrimRight(line, " \t")
+	if trimmed != "" && strings.HasSuffix(line, "  ") {
+		return trimmed + "  "
+	}
+	return trimmed
+}
+
+func isFence(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "```")
+}
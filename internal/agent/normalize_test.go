@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"plain text unchanged", "hello world", "hello world"},
+		{"windows line endings", "a\r\nb\rc", "a\nb\nc"},
+		{"trailing whitespace", "a \nb\t\n", "a\nb"},
+		{"keeps hard line breaks", "a  \nb    \nc \t  \nd", "a  \nb  \nc  \nd"},
+		{"whitespace-only line is a paragraph break", "a\n   \nb", "a\n\nb"},
+		{"collapses blank lines", "a\n\n\n\nb", "a\n\nb"},
+		{"trims surrounding blank lines", "\n\n  a\n\n", "a"},
+		{"fence gets its own paragraph", "see:\n```go\nx := 1\n```\ndone", "see:\n\n```go\nx := 1\n```\n\ndone"},
+		{"fence content untouched", "```\nline  \n\n\n\nmore\n```", "```\nline  \n\n\n\nmore\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Normalize(tt.in))
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/honganh1206/tinker/internal/logger"
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// Turn is one prompt answered in a stored conversation, as the runner and
+// the API server run them without a terminal.
+type Turn struct {
+	Model       model.Model
+	Style       model.ResponseStyle
+	SessionsDir string
+	// Session, created on its first turn
+	ThreadID string
+	Prompt   string
+	// Directory the tools are confined to, see tools.Confine. Empty runs the
+	// turn in the directory the conversation started in, with every tool.
+	Workspace string
+	Logger    *logger.Logger
+	// Receives the events of the turn as they happen, may be nil
+	OnEvent func(model.Event)
+	// Blob store keeping the tool outputs too large for the context, as
+	// Owner's. Empty sends outputs to the model whatever their size.
+	BlobsDir string
+	Owner    string
+}
+
+// RunTurn runs t and returns the final answer. It changes the working
+// directory of the process for the duration of the turn, so callers must not
+// run turns concurrently.
+func RunTurn(ctx context.Context, t Turn) (string, storage.TurnStats, error) {
+	log := t.Logger
+	if log == nil {
+		log = logger.NewDefaultLogger()
+	}
+
+	toolset := tools.Builtin
+	if t.Workspace != "" {
+		toolset = tools.Confine(toolset, t.Workspace)
+		// New conversations record the workspace as where they started
+		restore, err := chdir(t.Workspace, log)
+		if err != nil {
+			return "", storage.TurnStats{}, err
+		}
+		defer restore()
+	}
+
+	db, err := storage.OpenSession(t.SessionsDir, t.ThreadID)
+	if errors.Is(err, storage.ErrInvalidSessionID) {
+		return "", storage.TurnStats{}, err
+	}
+	if err != nil {
+		// Could there be any error that is not related to no session?
+		db, err = storage.NewSession(t.SessionsDir, t.ThreadID)
+		if err != nil {
+			return "", storage.TurnStats{}, fmt.Errorf("creating new session: %w", err)
+		}
+	}
+
+	cw, err := model.NewContextWindow(ctx, db, t.Model, t.ThreadID)
+	if err != nil {
+		if err = db.Close(); err != nil {
+			return "", storage.TurnStats{}, fmt.Errorf("closing db: %w", err)
+		}
+		return "", storage.TurnStats{}, err
+	}
+	defer cw.Close()
+	if t.OnEvent != nil {
+		defer cw.Subscribe(t.OnEvent)()
+	}
+	if err := cw.SetResponseStyle(ctx, t.Style); err != nil {
+		return "", storage.TurnStats{}, err
+	}
+	if t.BlobsDir != "" {
+		blobs, err := storage.OpenBlobs(t.BlobsDir)
+		if err != nil {
+			return "", storage.TurnStats{}, err
+		}
+		cw.SetArtifacts(blobs, t.Owner)
+	}
+
+	exists, err := cw.HasContext()
+	if err != nil {
+		return "", storage.TurnStats{}, err
+	}
+	if !exists {
+		for _, tool := range toolset {
+			// Insert tool context to the session and load the tools to the memory
+			if err := cw.RegisterTool(ctx, tool); err != nil {
+				return "", storage.TurnStats{}, err
+			}
+		}
+	} else {
+		for _, tool := range toolset {
+			cw.LoadTool(tool)
+			// Only load the tools into memory
+		}
+	}
+
+	if t.Workspace == "" {
+		restore, err := enterWorkDir(ctx, cw, t.ThreadID, log)
+		if err != nil {
+			return "", storage.TurnStats{}, err
+		}
+		defer restore()
+	}
+
+	a := New(&Config{
+		ContextWindow: cw,
+		Logger:        log,
+	})
+
+	response, err := a.Run(ctx, t.Prompt)
+	if err != nil {
+		return "", storage.TurnStats{}, err
+	}
+
+	stats := cw.LastTurnStats()
+	log.Info("turn completed", "thread", t.ThreadID, "stats", stats.String())
+
+	return response, stats, nil
+}
+
+// enterWorkDir moves to the directory the conversation started in for the
+// turn, so relative paths from earlier turns still resolve when the runner was
+// restarted elsewhere. The returned function moves back.
+func enterWorkDir(ctx context.Context, cw *model.ContextWindow, threadID string, log *logger.Logger) (func(), error) {
+	noop := func() {}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return noop, err
+	}
+	dir, err := cw.WorkDir(ctx)
+	if err != nil || dir == "" || dir == cwd {
+		return noop, err
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		log.Warn("conversation directory is gone, continuing in the current one",
+			"thread", threadID, "dir", dir, "error", err)
+		note := fmt.Sprintf("This conversation started in %s, which is no longer available. "+
+			"Tools now run in %s, so paths from earlier turns may not resolve.", dir, cwd)
+		if err := cw.AddSystemNote(ctx, note); err != nil {
+			return noop, err
+		}
+		return noop, cw.SetWorkDir(ctx, cwd)
+	}
+
+	log.Info("running turn in the conversation directory", "thread", threadID, "dir", dir)
+	return func() {
+		if err := os.Chdir(cwd); err != nil {
+			log.Error("failed to return to the runner directory", "dir", cwd, "error", err)
+		}
+	}, nil
+}
+
+// chdir moves to dir and returns the function moving back.
+func chdir(dir string, log *logger.Logger) (func(), error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return nil, fmt.Errorf("enter workspace: %w", err)
+	}
+	return func() {
+		if err := os.Chdir(cwd); err != nil {
+			log.Error("failed to leave the workspace", "dir", cwd, "error", err)
+		}
+	}, nil
+}
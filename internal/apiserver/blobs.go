@@ -0,0 +1,154 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// Uploads larger than this are refused with a 413
+const maxBlobSize = 32 << 20
+
+// Text attachments up to this size are put in the prompt, larger ones and
+// other files are only referenced
+const maxInlineAttachment = 256 << 10
+
+// openBlobs opens the blob store next to the config file.
+func (s *Server) openBlobs() (*storage.Blobs, error) {
+	return storage.OpenBlobs(filepath.Join(filepath.Dir(s.configPath), storage.BlobsDir))
+}
+
+// handleBlobs stores the request body as a blob of the user. Its media type
+// is the Content-Type of the request and its name the name query parameter.
+func (s *Server) handleBlobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBlobSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("blob exceeds %d bytes", maxBlobSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "read blob: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "blob is empty", http.StatusBadRequest)
+		return
+	}
+	mediaType := r.Header.Get("Content-Type")
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+
+	blobs, err := s.openBlobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer blobs.Close()
+
+	blob, err := blobs.Put(r.Context(), userName(r.Context()), data, mediaType, r.URL.Query().Get("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, blob)
+}
+
+// handleBlobByHash serves the content of a blob the user stored.
+func (s *Server) handleBlobByHash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hash := strings.TrimPrefix(r.URL.Path, "/api/blobs/")
+
+	blobs, err := s.openBlobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer blobs.Close()
+
+	blob, data, err := blobs.Get(r.Context(), userName(r.Context()), hash)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", blob.MediaType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Blobs are named after their content, which never changes
+	w.Header().Set("ETag", `"`+blob.Hash+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	if blob.Name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": blob.Name}))
+	}
+	if r.Header.Get("If-None-Match") == `"`+blob.Hash+`"` {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(data)
+}
+
+// withAttachments appends the blobs attached to a message to its prompt.
+// Small text files are put in full, other files are described so the model
+// knows of them.
+func (s *Server) withAttachments(r *http.Request, prompt string, hashes []string) (string, error) {
+	if len(hashes) == 0 {
+		return prompt, nil
+	}
+	blobs, err := s.openBlobs()
+	if err != nil {
+		return "", err
+	}
+	defer blobs.Close()
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	for _, hash := range hashes {
+		blob, data, err := blobs.Get(r.Context(), userName(r.Context()), hash)
+		if err != nil {
+			return "", err
+		}
+		name := blob.Name
+		if name == "" {
+			name = blob.Hash
+		}
+		if isText(blob.MediaType) && blob.Size <= maxInlineAttachment && utf8.Valid(data) {
+			fmt.Fprintf(&b, "\n\n<attachment name=%q media_type=%q>\n%s\n</attachment>", name, blob.MediaType, data)
+			continue
+		}
+		fmt.Fprintf(&b, "\n\n<attachment name=%q media_type=%q size=%d blob=%q />", name, blob.MediaType, blob.Size, blob.Hash)
+	}
+	return b.String(), nil
+}
+
+func isText(mediaType string) bool {
+	t, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	switch t {
+	case "application/json", "application/xml", "application/yaml", "application/x-yaml", "application/toml":
+		return true
+	}
+	return strings.HasPrefix(t, "text/")
+}
@@ -0,0 +1,99 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// Files larger than this are not previewed
+const maxPreviewSize = 1 << 20
+
+// handleSessionFile serves a text file of the directory session id works in,
+// for the web UI to preview what the agent reads and edits. Paths outside
+// the directory or ignored by its .tinkerignore are refused.
+func (s *Server) handleSessionFile(w http.ResponseWriter, r *http.Request, dir, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+
+	session, err := storage.GetSession(r.Context(), dir, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var root string
+	for _, c := range session.Contexts {
+		if c.WorkDir != "" {
+			root = c.WorkDir
+			break
+		}
+	}
+	if root == "" {
+		http.Error(w, "session has no working directory", http.StatusNotFound)
+		return
+	}
+
+	path, err = tools.ConfinedPath(root, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	ignore, err := tools.LoadIgnore(root)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ignore.Ignored(path, false) {
+		http.Error(w, fmt.Sprintf("%s is ignored by %s", path, tools.IgnoreFile), http.StatusForbidden)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if info.IsDir() {
+		http.Error(w, path+" is a directory", http.StatusBadRequest)
+		return
+	}
+	if info.Size() > maxPreviewSize {
+		http.Error(w, fmt.Sprintf("file exceeds %d bytes", maxPreviewSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !utf8.Valid(data) {
+		http.Error(w, path+" is not a text file", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// The agent may change the file any time
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
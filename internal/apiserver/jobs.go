@@ -0,0 +1,344 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Jobs waiting for a worker before new ones are refused
+	jobQueueSize = 100
+	// Finished jobs kept for polling, older ones are forgotten first
+	maxFinishedJobs = 1000
+	// Tail of a job's stderr kept as its logs
+	maxJobLogs = 64 << 10
+	jobTimeout = time.Hour
+)
+
+type jobStatus string
+
+const (
+	jobQueued    jobStatus = "queued"
+	jobRunning   jobStatus = "running"
+	jobSucceeded jobStatus = "succeeded"
+	jobFailed    jobStatus = "failed"
+)
+
+type jobRequest struct {
+	Prompt string `json:"prompt"`
+	// Repository the agent works in, relative to the jobs root
+	Repo string `json:"repo"`
+}
+
+// job is one headless agent run queued over the API
+type job struct {
+	ID         string     `json:"id"`
+	Status     jobStatus  `json:"status"`
+	Prompt     string     `json:"prompt"`
+	Repo       string     `json:"repo"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Session the run was recorded in
+	SessionID string `json:"session_id,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Tail of the run's stderr
+	Logs string `json:"logs,omitempty"`
+	// Schedule the job was queued for, if any
+	Schedule string `json:"schedule,omitempty"`
+
+	user string
+}
+
+// jobQueue runs jobs in tinker processes, so jobs in different repositories
+// run at the same time.
+type jobQueue struct {
+	tinker string
+	root   string
+	queue  chan *job
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// IDs in the order the jobs were created
+	order []string
+}
+
+// EnableJobs lets clients queue headless runs of the tinker executable in the
+// repositories under root, running workers of them at once.
+func (s *Server) EnableJobs(tinker, root string, workers int) {
+	q := &jobQueue{
+		tinker: tinker,
+		root:   root,
+		queue:  make(chan *job, jobQueueSize),
+		jobs:   make(map[string]*job),
+	}
+	s.jobs = q
+	for range max(workers, 1) {
+		go s.runJobs(q)
+	}
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		http.Error(w, "jobs are disabled, start the server with -jobs-root", http.StatusServiceUnavailable)
+		return
+	}
+	user := userName(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.jobs.list(user))
+
+	case http.MethodPost:
+		var req jobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Prompt) == "" {
+			http.Error(w, "prompt is required", http.StatusBadRequest)
+			return
+		}
+		repo, err := s.jobs.resolveRepo(req.Repo)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		j := &job{
+			ID:        uuid.NewString(),
+			Status:    jobQueued,
+			Prompt:    req.Prompt,
+			Repo:      repo,
+			CreatedAt: time.Now().UTC(),
+			user:      user,
+		}
+		if !s.jobs.add(j) {
+			http.Error(w, "job queue is full", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Location", "/api/jobs/"+j.ID)
+		w.WriteHeader(http.StatusAccepted)
+		writeJSON(w, s.jobs.snapshot(j))
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleJobByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.jobs == nil {
+		http.Error(w, "jobs are disabled, start the server with -jobs-root", http.StatusServiceUnavailable)
+		return
+	}
+
+	j, ok := s.jobs.get(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), userName(r.Context()))
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, j)
+}
+
+// resolveRepo returns the absolute path of repo, refusing directories
+// outside the jobs root.
+func (q *jobQueue) resolveRepo(repo string) (string, error) {
+	root, err := filepath.EvalSymlinks(q.root)
+	if err != nil {
+		return "", fmt.Errorf("resolve jobs root: %w", err)
+	}
+	path := repo
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("repo %s: %w", repo, errors.Unwrap(err))
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("repo %s is not a directory", repo)
+	}
+	if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("repo %s is outside the jobs root %s", repo, root)
+	}
+	return path, nil
+}
+
+// add queues j, reporting false when the queue is full.
+func (q *jobQueue) add(j *job) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.queue <- j:
+	default:
+		return false
+	}
+	q.jobs[j.ID] = j
+	q.order = append(q.order, j.ID)
+	q.forgetFinished()
+	return true
+}
+
+// forgetFinished drops the oldest finished jobs beyond maxFinishedJobs.
+func (q *jobQueue) forgetFinished() {
+	finished := 0
+	for _, id := range q.order {
+		if s := q.jobs[id].Status; s == jobSucceeded || s == jobFailed {
+			finished++
+		}
+	}
+	kept := q.order[:0]
+	for _, id := range q.order {
+		if s := q.jobs[id].Status; finished > maxFinishedJobs && (s == jobSucceeded || s == jobFailed) {
+			delete(q.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	q.order = kept
+}
+
+// get returns a copy of the job id if it belongs to user.
+func (q *jobQueue) get(id, user string) (job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok || j.user != user {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// list returns copies of the jobs of user, newest first.
+func (q *jobQueue) list(user string) []job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := []job{}
+	for i := len(q.order) - 1; i >= 0; i-- {
+		if j := q.jobs[q.order[i]]; j.user == user {
+			jobs = append(jobs, *j)
+		}
+	}
+	return jobs
+}
+
+func (q *jobQueue) snapshot(j *job) job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *j
+}
+
+// update changes j under the queue lock, so pollers never see it half done.
+func (q *jobQueue) update(j *job, change func(*job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	change(j)
+}
+
+func (s *Server) runJobs(q *jobQueue) {
+	for j := range q.queue {
+		s.runJob(q, j)
+	}
+}
+
+// runJob runs j to completion as a headless tinker run in its repository.
+func (s *Server) runJob(q *jobQueue, j *job) {
+	q.update(j, func(j *job) {
+		now := time.Now().UTC()
+		j.Status, j.StartedAt = jobRunning, &now
+	})
+
+	result, sessionID, err := s.execJob(q, j)
+	q.update(j, func(j *job) {
+		now := time.Now().UTC()
+		j.FinishedAt = &now
+		j.SessionID = sessionID
+		if err != nil {
+			j.Status, j.Error = jobFailed, err.Error()
+			return
+		}
+		j.Status, j.Result = jobSucceeded, result
+	})
+	s.notifyJob(q.snapshot(j))
+	if j.Schedule != "" {
+		done := q.snapshot(j)
+		s.recordScheduleRun(done.Schedule, *done.StartedAt, done.SessionID, done.Error)
+	}
+	switch {
+	case err != nil:
+		s.log.Warn("job failed", "job", j.ID, "repo", j.Repo, "error", err)
+	default:
+		s.log.Info("job finished", "job", j.ID, "repo", j.Repo, "session", sessionID)
+	}
+}
+
+func (s *Server) execJob(q *jobQueue, j *job) (result, sessionID string, err error) {
+	dir, err := s.userSessionsDir(j.user)
+	if err != nil {
+		return "", "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	// The server's operator chose the jobs root, so its folders are trusted
+	c := exec.CommandContext(ctx, q.tinker, "--trust", "--sessions-dir", dir, "--", j.Prompt)
+	c.Dir = j.Repo
+	c.Stdout = &stdout
+	c.Stderr = &jobLogs{q: q, j: j}
+
+	if err := c.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", "", fmt.Errorf("job timed out after %s", jobTimeout)
+		}
+		return "", "", fmt.Errorf("tinker run: %w", err)
+	}
+
+	var run struct {
+		SessionID string          `json:"session_id"`
+		Response  string          `json:"response"`
+		Output    json.RawMessage `json:"output"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &run); err != nil {
+		return "", "", fmt.Errorf("parse run output: %w", err)
+	}
+	if run.Response == "" && len(run.Output) > 0 {
+		run.Response = string(run.Output)
+	}
+	return run.Response, run.SessionID, nil
+}
+
+// jobLogs keeps the tail of a job's stderr in its logs while it runs.
+type jobLogs struct {
+	q *jobQueue
+	j *job
+}
+
+func (l *jobLogs) Write(p []byte) (int, error) {
+	l.q.update(l.j, func(j *job) {
+		j.Logs += string(p)
+		if len(j.Logs) > maxJobLogs {
+			j.Logs = j.Logs[len(j.Logs)-maxJobLogs:]
+		}
+	})
+	return len(p), nil
+}
@@ -0,0 +1,98 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// Keymap binds web UI actions to keys. Keys use KeyboardEvent.key names with
+// optional modifiers, e.g. "j", "ArrowDown", "ctrl+d", "shift+Tab".
+type Keymap map[string][]string
+
+// DefaultKeymap lists every action the web UI handles
+var DefaultKeymap = Keymap{
+	"next_session":   {"j"},
+	"prev_session":   {"k"},
+	"focus_search":   {"/"},
+	"focus_next":     {"Tab"},
+	"focus_prev":     {"shift+Tab"},
+	"toggle_sidebar": {"b"},
+	"new_tab":        {"t"},
+	"close_tab":      {"x"},
+	"next_tab":       {"]"},
+	"prev_tab":       {"["},
+	"scroll_down":    {"ctrl+d", "PageDown"},
+	"scroll_up":      {"ctrl+u", "PageUp"},
+	"toggle_debug":   {"d"},
+	"toggle_preview": {"p"},
+	"refresh":        {"r"},
+	"close":          {"Escape"},
+	"show_keys":      {"?"},
+}
+
+// uiConfig is the part of the config file read by the API server
+type uiConfig struct {
+	Keymap    Keymap            `json:"keymap"`
+	Retention storage.Retention `json:"retention"`
+}
+
+// loadKeymap returns DefaultKeymap with the bindings of the config file at
+// path applied on top. Missing files and actions keep their defaults.
+func loadKeymap(path string) (Keymap, error) {
+	keymap := make(Keymap, len(DefaultKeymap))
+	for action, keys := range DefaultKeymap {
+		keymap[action] = keys
+	}
+	if path == "" {
+		return keymap, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return keymap, nil
+	}
+	if err != nil {
+		return keymap, fmt.Errorf("read config: %w", err)
+	}
+
+	var cfg uiConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return keymap, fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	var unknown []string
+	for action, keys := range cfg.Keymap {
+		if _, ok := DefaultKeymap[action]; !ok {
+			unknown = append(unknown, action)
+			continue
+		}
+		keymap[action] = keys
+	}
+	if len(unknown) > 0 {
+		return keymap, fmt.Errorf("config %s: unknown keymap actions: %s", path, strings.Join(unknown, ", "))
+	}
+	return keymap, nil
+}
+
+// handleKeymap serves the effective keymap. A broken config still serves
+// the bindings that could be applied, so the UI stays usable.
+func (s *Server) handleKeymap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keymap, err := loadKeymap(s.configPath)
+	if err != nil {
+		s.log.Error("failed to load keymap", "error", err)
+	}
+
+	writeJSON(w, keymap)
+}
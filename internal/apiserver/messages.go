@@ -0,0 +1,141 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/agent"
+	"github.com/honganh1206/tinker/internal/channel"
+	"github.com/honganh1206/tinker/internal/eventbus"
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+type messageRequest struct {
+	Content string `json:"content"`
+	// Hashes of blobs uploaded to /api/blobs, attached to the message
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+type messageResponse struct {
+	// Final answer of the turn
+	Content string            `json:"content"`
+	Stats   storage.TurnStats `json:"stats"`
+}
+
+// blockedResponse is returned with 422 when the provider's safety filters
+// withheld the prompt or answer of a turn
+type blockedResponse struct {
+	Error   string              `json:"error"`
+	Blocked *model.BlockedError `json:"blocked"`
+}
+
+// SetAgent lets clients run agent turns on the server with llm. The tools
+// are confined to workspace, and bash is not offered.
+func (s *Server) SetAgent(llm model.Model, style model.ResponseStyle, workspace string) {
+	s.agentModel = llm
+	s.agentStyle = style
+	s.workspace = workspace
+}
+
+// handleMessages runs one agent turn in session id, creating the session on
+// its first message, and returns the answer. Tool input and file edits are
+// streamed to the user's WebSocket clients while the turn runs.
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request, dir, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.agentModel == nil {
+		http.Error(w, "agent turns are disabled, start the server with -workspace", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req messageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		http.Error(w, "content is required", http.StatusBadRequest)
+		return
+	}
+	prompt, err := s.withAttachments(r, req.Content, req.Attachments)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			http.Error(w, "attachment: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Turns change the working directory of the process, one runs at a time
+	select {
+	case s.turns <- struct{}{}:
+		defer func() { <-s.turns }()
+	case <-r.Context().Done():
+		return
+	}
+
+	user := userName(r.Context())
+	onEvent := func(ev model.Event) {
+		var streamEvent *eventbus.Event
+		var err error
+		meta := map[string]string{"user": user}
+		switch ev.Kind {
+		case model.EventToolInput:
+			d := ev.ToolInput
+			streamEvent, err = eventbus.NewEvent(eventbus.TopicAgentStreamChunk, meta, channel.AgentToolInput{
+				ThreadID:     id,
+				ToolUseID:    d.ToolUseID,
+				Tool:         d.Name,
+				PartialInput: d.PartialInput,
+				Done:         d.Done,
+			})
+		case model.EventFileEdit:
+			streamEvent, err = eventbus.NewEvent(eventbus.TopicAgentFileEdit, meta, channel.AgentFileEdit{
+				ThreadID: id,
+				Tool:     ev.Tool,
+				FileEdit: ev.Edit,
+			})
+		default:
+			return
+		}
+		if err != nil {
+			return
+		}
+		if data, err := json.Marshal(streamEvent); err == nil {
+			s.broadcast(user, data)
+		}
+	}
+
+	answer, stats, err := agent.RunTurn(r.Context(), agent.Turn{
+		Model:       s.agentModel,
+		Style:       s.agentStyle,
+		SessionsDir: dir,
+		ThreadID:    id,
+		Prompt:      prompt,
+		Workspace:   s.workspace,
+		Logger:      s.log,
+		OnEvent:     onEvent,
+		BlobsDir:    filepath.Join(filepath.Dir(s.configPath), storage.BlobsDir),
+		Owner:       user,
+	})
+	if err != nil {
+		s.notifyTurnFailed(user, id, err)
+		var blocked *model.BlockedError
+		if errors.As(err, &blocked) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(blockedResponse{Error: err.Error(), Blocked: blocked})
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, messageResponse{Content: answer, Stats: stats})
+}
@@ -0,0 +1,223 @@
+package apiserver
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/honganh1206/tinker/internal/mcp"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/invopop/jsonschema"
+)
+
+// endpoint documents one route of the API. The OpenAPI document is built
+// from this table and the Go types the handlers encode, so both change together.
+type endpoint struct {
+	method  string
+	path    string
+	summary string
+	// Value of the type of the JSON request body, nil when there is none
+	request any
+	// Value of the type the handler writes as JSON, nil for other bodies
+	response any
+	// Status of a successful response, 200 by default
+	status int
+	// Statuses of failures other than 405 and 500
+	errors []int
+}
+
+var endpoints = []endpoint{
+	{method: http.MethodGet, path: "/healthz", summary: "Report that the server is up"},
+	{method: http.MethodGet, path: "/api/sessions", summary: "List sessions", response: []storage.Session{}},
+	{method: http.MethodGet, path: "/api/sessions/{id}", summary: "Get a session with its contexts and live records",
+		response: storage.Session{}, errors: []int{http.StatusNotFound}},
+	{method: http.MethodDelete, path: "/api/sessions/{id}", summary: "Delete a session",
+		status: http.StatusNoContent, errors: []int{http.StatusNotFound}},
+	{method: http.MethodPost, path: "/api/sessions/{id}/messages", summary: "Run one agent turn on a message, creating the session on its first, and return the answer",
+		request: messageRequest{}, response: messageResponse{},
+		errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusServiceUnavailable}},
+	{method: http.MethodPost, path: "/api/sessions/{id}/share", summary: "Sign a read-only link to a session, valid for expires_in_hours (7 days by default)",
+		request: shareRequest{}, response: shareResponse{}, status: http.StatusCreated, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: http.MethodGet, path: "/api/sessions/{id}/file", summary: "Read a text file, named by the path query parameter, of the directory a session works in",
+		errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType}},
+	{method: http.MethodGet, path: "/shared/{token}", summary: "Read a shared session as a static HTML page",
+		errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{method: http.MethodGet, path: "/api/projects", summary: "List projects", response: []storage.Project{}},
+	{method: http.MethodPost, path: "/api/projects", summary: "Create a project", request: projectRequest{},
+		response: storage.Project{}, status: http.StatusCreated, errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{method: http.MethodGet, path: "/api/projects/{project}", summary: "Get a project by ID or name",
+		response: storage.Project{}, errors: []int{http.StatusNotFound}},
+	{method: http.MethodPut, path: "/api/projects/{project}", summary: "Change a project's name, path or settings",
+		request: projectRequest{}, response: storage.Project{}, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: http.MethodDelete, path: "/api/projects/{project}", summary: "Delete a project, keeping its sessions",
+		status: http.StatusNoContent, errors: []int{http.StatusNotFound}},
+	{method: http.MethodPost, path: "/api/blobs", summary: "Store the request body as a blob named by the name query parameter, to attach to messages",
+		response: storage.Blob{}, status: http.StatusCreated, errors: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge}},
+	{method: http.MethodGet, path: "/api/blobs/{blob}", summary: "Download a blob stored by the user",
+		errors: []int{http.StatusNotFound}},
+	{method: http.MethodGet, path: "/api/jobs", summary: "List jobs, newest first", response: []job{},
+		errors: []int{http.StatusServiceUnavailable}},
+	{method: http.MethodPost, path: "/api/jobs", summary: "Queue a headless agent run on a prompt in a repository under the jobs root",
+		request: jobRequest{}, response: job{}, status: http.StatusAccepted,
+		errors: []int{http.StatusBadRequest, http.StatusServiceUnavailable}},
+	{method: http.MethodGet, path: "/api/jobs/{job}", summary: "Get a job's status, result and logs",
+		response: job{}, errors: []int{http.StatusNotFound, http.StatusServiceUnavailable}},
+	{method: http.MethodGet, path: "/api/webhooks", summary: "List webhooks, without their secrets", response: []webhook{}},
+	{method: http.MethodPost, path: "/api/webhooks", summary: "Register a URL receiving job.finished and turn.failed events, signed with the returned secret",
+		request: webhookRequest{}, response: webhook{}, status: http.StatusCreated, errors: []int{http.StatusBadRequest}},
+	{method: http.MethodDelete, path: "/api/webhooks/{webhook}", summary: "Remove a webhook",
+		status: http.StatusNoContent, errors: []int{http.StatusNotFound}},
+	{method: http.MethodGet, path: "/api/mcp/configs", summary: "List MCP server configurations", response: []mcp.ServerConfig{}},
+	{method: http.MethodGet, path: "/api/stats", summary: "Aggregate latency, throughput and usage across sessions", response: statsResponse{}},
+	{method: http.MethodGet, path: "/api/keymap", summary: "Get the effective web UI key bindings", response: Keymap{}},
+	{method: http.MethodGet, path: "/ws/stream", summary: "Stream agent output as WebSocket text messages, one JSON event each",
+		status: http.StatusSwitchingProtocols},
+	{method: http.MethodGet, path: "/openapi.json", summary: "Get this document"},
+	{method: http.MethodGet, path: "/docs", summary: "Browse this document with Swagger UI"},
+}
+
+// pathParams describes the parameters endpoint paths use
+var pathParams = map[string]string{
+	"id":      "Session ID",
+	"token":   "Share token",
+	"project": "Project ID or name",
+	"job":     "Job ID",
+	"blob":    "SHA-256 of the blob content",
+	"webhook": "Webhook ID",
+}
+
+// openAPIDocument is built once, the endpoints never change at runtime.
+var openAPIDocument = sync.OnceValue(buildOpenAPI)
+
+func buildOpenAPI() map[string]any {
+	reflector := jsonschema.Reflector{DoNotReference: true}
+	schemas := map[string]any{}
+
+	// schemaOf registers the type of v as a component and references it
+	schemaOf := func(v any) any {
+		t := reflect.TypeOf(v)
+		if t.Kind() == reflect.Slice {
+			elem := reflect.New(t.Elem()).Elem().Interface()
+			return map[string]any{"type": "array", "items": schemaRef(reflector, schemas, elem)}
+		}
+		return schemaRef(reflector, schemas, v)
+	}
+
+	paths := map[string]map[string]any{}
+	for _, e := range endpoints {
+		status := e.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		ok := map[string]any{"description": http.StatusText(status)}
+		if e.response != nil {
+			ok["content"] = map[string]any{"application/json": map[string]any{"schema": schemaOf(e.response)}}
+		}
+		responses := map[string]any{strconv.Itoa(status): ok}
+		codes := append(e.errors, http.StatusMethodNotAllowed, http.StatusInternalServerError)
+		if limited(e.path) {
+			codes = append(codes, http.StatusTooManyRequests)
+		}
+		for _, code := range codes {
+			responses[strconv.Itoa(code)] = map[string]any{"description": http.StatusText(code)}
+		}
+
+		op := map[string]any{
+			"summary":     e.summary,
+			"operationId": operationID(e),
+			"responses":   responses,
+		}
+		if e.request != nil {
+			op["requestBody"] = map[string]any{
+				"content": map[string]any{"application/json": map[string]any{"schema": schemaOf(e.request)}},
+			}
+		}
+		for name, desc := range pathParams {
+			if strings.Contains(e.path, "{"+name+"}") {
+				op["parameters"] = []any{map[string]any{
+					"name": name, "in": "path", "required": true,
+					"description": desc, "schema": map[string]any{"type": "string"},
+				}}
+			}
+		}
+
+		if paths[e.path] == nil {
+			paths[e.path] = map[string]any{}
+		}
+		paths[e.path][strings.ToLower(e.method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Tinker API",
+			"description": "Read and manage tinker sessions, and stream agent output.",
+			"version":     "1",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+// schemaRef reflects the named type of v into schemas and returns a reference to it.
+func schemaRef(reflector jsonschema.Reflector, schemas map[string]any, v any) any {
+	// Unexported response types are still public in the document
+	name := reflect.TypeOf(v).Name()
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if _, ok := schemas[name]; !ok {
+		s := reflector.Reflect(v)
+		// The document declares the dialect, components do not
+		s.Version, s.ID = "", ""
+		schemas[name] = s
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// operationID names an operation after its method and path, e.g.
+// "deleteApiSessionsId".
+func operationID(e endpoint) string {
+	id := strings.ToLower(e.method)
+	for _, part := range strings.FieldsFunc(e.path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '-'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, openAPIDocument())
+}
+
+// docsPage renders the document with Swagger UI, loaded from a CDN so the
+// binary does not have to embed it
+const docsPage = `<!doctype html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Tinker API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });</script>
+</body>
+</html>
+`
+
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
@@ -0,0 +1,142 @@
+package apiserver
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// projectRequest is the body of project creations and updates. Fields left
+// out of an update keep their value.
+type projectRequest struct {
+	Name     string                   `json:"name,omitempty"`
+	Path     string                   `json:"path,omitempty"`
+	Settings *storage.ProjectSettings `json:"settings,omitempty"`
+}
+
+// openProjects opens the projects database next to the config file.
+func (s *Server) openProjects() (*sql.DB, error) {
+	return storage.OpenProjects(filepath.Join(filepath.Dir(s.configPath), storage.ProjectsFile))
+}
+
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
+	db, err := s.openProjects()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	switch r.Method {
+	case http.MethodGet:
+		projects, err := storage.ListProjects(r.Context(), db)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if projects == nil {
+			projects = []storage.Project{}
+		}
+		writeJSON(w, projects)
+
+	case http.MethodPost:
+		var req projectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" || !filepath.IsAbs(req.Path) {
+			http.Error(w, "name and an absolute path are required", http.StatusBadRequest)
+			return
+		}
+		var settings storage.ProjectSettings
+		if req.Settings != nil {
+			settings = *req.Settings
+		}
+		p, err := storage.CreateProject(r.Context(), db, req.Name, req.Path, settings)
+		if err != nil {
+			if strings.Contains(err.Error(), "already exists") {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, p)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleProjectByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/projects/")
+	if id == "" {
+		http.Error(w, "project id required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := s.openProjects()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	// Every method works on an existing project
+	p, err := storage.GetProject(r.Context(), db, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrProjectNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, p)
+
+	case http.MethodPut:
+		var req projectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Path != "" && !filepath.IsAbs(req.Path) {
+			http.Error(w, "path must be absolute", http.StatusBadRequest)
+			return
+		}
+		if req.Name != "" {
+			p.Name = req.Name
+		}
+		if req.Path != "" {
+			p.Path = filepath.Clean(req.Path)
+		}
+		if req.Settings != nil {
+			p.Settings = *req.Settings
+		}
+		if err := storage.UpdateProject(r.Context(), db, p); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, p)
+
+	case http.MethodDelete:
+		if err := storage.DeleteProject(r.Context(), db, p.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
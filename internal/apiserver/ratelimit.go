@@ -0,0 +1,145 @@
+package apiserver
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits caps what clients can ask of the server. Zero values lift a limit.
+type Limits struct {
+	// Sustained requests per minute of each client, who are told of the
+	// next allowed request with a 429 past it
+	RequestsPerMinute int
+	// Requests a client can make at once above the sustained rate
+	Burst int
+	// Requests served at once across clients, the others are refused with a 429
+	MaxConcurrent int
+}
+
+// Buckets of clients idle for long enough to be full again are dropped once
+// this many are tracked
+const maxTrackedClients = 10_000
+
+// SetLimits limits the requests of clients to the API. Clients are told
+// apart by user once users are set up, by IP address otherwise.
+func (s *Server) SetLimits(l Limits) {
+	if l.RequestsPerMinute > 0 {
+		burst := l.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		s.limiter = newRateLimiter(float64(l.RequestsPerMinute)/60, float64(burst))
+	}
+	if l.MaxConcurrent > 0 {
+		s.inFlight = make(chan struct{}, l.MaxConcurrent)
+	}
+}
+
+// limited reports whether path counts against the limits. The web UI assets
+// are served freely, and the WebSocket stream would hold a slot for its
+// whole lifetime.
+func limited(path string) bool {
+	return strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/shared/")
+}
+
+// limit refuses requests of clients over their rate, and requests arriving
+// while the server already serves as many as it allows.
+func (s *Server) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limited(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.limiter != nil {
+			if ok, wait := s.limiter.allow(clientKey(r), time.Now()); !ok {
+				tooManyRequests(w, wait, "rate limit exceeded")
+				return
+			}
+		}
+		if s.inFlight != nil {
+			select {
+			case s.inFlight <- struct{}{}:
+				defer func() { <-s.inFlight }()
+			default:
+				tooManyRequests(w, time.Second, "server busy")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+	// Retry-After is in whole seconds, rounded up so the retry is allowed
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+// clientKey tells the clients of requests apart for rate limiting.
+func clientKey(r *http.Request) string {
+	if user := userName(r.Context()); user != "" {
+		return "user:" + user
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// rateLimiter keeps a token bucket per client, filled at rate tokens per
+// second up to burst.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, buckets: make(map[string]*bucket)}
+}
+
+// allow takes a token of key's bucket, or returns how long until one is there.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxTrackedClients {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops the buckets that have filled up again, which behave like new ones.
+func (l *rateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
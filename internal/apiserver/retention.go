@@ -0,0 +1,64 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/users"
+)
+
+// loadRetention returns the retention section of the config file at path,
+// keeping every session when there is none.
+func loadRetention(path string) (storage.Retention, error) {
+	if path == "" {
+		return storage.Retention{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return storage.Retention{}, nil
+	}
+	if err != nil {
+		return storage.Retention{}, fmt.Errorf("read config: %w", err)
+	}
+
+	var cfg uiConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return storage.Retention{}, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg.Retention, nil
+}
+
+// enforceRetention prunes the sessions the configured retention does not
+// keep. Failures are logged, they never keep the server from starting.
+func (s *Server) enforceRetention(now time.Time) {
+	r, err := loadRetention(s.configPath)
+	if err != nil {
+		s.log.Error("failed to load retention policy", "error", err)
+		return
+	}
+	if r.IsZero() {
+		return
+	}
+
+	dirs := []string{s.sessionsDir}
+	for _, u := range s.users {
+		dirs = append(dirs, users.SessionsDir(s.sessionsDir, u.Name))
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		pruned, err := storage.PruneSessions(dir, r, now, false)
+		if err != nil {
+			s.log.Error("failed to prune sessions", "error", err, "dir", dir)
+		}
+		if len(pruned) > 0 {
+			s.log.Info("Pruned sessions", "count", len(pruned), "archived", r.Archive, "dir", dir)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package apiserver
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/cron"
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// EnableSchedules queues the schedules in db as jobs at their times, which
+// `tinker schedule add` creates. Jobs must be enabled first. Times the
+// server was down for are not caught up on.
+func (s *Server) EnableSchedules(db *sql.DB) {
+	s.schedules = db
+	go func() {
+		last := time.Now()
+		for now := range time.Tick(time.Minute) {
+			s.queueDueSchedules(context.Background(), last, now)
+			last = now
+		}
+	}()
+}
+
+// queueDueSchedules queues a job for each schedule due after from, up to
+// to. Schedules that cannot run record why as the error of their run.
+func (s *Server) queueDueSchedules(ctx context.Context, from, to time.Time) {
+	schedules, err := storage.ListSchedules(ctx, s.schedules)
+	if err != nil {
+		s.log.Error("failed to list schedules", "error", err)
+		return
+	}
+	for _, sc := range schedules {
+		spec, err := cron.Parse(sc.Cron)
+		if err != nil {
+			s.log.Warn("skipping schedule", "schedule", sc.ID, "error", err)
+			continue
+		}
+		next := spec.Next(from.Local())
+		if next.IsZero() || next.After(to) {
+			continue
+		}
+
+		repo, err := s.jobs.resolveRepo(sc.Repo)
+		if err != nil {
+			s.recordScheduleRun(sc.ID, next, "", err.Error())
+			continue
+		}
+		j := &job{
+			ID:        uuid.NewString(),
+			Status:    jobQueued,
+			Prompt:    sc.Prompt,
+			Repo:      repo,
+			CreatedAt: time.Now().UTC(),
+			Schedule:  sc.ID,
+		}
+		if !s.jobs.add(j) {
+			s.recordScheduleRun(sc.ID, next, "", "job queue is full")
+			continue
+		}
+		s.log.Info("Queued scheduled job", "schedule", sc.ID, "job", j.ID, "repo", repo)
+	}
+}
+
+func (s *Server) recordScheduleRun(id string, at time.Time, sessionID, runErr string) {
+	if err := storage.RecordScheduleRun(context.Background(), s.schedules, id, at, sessionID, runErr); err != nil {
+		s.log.Warn("failed to record schedule run", "schedule", id, "error", err)
+	}
+}
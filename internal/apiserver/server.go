@@ -2,6 +2,7 @@ package apiserver
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"io/fs"
 	"net/http"
@@ -13,7 +14,9 @@ import (
 	"github.com/honganh1206/tinker/internal/eventbus"
 	"github.com/honganh1206/tinker/internal/logger"
 	"github.com/honganh1206/tinker/internal/mcp"
+	"github.com/honganh1206/tinker/internal/model"
 	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/users"
 )
 
 // upgrader upgrades HTTP requests to WebSocket protocol via a handshake
@@ -27,25 +30,59 @@ type Server struct {
 	log         *logger.Logger
 	sessionsDir string
 	mcpStore    mcp.ConfigStore
+	configPath  string
 	mux         *http.ServeMux
+	// Origins whose browsers may call the API, "*" for any
+	corsOrigins []string
+	// People the server is shared by, none when it serves only its owner
+	users users.Users
+
+	// Agent turns clients may run, disabled while agentModel is nil
+	agentModel model.Model
+	agentStyle model.ResponseStyle
+	workspace  string
+	// Holds the turn running, see handleMessages
+	turns chan struct{}
+	// Headless runs queued with POST /api/jobs, nil while disabled
+	jobs *jobQueue
+	// Recurring jobs, see EnableSchedules, nil while disabled
+	schedules *sql.DB
+	// Serializes changes to the webhooks file
+	webhooksMu sync.Mutex
+	// Per-client request rate and requests served at once, nil while unlimited
+	limiter  *rateLimiter
+	inFlight chan struct{}
 
 	clientsMu sync.Mutex
-	clients   map[chan []byte]struct{}
+	// Each client with the user it streams for
+	clients map[chan []byte]string
 }
 
-// NewServer creates a new Tinker API server.
-func NewServer(bus eventbus.EventBus, log *logger.Logger, sessionsDir, mcpDir string) *Server {
+// NewServer creates a new Tinker API server, logging to stderr when log is
+// nil.
+func NewServer(bus eventbus.EventBus, log *logger.Logger, sessionsDir, mcpDir, configPath string) *Server {
+	if log == nil {
+		log = logger.NewDefaultLogger()
+	}
 	s := &Server{
 		eventbus:    bus,
 		log:         log,
 		sessionsDir: sessionsDir,
 		mcpStore:    mcp.NewFileConfigStore(mcpDir),
-		clients:     make(map[chan []byte]struct{}),
+		configPath:  configPath,
+		clients:     make(map[chan []byte]string),
+		turns:       make(chan struct{}, 1),
 	}
 	s.registerRoutes()
 	return s
 }
 
+// AllowOrigins lets browsers on other origins call the API. The embedded
+// web UI is served from the same origin and does not need it.
+func (s *Server) AllowOrigins(origins ...string) {
+	s.corsOrigins = origins
+}
+
 // Start starts the HTTP server with an embedded frontend SPA.
 func (s *Server) Start(addr string, frontendFS fs.FS) error {
 	if frontendFS != nil {
@@ -54,10 +91,16 @@ func (s *Server) Start(addr string, frontendFS fs.FS) error {
 
 	httpServer := &http.Server{
 		Addr:              addr,
-		Handler:           s.mux,
+		Handler:           s.handler(),
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
+	if s.eventbus != nil {
+		go s.forwardEvents(context.Background())
+		go s.watchFailedRuns(context.Background())
+	}
+	s.enforceRetention(time.Now())
+
 	s.log.Info("Starting API server", "addr", addr)
 	return httpServer.ListenAndServe()
 }
@@ -68,8 +111,21 @@ func (s *Server) registerRoutes() {
 	mux.HandleFunc("/healthz", s.handleHealth)
 	mux.HandleFunc("/api/sessions", s.handleSessions)
 	mux.HandleFunc("/api/sessions/", s.handleSessionByID)
+	mux.HandleFunc("/api/projects", s.handleProjects)
+	mux.HandleFunc("/api/projects/", s.handleProjectByID)
+	mux.HandleFunc("/api/blobs", s.handleBlobs)
+	mux.HandleFunc("/api/blobs/", s.handleBlobByHash)
+	mux.HandleFunc("/api/jobs", s.handleJobs)
+	mux.HandleFunc("/api/jobs/", s.handleJobByID)
+	mux.HandleFunc("/api/webhooks", s.handleWebhooks)
+	mux.HandleFunc("/api/webhooks/", s.handleWebhookByID)
 	mux.HandleFunc("/api/mcp/configs", s.handleMCPConfigs)
+	mux.HandleFunc("/api/stats", s.handleStats)
+	mux.HandleFunc("/api/keymap", s.handleKeymap)
 	mux.HandleFunc("/ws/stream", s.handleStream)
+	mux.HandleFunc("/shared/", s.handleShared)
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("/docs", s.handleDocs)
 	s.mux = mux
 }
 
@@ -88,7 +144,12 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessions, err := storage.ListSessions(s.sessionsDir)
+	dir, err := s.sessionsFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sessions, err := storage.ListSessions(r.Context(), dir)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -98,15 +159,40 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/sessions/"), "/")
 	if id == "" {
 		http.Error(w, "session id required", http.StatusBadRequest)
 		return
 	}
+	// An escaped slash in the path would otherwise reach another user's sessions
+	if !storage.ValidSessionID(id) {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+	dir, err := s.sessionsFor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch action {
+	case "":
+	case "share":
+		s.handleShare(w, r, dir, id)
+		return
+	case "messages":
+		s.handleMessages(w, r, dir, id)
+		return
+	case "file":
+		s.handleSessionFile(w, r, dir, id)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
-		session, err := storage.GetSession(s.sessionsDir, id)
+		session, err := storage.GetSession(r.Context(), dir, id)
 		if err != nil {
 			if strings.Contains(err.Error(), "not found") {
 				http.Error(w, err.Error(), http.StatusNotFound)
@@ -118,7 +204,7 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, session)
 
 	case http.MethodDelete:
-		if err := storage.DeleteSession(s.sessionsDir, id); err != nil {
+		if err := storage.DeleteSession(dir, id); err != nil {
 			if strings.Contains(err.Error(), "not found") {
 				http.Error(w, err.Error(), http.StatusNotFound)
 				return
@@ -148,53 +234,123 @@ func (s *Server) handleMCPConfigs(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, configs)
 }
 
-// handleStream upgrades the HTTP connection to a WebSocket and registers the
-// client for event broadcasts. Blocks until the client disconnects.
-func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// statsResponse bundles turn latency aggregates with per-day usage
+type statsResponse struct {
+	Summary storage.StatsSummary `json:"summary"`
+	Usage   []storage.Usage      `json:"usage"`
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dir, err := s.sessionsFor(r)
 	if err != nil {
-		if s.log != nil {
-			s.log.Error("websocket upgrade failed", "error", err)
-		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer conn.Close()
+	summary, err := storage.SummarizeSessions(r.Context(), dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := storage.UsageSessions(r.Context(), dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if usage == nil {
+		usage = []storage.Usage{}
+	}
 
-	ctx, cancel := context.WithCancel(r.Context())
-	defer cancel()
+	writeJSON(w, statsResponse{Summary: summary, Usage: usage})
+}
 
-	// TODO: To be done when we integrate /ws/stream endpoint to the web UI
-	events, err := s.eventbus.Subscribe(ctx, eventbus.TopicAgentStreamChunk)
+// handleStream upgrades the HTTP connection to a WebSocket and registers the
+// client for event broadcasts. Blocks until the client disconnects or falls
+// too far behind.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		s.log.Error("failed to subscribe to events", "error", err)
+		s.log.Error("websocket upgrade failed", "error", err)
 		return
 	}
+	defer conn.Close()
+
+	client := s.addClient(userName(r.Context()))
+	defer s.removeClient(client)
 
 	// Read loop (handle client messages / keep-alive)
+	gone := make(chan struct{})
 	go func() {
+		defer close(gone)
 		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				cancel()
+			if _, _, err := conn.ReadMessage(); err != nil {
+				s.removeClient(client)
 				return
 			}
 		}
 	}()
 
-	// Write loop (forward events to client)
-	// TODO: A centralized hub for multi-players, going for broadcast pattern?
-	for {
-		select {
-		case <-ctx.Done():
+	// Write loop (forward broadcasts to client)
+	for data := range client {
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
 			return
-		case event, ok := <-events:
-			if !ok {
-				return
-			}
-			data, _ := json.Marshal(event)
-			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-				return
-			}
+		}
+	}
+
+	// The channel is closed either because the client went away or because
+	// broadcast dropped it for being too slow; tell the latter to reconnect
+	select {
+	case <-gone:
+	default:
+		msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	}
+}
+
+// clientBuffer is how many events a WebSocket client may fall behind before
+// it is disconnected. Stream chunks only make sense in order, so a slow client
+// is closed and reconnects instead of silently missing some.
+const clientBuffer = 256
+
+// addClient registers a WebSocket client streaming for user.
+func (s *Server) addClient(user string) chan []byte {
+	ch := make(chan []byte, clientBuffer)
+	s.clientsMu.Lock()
+	s.clients[ch] = user
+	s.clientsMu.Unlock()
+	return ch
+}
+
+// removeClient unregisters a client and closes its channel. Safe to call
+// more than once.
+func (s *Server) removeClient(ch chan []byte) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	if _, ok := s.clients[ch]; ok {
+		delete(s.clients, ch)
+		close(ch)
+	}
+}
+
+// broadcast queues data for the clients of owner without blocking, dropping
+// clients whose buffer is full. Without users every client gets everything.
+func (s *Server) broadcast(owner string, data []byte) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	for ch, user := range s.clients {
+		if len(s.users) > 0 && user != owner {
+			continue
+		}
+		select {
+		case ch <- data:
+		default:
+			delete(s.clients, ch)
+			close(ch)
 		}
 	}
 }
@@ -207,6 +363,31 @@ func (s *Server) clientCount() int {
 	return len(s.clients)
 }
 
+// forwardEvents subscribes once to the agent's stream chunks and file edits
+// and broadcasts them to all connected clients, so clients share one bus
+// consumer per topic.
+func (s *Server) forwardEvents(ctx context.Context) {
+	for _, topic := range []string{eventbus.TopicAgentStreamChunk, eventbus.TopicAgentFileEdit} {
+		events, err := s.eventbus.Subscribe(ctx, topic)
+		if err != nil {
+			s.log.Error("failed to subscribe to events", "error", err, "topic", topic)
+			continue
+		}
+		go s.forward(events)
+	}
+}
+
+func (s *Server) forward(events <-chan *eventbus.Event) {
+	for event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		// The runner tags the events of users' conversations with their name
+		s.broadcast(event.Metadata["user"], data)
+	}
+}
+
 func writeJSON(w http.ResponseWriter, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(v); err != nil {
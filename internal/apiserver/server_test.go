@@ -1,16 +1,30 @@
 package apiserver
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/honganh1206/tinker/internal/logger"
 	"github.com/honganh1206/tinker/internal/mcp"
+	"github.com/honganh1206/tinker/internal/model"
 	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/users"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,7 +34,7 @@ func setupServer(t *testing.T) (*Server, string) {
 	t.Helper()
 	sessionsDir := t.TempDir()
 	mcpDir := t.TempDir()
-	s := NewServer(nil, nil, sessionsDir, mcpDir)
+	s := NewServer(nil, logger.NewLogger(io.Discard, false), sessionsDir, mcpDir, filepath.Join(t.TempDir(), "config.json"))
 	return s, sessionsDir
 }
 
@@ -28,7 +42,7 @@ func setupServer(t *testing.T) (*Server, string) {
 func setupWSServer(t *testing.T) (*Server, string) {
 	t.Helper()
 	s, _ := setupServer(t)
-	httpServer := httptest.NewServer(s.mux)
+	httpServer := httptest.NewServer(s.handler())
 	t.Cleanup(httpServer.Close)
 	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/ws/stream"
 	return s, wsURL
@@ -73,7 +87,7 @@ func TestListSessions_WithData(t *testing.T) {
 
 	db, err := storage.NewSession(sessionsDir, "thread-1")
 	require.NoError(t, err)
-	_, err = storage.CreateContext(db, "ctx-1")
+	_, err = storage.CreateContext(t.Context(), db, "ctx-1")
 	require.NoError(t, err)
 	db.Close()
 
@@ -95,7 +109,7 @@ func TestGetSession(t *testing.T) {
 
 	db, err := storage.NewSession(sessionsDir, "thread-1")
 	require.NoError(t, err)
-	_, err = storage.CreateContext(db, "ctx-1")
+	_, err = storage.CreateContext(t.Context(), db, "ctx-1")
 	require.NoError(t, err)
 	db.Close()
 
@@ -136,7 +150,7 @@ func TestDeleteSession(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, w.Code)
 
 	// Verify it's gone
-	_, err = storage.GetSession(sessionsDir, "thread-1")
+	_, err = storage.GetSession(t.Context(), sessionsDir, "thread-1")
 	assert.Error(t, err)
 	// Verify .db file is removed
 	assert.NoFileExists(t, filepath.Join(sessionsDir, "thread-1.db"))
@@ -186,6 +200,34 @@ func TestListMCPConfigs_WithData(t *testing.T) {
 	assert.Equal(t, "test-server", configs[0].ID)
 }
 
+func TestStats(t *testing.T) {
+	s, sessionsDir := setupServer(t)
+
+	db, err := storage.NewSession(sessionsDir, "thread-1")
+	require.NoError(t, err)
+	ctx, err := storage.CreateContext(t.Context(), db, "ctx-1")
+	require.NoError(t, err)
+	_, err = storage.InsertTurnStats(t.Context(), db, ctx.ID, storage.TurnStats{Duration: time.Second, OutputTokens: 10})
+	require.NoError(t, err)
+	err = storage.RecordUsage(t.Context(), db, time.Now(), storage.Usage{Model: "claude-sonnet-4-6", OutputTokens: 10, Turns: 1})
+	require.NoError(t, err)
+	db.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp statsResponse
+	err = json.NewDecoder(w.Body).Decode(&resp)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Summary.Turns)
+	require.Len(t, resp.Usage, 1)
+	assert.Equal(t, "claude-sonnet-4-6", resp.Usage[0].Model)
+	assert.Equal(t, 1, resp.Usage[0].Turns)
+}
+
 func TestMethodNotAllowed(t *testing.T) {
 	s, _ := setupServer(t)
 
@@ -196,6 +238,8 @@ func TestMethodNotAllowed(t *testing.T) {
 		{http.MethodPost, "/healthz"},
 		{http.MethodPost, "/api/sessions"},
 		{http.MethodPost, "/api/mcp/configs"},
+		{http.MethodPost, "/api/stats"},
+		{http.MethodPost, "/api/keymap"},
 		{http.MethodPut, "/api/sessions/some-id"},
 	}
 
@@ -208,3 +252,853 @@ func TestMethodNotAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestKeymap_Defaults(t *testing.T) {
+	s, _ := setupServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/keymap", nil)
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var keymap Keymap
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&keymap))
+	assert.Equal(t, DefaultKeymap, keymap)
+}
+
+func TestLoadKeymap_Overrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{"keymap": {"next_session": ["n", "ArrowDown"], "launch": ["x"]}}`
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+
+	keymap, err := loadKeymap(path)
+
+	assert.ErrorContains(t, err, "unknown keymap actions: launch")
+	assert.Equal(t, []string{"n", "ArrowDown"}, keymap["next_session"])
+	assert.Equal(t, DefaultKeymap["prev_session"], keymap["prev_session"])
+	assert.NotContains(t, keymap, "launch")
+}
+
+func TestStream_BroadcastsToAllClients(t *testing.T) {
+	s, wsURL := setupWSServer(t)
+
+	var conns []*websocket.Conn
+	for range 2 {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conns = append(conns, conn)
+	}
+	waitForClientCount(t, s, 2)
+
+	s.broadcast("", []byte(`{"topic":"agent.stream.chunk"}`))
+
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"topic":"agent.stream.chunk"}`, string(data))
+	}
+
+	conns[0].Close()
+	waitForClientCount(t, s, 1)
+}
+
+func TestBroadcast_DropsSlowClient(t *testing.T) {
+	s, _ := setupServer(t)
+	slow := s.addClient("")
+	fast := s.addClient("")
+
+	for range clientBuffer {
+		s.broadcast("", []byte("chunk"))
+	}
+	<-fast
+	s.broadcast("", []byte("chunk"))
+
+	assert.Equal(t, 1, s.clientCount())
+	assert.Len(t, slow, clientBuffer)
+	for range slow {
+	}
+	assert.Len(t, fast, clientBuffer)
+}
+
+func TestEnforceRetention(t *testing.T) {
+	sessionsDir := t.TempDir()
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"retention": {"keep_last": 1}}`), 0o644))
+	s := NewServer(nil, logger.NewLogger(io.Discard, false), sessionsDir, t.TempDir(), configPath)
+
+	for _, id := range []string{"a", "b"} {
+		db, err := storage.NewSession(sessionsDir, id)
+		require.NoError(t, err)
+		_, err = storage.CreateContext(t.Context(), db, id)
+		require.NoError(t, err)
+		db.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.enforceRetention(time.Now())
+
+	sessions, err := storage.ListSessions(t.Context(), sessionsDir)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "b", sessions[0].ID)
+}
+
+func TestMiddleware_RequestID(t *testing.T) {
+	s, _ := setupServer(t)
+
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(RequestIDHeader, "trace-1")
+	w = httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+	assert.Equal(t, "trace-1", w.Header().Get(RequestIDHeader))
+}
+
+func TestMiddleware_RecoversPanic(t *testing.T) {
+	s, _ := setupServer(t)
+	s.mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestMiddleware_Gzip(t *testing.T) {
+	s, _ := setupServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/keymap", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	var keymap Keymap
+	require.NoError(t, json.NewDecoder(gz).Decode(&keymap))
+	assert.Equal(t, DefaultKeymap, keymap)
+}
+
+func TestMiddleware_CORS(t *testing.T) {
+	s, _ := setupServer(t)
+	s.AllowOrigins("https://dash.example.com")
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/sessions", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://dash.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestMiddleware_RateLimit(t *testing.T) {
+	s, _ := setupServer(t)
+	s.SetLimits(Limits{RequestsPerMinute: 60, Burst: 2})
+
+	get := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/keymap", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		s.handler().ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1000").Code)
+	assert.Equal(t, http.StatusOK, get("10.0.0.1:1001").Code)
+	w := get("10.0.0.1:1002")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Other clients have their own bucket, and the health check is never limited
+	assert.Equal(t, http.StatusOK, get("10.0.0.2:1000").Code)
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.RemoteAddr = "10.0.0.1:1003"
+	w = httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimiter_Refills(t *testing.T) {
+	l := newRateLimiter(0.5, 1)
+	now := time.Now()
+
+	ok, _ := l.allow("a", now)
+	assert.True(t, ok)
+	ok, wait := l.allow("a", now.Add(time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+	ok, _ = l.allow("a", now.Add(2*time.Second))
+	assert.True(t, ok)
+}
+
+func TestMiddleware_MaxConcurrent(t *testing.T) {
+	s, _ := setupServer(t)
+	s.SetLimits(Limits{MaxConcurrent: 1})
+	started, release := make(chan struct{}), make(chan struct{})
+	s.mux.HandleFunc("/api/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/slow", nil))
+		done <- w.Code
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/keymap", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	w = httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/keymap", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestOpenAPI(t *testing.T) {
+	s, _ := setupServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+
+	// Every documented route is served
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			req := httptest.NewRequest(strings.ToUpper(method), strings.NewReplacer("{id}", "missing", "{project}", "missing", "{job}", "missing", "{webhook}", "missing", "{blob}", "missing").Replace(path), nil)
+			_, pattern := s.mux.Handler(req)
+			assert.NotEqual(t, "", pattern, "%s %s is not routed", method, path)
+		}
+	}
+
+	assert.Contains(t, doc.Paths["/api/sessions/{id}"], "delete")
+	assert.Contains(t, doc.Components.Schemas, "Session")
+	assert.Contains(t, doc.Components.Schemas["Session"]["properties"], "contexts")
+}
+
+func TestDocs(t *testing.T) {
+	s, _ := setupServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `url: "/openapi.json"`)
+}
+
+func TestShareSession(t *testing.T) {
+	s, sessionsDir := setupServer(t)
+
+	db, err := storage.NewSession(sessionsDir, "thread-1")
+	require.NoError(t, err)
+	c, err := storage.CreateContext(t.Context(), db, "ctx-1")
+	require.NoError(t, err)
+	_, err = storage.InsertRecord(t.Context(), db, c.ID, storage.Prompt, "<b>fix the bug</b>", true)
+	require.NoError(t, err)
+	db.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/thread-1/share", strings.NewReader(`{"expires_in_hours": 1}`))
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var share shareResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&share))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), share.ExpiresAt, time.Minute)
+
+	req = httptest.NewRequest(http.MethodGet, share.URL, nil)
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "&lt;b&gt;fix the bug&lt;/b&gt;")
+
+	// A tampered token is refused
+	req = httptest.NewRequest(http.MethodGet, share.URL+"x", nil)
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestShareSession_NotFound(t *testing.T) {
+	s, _ := setupServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/nonexistent/share", nil)
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestShareKey_ConcurrentFirstUse(t *testing.T) {
+	s, _ := setupServer(t)
+	keys := make([][]byte, 32)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			key, err := s.shareKey()
+			assert.NoError(t, err)
+			keys[i] = key
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	stored, err := s.shareKey()
+	require.NoError(t, err)
+	assert.Len(t, stored, 32)
+	for _, key := range keys {
+		assert.Equal(t, stored, key, "every link is signed with the stored key")
+	}
+}
+
+func TestVerifyShare(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Now()
+	token := signShare(key, "alice", "thread-1", now.Add(time.Hour))
+
+	owner, id, err := verifyShare(key, token, now)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", owner)
+	assert.Equal(t, "thread-1", id)
+
+	_, _, err = verifyShare(key, token, now.Add(2*time.Hour))
+	assert.ErrorContains(t, err, "expired")
+
+	_, _, err = verifyShare([]byte("another key, another key, another"), token, now)
+	assert.ErrorContains(t, err, "invalid")
+}
+
+func TestUsers_IsolateSessions(t *testing.T) {
+	s, sessionsDir := setupServer(t)
+	var u users.Users
+	alice, err := u.Add("alice", nil)
+	require.NoError(t, err)
+	bob, err := u.Add("bob", nil)
+	require.NoError(t, err)
+	s.SetUsers(u)
+
+	db, err := storage.NewSession(users.SessionsDir(sessionsDir, "alice"), "thread-1")
+	require.NoError(t, err)
+	db.Close()
+
+	list := func(header, value string) (int, []*storage.Session) {
+		req := httptest.NewRequest(http.MethodGet, "/api/sessions", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		s.handler().ServeHTTP(w, req)
+		var sessions []*storage.Session
+		json.NewDecoder(w.Body).Decode(&sessions)
+		return w.Code, sessions
+	}
+
+	code, _ := list("", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, sessions := list("Authorization", "Bearer "+alice)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "thread-1", sessions[0].ID)
+
+	code, sessions = list("Authorization", "Bearer "+bob)
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, sessions)
+
+	// Bob cannot read Alice's session by ID either
+	req := httptest.NewRequest(http.MethodGet, "/api/sessions/thread-1", nil)
+	req.Header.Set("Authorization", "Bearer "+bob)
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUsers_RejectTraversalToOtherUsers(t *testing.T) {
+	s, sessionsDir := setupServer(t)
+	var u users.Users
+	alice, err := u.Add("alice", nil)
+	require.NoError(t, err)
+	_, err = u.Add("bob", nil)
+	require.NoError(t, err)
+	s.SetUsers(u)
+
+	bobDir := users.SessionsDir(sessionsDir, "bob")
+	db, err := storage.NewSession(bobDir, "secret")
+	require.NoError(t, err)
+	db.Close()
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/api/sessions/..%2Fbob%2Fsecret"},
+		{http.MethodDelete, "/api/sessions/..%2Fbob%2Fsecret"},
+		{http.MethodPost, "/api/sessions/..%2Fbob%2Fsecret/share"},
+		{http.MethodGet, "/api/sessions/..%2Fbob%2Fsecret/file?path=README.md"},
+		{http.MethodGet, "/api/sessions/..%2F..%2Fbob%2Fsecret"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+alice)
+		w := httptest.NewRecorder()
+		s.handler().ServeHTTP(w, req)
+		assert.NotEqual(t, http.StatusOK, w.Code, "%s %s", tc.method, tc.path)
+		assert.NotContains(t, w.Body.String(), "secret", "%s %s", tc.method, tc.path)
+	}
+	_, err = os.Stat(filepath.Join(bobDir, "secret.db"))
+	assert.NoError(t, err, "bob's session was deleted")
+}
+
+func TestUsers_TokenQuerySetsCookie(t *testing.T) {
+	s, _ := setupServer(t)
+	var u users.Users
+	token, err := u.Add("alice", nil)
+	require.NoError(t, err)
+	s.SetUsers(u)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sessions?token="+token, nil)
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/sessions", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBroadcast_OnlyToOwner(t *testing.T) {
+	s, _ := setupServer(t)
+	var u users.Users
+	_, err := u.Add("alice", nil)
+	require.NoError(t, err)
+	s.SetUsers(u)
+
+	alice := s.addClient("alice")
+	bob := s.addClient("bob")
+	s.broadcast("alice", []byte("chunk"))
+
+	assert.Len(t, alice, 1)
+	assert.Empty(t, bob)
+}
+
+func TestProjects_CRUD(t *testing.T) {
+	s, _ := setupServer(t)
+	root := t.TempDir()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/projects", `{"name": "tinker", "path": "`+root+`", "settings": {"model": "claude-sonnet-4-6"}}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var p storage.Project
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, root, p.Path)
+
+	w = do(http.MethodPost, "/api/projects", `{"name": "tinker", "path": "`+root+`"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = do(http.MethodPut, "/api/projects/tinker", `{"settings": {"language": "French"}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do(http.MethodGet, "/api/projects/"+p.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, "French", p.Settings.Language)
+
+	w = do(http.MethodDelete, "/api/projects/tinker", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = do(http.MethodGet, "/api/projects/tinker", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// echoModel answers every prompt with the last record it was sent.
+type echoModel struct{}
+
+func (echoModel) Call(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+	return []storage.Record{{Source: storage.ModelResp, Content: "echo: " + inputs[len(inputs)-1].Content}}, 10, nil
+}
+
+func TestMessages_RunsTurn(t *testing.T) {
+	s, sessionsDir := setupServer(t)
+	workspace := t.TempDir()
+	s.SetAgent(echoModel{}, model.ResponseStyle{}, workspace)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/remote-1/messages", strings.NewReader(`{"content": "hello"}`))
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp messageResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "echo: hello", resp.Content)
+
+	session, err := storage.GetSession(t.Context(), sessionsDir, "remote-1")
+	require.NoError(t, err)
+	require.Len(t, session.Contexts, 1)
+	resolved, _ := filepath.EvalSymlinks(workspace)
+	assert.Contains(t, []string{workspace, resolved}, session.Contexts[0].WorkDir)
+}
+
+// blockedModel fails every call as the safety filters of Gemini would
+type blockedModel struct{}
+
+func (blockedModel) Call(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+	return nil, 0, &model.BlockedError{Reason: "SAFETY", Categories: []string{"HARM_CATEGORY_DANGEROUS_CONTENT"}}
+}
+
+func TestMessages_BlockedTurn(t *testing.T) {
+	s, _ := setupServer(t)
+	s.SetAgent(blockedModel{}, model.ResponseStyle{}, t.TempDir())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/remote-1/messages", strings.NewReader(`{"content": "kill the server"}`))
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	var resp blockedResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "SAFETY", resp.Blocked.Reason)
+	assert.Equal(t, []string{"HARM_CATEGORY_DANGEROUS_CONTENT"}, resp.Blocked.Categories)
+}
+
+func TestMessages_DisabledWithoutWorkspace(t *testing.T) {
+	s, _ := setupServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/remote-1/messages", strings.NewReader(`{"content": "hello"}`))
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestBlobs_UploadAndAttach(t *testing.T) {
+	s, _ := setupServer(t)
+	s.SetAgent(echoModel{}, model.ResponseStyle{}, t.TempDir())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/blobs?name=notes.txt", strings.NewReader("remember the milk"))
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var blob storage.Blob
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&blob))
+	assert.Equal(t, "notes.txt", blob.Name)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/blobs/"+blob.Hash, nil)
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "remember the milk", w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"`+blob.Hash+`"`, w.Header().Get("ETag"))
+
+	body := `{"content": "what is in my notes?", "attachments": ["` + blob.Hash + `"]}`
+	req = httptest.NewRequest(http.MethodPost, "/api/sessions/remote-1/messages", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp messageResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Contains(t, resp.Content, "remember the milk")
+
+	body = `{"content": "and these?", "attachments": ["` + strings.Repeat("0", 64) + `"]}`
+	req = httptest.NewRequest(http.MethodPost, "/api/sessions/remote-1/messages", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/blobs/"+strings.Repeat("0", 64), nil)
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSessionFile(t *testing.T) {
+	s, sessionsDir := setupServer(t)
+	work := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(work, "main.go"), []byte("package main\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(work, ".env"), []byte("TOKEN=secret\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(work, ".tinkerignore"), []byte(".env\n"), 0o644))
+
+	db, err := storage.NewSession(sessionsDir, "thread-1")
+	require.NoError(t, err)
+	c, err := storage.CreateContext(t.Context(), db, "ctx-1")
+	require.NoError(t, err)
+	require.NoError(t, storage.SetContextWorkDir(t.Context(), db, c.ID, work))
+	db.Close()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sessions/thread-1/file?path="+url.QueryEscape(path), nil))
+		return w
+	}
+
+	w := get("main.go")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "package main\n", w.Body.String())
+	assert.Equal(t, http.StatusOK, get(filepath.Join(work, "main.go")).Code)
+
+	assert.Equal(t, http.StatusForbidden, get("../outside.go").Code)
+	assert.Equal(t, http.StatusForbidden, get(".env").Code)
+	assert.Equal(t, http.StatusNotFound, get("missing.go").Code)
+}
+
+// fakeTinker writes a tinker stand-in answering with its working directory.
+func fakeTinker(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tinker")
+	script := "#!/bin/sh\necho \"thinking in $PWD\" >&2\nprintf '{\"session_id\": \"s-1\", \"response\": \"done in %s\"}' \"$PWD\"\n"
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o755))
+	return path
+}
+
+// setupJobServer enables jobs in repositories under root, run by fakeTinker.
+func setupJobServer(t *testing.T, root string) *Server {
+	t.Helper()
+	s := NewServer(nil, logger.NewLogger(io.Discard, false), t.TempDir(), t.TempDir(), filepath.Join(t.TempDir(), "config.json"))
+	s.EnableJobs(fakeTinker(t), root, 1)
+	return s
+}
+
+func TestJobs_RunAndPoll(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "repo"), 0o755))
+	s := setupJobServer(t, root)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{"prompt": "fix the build", "repo": "repo"}`))
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var queued job
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&queued))
+	assert.Equal(t, "/api/jobs/"+queued.ID, w.Header().Get("Location"))
+
+	var done job
+	require.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/jobs/"+queued.ID, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&done))
+		return done.Status == jobSucceeded || done.Status == jobFailed
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, jobSucceeded, done.Status, done.Error)
+	assert.Equal(t, "s-1", done.SessionID)
+	assert.Equal(t, "done in "+queued.Repo, done.Result)
+	assert.Contains(t, done.Logs, "thinking in")
+	assert.NotNil(t, done.FinishedAt)
+
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	var jobs []job
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&jobs))
+	assert.Len(t, jobs, 1)
+}
+
+func TestSchedules_QueueDueJobs(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	require.NoError(t, os.Mkdir(repo, 0o755))
+	s := setupJobServer(t, root)
+	db, err := storage.OpenSchedules(filepath.Join(t.TempDir(), storage.SchedulesFile))
+	require.NoError(t, err)
+	defer db.Close()
+	s.schedules = db
+
+	daily, err := storage.CreateSchedule(t.Context(), db, "0 7 * * *", "update deps", repo)
+	require.NoError(t, err)
+	_, err = storage.CreateSchedule(t.Context(), db, "0 8 * * *", "not yet", repo)
+	require.NoError(t, err)
+	outside, err := storage.CreateSchedule(t.Context(), db, "0 7 * * *", "escape", t.TempDir())
+	require.NoError(t, err)
+
+	from := time.Date(2026, 10, 14, 6, 59, 30, 0, time.Local)
+	s.queueDueSchedules(t.Context(), from, from.Add(time.Minute))
+
+	byID := func() map[string]storage.Schedule {
+		schedules, err := storage.ListSchedules(t.Context(), db)
+		require.NoError(t, err)
+		m := make(map[string]storage.Schedule)
+		for _, sc := range schedules {
+			m[sc.ID] = sc
+		}
+		return m
+	}
+	require.Eventually(t, func() bool {
+		return byID()[daily.ID].LastRun != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	schedules := byID()
+	assert.Equal(t, "s-1", schedules[daily.ID].LastSession, "the run's conversation is recorded")
+	assert.Empty(t, schedules[daily.ID].LastError)
+	assert.Contains(t, schedules[outside.ID].LastError, "outside the jobs root")
+	jobs := s.jobs.list("")
+	require.Len(t, jobs, 1, "only the due schedule in the jobs root runs")
+	assert.Equal(t, daily.ID, jobs[0].Schedule)
+	assert.Equal(t, "update deps", jobs[0].Prompt)
+}
+
+func TestSchedules_WithoutLogger(t *testing.T) {
+	root := t.TempDir()
+	s, _ := setupServer(t)
+	s.EnableJobs(fakeTinker(t), root, 1)
+	db, err := storage.OpenSchedules(filepath.Join(t.TempDir(), storage.SchedulesFile))
+	require.NoError(t, err)
+	defer db.Close()
+	s.schedules = db
+
+	// Neither a schedule that cannot be parsed nor a job run needs a logger
+	_, err = storage.CreateSchedule(t.Context(), db, "every morning", "invalid", root)
+	require.NoError(t, err)
+	daily, err := storage.CreateSchedule(t.Context(), db, "0 7 * * *", "update deps", root)
+	require.NoError(t, err)
+
+	from := time.Date(2026, 10, 14, 6, 59, 30, 0, time.Local)
+	s.queueDueSchedules(t.Context(), from, from.Add(time.Minute))
+	require.Eventually(t, func() bool {
+		schedules, err := storage.ListSchedules(t.Context(), db)
+		require.NoError(t, err)
+		for _, sc := range schedules {
+			if sc.ID == daily.ID {
+				return sc.LastRun != nil
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestJobs_RefusesRepoOutsideRoot(t *testing.T) {
+	s := setupJobServer(t, t.TempDir())
+
+	for _, repo := range []string{t.TempDir(), "..", "missing"} {
+		body, _ := json.Marshal(jobRequest{Prompt: "hi", Repo: repo})
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/jobs", bytes.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, repo)
+	}
+}
+
+func TestJobs_NotFoundForOtherUsers(t *testing.T) {
+	s := setupJobServer(t, t.TempDir())
+	// Not queued, so no worker runs it
+	s.jobs.jobs["j-1"] = &job{ID: "j-1", Status: jobQueued, user: "alice"}
+	s.jobs.order = append(s.jobs.order, "j-1")
+
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/jobs/j-1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWebhooks_DeliverFinishedJob(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	t.Cleanup(receiver.Close)
+
+	root := t.TempDir()
+	s := setupJobServer(t, root)
+
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/webhooks",
+		strings.NewReader(`{"url": "`+receiver.URL+`", "events": ["job.finished"]}`)))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var hook webhook
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&hook))
+	require.NotEmpty(t, hook.Secret)
+
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{"prompt": "hi"}`)))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+	var r *http.Request
+	select {
+	case r = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	body := <-bodies
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Tinker-Signature"))
+
+	var p webhookPayload
+	require.NoError(t, json.Unmarshal(body, &p))
+	assert.Equal(t, webhookJobFinished, p.Event)
+	assert.Equal(t, "s-1", p.SessionID)
+	require.NotNil(t, p.Job)
+	assert.Equal(t, jobSucceeded, p.Job.Status)
+	assert.Contains(t, p.Text, "succeeded")
+
+	// Listing hides the secret
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/webhooks", nil))
+	var hooks []webhook
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&hooks))
+	require.Len(t, hooks, 1)
+	assert.Empty(t, hooks[0].Secret)
+
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/webhooks/"+hook.ID, nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/webhooks/"+hook.ID, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWebhooks_RejectsInvalid(t *testing.T) {
+	s, _ := setupServer(t)
+
+	for _, body := range []string{
+		`{"url": "ftp://example.com"}`,
+		`{"url": "not a url"}`,
+		`{"url": "https://example.com", "events": ["plan.done"]}`,
+	} {
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
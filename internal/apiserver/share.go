@@ -0,0 +1,273 @@
+package apiserver
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// defaultShareTTL is how long a share link works when the request does not say
+const defaultShareTTL = 7 * 24 * time.Hour
+
+// shareKeyFile holds the key share links are signed with, next to the config
+// file. Deleting it revokes every link handed out.
+const shareKeyFile = "share.key"
+
+type shareRequest struct {
+	// Lifetime of the link, 7 days when zero
+	ExpiresInHours int `json:"expires_in_hours,omitempty"`
+}
+
+type shareResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleShare signs a read-only link to session id. Whoever has the link can
+// read the session as a static page, and nothing else.
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request, dir, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req shareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		http.Error(w, "expires_in_hours cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	path, err := storage.SessionPath(dir, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		http.Error(w, fmt.Sprintf("session %s not found", id), http.StatusNotFound)
+		return
+	}
+
+	ttl := defaultShareTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+
+	key, err := s.shareKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := signShare(key, userName(r.Context()), id, expires)
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, shareResponse{Token: token, URL: "/shared/" + token, ExpiresAt: expires.UTC()})
+}
+
+// handleShared renders the session a share link points to as a
+// self-contained page, which can be saved and passed around as it is.
+func (s *Server) handleShared(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, err := s.shareKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	owner, id, err := verifyShare(key, strings.TrimPrefix(r.URL.Path, "/shared/"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	dir, err := s.userSessionsDir(owner)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	session, err := storage.GetSession(r.Context(), dir, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The page is a snapshot, keep it out of shared caches and search engines
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if err := sharedPage.Execute(w, session); err != nil {
+		s.log.Error("failed to render shared session", "error", err, "session", id)
+	}
+}
+
+// shareKey returns the signing key, generating it on first use. Requests
+// racing on the first use all get the key that was stored first.
+func (s *Server) shareKey() ([]byte, error) {
+	path := filepath.Join(filepath.Dir(s.configPath), shareKeyFile)
+	key, err := readShareKey(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return key, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate share key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create config dir: %w", err)
+	}
+	// Linking a complete file in place fails if another request stored its
+	// key first, which is never seen half written
+	tmp, err := os.CreateTemp(filepath.Dir(path), shareKeyFile+".*")
+	if err != nil {
+		return nil, fmt.Errorf("write share key: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(key)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("write share key: %w", err)
+	}
+	if err := os.Link(tmp.Name(), path); errors.Is(err, fs.ErrExist) {
+		return readShareKey(path)
+	} else if err != nil {
+		return nil, fmt.Errorf("write share key: %w", err)
+	}
+	return key, nil
+}
+
+func readShareKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read share key: %w", err)
+	}
+	if len(key) < 32 {
+		return nil, fmt.Errorf("share key %s is shorter than 32 bytes, remove it to generate another", path)
+	}
+	return key, nil
+}
+
+// signShare returns a token naming session id of user owner until expires,
+// in the form base64(owner:id:expiry).base64(hmac).
+func signShare(key []byte, owner, id string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(owner + ":" + id + ":" + strconv.FormatInt(expires.Unix(), 10)))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(shareMAC(key, payload))
+}
+
+// verifyShare returns the owner and session token names, if it was signed
+// with key and has not expired by now.
+func verifyShare(key []byte, token string, now time.Time) (owner, id string, err error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", errors.New("malformed share link")
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, shareMAC(key, payload)) {
+		return "", "", errors.New("invalid share link")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", errors.New("malformed share link")
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return "", "", errors.New("malformed share link")
+	}
+	unix, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", "", errors.New("malformed share link")
+	}
+	if now.After(time.Unix(unix, 0)) {
+		return "", "", errors.New("share link expired")
+	}
+	return parts[0], parts[1], nil
+}
+
+func shareMAC(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("tinker share:" + payload))
+	return mac.Sum(nil)
+}
+
+var sharedPage = template.Must(template.New("shared").Funcs(template.FuncMap{
+	"source": recordSource,
+}).Parse(`<!doctype html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>tinker session {{.ID}}</title>
+  <style>
+    body { font-family: system-ui, sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+    h1 { font-size: 1.2rem; }
+    .context { color: #666; margin-top: 2rem; border-bottom: 1px solid #ddd; }
+    .record { margin: 1rem 0; }
+    .source { font-size: .8rem; font-weight: bold; text-transform: uppercase; color: #888; }
+    pre { white-space: pre-wrap; word-break: break-word; background: #f6f6f6; padding: .75rem; border-radius: 4px; }
+    .prompt pre { background: #eef4ff; }
+  </style>
+</head>
+<body>
+  <h1>Session {{.ID}}</h1>
+  {{range $c := .Contexts}}
+  <h2 class="context">{{$c.Name}} · {{$c.StartTime.Format "2006-01-02 15:04"}}{{with $c.WorkDir}} · {{.}}{{end}}</h2>
+  {{range $.Records}}{{if eq .ContextID $c.ID}}{{$src := source .Source}}{{if ne $src "system"}}
+  <div class="record {{$src}}">
+    <div class="source">{{$src}}{{with .Summary}} · {{.}}{{end}}</div>
+    <pre>{{.Content}}</pre>
+  </div>
+  {{end}}{{end}}{{end}}
+  {{end}}
+</body>
+</html>
+`))
+
+func recordSource(t storage.RecordType) string {
+	switch t {
+	case storage.Prompt:
+		return "prompt"
+	case storage.ModelResp:
+		return "response"
+	case storage.ToolUse:
+		return "tool call"
+	case storage.ToolResult:
+		return "tool result"
+	case storage.SystemNote:
+		return "note"
+	case storage.Thinking:
+		return "thinking"
+	default:
+		return "system"
+	}
+}
@@ -0,0 +1,88 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/users"
+)
+
+// tokenCookie keeps the token of a browser that opened the web UI with
+// ?token=, since the UI and its WebSocket cannot send an Authorization header
+const tokenCookie = "tinker_token"
+
+type userKey struct{}
+
+// userName returns the user a request was made by, empty when the server
+// has no users.
+func userName(ctx context.Context) string {
+	name, _ := ctx.Value(userKey{}).(string)
+	return name
+}
+
+// SetUsers makes the API require a token of one of u, and scopes sessions,
+// stats and the stream to the user it belongs to. Without users the API
+// serves the top-level sessions directory to anyone who can reach it.
+func (s *Server) SetUsers(u users.Users) {
+	s.users = u
+}
+
+// identify resolves the user of API and stream requests. The web UI assets,
+// health check, API docs and share links stay public.
+func (s *Server) identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.users) == 0 || !(strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/ws/")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, fromQuery := requestToken(r)
+		user, ok := s.users.ByToken(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tinker"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if fromQuery {
+			http.SetCookie(w, &http.Cookie{
+				Name: tokenCookie, Value: token, Path: "/",
+				HttpOnly: true, SameSite: http.SameSiteStrictMode,
+			})
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user.Name)))
+	})
+}
+
+// requestToken reads the token from the Authorization header, the cookie or
+// the token query parameter, in that order.
+func requestToken(r *http.Request) (token string, fromQuery bool) {
+	if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(t), false
+	}
+	if c, err := r.Cookie(tokenCookie); err == nil {
+		return c.Value, false
+	}
+	if t := r.URL.Query().Get("token"); t != "" {
+		return t, true
+	}
+	return "", false
+}
+
+// sessionsFor returns the sessions directory of the user of r.
+func (s *Server) sessionsFor(r *http.Request) (string, error) {
+	return s.userSessionsDir(userName(r.Context()))
+}
+
+func (s *Server) userSessionsDir(name string) (string, error) {
+	if name == "" {
+		return s.sessionsDir, nil
+	}
+	dir := users.SessionsDir(s.sessionsDir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create sessions dir: %w", err)
+	}
+	return dir, nil
+}
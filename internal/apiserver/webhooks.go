@@ -0,0 +1,296 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/channel"
+	"github.com/honganh1206/tinker/internal/eventbus"
+)
+
+// webhooksFile holds the registered webhooks next to the config file
+const webhooksFile = "webhooks.json"
+
+// Events webhooks subscribe to
+const (
+	webhookJobFinished = "job.finished"
+	webhookTurnFailed  = "turn.failed"
+)
+
+var webhookEvents = []string{webhookJobFinished, webhookTurnFailed}
+
+// webhookClient delivers payloads, a slow receiver must not pile up requests
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+type webhookRequest struct {
+	URL string `json:"url"`
+	// Events to deliver, all of them when empty
+	Events []string `json:"events,omitempty"`
+}
+
+// webhook is a URL receiving a JSON payload for each of its events
+type webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+	// Key of the X-Tinker-Signature header, only returned on creation
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	User      string    `json:"user,omitempty"`
+}
+
+func (h webhook) wants(event string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, event)
+}
+
+// webhookPayload is the body POSTed to webhooks. Text summarizes the event
+// so Slack incoming webhooks can post it as is.
+type webhookPayload struct {
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Text      string    `json:"text"`
+	SessionID string    `json:"session_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Job       *job      `json:"job,omitempty"`
+}
+
+func (s *Server) webhooksPath() string {
+	return filepath.Join(filepath.Dir(s.configPath), webhooksFile)
+}
+
+func (s *Server) loadWebhooks() ([]webhook, error) {
+	data, err := os.ReadFile(s.webhooksPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read webhooks: %w", err)
+	}
+	var hooks []webhook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, fmt.Errorf("parse webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+func (s *Server) saveWebhooks(hooks []webhook) error {
+	data, err := json.MarshalIndent(hooks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.webhooksPath()), 0o700); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	// The file holds the signing secrets
+	if err := os.WriteFile(s.webhooksPath(), data, 0o600); err != nil {
+		return fmt.Errorf("write webhooks: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	user := userName(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		hooks, err := s.loadWebhooks()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		own := []webhook{}
+		for _, h := range hooks {
+			if h.User == user {
+				h.Secret = ""
+				own = append(own, h)
+			}
+		}
+		writeJSON(w, own)
+
+	case http.MethodPost:
+		var req webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "url must be an http or https URL", http.StatusBadRequest)
+			return
+		}
+		for _, e := range req.Events {
+			if !slices.Contains(webhookEvents, e) {
+				http.Error(w, fmt.Sprintf("unknown event %q, expected one of %s", e, strings.Join(webhookEvents, ", ")), http.StatusBadRequest)
+				return
+			}
+		}
+
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h := webhook{
+			ID:        uuid.NewString(),
+			URL:       req.URL,
+			Events:    req.Events,
+			Secret:    hex.EncodeToString(secret),
+			CreatedAt: time.Now().UTC(),
+			User:      user,
+		}
+
+		s.webhooksMu.Lock()
+		defer s.webhooksMu.Unlock()
+		hooks, err := s.loadWebhooks()
+		if err == nil {
+			err = s.saveWebhooks(append(hooks, h))
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, h)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleWebhookByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/webhooks/")
+	user := userName(r.Context())
+
+	s.webhooksMu.Lock()
+	defer s.webhooksMu.Unlock()
+	hooks, err := s.loadWebhooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	kept := slices.DeleteFunc(slices.Clone(hooks), func(h webhook) bool {
+		return h.ID == id && h.User == user
+	})
+	if len(kept) == len(hooks) {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return
+	}
+	if err := s.saveWebhooks(kept); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notify delivers p to the webhooks of user subscribed to its event. Delivery
+// runs in the background and failures are only logged, webhooks are not
+// retried.
+func (s *Server) notify(user string, p webhookPayload) {
+	s.webhooksMu.Lock()
+	hooks, err := s.loadWebhooks()
+	s.webhooksMu.Unlock()
+	if err != nil {
+		s.log.Error("failed to load webhooks", "error", err)
+		return
+	}
+	p.Time = time.Now().UTC()
+	body, err := json.Marshal(p)
+	if err != nil {
+		s.log.Error("failed to marshal webhook payload", "error", err)
+		return
+	}
+
+	for _, h := range hooks {
+		if h.User != user || !h.wants(p.Event) {
+			continue
+		}
+		go func() {
+			if err := deliverWebhook(context.Background(), h, body); err != nil {
+				s.log.Warn("webhook delivery failed", "webhook", h.ID, "event", p.Event, "error", err)
+			}
+		}()
+	}
+}
+
+// deliverWebhook POSTs body to h, signed with its secret so receivers can
+// check it came from this server.
+func deliverWebhook(ctx context.Context, h webhook, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(h.Secret))
+	mac.Write(body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tinker-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyJob reports a finished job to its owner's webhooks.
+func (s *Server) notifyJob(j job) {
+	text := fmt.Sprintf("Job %s in %s succeeded", j.ID, j.Repo)
+	if j.Status == jobFailed {
+		text = fmt.Sprintf("Job %s in %s failed: %s", j.ID, j.Repo, j.Error)
+	}
+	s.notify(j.user, webhookPayload{
+		Event:     webhookJobFinished,
+		Text:      text,
+		SessionID: j.SessionID,
+		Error:     j.Error,
+		Job:       &j,
+	})
+}
+
+// notifyTurnFailed reports a turn of session that ended in err.
+func (s *Server) notifyTurnFailed(user, session string, err error) {
+	s.notify(user, webhookPayload{
+		Event:     webhookTurnFailed,
+		Text:      fmt.Sprintf("A turn in session %s failed: %v", session, err),
+		SessionID: session,
+		Error:     err.Error(),
+	})
+}
+
+// watchFailedRuns reports the runner's failed turns to webhooks.
+func (s *Server) watchFailedRuns(ctx context.Context) {
+	events, err := s.eventbus.Subscribe(ctx, eventbus.TopicAgentRunFailed)
+	if err != nil {
+		s.log.Error("failed to subscribe to failed runs", "error", err)
+		return
+	}
+	for event := range events {
+		var failed channel.AgentRunFailed
+		if err := json.Unmarshal(event.Data, &failed); err != nil {
+			continue
+		}
+		s.notifyTurnFailed(event.Metadata["user"], failed.ThreadID, errors.New(failed.Error))
+	}
+}
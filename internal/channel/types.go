@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/honganh1206/tinker/internal/eventbus"
+	"github.com/honganh1206/tinker/internal/tools"
 )
 
 // InboundMessage represents a message received from an external channel.
@@ -27,6 +28,8 @@ type OutboundMessage struct {
 	Text     string `json:"text"`
 	Format   string `json:"format,omitempty"` // plain, markdown, html
 	ReplyTo  string `json:"replyTo,omitempty"`
+	// Secondary line rendered under the text, e.g. turn stats
+	Footer string `json:"footer,omitempty"`
 }
 
 // AgentRunCompleted mirrors the payload from the runner process.
@@ -38,6 +41,33 @@ type AgentRunCompleted struct {
 	FinalMessage string `json:"finalMessage"`
 	Status       string `json:"status"`
 	SessionID    string `json:"sessionId"`
+	// One-line latency and throughput summary of the turn
+	Stats string `json:"stats,omitempty"`
+}
+
+// AgentRunFailed reports a turn of the runner that ended in an error.
+type AgentRunFailed struct {
+	Channel  string `json:"channel"`
+	ChatID   string `json:"chatId"`
+	ThreadID string `json:"threadId,omitempty"`
+	Error    string `json:"error"`
+}
+
+// AgentToolInput streams the input of a tool call while the model is generating it.
+type AgentToolInput struct {
+	ThreadID     string `json:"threadId,omitempty"`
+	ToolUseID    string `json:"toolUseId"`
+	Tool         string `json:"tool"`
+	PartialInput string `json:"partialInput"`
+	Done         bool   `json:"done,omitempty"`
+}
+
+// AgentFileEdit streams a change the agent made to a file, for editors to
+// preview and apply in their buffers.
+type AgentFileEdit struct {
+	ThreadID string `json:"threadId,omitempty"`
+	Tool     string `json:"tool"`
+	tools.FileEdit
 }
 
 // Attachment represents a file or media attachment.
@@ -0,0 +1,299 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var batchReport string
+
+// batchFile is the YAML task list consumed by `tinker batch`
+type batchFile struct {
+	// Number of tasks run at once, 1 when unset
+	Parallel int `yaml:"parallel"`
+	// Run every task in its own git worktree of its directory
+	Worktrees bool        `yaml:"worktrees"`
+	Tasks     []batchTask `yaml:"tasks"`
+}
+
+type batchTask struct {
+	Name   string `yaml:"name"`
+	Prompt string `yaml:"prompt"`
+	// Directory the task runs in, relative to the batch file
+	Dir          string `yaml:"dir"`
+	OutputSchema string `yaml:"output_schema"`
+
+	// Whether Dir is trusted, decided before the tasks start
+	trusted bool
+}
+
+// batchResult is the outcome of one task in the report
+type batchResult struct {
+	Name      string          `json:"name"`
+	Dir       string          `json:"dir"`
+	Worktree  string          `json:"worktree,omitempty"`
+	ExitCode  int             `json:"exit_code"`
+	Duration  time.Duration   `json:"duration"`
+	SessionID string          `json:"session_id,omitempty"`
+	Response  string          `json:"response,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type batchSummary struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []batchResult `json:"results"`
+}
+
+// BatchHandler runs every task of a batch file as a headless run
+// and reports their results.
+func BatchHandler(cmd *cobra.Command, args []string) error {
+	batch, err := loadBatch(args[0])
+	if err != nil {
+		return err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate tinker executable: %w", err)
+	}
+
+	// Ask about every folder up front, tasks run without a terminal
+	for i := range batch.Tasks {
+		t := &batch.Tasks[i]
+		if t.trusted, err = folderTrusted(t.Dir, os.Stdin, os.Stderr); err != nil {
+			return fmt.Errorf("check folder trust: %w", err)
+		}
+	}
+
+	start := time.Now()
+	results := make([]batchResult, len(batch.Tasks))
+	sem := make(chan struct{}, batch.Parallel)
+	var wg sync.WaitGroup
+	for i, task := range batch.Tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = runBatchTask(self, task, batch.Worktrees)
+		}()
+	}
+	wg.Wait()
+
+	summary := summarizeBatch(results)
+
+	printBatchSummary(cmd.OutOrStdout(), summary)
+	notifyDone(cmd.ErrOrStderr(), start, fmt.Sprintf("tinker batch finished: %d succeeded, %d failed", summary.Succeeded, summary.Failed))
+
+	if batchReport != "" {
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal batch report: %w", err)
+		}
+		if err := os.WriteFile(batchReport, data, 0o644); err != nil {
+			return fmt.Errorf("write batch report: %w", err)
+		}
+	}
+
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d tasks failed", summary.Failed, len(results))
+	}
+	return nil
+}
+
+func loadBatch(path string) (*batchFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read batch file: %w", err)
+	}
+
+	var batch batchFile
+	if err := yaml.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("parse batch file %s: %w", path, err)
+	}
+
+	if len(batch.Tasks) == 0 {
+		return nil, errors.New("batch file has no tasks")
+	}
+	if batch.Parallel <= 0 {
+		batch.Parallel = 1
+	}
+
+	base := filepath.Dir(path)
+	for i := range batch.Tasks {
+		t := &batch.Tasks[i]
+		if t.Prompt == "" {
+			return nil, fmt.Errorf("task %d has no prompt", i+1)
+		}
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("task-%d", i+1)
+		}
+		t.Dir = resolveRelative(base, t.Dir)
+		if t.OutputSchema != "" {
+			t.OutputSchema = resolveRelative(base, t.OutputSchema)
+		}
+	}
+
+	return &batch, nil
+}
+
+// resolveRelative makes path absolute, since tasks run in other directories.
+func resolveRelative(base, path string) string {
+	if path == "" {
+		path = "."
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// runBatchTask runs a task in its own tinker process, so tasks can work
+// in different directories at the same time.
+func runBatchTask(self string, task batchTask, worktree bool) (result batchResult) {
+	result = batchResult{Name: task.Name, Dir: task.Dir}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	dir := task.Dir
+	if worktree {
+		wt, err := addWorktree(task.Dir, task.Name)
+		if err != nil {
+			result.ExitCode = -1
+			result.Error = err.Error()
+			return result
+		}
+		dir, result.Worktree = wt, wt
+	}
+
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(self, batchArgs(task)...)
+	c.Dir = dir
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	return batchOutcome(result, c.Run(), stdout.Bytes(), stderr.String())
+}
+
+// batchArgs are the arguments of the headless run of task, passing on the
+// flags of the batch.
+func batchArgs(task batchTask) []string {
+	args := []string{"--provider", provider, "--model", modelName, "--verbosity", verbosity}
+	if language != "" {
+		args = append(args, "--language", language)
+	}
+	if profileName != "" {
+		args = append(args, "--profile", profileName)
+	}
+	if sessionsDir != "" {
+		args = append(args, "--sessions-dir", resolveRelative(".", sessionsDir))
+	} else if dir, ok := projectSessionsDir(task.Dir); ok {
+		// Worktrees are outside the project, so its sessions are found from the source
+		args = append(args, "--sessions-dir", dir)
+	}
+	if task.OutputSchema != "" {
+		args = append(args, "--output-schema", task.OutputSchema)
+	}
+	// Worktrees live in temp dirs, so the decision for the source folder is passed on
+	if task.trusted {
+		args = append(args, "--trust")
+	}
+	// The prompt may start with a dash
+	args = append(args, "--", task.Prompt)
+	return args
+}
+
+// batchOutcome completes result with how its run ended: runErr, the JSON
+// runResult on stdout, or the last line of stderr when it failed.
+func batchOutcome(result batchResult, runErr error, stdout []byte, stderr string) batchResult {
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode = -1
+		}
+		result.Error = lastLine(stderr)
+		if result.Error == "" {
+			result.Error = runErr.Error()
+		}
+		return result
+	}
+
+	var run runResult
+	if err := json.Unmarshal(stdout, &run); err != nil {
+		result.ExitCode = -1
+		result.Error = fmt.Sprintf("parse run output: %v", err)
+		return result
+	}
+	result.SessionID = run.SessionID
+	result.Response = run.Response
+	result.Output = run.Output
+	return result
+}
+
+// summarizeBatch counts the tasks of results that succeeded and failed.
+func summarizeBatch(results []batchResult) batchSummary {
+	summary := batchSummary{Results: results}
+	for _, r := range results {
+		if r.ExitCode == 0 {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// addWorktree checks out HEAD of the repository at dir into a fresh worktree.
+// Worktrees are left in place so their changes can be reviewed.
+func addWorktree(dir, name string) (string, error) {
+	parent, err := os.MkdirTemp("", "tinker-batch-")
+	if err != nil {
+		return "", fmt.Errorf("create worktree dir: %w", err)
+	}
+	path := filepath.Join(parent, name)
+
+	out, err := exec.Command("git", "-C", dir, "worktree", "add", "--detach", path, "HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git worktree add: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return path, nil
+}
+
+func printBatchSummary(out io.Writer, summary batchSummary) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tSTATUS\tDURATION\tSESSION\tDETAIL")
+	for _, r := range summary.Results {
+		status, detail := "ok", r.Worktree
+		if r.ExitCode != 0 {
+			status = fmt.Sprintf("exit %d", r.ExitCode)
+			detail = r.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1fs\t%s\t%s\n", r.Name, status, r.Duration.Seconds(), r.SessionID, detail)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(out, "\n%d succeeded, %d failed\n", summary.Succeeded, summary.Failed)
+}
+
+func lastLine(s string) string {
+	lines := bytes.Split(bytes.TrimSpace([]byte(s)), []byte("\n"))
+	return string(lines[len(lines)-1])
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useBatchFlags sets the flags a batch passes on to its runs for the test.
+func useBatchFlags(t *testing.T, sessions string) {
+	t.Helper()
+	savedProvider, savedModel, savedVerbosity, savedLanguage, savedProfile, savedSessions := provider, modelName, verbosity, language, profileName, sessionsDir
+	t.Cleanup(func() {
+		provider, modelName, verbosity, language, profileName, sessionsDir = savedProvider, savedModel, savedVerbosity, savedLanguage, savedProfile, savedSessions
+	})
+	provider, modelName, verbosity, language, profileName, sessionsDir = "anthropic", "claude-sonnet-4-6", "standard", "", "", sessions
+}
+
+func TestBatchArgs(t *testing.T) {
+	sessions := t.TempDir()
+	useBatchFlags(t, sessions)
+
+	tests := []struct {
+		name string
+		task batchTask
+		want []string
+	}{
+		{
+			name: "untrusted",
+			task: batchTask{Prompt: "fix the build"},
+			want: []string{"--provider", "anthropic", "--model", "claude-sonnet-4-6", "--verbosity", "standard", "--sessions-dir", sessions, "--", "fix the build"},
+		},
+		{
+			name: "trusted with schema",
+			task: batchTask{Prompt: "summarize", OutputSchema: "/tmp/schema.json", trusted: true},
+			want: []string{"--provider", "anthropic", "--model", "claude-sonnet-4-6", "--verbosity", "standard", "--sessions-dir", sessions, "--output-schema", "/tmp/schema.json", "--trust", "--", "summarize"},
+		},
+		{
+			// Not taken for a flag of the run
+			name: "prompt starting with a dash",
+			task: batchTask{Prompt: "--help is out of date, update it"},
+			want: []string{"--provider", "anthropic", "--model", "claude-sonnet-4-6", "--verbosity", "standard", "--sessions-dir", sessions, "--", "--help is out of date, update it"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, batchArgs(tt.task))
+		})
+	}
+}
+
+func TestBatchOutcome(t *testing.T) {
+	exit3 := exec.Command("sh", "-c", "exit 3").Run()
+	require.Error(t, exit3)
+
+	tests := []struct {
+		name   string
+		runErr error
+		stdout string
+		stderr string
+		want   batchResult
+	}{
+		{
+			name:   "succeeded",
+			stdout: `{"session_id": "s-1", "response": "done", "output": {"ok": true}}`,
+			want:   batchResult{Name: "t", SessionID: "s-1", Response: "done", Output: []byte(`{"ok": true}`)},
+		},
+		{
+			name:   "exit code and last line of stderr",
+			runErr: exit3,
+			stderr: "thinking\nError: create model: ANTHROPIC_API_KEY not set\n",
+			want:   batchResult{Name: "t", ExitCode: 3, Error: "Error: create model: ANTHROPIC_API_KEY not set"},
+		},
+		{
+			name:   "not started",
+			runErr: errors.New("fork/exec tinker: no such file or directory"),
+			want:   batchResult{Name: "t", ExitCode: -1, Error: "fork/exec tinker: no such file or directory"},
+		},
+		{
+			name:   "output is not a run result",
+			stdout: "hello",
+			want:   batchResult{Name: "t", ExitCode: -1, Error: "parse run output: invalid character 'h' looking for beginning of value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, batchOutcome(batchResult{Name: "t"}, tt.runErr, []byte(tt.stdout), tt.stderr))
+		})
+	}
+}
+
+func TestSummarizeBatch(t *testing.T) {
+	summary := summarizeBatch([]batchResult{{Name: "a"}, {Name: "b", ExitCode: 1}, {Name: "c", ExitCode: -1}})
+	assert.Equal(t, 1, summary.Succeeded)
+	assert.Equal(t, 2, summary.Failed)
+	assert.Len(t, summary.Results, 3)
+}
+
+func TestRunBatchTask_Worktree(t *testing.T) {
+	useBatchFlags(t, t.TempDir())
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	// Answers with where it ran and the prompt it got, its last argument
+	self := filepath.Join(t.TempDir(), "tinker")
+	script := "#!/bin/sh\nfor a; do prompt=$a; done\nprintf '{\"session_id\": \"s-1\", \"response\": \"%s: %s\"}' \"$PWD\" \"$prompt\"\n"
+	require.NoError(t, os.WriteFile(self, []byte(script), 0o755))
+
+	result := runBatchTask(self, batchTask{Name: "docs", Prompt: "-v does nothing", Dir: repo}, true)
+	require.Zero(t, result.ExitCode, result.Error)
+	t.Cleanup(func() {
+		exec.Command("git", "-C", repo, "worktree", "remove", "--force", result.Worktree).Run()
+		os.RemoveAll(filepath.Dir(result.Worktree))
+	})
+
+	assert.Equal(t, repo, result.Dir)
+	assert.Equal(t, "docs", filepath.Base(result.Worktree))
+	assert.NotEqual(t, repo, result.Worktree)
+	wt, err := filepath.EvalSymlinks(result.Worktree)
+	require.NoError(t, err)
+	dir, prompt, ok := strings.Cut(result.Response, ": ")
+	require.True(t, ok, result.Response)
+	assert.Equal(t, wt, dir, "the run works in the worktree")
+	assert.Equal(t, "-v does nothing", prompt)
+	assert.Equal(t, "s-1", result.SessionID)
+}
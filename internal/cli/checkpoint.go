@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/honganh1206/tinker/internal/editor"
+	"github.com/spf13/cobra"
+)
+
+// Checkpoints are commits of the working tree kept under these refs, out of
+// the branches, tags and reflog of the repository
+const checkpointRefs = "refs/tinker/checkpoints/"
+
+// Print what a checkpoint diff changes instead of the diff
+var checkpointStat bool
+
+// CheckpointSaveHandler snapshots the working tree, untracked files
+// included, under a name to diff against later.
+func CheckpointSaveHandler(cmd *cobra.Command, args []string) error {
+	ref, err := checkpointRef(args[0])
+	if err != nil {
+		return err
+	}
+	tree, err := worktreeTree()
+	if err != nil {
+		return err
+	}
+	// Checkpoints are not the user's commits, so they need no identity of theirs
+	env := []string{
+		"GIT_AUTHOR_NAME=tinker", "GIT_AUTHOR_EMAIL=tinker@localhost",
+		"GIT_COMMITTER_NAME=tinker", "GIT_COMMITTER_EMAIL=tinker@localhost",
+	}
+	commit, err := gitEnv(env, "commit-tree", tree, "-m", "tinker checkpoint "+args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := git("update-ref", ref, strings.TrimSpace(commit)); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Saved checkpoint %s, see what changed since with `tinker checkpoint diff %s`\n", args[0], args[0])
+	return nil
+}
+
+// CheckpointDiffHandler prints the changes of the working tree since a
+// checkpoint, across however many runs made them.
+func CheckpointDiffHandler(cmd *cobra.Command, args []string) error {
+	ref, err := checkpointRef(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := git("rev-parse", "--verify", "--quiet", ref); err != nil {
+		return fmt.Errorf("no checkpoint %s, list them with `tinker checkpoint list`", args[0])
+	}
+	tree, err := worktreeTree()
+	if err != nil {
+		return err
+	}
+	diff, err := git("diff", "--no-color", "--no-ext-diff", ref+"^{tree}", tree)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case diff == "":
+		fmt.Fprintf(out, "Nothing changed since checkpoint %s\n", args[0])
+	case checkpointStat:
+		fmt.Fprintln(out, symbols(editor.Stat(diff).String()))
+	default:
+		fmt.Fprint(out, diff)
+	}
+	return nil
+}
+
+func CheckpointListHandler(cmd *cobra.Command, args []string) error {
+	if _, err := git("rev-parse", "--git-dir"); err != nil {
+		return errors.New("checkpoints need a git repository")
+	}
+	refs, err := git("for-each-ref", "--sort=-committerdate", "--format=%(refname:lstrip=3)\t%(committerdate:relative)", checkpointRefs)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if strings.TrimSpace(refs) == "" {
+		fmt.Fprintln(out, "No checkpoints yet. Save one with `tinker checkpoint save <name>`.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSAVED")
+	fmt.Fprint(tw, refs)
+	return tw.Flush()
+}
+
+func CheckpointRemoveHandler(cmd *cobra.Command, args []string) error {
+	ref, err := checkpointRef(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := git("rev-parse", "--verify", "--quiet", ref); err != nil {
+		return fmt.Errorf("no checkpoint %s", args[0])
+	}
+	_, err = git("update-ref", "-d", ref)
+	return err
+}
+
+// checkpointRef returns the ref of the checkpoint name, which must be a valid
+// ref name in a git repository.
+func checkpointRef(name string) (string, error) {
+	if _, err := git("rev-parse", "--git-dir"); err != nil {
+		return "", errors.New("checkpoints need a git repository")
+	}
+	ref := checkpointRefs + name
+	if _, err := git("check-ref-format", ref); err != nil {
+		return "", fmt.Errorf("invalid checkpoint name %q", name)
+	}
+	return ref, nil
+}
+
+// worktreeTree writes the working tree as git would commit it with every
+// change added, ignored files left out, and returns the tree. A copy of the
+// index is used, so neither it nor the files are touched.
+func worktreeTree() (string, error) {
+	tmp, err := os.MkdirTemp("", "tinker-checkpoint-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	index := filepath.Join(tmp, "index")
+
+	// Starting from the real index spares hashing the files it knows unchanged
+	if path, err := git("rev-parse", "--path-format=absolute", "--git-path", "index"); err == nil {
+		if data, err := os.ReadFile(strings.TrimSpace(path)); err == nil {
+			if err := os.WriteFile(index, data, 0o600); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// Without paths, add covers the whole working tree from any directory of it
+	env := []string{"GIT_INDEX_FILE=" + index}
+	if _, err := gitEnv(env, "add", "--all"); err != nil {
+		return "", err
+	}
+	tree, err := gitEnv(env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
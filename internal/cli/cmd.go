@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/honganh1206/tinker/internal/mcp"
+	"github.com/honganh1206/tinker/internal/model"
 	"github.com/spf13/cobra"
 )
 
@@ -91,25 +93,497 @@ Examples:
 		RunE: MCPHandler,
 	}
 
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show usage, latency and throughput stats across sessions",
+		RunE:  StatsHandler,
+	}
+	statsCmd.Flags().BoolVar(&statsTools, "tools", false, "Show the calls, failure rate and median latency of each tool instead")
+
+	modelCmd := &cobra.Command{
+		Use:   "model",
+		Short: "List the known models with their capabilities and prices",
+		Args:  cobra.NoArgs,
+		RunE:  ModelHandler,
+	}
+
+	batchCmd := &cobra.Command{
+		Use:   "batch <tasks.yaml>",
+		Short: "Run a list of prompts headless and report their results",
+		Long: `Run every task of a YAML batch file as a headless run, optionally in parallel
+and each in its own git worktree, then print a summary of their exit statuses.
+
+Example batch file:
+  parallel: 4
+  worktrees: true
+  tasks:
+    - name: bump-go
+      dir: ../service-a
+      prompt: "bump the go directive to 1.25 and fix the build"
+    - name: bump-go-b
+      dir: ../service-b
+      prompt: "bump the go directive to 1.25 and fix the build"`,
+		Args: cobra.ExactArgs(1),
+		RunE: BatchHandler,
+	}
+	batchCmd.Flags().StringVar(&batchReport, "report", "", "Write the full JSON report to this file")
+
+	evalCmd := &cobra.Command{
+		Use:   "eval <suite.yaml>",
+		Short: "Compare models on a suite of tasks checked by scripts",
+		Long: `Run every task of a YAML suite against every model, each on a fresh copy of
+its repository, then run the task's check in the copy. A check exiting with 0
+passes the run. Prints the result of every run and, per model, the pass rate,
+total duration and cost.
+
+Example suite:
+  models:
+    - anthropic/claude-sonnet-4-6
+    - gemini/gemini-2.5-pro
+  parallel: 2
+  tasks:
+    - name: fix-failing-test
+      repo: fixtures/calculator
+      prompt: "go test fails, fix the bug in the code, not the test"
+      check: go test ./...
+      timeout: 5m`,
+		Args: cobra.ExactArgs(1),
+		RunE: EvalHandler,
+	}
+	evalCmd.Flags().StringSliceVar(&evalModels, "models", nil, "Models to compare, as provider/model, instead of the suite's")
+	evalCmd.Flags().StringVar(&evalReport, "report", "", "Write the full JSON report to this file")
+
+	commitCmd := &cobra.Command{
+		Use:   "commit",
+		Short: "Write a commit message for the staged changes",
+		Args:  cobra.NoArgs,
+		RunE:  CommitHandler,
+	}
+	commitCmd.Flags().BoolVar(&applyChange, "apply", false, "Create the commit instead of printing the message")
+
+	prCmd := &cobra.Command{
+		Use:   "pr",
+		Short: "Write a pull request description for the current branch",
+		Args:  cobra.NoArgs,
+		RunE:  PRHandler,
+	}
+	prCmd.Flags().BoolVar(&applyChange, "apply", false, "Open the pull request with the gh CLI")
+	prCmd.Flags().StringVar(&prBase, "base", "", "Branch to compare against (default origin/HEAD, else main)")
+
+	fromIssueCmd := &cobra.Command{
+		Use:   "from-issue <url|number>",
+		Short: "Plan the resolution of a GitHub issue, and work on it with --start",
+		Long: `Fetch a GitHub issue and its comments with the gh CLI, or with the REST API and
+GITHUB_TOKEN when gh is not installed, and start a session asking for a plan to
+resolve it. With --start the agent carries the plan out in the same session.
+The session ID, plan and final answer are printed as JSON on stdout.`,
+		Args: cobra.ExactArgs(1),
+		RunE: FromIssueHandler,
+	}
+	fromIssueCmd.Flags().BoolVar(&startWork, "start", false, "Work on the plan once it is written")
+
+	reviewCmd := &cobra.Command{
+		Use:   "review",
+		Short: "Review a diff and report findings",
+		Long: `Review a diff with read-only tools and report findings by file, line and severity.
+Without --diff the uncommitted changes are reviewed.`,
+		Args: cobra.NoArgs,
+		RunE: ReviewHandler,
+	}
+	reviewCmd.Flags().StringVar(&reviewRange, "diff", "", "Revision range to review, e.g. main...HEAD")
+	reviewCmd.Flags().BoolVar(&reviewJSON, "json", false, "Print the findings as JSON")
+
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Explore the repository and write a TINKER.md context file",
+		Long: `Explore the repository in the working directory and write what was learned
+(build and test commands, structure, conventions) to TINKER.md. Sessions started
+in a directory with a TINKER.md include it in their system prompt.`,
+		Args: cobra.NoArgs,
+		RunE: InitHandler,
+	}
+	initCmd.Flags().BoolVar(&forceInit, "force", false, "Overwrite an existing TINKER.md")
+
+	upgradeCmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade tinker to the latest release",
+		Args:  cobra.NoArgs,
+		RunE:  UpgradeHandler,
+	}
+	upgradeCmd.Flags().BoolVar(&checkOnly, "check", false, "Only report whether a newer release exists")
+
+	trustCmd := &cobra.Command{
+		Use:   "trust [dir]",
+		Short: "Trust a folder so runs there get tools that modify files",
+		Long: `Record that tinker may modify files in a folder, the working directory by default.
+Runs in folders that are not trusted only get read-only tools. The first run in a
+new folder asks, this command changes the answer later.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: TrustHandler,
+	}
+	trustCmd.Flags().BoolVar(&revokeTrust, "revoke", false, "Mark the folder untrusted instead")
+
+	telemetryCmd := &cobra.Command{
+		Use:   "telemetry",
+		Short: "Show or change opt-in usage telemetry",
+		Long: `Telemetry is off unless turned on. When on, every command records its name, the
+names of the flags it was given and the step it failed at, if any, to
+~/.tinker/telemetry. Flag values, prompts and file contents are never recorded.
+Events are sent nowhere unless an endpoint is configured.`,
+		RunE: TelemetryShowHandler,
+	}
+	telemetryOnCmd := &cobra.Command{
+		Use:   "on",
+		Short: "Start recording usage telemetry",
+		Args:  cobra.NoArgs,
+		RunE:  TelemetryOnHandler,
+	}
+	telemetryOnCmd.Flags().StringVar(&telemetryEndpoint, "endpoint", "", "Also POST every event as JSON to this URL")
+	telemetryOffCmd := &cobra.Command{
+		Use:   "off",
+		Short: "Stop recording usage telemetry",
+		Args:  cobra.NoArgs,
+		RunE:  TelemetryOffHandler,
+	}
+	telemetryOffCmd.Flags().BoolVar(&clearTelemetry, "clear", false, "Also delete the recorded events")
+	telemetryCmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Show the telemetry setting and recorded usage",
+		Args:  cobra.NoArgs,
+		RunE:  TelemetryShowHandler,
+	}, telemetryOnCmd, telemetryOffCmd)
+
 	mcpCmd.Flags().StringVar(&mcpServerCmd, "server-cmd", "", "Server configuration in format id:command (e.g., 'my-server:uvx mcp-server-fetch')")
 
+	setupCmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Choose a provider, API key and default model",
+		Long: `Ask for the provider, its API key and the default model and save them to
+~/.tinker/config.json. The key is checked with the provider first. Keys in the
+environment take precedence over the saved one, and --provider and --model over
+the saved defaults. The first run without a key starts this automatically.`,
+		Args: cobra.NoArgs,
+		RunE: SetupHandler,
+	}
+
+	templatesCmd := &cobra.Command{
+		Use:   "templates",
+		Short: "Manage reusable prompt templates",
+		Long: `Manage prompt templates kept in ~/.tinker/templates.db. Templates hold {{name}}
+placeholders filled in with --var when they are run:
+
+  tinker templates add tests "Write table-driven tests for {{file}}"
+  tinker --template tests --var file=internal/storage/usage.go`,
+		Args: cobra.NoArgs,
+		RunE: TemplatesListHandler,
+	}
+	templatesAddCmd := &cobra.Command{
+		Use:   "add <name> [text]",
+		Short: "Save a template, replacing one with the same name",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  TemplatesAddHandler,
+	}
+	templatesAddCmd.Flags().StringVar(&templateFile, "file", "", "Read the template text from a file, - for stdin")
+	templatesCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List templates and their variables",
+		Args:  cobra.NoArgs,
+		RunE:  TemplatesListHandler,
+	}, templatesAddCmd, &cobra.Command{
+		Use:   "show <name>",
+		Short: "Print a template",
+		Args:  cobra.ExactArgs(1),
+		RunE:  TemplatesShowHandler,
+	}, &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Delete a template",
+		Args:  cobra.ExactArgs(1),
+		RunE:  TemplatesRemoveHandler,
+	})
+
+	checkpointCmd := &cobra.Command{
+		Use:   "checkpoint",
+		Short: "Save the working tree under a name and diff against it later",
+		Long: `Checkpoints snapshot the working tree of a git repository, untracked files
+included, without touching the index, the branches or the files:
+
+  tinker checkpoint save before-refactor
+  tinker "split the storage package"
+  tinker "now update its callers"
+  tinker checkpoint diff before-refactor`,
+	}
+	checkpointDiffCmd := &cobra.Command{
+		Use:   "diff <name>",
+		Short: "Show what changed since a checkpoint",
+		Args:  cobra.ExactArgs(1),
+		RunE:  CheckpointDiffHandler,
+	}
+	checkpointDiffCmd.Flags().BoolVar(&checkpointStat, "stat", false, "Only count the files and lines changed")
+	checkpointCmd.AddCommand(&cobra.Command{
+		Use:   "save <name>",
+		Short: "Snapshot the working tree, replacing a checkpoint with the same name",
+		Args:  cobra.ExactArgs(1),
+		RunE:  CheckpointSaveHandler,
+	}, checkpointDiffCmd, &cobra.Command{
+		Use:   "list",
+		Short: "List checkpoints, most recent first",
+		Args:  cobra.NoArgs,
+		RunE:  CheckpointListHandler,
+	}, &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Delete a checkpoint",
+		Args:  cobra.ExactArgs(1),
+		RunE:  CheckpointRemoveHandler,
+	})
+
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Back up, compact and check the session store",
+		Long: `Maintain the SQLite session files in the sessions directory. Sessions written by
+an older version are also copied to its backups directory before their schema
+is upgraded.`,
+	}
+	dbCmd.AddCommand(&cobra.Command{
+		Use:   "backup [dir]",
+		Short: "Copy every session to a directory (default ~/.tinker/backups/sessions-<time>)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  DBBackupHandler,
+	}, &cobra.Command{
+		Use:   "vacuum",
+		Short: "Reclaim the space left by deleted conversations",
+		Args:  cobra.NoArgs,
+		RunE:  DBVacuumHandler,
+	}, &cobra.Command{
+		Use:   "check",
+		Short: "Run an integrity check on every session",
+		Args:  cobra.NoArgs,
+		RunE:  DBCheckHandler,
+	})
+
+	encryptCmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Show or change encryption of session payloads",
+		Long: `Encrypt the prompts, answers and tool output stored in sessions with AES-256-GCM.
+The key is derived from a passphrase, asked for on the terminal or read from
+TINKER_PASSPHRASE, or generated and kept in the OS keychain (macOS Keychain,
+libsecret on Linux). Records written before encryption was turned on stay
+readable as they are.`,
+		RunE: EncryptShowHandler,
+	}
+	encryptOnCmd := &cobra.Command{
+		Use:   "on",
+		Short: "Encrypt session payloads written from now on",
+		Args:  cobra.NoArgs,
+		RunE:  EncryptOnHandler,
+	}
+	encryptOnCmd.Flags().BoolVar(&useKeychain, "keychain", false, "Keep a generated key in the OS keychain instead of asking for a passphrase")
+	encryptCmd.AddCommand(encryptOnCmd, &cobra.Command{
+		Use:   "off",
+		Short: "Stop encrypting new session payloads",
+		Args:  cobra.NoArgs,
+		RunE:  EncryptOffHandler,
+	})
+
+	conversationCmd := &cobra.Command{
+		Use:   "conversation",
+		Short: "Manage stored conversations",
+	}
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete or archive old conversations",
+		Long: `Delete the sessions the retention policy does not keep, or move them to the
+archive directory of the sessions directory. The policy is the "retention" section
+of ~/.tinker/config.json, which the API server also applies on startup:
+
+  "retention": {"max_age_days": 90, "keep_last": 200, "archive": true}
+
+Flags override the saved policy for one run.`,
+		Args: cobra.NoArgs,
+		RunE: ConversationPruneHandler,
+	}
+	pruneCmd.Flags().Int("max-age-days", 0, "Prune sessions without activity for this many days")
+	pruneCmd.Flags().Int("keep-last", 0, "Keep only this many most recently active sessions")
+	pruneCmd.Flags().Bool("archive", false, "Move pruned sessions to the archive directory instead of deleting them")
+	pruneCmd.Flags().BoolVar(&dryRunPrune, "dry-run", false, "Only list the sessions that would be pruned")
+	replayConversationCmd := &cobra.Command{
+		Use:   "replay <id>",
+		Short: "Play a stored conversation back turn by turn, as it happened",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ConversationReplayHandler,
+	}
+	replayConversationCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Play back this many times faster than recorded, 0 for no pauses")
+	replayConversationCmd.Flags().DurationVar(&replayMaxPause, "max-pause", 3*time.Second, "Longest pause between two steps, so idle hours are skipped")
+	importConversationCmd := &cobra.Command{
+		Use:   "import <log>...",
+		Short: "Import conversations from Claude Code or aider logs",
+		Long: `Store the conversations of Claude Code session logs (~/.claude/projects/<dir>/<id>.jsonl)
+or aider chat histories (.aider.chat.history.md) as tinker sessions, with the
+directory they ran in and, from Claude Code, their times and token counts.
+Conversations imported before are skipped.
+
+With --memory, the CLAUDE.md or CONVENTIONS.md of the directory is copied to
+TINKER.md when it has none, so new sessions there know the project as well.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: ConversationImportHandler,
+	}
+	importConversationCmd.Flags().StringVar(&importFrom, "from", "", "Format of the logs, claude-code or aider (default from their names)")
+	importConversationCmd.Flags().BoolVar(&importMemory, "memory", false, "Copy the agent's project instructions to TINKER.md")
+	conversationCmd.AddCommand(pruneCmd, replayConversationCmd, importConversationCmd)
+
+	userCmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage the people the API server is shared with",
+		Long: `Without users the API server serves your sessions to anyone who can reach it.
+Once a user is added it requires a token, and each user only sees the sessions
+in their own directory, users/<name> in the sessions directory. Channel senders
+registered with --sender have their conversations kept there by the runner.`,
+		RunE: UserListHandler,
+	}
+	userAddCmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add a user and print their API token",
+		Args:  cobra.ExactArgs(1),
+		RunE:  UserAddHandler,
+	}
+	userAddCmd.Flags().StringSliceVar(&userSenders, "sender", nil, "Channel sender whose conversations belong to the user, as channel:senderId (repeatable)")
+	userCmd.AddCommand(userAddCmd, &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Revoke a user's token, keeping their sessions",
+		Args:  cobra.ExactArgs(1),
+		RunE:  UserRemoveHandler,
+	})
+
+	scheduleCmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Manage prompts run on a schedule",
+		Long: `Schedules run a prompt headlessly in a repository at the times of a cron
+expression, such as "0 7 * * 1-5" for 7:00 on weekdays. The API server runs
+them as jobs when started with -jobs-root above the repository, and records
+the conversation of each run like any other session.`,
+		RunE: ScheduleListHandler,
+	}
+	scheduleAddCmd := &cobra.Command{
+		Use:   "add <cron>",
+		Short: "Run a prompt at the times of a cron expression",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ScheduleAddHandler,
+	}
+	scheduleAddCmd.Flags().StringVar(&schedulePrompt, "prompt", "", "Prompt to run")
+	scheduleAddCmd.Flags().StringVar(&scheduleRepo, "repo", ".", "Repository the prompt runs in")
+	scheduleCmd.AddCommand(scheduleAddCmd, &cobra.Command{
+		Use:   "list",
+		Short: "List schedules with their next and last runs",
+		Args:  cobra.NoArgs,
+		RunE:  ScheduleListHandler,
+	}, &cobra.Command{
+		Use:   "remove <id>",
+		Short: "Remove a schedule, keeping the sessions of its runs",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ScheduleRemoveHandler,
+	})
+
+	projectCmd := &cobra.Command{
+		Use:   "project",
+		Short: "Manage projects and their defaults",
+		Long: `A project is a directory, usually a repository root, whose runs share defaults.
+Runs anywhere inside it use the project's provider, model, language and
+verbosity unless a profile or flag says otherwise, and their conversations are
+recorded as part of it. Set the defaults with the --provider, --model,
+--language and --verbosity flags.`,
+		RunE: ProjectListHandler,
+	}
+	projectAddCmd := &cobra.Command{
+		Use:   "add [dir]",
+		Short: "Register a directory (default the working one) as a project",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  ProjectAddHandler,
+	}
+	projectSetCmd := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Change a project's name or defaults",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ProjectSetHandler,
+	}
+	for _, c := range []*cobra.Command{projectAddCmd, projectSetCmd} {
+		c.Flags().StringVar(&projectName, "name", "", "Project name (default the directory name)")
+	}
+	projectCmd.AddCommand(projectAddCmd, projectSetCmd, &cobra.Command{
+		Use:   "show <name>",
+		Short: "Show a project's defaults and how many sessions it has",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ProjectShowHandler,
+	}, &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a project, keeping its sessions",
+		Args:  cobra.ExactArgs(1),
+		RunE:  ProjectRemoveHandler,
+	})
+
 	rootCmd := &cobra.Command{
-		Use:   "tinker",
+		Use:   "tinker [prompt]",
 		Short: "A background coding agent",
-		Long:  `Tinker is a background coding agent. Agent runs are triggered via channel messages (e.g., Discord).`,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		Long: `Tinker is a background coding agent. Agent runs are triggered via channel messages (e.g., Discord),
+or headless by passing a prompt, in which case the result is printed as JSON on stdout.`,
+		// The prompt is free text, not a subcommand name
+		Args: cobra.ArbitraryArgs,
+		RunE: RunHandler,
+		// Usage is noise for runtime failures such as a missing API key
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if configs, err := mcp.LoadConfigs(); err == nil {
 				mcpServerConfigs = configs
 				if verbose && len(configs) > 0 {
 					fmt.Printf("Loaded %d MCP server configurations\n", len(configs))
 				}
 			}
+			startUpdateCheck(cmd)
+			if err := validateNotify(); err != nil {
+				return err
+			}
+			if err := configureEncryption(); err != nil {
+				return err
+			}
+			return onboard(cmd, args)
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			printUpdateNotice(cmd)
 		},
 	}
 
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Enable verbose output")
+	rootCmd.PersistentFlags().StringVar(&sessionsDir, "sessions-dir", "", "Session store directory (default ~/.tinker/sessions)")
+	rootCmd.PersistentFlags().StringVar(&provider, "provider", string(model.ProviderAnthropic), "LLM provider (anthropic, gemini, mock)")
+	rootCmd.PersistentFlags().StringVar(&modelName, "model", string(model.Claude46Sonnet), "LLM model name")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Profile from ~/.tinker/config.json bundling model, tools and instructions")
+	rootCmd.PersistentFlags().BoolVar(&trustFolder, "trust", false, "Trust the working directory for this run without asking")
+	rootCmd.PersistentFlags().StringVar(&recordFile, "record", "", "Record the model's answers and tool results of the session to a fixture file")
+	rootCmd.PersistentFlags().StringVar(&replayFile, "replay", "", "Replay a fixture recorded with --record instead of calling the model and tools")
+	rootCmd.PersistentFlags().StringVar(&fixturesFile, "fixtures", "", "Fixture file scripting the answers of --provider mock")
+	rootCmd.PersistentFlags().BoolVar(&plain, "plain", false, "Write progress as USER:, TOOL: and ASSISTANT: lines on stderr instead of a spinner, for screen readers and logs")
+	rootCmd.PersistentFlags().StringVar(&transcriptFile, "transcript", "", "Append a markdown transcript of the conversation to this file as it happens")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+	rootCmd.PersistentFlags().IntVar(&thinkingTokens, "thinking-tokens", 0, "Let Claude think for up to this many tokens before each response and between tool calls (min 1024, default off)")
+	rootCmd.PersistentFlags().BoolVar(&debugLLM, "debug-llm", false, "Write the exact provider requests and raw responses, API keys redacted, to ~/.tinker/debug")
+	rootCmd.PersistentFlags().BoolVar(&strictSchemas, "strict-schemas", false, "Fail when a tool's input schema uses JSON schema features the provider does not support, instead of dropping them with a warning")
+	rootCmd.PersistentFlags().BoolVar(&noTools, "no-tools", false, "Answer without any tools, for explanations that need no files read or changed and fewer tokens")
+	rootCmd.PersistentFlags().StringArrayVar(&pinFiles, "pin", nil, "Keep a file in the context, read again before every model call, repeatable")
+	rootCmd.PersistentFlags().StringVar(&language, "language", "", "Language the agent answers in, e.g. Vietnamese (default: the prompt's)")
+	rootCmd.PersistentFlags().StringVar(&verbosity, "verbosity", string(model.VerbosityStandard), "Answer verbosity: concise, standard or explanatory")
+	rootCmd.PersistentFlags().StringVar(&notifyMode, "notify", notifyOff, "Notify when a run finishes: off, bell or osc9 (desktop notification)")
+	rootCmd.PersistentFlags().StringVar(&notifyCmd, "notify-cmd", "", "Shell command run when a run finishes, with the message in $TINKER_NOTIFY_MESSAGE")
+	rootCmd.PersistentFlags().DurationVar(&notifyAfter, "notify-after", 30*time.Second, "Only notify for runs that took at least this long")
+	rootCmd.Flags().StringVar(&outputSchema, "output-schema", "", "JSON schema file the final answer must conform to")
+	rootCmd.Flags().StringVar(&templateName, "template", "", "Run a saved prompt template, the prompt arguments are appended to it")
+	rootCmd.Flags().StringArrayVar(&templateVars, "var", nil, "Template variable as name=value, repeatable")
+	rootCmd.Flags().BoolVar(&editorServer, "editor-server", false, "Serve editor plugins JSON-RPC over stdio instead of running a prompt")
+
+	// Commands that call a model set up an API key on their first run
+	for _, c := range []*cobra.Command{rootCmd, batchCmd, commitCmd, prCmd, fromIssueCmd, reviewCmd, initCmd} {
+		c.Annotations = map[string]string{annotationModel: "true"}
+	}
 
-	rootCmd.AddCommand(versionCmd, mcpCmd)
+	rootCmd.AddCommand(versionCmd, mcpCmd, statsCmd, modelCmd, batchCmd, evalCmd, commitCmd, prCmd, fromIssueCmd, reviewCmd, initCmd, upgradeCmd, trustCmd, telemetryCmd, setupCmd, templatesCmd, checkpointCmd, dbCmd, encryptCmd, conversationCmd, userCmd, scheduleCmd, projectCmd)
+	instrument(rootCmd)
 
 	return rootCmd
 }
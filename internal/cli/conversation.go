@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/history"
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Retention policy of ~/.tinker/config.json, overridden by the prune flags
+	retention   storage.Retention
+	dryRunPrune bool
+	// Playback of `tinker conversation replay`: how many times faster than
+	// recorded, 0 for no pauses, and the longest pause between two records
+	replaySpeed    float64
+	replayMaxPause time.Duration
+	// Format of the logs `tinker conversation import` reads, told from their
+	// names when empty, and whether the agent's project instructions are
+	// copied to TINKER.md
+	importFrom   string
+	importMemory bool
+)
+
+// ConversationPruneHandler deletes or archives the sessions the retention
+// policy does not keep.
+func ConversationPruneHandler(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	r := retention
+	if flags.Changed("max-age-days") {
+		r.MaxAgeDays, _ = flags.GetInt("max-age-days")
+	}
+	if flags.Changed("keep-last") {
+		r.KeepLast, _ = flags.GetInt("keep-last")
+	}
+	if flags.Changed("archive") {
+		r.Archive, _ = flags.GetBool("archive")
+	}
+	if r.IsZero() {
+		return errors.New("no retention policy, pass --max-age-days or --keep-last or set retention in ~/.tinker/config.json")
+	}
+
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return err
+	}
+	pruned, err := storage.PruneSessions(dir, r, time.Now(), dryRunPrune)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for _, s := range pruned {
+		fmt.Fprintf(out, "%s  last active %s\n", s.ID, s.LastActive.Local().Format("2006-01-02 15:04"))
+	}
+	verb := "Deleted"
+	switch {
+	case dryRunPrune:
+		verb = "Would prune"
+	case r.Archive:
+		verb = "Archived"
+	}
+	fmt.Fprintf(out, "%s %d sessions\n", verb, len(pruned))
+	return nil
+}
+
+// ConversationImportHandler stores the conversations of other agents' logs
+// as tinker sessions. Conversations imported before are skipped, so a log
+// can be imported again as it grows.
+func ConversationImportHandler(cmd *cobra.Command, args []string) error {
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	memories := make(map[string]bool)
+	for _, path := range args {
+		format := history.Format(importFrom)
+		if format == "" {
+			if format, err = history.Detect(path); err != nil {
+				return err
+			}
+		}
+		convs, err := history.Read(path, format)
+		if err != nil {
+			return err
+		}
+		for _, c := range convs {
+			if err := importConversation(cmd.Context(), out, dir, c); err != nil {
+				return err
+			}
+			if importMemory && c.WorkDir != "" && !memories[c.WorkDir] {
+				memories[c.WorkDir] = true
+				if err := importMemoryFile(out, c.WorkDir, c.MemoryFile); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func importConversation(ctx context.Context, out io.Writer, dir string, c history.Conversation) error {
+	path, err := storage.SessionPath(dir, c.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(out, "Skipped %s, already imported\n", c.ID)
+		return nil
+	}
+	db, err := storage.NewSession(dir, c.ID)
+	if err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+	meta := storage.Context{StartTime: c.StartTime, WorkDir: c.WorkDir}
+	if c.WorkDir != "" {
+		if p, ok := projectOfDir(ctx, c.WorkDir); ok {
+			meta.ProjectID = p.ID
+		}
+	}
+	_, err = storage.ImportContext(ctx, db, c.ID, meta, c.Records)
+	db.Close()
+	if err != nil {
+		// A session left behind would pass for imported next time
+		storage.DeleteSession(dir, c.ID)
+		return err
+	}
+
+	turns := 0
+	for _, r := range c.Records {
+		if r.Source == storage.Prompt {
+			turns++
+		}
+	}
+	fmt.Fprintf(out, "Imported %s, %d turns from %s\n", c.ID, turns, c.StartTime.Local().Format(time.DateTime))
+	return nil
+}
+
+// importMemoryFile copies the project instructions file of another agent in
+// dir to TINKER.md, unless dir has one already.
+func importMemoryFile(out io.Writer, dir, name string) error {
+	dst := filepath.Join(dir, model.ProjectContextFile)
+	if _, err := os.Stat(dst); err == nil {
+		fmt.Fprintf(out, "Kept %s, it exists already\n", dst)
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Copied %s to %s\n", name, dst)
+	return nil
+}
+
+// ConversationReplayHandler re-renders a stored conversation turn by turn,
+// pausing between records for as long as they took, sped up by --speed.
+func ConversationReplayHandler(cmd *cobra.Command, args []string) error {
+	if replaySpeed < 0 {
+		return errors.New("--speed must not be negative")
+	}
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return err
+	}
+	session, err := storage.GetSessionHistory(cmd.Context(), dir, args[0])
+	if err != nil {
+		return err
+	}
+	return playRecords(cmd.Context(), cmd.OutOrStdout(), session.Records)
+}
+
+// playRecords writes the prompts, tool calls and answers of records to out,
+// each after the pause that preceded it.
+func playRecords(ctx context.Context, out io.Writer, records []storage.Record) error {
+	turn := 0
+	var last time.Time
+	for _, r := range records {
+		var text string
+		switch r.Source {
+		case storage.Prompt:
+			turn++
+			text = fmt.Sprintf("─── Turn %d · %s\n> %s\n",
+				turn, r.Timestamp.Local().Format(time.DateTime), strings.ReplaceAll(strings.TrimSpace(r.Content), "\n", "\n> "))
+		case storage.ToolUse:
+			block, _ := storage.DecodeToolUse(r.Content)
+			summary := r.Summary
+			if summary == "" {
+				// Records older than summaries only have the call
+				summary = block.Name
+			}
+			text = fmt.Sprintf("  %s %s\n", tools.Icon(block.Name), summary)
+		case storage.ModelResp:
+			// Turns that only call tools answer nothing
+			if strings.TrimSpace(r.Content) == "" {
+				continue
+			}
+			text = strings.TrimSpace(r.Content) + "\n\n"
+		default:
+			continue
+		}
+
+		if !last.IsZero() {
+			if err := pause(ctx, r.Timestamp.Sub(last)); err != nil {
+				return err
+			}
+		}
+		last = r.Timestamp
+		fmt.Fprint(out, symbols(text))
+	}
+	if turn == 0 {
+		fmt.Fprintln(out, "The conversation has no turns")
+	}
+	return nil
+}
+
+// pause waits for gap played at --speed, at most --max-pause.
+func pause(ctx context.Context, gap time.Duration) error {
+	if replaySpeed == 0 || gap <= 0 {
+		return nil
+	}
+	d := min(time.Duration(float64(gap)/replaySpeed), replayMaxPause)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// criticProfile is a second agent reviewing the changes of a run before it
+// finishes, on its own model and prompt. A change it does not approve is
+// revised once with its feedback.
+type criticProfile struct {
+	// The run's provider and model when empty
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Replaces the default instructions of the review
+	Instructions string `json:"instructions,omitempty"`
+}
+
+func (c criticProfile) validate() error {
+	if c.Provider != "" {
+		if _, ok := model.APIKeyEnv[model.ProviderName(c.Provider)]; !ok {
+			return fmt.Errorf("critic: unknown provider %q", c.Provider)
+		}
+	}
+	return nil
+}
+
+var criticSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"approved": map[string]any{
+			"type":        "boolean",
+			"description": "Whether the change does what was asked, correctly and in the style of the code around it",
+		},
+		"feedback": map[string]any{
+			"type":        "string",
+			"description": "What to change and why, empty when approved",
+		},
+	},
+	"required":             []string{"approved", "feedback"},
+	"additionalProperties": false,
+}
+
+const criticInstructions = `Another agent made the change below for the request that follows. Review it
+as the maintainer who has to merge it: read the changed files for context, then
+approve it only if it does what was asked, has no bugs and follows the
+conventions of the surrounding code. Otherwise give feedback the agent can act
+on, the most important problems first, and nothing about what is fine.`
+
+// criticResult is the review of a run's changes by its profile's critic
+type criticResult struct {
+	SessionID string `json:"session_id"`
+	Approved  bool   `json:"approved"`
+	Feedback  string `json:"feedback,omitempty"`
+	// The agent revised the change with the feedback
+	Revised bool `json:"revised,omitempty"`
+}
+
+// critique has the critic review diff, the change made for prompt by a run
+// on run.
+func critique(ctx context.Context, c criticProfile, run sessionSpec, prompt, diff string) (criticResult, error) {
+	sess, err := newAgentSessionWith(ctx, uuid.New().String(), tools.ReadOnly, c.spec(run))
+	if err != nil {
+		return criticResult{}, err
+	}
+	defer sess.Close()
+
+	instructions := criticInstructions
+	if c.Instructions != "" {
+		instructions = c.Instructions
+	}
+	review := fmt.Sprintf("%s\n\nRequest:\n\n%s\n\nChange:\n\n%s", instructions, prompt, fenceDiff(diff))
+	stop := sess.showProgress(ctx)
+	answer, err := sess.Agent.RunStructured(ctx, review, criticSchema)
+	stop()
+	if err != nil {
+		return criticResult{}, err
+	}
+	result := criticResult{SessionID: sess.ID}
+	if err := json.Unmarshal(answer, &result); err != nil {
+		return criticResult{}, fmt.Errorf("decode critic answer: %w", err)
+	}
+	result.Feedback = strings.TrimSpace(result.Feedback)
+	return result, nil
+}
+
+// spec is the session the critic of a run on run reviews in: its own
+// provider and model, and the workspace of the run so it reads the files
+// the run changed.
+func (c criticProfile) spec(run sessionSpec) sessionSpec {
+	spec := sessionSpec{Provider: run.Provider, Model: run.Model}
+	if c.Model != "" {
+		spec.Model = resolveModel(c.Model)
+		if p := model.ProviderOf(model.ModelVersion(spec.Model)); p != "" {
+			spec.Provider = string(p)
+		}
+	}
+	if c.Provider != "" && c.Provider != spec.Provider {
+		spec.Provider = c.Provider
+		if c.Model == "" {
+			spec.Model = string(model.DefaultModels[model.ProviderName(spec.Provider)])
+		}
+	}
+	// The run's instructions and tool choices are not the critic's
+	spec.Profile = &profile{}
+	if run.Profile != nil {
+		spec.Profile.Remote, spec.Profile.Docker = run.Profile.Remote, run.Profile.Docker
+	}
+	return spec
+}
+
+// reviewChange has the critic review diff, the change made for prompt by a
+// run on run. A change it does not approve is revised once by revise, with
+// its feedback, and not reviewed again. The review is nil if the critic
+// failed, which only warns on w since the change itself is fine.
+func reviewChange(ctx context.Context, w io.Writer, c criticProfile, run sessionSpec, prompt, diff string, revise func(prompt string) error) (*criticResult, error) {
+	result, err := critique(ctx, c, run, prompt, diff)
+	if err != nil {
+		fmt.Fprintf(w, "Critic failed, keeping the change unreviewed: %v\n", err)
+		return nil, nil
+	}
+	printCritic(w, result)
+	if !result.Approved {
+		if err := revise(revisionPrompt(result.Feedback)); err != nil {
+			return nil, err
+		}
+		result.Revised = true
+	}
+	return &result, nil
+}
+
+// printCritic tells how the critic judged the change of a run.
+func printCritic(w io.Writer, c criticResult) {
+	if c.Approved {
+		fmt.Fprintln(w, "Critic approved the change")
+		return
+	}
+	fmt.Fprintf(w, "Critic asked for changes:\n%s\n", c.Feedback)
+}
+
+// revisionPrompt asks the agent to address the critic's feedback.
+func revisionPrompt(feedback string) string {
+	return "A reviewer did not approve your change:\n\n" + feedback +
+		"\n\nAddress the feedback where it is right, then answer the original request again."
+}
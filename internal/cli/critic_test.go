@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCriticProfile_Spec(t *testing.T) {
+	docker := &dockerProfile{Image: "golang:1.25"}
+	run := sessionSpec{
+		Provider: string(model.ProviderGemini),
+		Model:    string(model.Gemini25Pro),
+		Profile:  &profile{Instructions: "Answer in French", Docker: docker},
+	}
+	tests := []struct {
+		name   string
+		critic criticProfile
+		want   sessionSpec
+	}{
+		{"run's model", criticProfile{}, sessionSpec{Provider: run.Provider, Model: run.Model}},
+		{"model implies provider", criticProfile{Model: string(model.Claude41Opus)}, sessionSpec{Provider: string(model.ProviderAnthropic), Model: string(model.Claude41Opus)}},
+		{"provider's default model", criticProfile{Provider: string(model.ProviderAnthropic)}, sessionSpec{Provider: string(model.ProviderAnthropic), Model: string(model.DefaultModels[model.ProviderAnthropic])}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.critic.spec(run)
+			assert.Equal(t, tt.want.Provider, spec.Provider)
+			assert.Equal(t, tt.want.Model, spec.Model)
+			// Same workspace, not the run's instructions
+			require.NotNil(t, spec.Profile)
+			assert.Same(t, docker, spec.Profile.Docker)
+			assert.Empty(t, spec.Profile.Instructions)
+		})
+	}
+}
+
+func TestReviewChange(t *testing.T) {
+	tests := []struct {
+		name      string
+		answer    string
+		reviseErr error
+		want      *criticResult
+		wantErr   bool
+		revisions int
+		printed   string
+	}{
+		{
+			name:    "approved",
+			answer:  `{"approved": true, "feedback": ""}`,
+			want:    &criticResult{Approved: true},
+			printed: "Critic approved the change",
+		},
+		{
+			name:      "revised once",
+			answer:    `{"approved": false, "feedback": " Handle the empty list. "}`,
+			want:      &criticResult{Feedback: "Handle the empty list.", Revised: true},
+			revisions: 1,
+			printed:   "Handle the empty list.",
+		},
+		{
+			name:      "revision fails",
+			answer:    `{"approved": false, "feedback": "Handle the empty list."}`,
+			reviseErr: errors.New("model unavailable"),
+			wantErr:   true,
+			revisions: 1,
+		},
+		{
+			name:    "critic fails",
+			answer:  "Looks good to me",
+			printed: "Critic failed, keeping the change unreviewed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMockCritic(t, tt.answer)
+
+			var prompts []string
+			revise := func(prompt string) error {
+				prompts = append(prompts, prompt)
+				return tt.reviseErr
+			}
+			var out bytes.Buffer
+			run := sessionSpec{Provider: string(model.ProviderMock)}
+			got, err := reviewChange(t.Context(), &out, criticProfile{}, run, "fix the bug", "diff --git a/x b/x\n", revise)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Len(t, prompts, tt.revisions)
+			for _, p := range prompts {
+				assert.Contains(t, p, "Handle the empty list.")
+			}
+			assert.Contains(t, out.String(), tt.printed)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.NotEmpty(t, got.SessionID)
+			got.SessionID = ""
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// useMockCritic has the mock provider answer the critic with answer, in
+// session and config directories of the test.
+func useMockCritic(t *testing.T, answer string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	fixture := filepath.Join(dir, "critic.yaml")
+	require.NoError(t, os.WriteFile(fixture, []byte("calls:\n  - response: '"+answer+"'\n"), 0o644))
+
+	savedFixtures, savedSessions, savedTrust := fixturesFile, sessionsDir, trustFolder
+	t.Cleanup(func() { fixturesFile, sessionsDir, trustFolder = savedFixtures, savedSessions, savedTrust })
+	fixturesFile, sessionsDir, trustFolder = fixture, filepath.Join(dir, "sessions"), true
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// DBBackupHandler copies every session to the given directory, by default
+// a timestamped directory under ~/.tinker/backups.
+func DBBackupHandler(cmd *cobra.Command, args []string) error {
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return err
+	}
+
+	var dest string
+	if len(args) > 0 {
+		dest = args[0]
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("get home directory: %w", err)
+		}
+		dest = filepath.Join(home, ".tinker", "backups", "sessions-"+time.Now().Format("20060102-150405"))
+	}
+
+	n, err := storage.BackupSessions(dir, dest)
+	if err != nil {
+		return fmt.Errorf("back up sessions: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Backed up %d sessions to %s\n", n, dest)
+	return nil
+}
+
+func DBVacuumHandler(cmd *cobra.Command, args []string) error {
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return err
+	}
+
+	freed, err := storage.VacuumSessions(dir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Freed %.1f KB\n", float64(freed)/1024)
+	return nil
+}
+
+func DBCheckHandler(cmd *cobra.Command, args []string) error {
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return err
+	}
+
+	problems, err := storage.CheckSessions(dir)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "All sessions are intact")
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", p.Session, p.Message)
+	}
+	return fmt.Errorf("%d problems found, restore the affected sessions from a backup", len(problems))
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/editor"
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var editorServer bool
+
+// EditorServerHandler serves editor plugins over stdio until they shut the
+// server down, answering their prompts in one session.
+func EditorServerHandler(cmd *cobra.Command) error {
+	edits := editor.NewEdits()
+	sess, err := newAgentSession(cmd.Context(), uuid.New().String(), edits.Track(tools.Builtin))
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	s := &editor.Server{
+		SessionID: sess.ID,
+		Run:       sess.Agent.Run,
+		Edits:     edits,
+	}
+	defer sess.CW.Subscribe(func(ev model.Event) {
+		if ev.Kind == model.EventFileEdit {
+			s.Edit(ev.Edit)
+		}
+	})()
+	return s.Serve(cmd.Context(), os.Stdin, os.Stdout)
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+var useKeychain bool
+
+// configureEncryption applies the saved encryption choice. The passphrase is
+// asked for on the terminal the first time a session payload needs it.
+func configureEncryption() error {
+	var ask func() (string, error)
+	if isTerminal(os.Stdin) {
+		ask = func() (string, error) { return askPassphrase("Session passphrase: ") }
+	}
+	return secret.Configure(ask)
+}
+
+// askPassphrase reads a line from the terminal without echoing it.
+func askPassphrase(prompt string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", fmt.Errorf("no terminal to ask for the passphrase, set %s", secret.PassphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	if stty("-echo") == nil {
+		defer func() {
+			stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+func EncryptShowHandler(cmd *cobra.Command, args []string) error {
+	path, err := secret.DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := secret.Load(path)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case cfg.Enabled:
+		fmt.Fprintf(out, "Session payloads are encrypted with a key from the %s\n", cfg.Mode)
+	case cfg.Mode != "":
+		fmt.Fprintf(out, "Encryption is off, sessions encrypted earlier are read with the %s key\n", cfg.Mode)
+	default:
+		fmt.Fprintln(out, "Encryption is off. Turn it on with `tinker encrypt on`.")
+	}
+	return nil
+}
+
+func EncryptOnHandler(cmd *cobra.Command, args []string) error {
+	path, err := secret.DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := secret.Load(path)
+	if err != nil {
+		return err
+	}
+
+	mode := secret.ModePassphrase
+	if useKeychain {
+		mode = secret.ModeKeychain
+	}
+	// Sessions encrypted with an earlier key must stay readable
+	if cfg.Mode != "" && cfg.Mode != mode {
+		return fmt.Errorf("sessions are already encrypted with a %s key", cfg.Mode)
+	}
+
+	if cfg.Mode == "" {
+		cfg, err = secret.New(mode, confirmPassphrase)
+		if err != nil {
+			return err
+		}
+	}
+	cfg.Enabled = true
+	if err := secret.Save(path, cfg); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), "Session payloads written from now on are encrypted")
+	if mode == secret.ModePassphrase {
+		fmt.Fprintf(cmd.OutOrStdout(), "Runs without a terminal read the passphrase from %s\n", secret.PassphraseEnv)
+	}
+	return nil
+}
+
+func EncryptOffHandler(cmd *cobra.Command, args []string) error {
+	path, err := secret.DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := secret.Load(path)
+	if err != nil {
+		return err
+	}
+	cfg.Enabled = false
+	if err := secret.Save(path, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Encryption is off, sessions encrypted earlier still need the key")
+	return nil
+}
+
+func confirmPassphrase() (string, error) {
+	if p := os.Getenv(secret.PassphraseEnv); p != "" {
+		return p, nil
+	}
+	p, err := askPassphrase("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	again, err := askPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if p != again {
+		return "", errors.New("passphrases do not match")
+	}
+	return p, nil
+}
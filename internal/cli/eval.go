@@ -0,0 +1,351 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	evalModels []string
+	evalReport string
+)
+
+// Runs and checks taking longer than this fail, unless the task sets its own
+const defaultEvalTimeout = 10 * time.Minute
+
+// evalSuite is the YAML suite consumed by `tinker eval`
+type evalSuite struct {
+	// Models every task runs against, as provider/model
+	Models []string `yaml:"models"`
+	// Number of runs at once, 1 when unset
+	Parallel int        `yaml:"parallel"`
+	Tasks    []evalTask `yaml:"tasks"`
+}
+
+type evalTask struct {
+	Name   string `yaml:"name"`
+	Prompt string `yaml:"prompt"`
+	// Directory copied afresh for every run, relative to the suite file
+	Repo string `yaml:"repo"`
+	// Shell command run in the copy once the agent is done, passing on exit 0
+	Check   string        `yaml:"check"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Script of the mock provider, relative to the suite file
+	Fixtures string `yaml:"fixtures"`
+}
+
+// evalResult is the outcome of one task against one model
+type evalResult struct {
+	Task      string        `json:"task"`
+	Model     string        `json:"model"`
+	Passed    bool          `json:"passed"`
+	Duration  time.Duration `json:"duration"`
+	Cost      float64       `json:"cost"`
+	SessionID string        `json:"session_id,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// evalModelSummary sums up the results of a model over the suite
+type evalModelSummary struct {
+	Model    string        `json:"model"`
+	Passed   int           `json:"passed"`
+	Total    int           `json:"total"`
+	PassRate float64       `json:"pass_rate"`
+	Duration time.Duration `json:"duration"`
+	Cost     float64       `json:"cost"`
+}
+
+type evalReportFile struct {
+	Models  []evalModelSummary `json:"models"`
+	Results []evalResult       `json:"results"`
+}
+
+// EvalHandler runs every task of a suite against every model, checks what
+// the agent left behind and reports pass rates, durations and costs.
+func EvalHandler(cmd *cobra.Command, args []string) error {
+	suite, err := loadEvalSuite(args[0])
+	if err != nil {
+		return err
+	}
+	if len(evalModels) > 0 {
+		suite.Models = evalModels
+	}
+	if len(suite.Models) == 0 {
+		suite.Models = []string{provider + "/" + modelName}
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate tinker executable: %w", err)
+	}
+
+	start := time.Now()
+	results := make([]evalResult, 0, len(suite.Models)*len(suite.Tasks))
+	for _, m := range suite.Models {
+		for _, t := range suite.Tasks {
+			results = append(results, evalResult{Task: t.Name, Model: m})
+		}
+	}
+	sem := make(chan struct{}, suite.Parallel)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			task := suite.Tasks[i%len(suite.Tasks)]
+			runEvalTask(cmd.Context(), self, task, &results[i])
+		}()
+	}
+	wg.Wait()
+
+	report := evalReportFile{Models: summarizeEval(suite.Models, results), Results: results}
+	printEvalReport(cmd.OutOrStdout(), report)
+	notifyDone(cmd.ErrOrStderr(), start, "tinker eval finished")
+
+	if evalReport != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal eval report: %w", err)
+		}
+		if err := os.WriteFile(evalReport, data, 0o644); err != nil {
+			return fmt.Errorf("write eval report: %w", err)
+		}
+	}
+	return nil
+}
+
+func loadEvalSuite(path string) (*evalSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read eval suite: %w", err)
+	}
+
+	var suite evalSuite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("parse eval suite %s: %w", path, err)
+	}
+
+	if len(suite.Tasks) == 0 {
+		return nil, errors.New("eval suite has no tasks")
+	}
+	if suite.Parallel <= 0 {
+		suite.Parallel = 1
+	}
+	for _, m := range suite.Models {
+		if _, _, err := parseEvalModel(m); err != nil {
+			return nil, err
+		}
+	}
+
+	base := filepath.Dir(path)
+	for i := range suite.Tasks {
+		t := &suite.Tasks[i]
+		if t.Prompt == "" {
+			return nil, fmt.Errorf("task %d has no prompt", i+1)
+		}
+		if t.Check == "" {
+			return nil, fmt.Errorf("task %d has no check", i+1)
+		}
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("task-%d", i+1)
+		}
+		if t.Timeout <= 0 {
+			t.Timeout = defaultEvalTimeout
+		}
+		t.Repo = resolveRelative(base, t.Repo)
+		if t.Fixtures != "" {
+			t.Fixtures = resolveRelative(base, t.Fixtures)
+		}
+	}
+
+	return &suite, nil
+}
+
+// parseEvalModel splits provider/model, inferring the provider of a bare
+// model name. Either may be a model alias.
+func parseEvalModel(s string) (model.ProviderName, model.ModelVersion, error) {
+	if model.ProviderName(s) == model.ProviderMock {
+		return model.ProviderMock, "", nil
+	}
+	if p, m, ok := strings.Cut(s, "/"); ok {
+		return model.ProviderName(p), model.ModelVersion(resolveModel(m)), nil
+	}
+	m := model.ModelVersion(resolveModel(s))
+	if p := model.ProviderOf(m); p != "" {
+		return p, m, nil
+	}
+	return "", "", fmt.Errorf("model %q has no provider, write it as provider/model", s)
+}
+
+// runEvalTask runs the task in its own tinker process on a fresh copy of
+// its repository, then runs the check on what the agent left.
+func runEvalTask(ctx context.Context, self string, task evalTask, result *evalResult) {
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	ctx, cancel := context.WithTimeout(ctx, task.Timeout)
+	defer cancel()
+
+	fail := func(err error) {
+		result.Error = err.Error()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.Error = fmt.Sprintf("timed out after %s", task.Timeout)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "tinker-eval-")
+	if err != nil {
+		fail(fmt.Errorf("create eval dir: %w", err))
+		return
+	}
+	defer os.RemoveAll(dir)
+	if err := copyDir(task.Repo, dir); err != nil {
+		fail(err)
+		return
+	}
+
+	p, m, _ := parseEvalModel(result.Model)
+	// The copy is made for this run only, so it is trusted
+	args := []string{"--trust", "--provider", string(p), "--model", string(m), "--verbosity", verbosity}
+	if task.Fixtures != "" {
+		args = append(args, "--fixtures", task.Fixtures)
+	}
+	if sessionsDir != "" {
+		args = append(args, "--sessions-dir", resolveRelative(".", sessionsDir))
+	}
+	args = append(args, "--", task.Prompt)
+
+	var stdout, stderr bytes.Buffer
+	c := exec.CommandContext(ctx, self, args...)
+	c.Dir = dir
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		if line := lastLine(stderr.String()); line != "" {
+			err = errors.New(line)
+		}
+		fail(err)
+		return
+	}
+
+	var run runResult
+	if err := json.Unmarshal(stdout.Bytes(), &run); err != nil {
+		fail(fmt.Errorf("parse run output: %w", err))
+		return
+	}
+	result.SessionID = run.SessionID
+	result.Cost = model.Cost(m, run.Stats.InputTokens, run.Stats.OutputTokens)
+
+	check := exec.CommandContext(ctx, "sh", "-c", task.Check)
+	check.Dir = dir
+	out, err := check.CombinedOutput()
+	if err != nil {
+		if line := lastLine(string(out)); line != "" {
+			err = fmt.Errorf("check failed: %s", line)
+		} else {
+			err = fmt.Errorf("check failed: %w", err)
+		}
+		fail(err)
+		return
+	}
+	result.Passed = true
+}
+
+// copyDir copies the files under src into dst, keeping their modes.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !d.Type().IsRegular():
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+func summarizeEval(models []string, results []evalResult) []evalModelSummary {
+	summaries := make([]evalModelSummary, len(models))
+	for i, m := range models {
+		s := &summaries[i]
+		s.Model = m
+		for _, r := range results {
+			if r.Model != m {
+				continue
+			}
+			s.Total++
+			if r.Passed {
+				s.Passed++
+			}
+			s.Duration += r.Duration
+			s.Cost += r.Cost
+		}
+		if s.Total > 0 {
+			s.PassRate = float64(s.Passed) / float64(s.Total)
+		}
+	}
+	return summaries
+}
+
+func printEvalReport(out io.Writer, report evalReportFile) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tMODEL\tRESULT\tDURATION\tCOST\tDETAIL")
+	for _, r := range report.Results {
+		status := "pass"
+		if !r.Passed {
+			status = "fail"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.1fs\t$%.4f\t%s\n", r.Task, r.Model, status, r.Duration.Seconds(), r.Cost, r.Error)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(out)
+	tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tPASSED\tPASS RATE\tDURATION\tCOST")
+	for _, s := range report.Models {
+		fmt.Fprintf(tw, "%s\t%d/%d\t%.0f%%\t%.1fs\t$%.4f\n", s.Model, s.Passed, s.Total, s.PassRate*100, s.Duration.Seconds(), s.Cost)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyChange bool
+	prBase      string
+)
+
+// Diffs beyond this are cut, the agent can read the files if it needs more
+const maxDiffBytes = 100_000
+
+var commitSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"subject": map[string]any{
+			"type":        "string",
+			"description": "Conventional commit subject, e.g. fix(storage): close rows on error",
+		},
+		"body": map[string]any{
+			"type":        "string",
+			"description": "Why the change was made, wrapped at 72 columns. Empty for trivial changes",
+		},
+	},
+	"required":             []string{"subject", "body"},
+	"additionalProperties": false,
+}
+
+var prSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"title": map[string]any{"type": "string"},
+		"body": map[string]any{
+			"type":        "string",
+			"description": "Markdown description: what changed, why, and how it was tested",
+		},
+	},
+	"required":             []string{"title", "body"},
+	"additionalProperties": false,
+}
+
+type commitMessage struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+func (m commitMessage) String() string {
+	if m.Body == "" {
+		return m.Subject
+	}
+	return m.Subject + "\n\n" + m.Body
+}
+
+type prDescription struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// CommitHandler writes a conventional commit message for the staged changes
+// and, with --apply, commits them.
+func CommitHandler(cmd *cobra.Command, args []string) error {
+	diff, err := git("diff", "--staged")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return errors.New("nothing staged to commit")
+	}
+
+	prompt := "Write a conventional commit message for this staged diff. " +
+		"Explain why the change was made rather than restating the diff.\n\n" + fenceDiff(diff)
+
+	var msg commitMessage
+	sess, err := describeChange(cmd, prompt, commitSchema, tools.ReadOnly, &msg)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	if !applyChange {
+		fmt.Fprintln(cmd.OutOrStdout(), msg)
+		return nil
+	}
+
+	c := exec.Command("git", "commit", "-F", "-")
+	c.Stdin = strings.NewReader(msg.String())
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("git commit: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	sha, err := git("rev-parse", "--short", "HEAD")
+	if err != nil {
+		return err
+	}
+	sha = strings.TrimSpace(sha)
+	if err := sess.CW.AddSystemNote(cmd.Context(), fmt.Sprintf("Created commit %s: %s", sha, msg.Subject)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s %s\n", sha, msg.Subject)
+	return nil
+}
+
+// PRHandler writes a pull request description for the commits of the current
+// branch and, with --apply, opens the pull request with the gh CLI.
+func PRHandler(cmd *cobra.Command, args []string) error {
+	base := prBase
+	if base == "" {
+		base = defaultBranch()
+	}
+
+	log, err := git("log", "--format=%s%n%n%b", base+"..HEAD")
+	if err != nil {
+		return err
+	}
+	diff, err := git("diff", base+"...HEAD")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return fmt.Errorf("no changes between %s and HEAD", base)
+	}
+
+	prompt := fmt.Sprintf("Write a pull request title and description for this branch against %s.\n\n"+
+		"Commits:\n%s\n%s", base, log, fenceDiff(diff))
+
+	var desc prDescription
+	sess, err := describeChange(cmd, prompt, prSchema, tools.ReadOnly, &desc)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	if !applyChange {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\n\n%s\n", desc.Title, desc.Body)
+		return nil
+	}
+
+	out, err := exec.Command("gh", "pr", "create", "--base", strings.TrimPrefix(base, "origin/"), "--title", desc.Title, "--body", desc.Body).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("gh pr create: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	// gh prints the pull request URL last
+	url := lastLine(string(out))
+	if err := sess.CW.AddSystemNote(cmd.Context(), fmt.Sprintf("Opened pull request %s: %s", url, desc.Title)); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), url)
+	return nil
+}
+
+// describeChange runs the agent in a fresh session and decodes its structured
+// answer into out. The session stays open so the caller can record what it did.
+func describeChange(cmd *cobra.Command, prompt string, schema map[string]any, toolset []tools.ToolDefinition, out any) (*agentSession, error) {
+	sess, err := newAgentSession(cmd.Context(), uuid.New().String(), toolset)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := sess.showProgress(cmd.Context())
+	answer, err := sess.Agent.RunStructured(cmd.Context(), prompt, schema)
+	stop()
+	if err != nil {
+		sess.Close()
+		return nil, err
+	}
+	if err := json.Unmarshal(answer, out); err != nil {
+		sess.Close()
+		return nil, fmt.Errorf("decode answer: %w", err)
+	}
+	return sess, nil
+}
+
+func fenceDiff(diff string) string {
+	if len(diff) > maxDiffBytes {
+		diff = diff[:maxDiffBytes] + "\n... (diff truncated)"
+	}
+	return "```diff\n" + diff + "\n```"
+}
+
+// defaultBranch is the branch origin/HEAD points at, main when unknown.
+func defaultBranch() string {
+	ref, err := git("symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "main"
+	}
+	return strings.TrimSpace(ref)
+}
+
+func git(args ...string) (string, error) {
+	return gitEnv(nil, args...)
+}
+
+// gitEnv runs git with env added to the environment.
+func gitEnv(env []string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	c := exec.Command("git", args...)
+	if env != nil {
+		c.Env = append(os.Environ(), env...)
+	}
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		// Only the first line, git follows usage errors with the full usage
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+	}
+	return string(out), nil
+}
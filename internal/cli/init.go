@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var forceInit bool
+
+const initPrompt = `Explore this repository and write a concise context file for future coding
+sessions in it. Find out, by reading the files rather than guessing:
+
+- what the project does and its main languages and frameworks
+- how to build, test, lint and run it, as exact commands
+- the layout of the source tree and what each top-level module is for
+- conventions a contributor must follow (error handling, naming, test layout)
+- anything surprising, such as generated code or files not to edit
+
+Reply with the Markdown content of the file only, starting with a top-level
+heading. Keep it under 150 lines.`
+
+// InitHandler explores the repository in the working directory with read-only
+// tools and writes what it learned to the project context file.
+func InitHandler(cmd *cobra.Command, args []string) error {
+	path := model.ProjectContextFile
+	if _, err := os.Stat(path); err == nil && !forceInit {
+		return fmt.Errorf("%s already exists, use --force to regenerate it", path)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	id, err := writeProjectContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s (session %s)\n", path, id)
+	return nil
+}
+
+// writeProjectContext runs the exploration and writes the project context
+// file, returning the session ID.
+func writeProjectContext(ctx context.Context) (string, error) {
+	sess, err := newAgentSession(ctx, uuid.New().String(), tools.ReadOnly)
+	if err != nil {
+		return "", err
+	}
+	defer sess.Close()
+
+	stop := sess.showProgress(ctx)
+	content, err := sess.Agent.Run(ctx, initPrompt)
+	stop()
+	if err != nil {
+		return "", err
+	}
+
+	path := model.ProjectContextFile
+	if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("write %s: %w", path, err)
+	}
+	return sess.ID, nil
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var startWork bool
+
+// Issue bodies and comments beyond this are cut, like diffs
+const maxIssueBytes = 50_000
+
+// githubIssue is the part of an issue the agent is given, in the shape
+// `gh issue view --json` prints it
+type githubIssue struct {
+	Number   int            `json:"number"`
+	Title    string         `json:"title"`
+	Body     string         `json:"body"`
+	URL      string         `json:"url"`
+	Comments []issueComment `json:"comments"`
+}
+
+type issueComment struct {
+	Author githubUser `json:"author"`
+	Body   string     `json:"body"`
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// issueResult is printed to stdout as JSON once from-issue finishes
+type issueResult struct {
+	SessionID string `json:"session_id"`
+	Issue     string `json:"issue"`
+	Plan      string `json:"plan"`
+	// Final answer of the work on the plan, with --start
+	Response string `json:"response,omitempty"`
+}
+
+var (
+	issueURLPattern = regexp.MustCompile(`github\.com/([^/]+)/([^/]+)/issues/(\d+)`)
+	remotePattern   = regexp.MustCompile(`github\.com[:/]([^/]+)/([^/]+?)(?:\.git)?$`)
+)
+
+// FromIssueHandler seeds a session with a GitHub issue and its comments, asks
+// for a plan to resolve it and, with --start, carries the plan out.
+func FromIssueHandler(cmd *cobra.Command, args []string) error {
+	issue, err := fetchIssue(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	sess, err := newAgentSession(cmd.Context(), uuid.New().String(), tools.Builtin)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	start := time.Now()
+	result := issueResult{SessionID: sess.ID, Issue: issue.URL}
+	stop := sess.showProgress(cmd.Context())
+	result.Plan, err = sess.Agent.Run(cmd.Context(), issuePrompt(issue))
+	if err == nil && startWork {
+		result.Response, err = sess.Agent.Run(cmd.Context(), "Carry out the plan. Run the tests that cover the change before you answer.")
+	}
+	stop()
+	if err != nil {
+		notifyDone(cmd.ErrOrStderr(), start, "tinker from-issue failed: "+err.Error())
+		return err
+	}
+	notifyDone(cmd.ErrOrStderr(), start, fmt.Sprintf("tinker from-issue finished for #%d", issue.Number))
+
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+func issuePrompt(issue githubIssue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Resolve GitHub issue #%d (%s) in this repository.\n\n", issue.Number, issue.URL)
+	fmt.Fprintf(&b, "# %s\n\n%s\n", issue.Title, issue.Body)
+	for _, c := range issue.Comments {
+		fmt.Fprintf(&b, "\n## Comment by %s\n\n%s\n", c.Author.Login, c.Body)
+	}
+	text := b.String()
+	if len(text) > maxIssueBytes {
+		text = text[:maxIssueBytes] + "\n... (issue truncated)"
+	}
+	return text + "\nRead the relevant code, then answer with a numbered plan of the changes " +
+		"and how to verify them. Do not change any files yet."
+}
+
+// fetchIssue reads the issue ref, a URL or a number in the repository of the
+// working directory, with the gh CLI, or with the REST API and GITHUB_TOKEN
+// when gh is not installed.
+func fetchIssue(ctx context.Context, ref string) (githubIssue, error) {
+	var issue githubIssue
+	if _, err := exec.LookPath("gh"); err == nil {
+		out, err := exec.CommandContext(ctx, "gh", "issue", "view", ref, "--json", "number,title,body,url,comments").Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return issue, fmt.Errorf("gh issue view: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return issue, fmt.Errorf("gh issue view: %w", err)
+		}
+		if err := json.Unmarshal(out, &issue); err != nil {
+			return issue, fmt.Errorf("parse issue: %w", err)
+		}
+		return issue, nil
+	}
+
+	owner, repo, number, err := parseIssueRef(ref)
+	if err != nil {
+		return issue, err
+	}
+	base := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues/%d", owner, repo, number)
+
+	var raw struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := githubGet(ctx, base, &raw); err != nil {
+		return issue, err
+	}
+	var comments []struct {
+		User githubUser `json:"user"`
+		Body string     `json:"body"`
+	}
+	if err := githubGet(ctx, base+"/comments?per_page=100", &comments); err != nil {
+		return issue, err
+	}
+
+	issue = githubIssue{Number: raw.Number, Title: raw.Title, Body: raw.Body, URL: raw.HTMLURL}
+	for _, c := range comments {
+		issue.Comments = append(issue.Comments, issueComment{Author: c.User, Body: c.Body})
+	}
+	return issue, nil
+}
+
+// parseIssueRef returns the repository and number of an issue URL, or of a
+// number in the repository the origin remote points at.
+func parseIssueRef(ref string) (owner, repo string, number int, err error) {
+	if m := issueURLPattern.FindStringSubmatch(ref); m != nil {
+		number, _ = strconv.Atoi(m[3])
+		return m[1], m[2], number, nil
+	}
+	number, err = strconv.Atoi(strings.TrimPrefix(ref, "#"))
+	if err != nil {
+		return "", "", 0, fmt.Errorf("%q is neither an issue URL nor a number", ref)
+	}
+	remote, err := git("remote", "get-url", "origin")
+	if err != nil {
+		return "", "", 0, err
+	}
+	m := remotePattern.FindStringSubmatch(strings.TrimSpace(remote))
+	if m == nil {
+		return "", "", 0, fmt.Errorf("origin %s is not a GitHub repository, pass the issue URL", strings.TrimSpace(remote))
+	}
+	return m[1], m[2], number, nil
+}
+
+func githubGet(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch issue: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch issue: %s, install gh or set GITHUB_TOKEN for private repositories", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("parse issue: %w", err)
+	}
+	return nil
+}
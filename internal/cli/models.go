@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/spf13/cobra"
+)
+
+// ModelHandler prints what each known model supports and costs, the model
+// runs use marked with a star, then the model aliases of the config.
+func ModelHandler(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tMODEL\tPROVIDER\tCONTEXT\tOUTPUT\tTOOLS\tVISION\tTHINKING\t$/MTOK IN\t$/MTOK OUT")
+	for _, v := range model.KnownModels() {
+		c, _ := model.LookupCapabilities(v)
+		current := ""
+		if string(v) == modelName {
+			current = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.3g\t%.3g\n", current, v, c.Provider,
+			tokenCount(c.ContextWindow), tokenCount(c.MaxOutputTokens),
+			yesNo(c.Tools), yesNo(c.Vision), yesNo(c.Thinking),
+			c.Pricing.InputPerMTok, c.Pricing.OutputPerMTok)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(modelAliases) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out, "\nAliases:")
+	tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, alias := range slices.Sorted(maps.Keys(modelAliases)) {
+		fmt.Fprintf(tw, "  %s\t%s\n", alias, modelAliases[alias])
+	}
+	return tw.Flush()
+}
+
+// tokenCount shortens n to thousands or millions of tokens, as models are
+// advertised: 200k, 1M.
+func tokenCount(n int) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dM", n>>20)
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n%1000 == 0:
+		return fmt.Sprintf("%dk", n/1000)
+	default:
+		return fmt.Sprintf("%dk", n>>10)
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
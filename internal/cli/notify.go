@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var (
+	notifyMode  string
+	notifyCmd   string
+	notifyAfter time.Duration
+)
+
+// Notification modes for --notify
+const (
+	notifyOff  = "off"
+	notifyBell = "bell"
+	// OSC 9 shows a desktop notification in terminals that support it
+	// (iTerm2, WezTerm, Windows Terminal, kitty) and is ignored elsewhere.
+	notifyOSC9 = "osc9"
+)
+
+func validateNotify() error {
+	switch notifyMode {
+	case notifyOff, notifyBell, notifyOSC9:
+		return nil
+	}
+	return fmt.Errorf("invalid --notify %q (expected off, bell or osc9)", notifyMode)
+}
+
+// notifyDone tells the user a run that started at start has finished, if it
+// took at least --notify-after. The terminal gets the bell or OSC 9 sequence
+// on stderr, which keeps stdout clean for JSON output, and --notify-cmd runs
+// with the message in TINKER_NOTIFY_MESSAGE.
+func notifyDone(stderr io.Writer, start time.Time, message string) {
+	if time.Since(start) < notifyAfter {
+		return
+	}
+
+	switch notifyMode {
+	case notifyBell:
+		fmt.Fprint(stderr, "\a")
+	case notifyOSC9:
+		// Terminals end the sequence at BEL, so the message must not contain one
+		fmt.Fprintf(stderr, "\x1b]9;%s\a", strings.ReplaceAll(message, "\a", ""))
+	}
+
+	if notifyCmd == "" {
+		return
+	}
+	c := exec.Command("sh", "-c", notifyCmd)
+	c.Env = append(os.Environ(), "TINKER_NOTIFY_MESSAGE="+message)
+	c.Stdout, c.Stderr = stderr, stderr
+	if err := c.Run(); err != nil {
+		fmt.Fprintf(stderr, "notify command failed: %v\n", err)
+	}
+}
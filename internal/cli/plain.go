@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/progress"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// Show progress with --plain as prefixed lines rather than a spinner
+var plain bool
+
+// Print ASCII in place of unicode symbols, set by "ascii" in config.json
+var asciiSymbols bool
+
+// symbols returns s with its unicode symbols replaced by ASCII if the config
+// asks for it.
+func symbols(s string) string {
+	if asciiSymbols {
+		return progress.ASCII(s)
+	}
+	return s
+}
+
+// plainLines returns an observer writing the conversation to w one line at a
+// time, every line prefixed by who it comes from: USER, TOOL or ASSISTANT.
+// Nothing is redrawn or colored, so screen readers read each line once and
+// log processors can split them.
+func plainLines(w io.Writer) func(model.Event) {
+	var mu sync.Mutex
+	return func(ev model.Event) {
+		var prefix, text string
+		switch ev.Kind {
+		case model.EventPrompt:
+			prefix, text = "USER", ev.Text
+		case model.EventToolEnd:
+			prefix, text = "TOOL", symbols(tools.Icon(ev.Tool)+" "+tools.FormatResult(ev.Tool, ev.Args, ev.Output, ev.Err))
+		case model.EventAnswer:
+			prefix, text = "ASSISTANT", ev.Text
+		default:
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+			fmt.Fprintln(w, strings.TrimRight(prefix+": "+line, " "))
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+var profileName string
+
+// activeProfile is the profile selected with --profile, nil without one
+var activeProfile *profile
+
+// profile bundles settings under a name in ~/.tinker/config.json, e.g. a
+// "fast" profile on a small model with only the read-only tools. Flags given
+// on the command line take precedence.
+type profile struct {
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Verbosity string `json:"verbosity,omitempty"`
+	// Added to the system prompt
+	Instructions string `json:"instructions,omitempty"`
+	// Names of the built-in tools to offer, all of them when empty
+	Tools []string `json:"tools,omitempty"`
+	// Only offer read-only tools, even in trusted folders
+	ReadOnly bool `json:"read_only,omitempty"`
+	// Host the file and bash tools run on instead of this machine
+	Remote *remoteProfile `json:"remote,omitempty"`
+	// Container the file and bash tools run in instead of this machine
+	Docker *dockerProfile `json:"docker,omitempty"`
+	// Reviews the changes of runs before they finish
+	Critic *criticProfile `json:"critic,omitempty"`
+}
+
+// remoteProfile is a workspace on another host, reached over SSH
+type remoteProfile struct {
+	// As ssh takes it, an alias of ~/.ssh/config or user@host
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"`
+	// Absolute directory the tools run in
+	Dir string `json:"dir"`
+}
+
+// dockerProfile is a workspace in a container, either running already or
+// started from an image with the working directory mounted
+type dockerProfile struct {
+	Container string `json:"container,omitempty"`
+	Image     string `json:"image,omitempty"`
+	// Absolute directory of the container the tools run in, where the
+	// working directory is mounted for images. /workspace by default.
+	Dir string `json:"dir,omitempty"`
+	// User the tools run as, e.g. "1000:1000" so files written to the
+	// mount are the user's
+	User string `json:"user,omitempty"`
+}
+
+const defaultContainerDir = "/workspace"
+
+func (p profile) validate() error {
+	if p.Provider != "" {
+		if _, ok := model.APIKeyEnv[model.ProviderName(p.Provider)]; !ok {
+			return fmt.Errorf("unknown provider %q", p.Provider)
+		}
+	}
+	if _, err := model.ParseVerbosity(p.Verbosity); err != nil {
+		return err
+	}
+	for _, name := range p.Tools {
+		if !slices.ContainsFunc(tools.Builtin, func(t tools.ToolDefinition) bool { return t.Name == name }) {
+			return fmt.Errorf("unknown tool %q", name)
+		}
+	}
+	if r := p.Remote; r != nil && (r.Host == "" || !path.IsAbs(r.Dir)) {
+		return fmt.Errorf("remote needs a host and an absolute dir")
+	}
+	if d := p.Docker; d != nil {
+		if (d.Container == "") == (d.Image == "") {
+			return fmt.Errorf("docker needs either a container or an image")
+		}
+		if d.Dir != "" && !path.IsAbs(d.Dir) {
+			return fmt.Errorf("docker dir %q is not absolute", d.Dir)
+		}
+		if p.Remote != nil {
+			return fmt.Errorf("a profile runs its tools either remotely or in docker, not both")
+		}
+	}
+	if p.Critic != nil {
+		return p.Critic.validate()
+	}
+	return nil
+}
+
+// workspace is where the profile runs the tools, nil for this machine.
+// Containers started from an image for repo are removed by the returned
+// function.
+func (p profile) workspace(ctx context.Context, repo string) (tools.Workspace, func() error, error) {
+	noop := func() error { return nil }
+	switch {
+	case p.Remote != nil:
+		return &tools.SSH{Host: p.Remote.Host, Port: p.Remote.Port, Directory: p.Remote.Dir}, noop, nil
+	case p.Docker != nil:
+		dir := p.Docker.Dir
+		if dir == "" {
+			dir = defaultContainerDir
+		}
+		if p.Docker.Container != "" {
+			return &tools.Docker{Container: p.Docker.Container, Directory: dir, User: p.Docker.User}, noop, nil
+		}
+		d, err := tools.StartContainer(ctx, p.Docker.Image, repo, dir, p.Docker.User)
+		if err != nil {
+			return nil, nil, err
+		}
+		return d, d.Close, nil
+	}
+	return nil, noop, nil
+}
+
+// toolset narrows toolset down to the tools the profile allows.
+func (p profile) toolset(toolset []tools.ToolDefinition) []tools.ToolDefinition {
+	if len(p.Tools) > 0 {
+		// Profiles written before a tool was renamed list its former name
+		toolset = slices.DeleteFunc(slices.Clone(toolset), func(t tools.ToolDefinition) bool {
+			return !slices.ContainsFunc(p.Tools, func(name string) bool {
+				current, _ := tools.Resolve(name)
+				return current == t.Name
+			})
+		})
+	}
+	if p.ReadOnly {
+		toolset = onlyReadOnly(toolset)
+	}
+	return toolset
+}
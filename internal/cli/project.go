@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// activeProject is the project of the working directory, nil outside any
+var activeProject *storage.Project
+
+var projectName string
+
+func projectsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinker", storage.ProjectsFile), nil
+}
+
+func openProjects() (*sql.DB, error) {
+	path, err := projectsPath()
+	if err != nil {
+		return nil, err
+	}
+	return storage.OpenProjects(path)
+}
+
+// workingProject returns the project of the working directory. Lookups that
+// fail only lose the project's defaults, so they are not reported.
+func workingProject(ctx context.Context) (storage.Project, bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return storage.Project{}, false
+	}
+	return projectOfDir(ctx, cwd)
+}
+
+// projectOfDir returns the project dir belongs to, like workingProject.
+func projectOfDir(ctx context.Context, dir string) (storage.Project, bool) {
+	path, err := projectsPath()
+	if err != nil {
+		return storage.Project{}, false
+	}
+	// Do not create the database for users without projects
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return storage.Project{}, false
+	}
+	db, err := storage.OpenProjects(path)
+	if err != nil {
+		return storage.Project{}, false
+	}
+	defer db.Close()
+
+	p, ok, err := storage.ProjectForDir(ctx, db, dir)
+	return p, ok && err == nil
+}
+
+// applySettingFlags sets the settings given with the --provider, --model,
+// --language and --verbosity flags, leaving the others as they are.
+func applySettingFlags(cmd *cobra.Command, s *storage.ProjectSettings) error {
+	flags := cmd.Flags()
+	for flag, f := range map[string]*string{
+		"provider":  &s.Provider,
+		"model":     &s.Model,
+		"language":  &s.Language,
+		"verbosity": &s.Verbosity,
+	} {
+		if flags.Changed(flag) {
+			*f, _ = flags.GetString(flag)
+		}
+	}
+
+	if s.Provider != "" {
+		if _, ok := model.APIKeyEnv[model.ProviderName(s.Provider)]; !ok {
+			return fmt.Errorf("unknown provider %q", s.Provider)
+		}
+	}
+	_, err := model.ParseVerbosity(s.Verbosity)
+	return err
+}
+
+func ProjectListHandler(cmd *cobra.Command, args []string) error {
+	db, err := openProjects()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	projects, err := storage.ListProjects(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	if len(projects) == 0 {
+		fmt.Fprintln(out, "No projects. Add one with `tinker project add [dir]`.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tPATH\tMODEL")
+	for _, p := range projects {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Name, p.Path, p.Settings.Model)
+	}
+	return tw.Flush()
+}
+
+// ProjectAddHandler registers a directory, the working one by default, as a
+// project named after it unless --name says otherwise.
+func ProjectAddHandler(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	name := projectName
+	if name == "" {
+		name = filepath.Base(dir)
+	}
+	var settings storage.ProjectSettings
+	if err := applySettingFlags(cmd, &settings); err != nil {
+		return err
+	}
+
+	db, err := openProjects()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	p, err := storage.CreateProject(cmd.Context(), db, name, dir, settings)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Added project %s at %s\n", p.Name, p.Path)
+	return nil
+}
+
+// ProjectShowHandler prints a project's settings and how many sessions have
+// a conversation in it.
+func ProjectShowHandler(cmd *cobra.Command, args []string) error {
+	db, err := openProjects()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	p, err := storage.GetProject(cmd.Context(), db, args[0])
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Name:      %s\n", p.Name)
+	fmt.Fprintf(out, "Path:      %s\n", p.Path)
+	for _, f := range []struct{ label, value string }{
+		{"Provider:  ", p.Settings.Provider},
+		{"Model:     ", p.Settings.Model},
+		{"Language:  ", p.Settings.Language},
+		{"Verbosity: ", p.Settings.Verbosity},
+	} {
+		if f.value != "" {
+			fmt.Fprintf(out, "%s%s\n", f.label, f.value)
+		}
+	}
+
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return err
+	}
+	ids, err := storage.ProjectSessions(cmd.Context(), dir, p.ID)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("list project sessions: %w", err)
+	}
+	fmt.Fprintf(out, "Sessions:  %d\n", len(ids))
+	return nil
+}
+
+// ProjectSetHandler changes the settings given as flags, keeping the others.
+func ProjectSetHandler(cmd *cobra.Command, args []string) error {
+	db, err := openProjects()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	p, err := storage.GetProject(cmd.Context(), db, args[0])
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("name") {
+		p.Name = projectName
+	}
+	if err := applySettingFlags(cmd, &p.Settings); err != nil {
+		return err
+	}
+
+	if err := storage.UpdateProject(cmd.Context(), db, p); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Updated project %s\n", p.Name)
+	return nil
+}
+
+func ProjectRemoveHandler(cmd *cobra.Command, args []string) error {
+	db, err := openProjects()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := storage.DeleteProject(cmd.Context(), db, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Removed project %s, its sessions are kept\n", args[0])
+	return nil
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reviewRange string
+	reviewJSON  bool
+)
+
+// Severities from most to least important
+var severities = []string{"critical", "major", "minor", "nit"}
+
+var reviewSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"summary": map[string]any{
+			"type":        "string",
+			"description": "One paragraph overall assessment of the change",
+		},
+		"findings": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"file": map[string]any{"type": "string"},
+					"line": map[string]any{
+						"type":        "integer",
+						"description": "Line in the new version of the file, 0 if the finding is not tied to a line",
+					},
+					"severity":   map[string]any{"type": "string", "enum": severities},
+					"suggestion": map[string]any{"type": "string"},
+				},
+				"required":             []string{"file", "line", "severity", "suggestion"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"summary", "findings"},
+	"additionalProperties": false,
+}
+
+const reviewPrompt = `Review the following diff as a senior engineer would.
+Look for bugs, unhandled errors, races, security issues, missing tests and
+code that does not follow the conventions of the surrounding files. Read the
+changed files for context before judging. Report only real problems with a
+concrete suggestion each, no praise and no restating of the diff.
+
+`
+
+type reviewFinding struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Severity   string `json:"severity"`
+	Suggestion string `json:"suggestion"`
+}
+
+type reviewReport struct {
+	SessionID string          `json:"session_id"`
+	Range     string          `json:"range"`
+	Summary   string          `json:"summary"`
+	Findings  []reviewFinding `json:"findings"`
+}
+
+// ReviewHandler reviews a diff with read-only tools and reports the findings.
+func ReviewHandler(cmd *cobra.Command, args []string) error {
+	// Uncommitted changes, staged or not, unless a range is given
+	rng := reviewRange
+	if rng == "" {
+		rng = "HEAD"
+	}
+
+	diff, err := git("diff", rng)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return fmt.Errorf("no changes in %s to review", rng)
+	}
+
+	report := reviewReport{Range: rng}
+	sess, err := describeChange(cmd, reviewPrompt+fenceDiff(diff), reviewSchema, tools.ReadOnly, &report)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	report.SessionID = sess.ID
+	sortFindings(report.Findings)
+
+	if reviewJSON {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printReview(cmd.OutOrStdout(), report)
+	return nil
+}
+
+// sortFindings orders findings by severity, then by position.
+func sortFindings(findings []reviewFinding) {
+	rank := func(severity string) int {
+		for i, s := range severities {
+			if s == severity {
+				return i
+			}
+		}
+		return len(severities)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if rank(a.Severity) != rank(b.Severity) {
+			return rank(a.Severity) < rank(b.Severity)
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
+
+func printReview(out io.Writer, report reviewReport) {
+	fmt.Fprintf(out, "%s\n\n", report.Summary)
+	if len(report.Findings) == 0 {
+		fmt.Fprintln(out, "No findings.")
+		return
+	}
+
+	for _, f := range report.Findings {
+		loc := f.File
+		if f.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		fmt.Fprintf(out, "[%s] %s\n  %s\n\n", f.Severity, loc, f.Suggestion)
+	}
+	fmt.Fprintf(out, "%d findings, session %s\n", len(report.Findings), report.SessionID)
+}
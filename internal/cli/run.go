@@ -0,0 +1,459 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/agent"
+	"github.com/honganh1206/tinker/internal/editor"
+	"github.com/honganh1206/tinker/internal/logger"
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/progress"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var (
+	provider     string
+	modelName    string
+	outputSchema string
+	language     string
+	verbosity    string
+	// Fixture files the session is recorded to or replayed from
+	recordFile string
+	replayFile string
+	// Script of the mock provider
+	fixturesFile string
+	// Tokens the model may think for before each response, 0 to not think
+	thinkingTokens int
+	// Threshold of Gemini's safety filters, set by "gemini_safety" in
+	// config.json. Empty keeps the API's defaults.
+	geminiSafety string
+	// Dump the provider requests and responses to ~/.tinker/debug
+	debugLLM bool
+	// Fail on tool schemas the provider only takes with constructs dropped
+	strictSchemas bool
+	// Send no tool definitions, for questions answered from the prompt alone
+	noTools bool
+	// Files sent as they are now with every model call
+	pinFiles []string
+)
+
+// Smallest thinking budget Anthropic accepts
+const minThinkingTokens = 1024
+
+// runResult is printed to stdout as JSON once a headless run finishes
+type runResult struct {
+	SessionID string `json:"session_id"`
+	Response  string `json:"response,omitempty"`
+	// Answer conforming to --output-schema
+	Output json.RawMessage   `json:"output,omitempty"`
+	Stats  storage.TurnStats `json:"stats"`
+	// Unified diff of the files the agent edited, and what it changes
+	Diff    string           `json:"diff,omitempty"`
+	Changes *editor.DiffStat `json:"changes,omitempty"`
+	// Review of the changes by the profile's critic
+	Critic *criticResult `json:"critic,omitempty"`
+}
+
+// RunHandler runs the agent on a prompt in a fresh session.
+func RunHandler(cmd *cobra.Command, args []string) error {
+	if editorServer {
+		return EditorServerHandler(cmd)
+	}
+	if len(args) == 0 && templateName == "" {
+		return cmd.Help()
+	}
+	prompt := strings.Join(args, " ")
+	if templateName != "" {
+		var err error
+		if prompt, err = templatePrompt(prompt); err != nil {
+			return err
+		}
+	}
+
+	var schema map[string]any
+	if outputSchema != "" {
+		var err error
+		if schema, err = loadSchema(outputSchema); err != nil {
+			return err
+		}
+	}
+
+	edits := editor.NewEdits()
+	sess, err := newAgentSession(cmd.Context(), uuid.New().String(), edits.Track(tools.Builtin))
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	start := time.Now()
+	result := runResult{SessionID: sess.ID}
+	run := func(prompt string) error {
+		stop := sess.showProgress(cmd.Context())
+		defer stop()
+		var err error
+		if schema != nil {
+			result.Output, err = sess.Agent.RunStructured(cmd.Context(), prompt, schema)
+		} else {
+			result.Response, err = sess.Agent.Run(cmd.Context(), prompt)
+		}
+		return err
+	}
+	if err := run(prompt); err != nil {
+		notifyDone(cmd.ErrOrStderr(), start, "tinker run failed: "+err.Error())
+		return err
+	}
+	if result.Diff, err = edits.Diff(); err != nil {
+		return err
+	}
+
+	if activeProfile != nil && activeProfile.Critic != nil && result.Diff != "" {
+		result.Critic, err = reviewChange(cmd.Context(), cmd.ErrOrStderr(), *activeProfile.Critic, runSpec(), prompt, result.Diff, run)
+		if err != nil {
+			notifyDone(cmd.ErrOrStderr(), start, "tinker run failed: "+err.Error())
+			return err
+		}
+		if result.Critic != nil && result.Critic.Revised {
+			if result.Diff, err = edits.Diff(); err != nil {
+				return err
+			}
+		}
+	}
+	notifyDone(cmd.ErrOrStderr(), start, "tinker run finished")
+	result.Stats = sess.CW.LastTurnStats()
+	if result.Diff != "" {
+		stat := editor.Stat(result.Diff)
+		result.Changes = &stat
+		fmt.Fprintln(cmd.ErrOrStderr(), symbols(stat.String()))
+	}
+
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+func loadSchema(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read output schema: %w", err)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("parse output schema %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// agentSession is an agent bound to its own session database
+type agentSession struct {
+	ID    string
+	CW    *model.ContextWindow
+	Agent *agent.Agent
+	// Releases the workspace of the profile, such as its container
+	closeWorkspace func() error
+	// With --transcript
+	transcript *transcript
+}
+
+// sessionSpec is what an agent session runs on
+type sessionSpec struct {
+	Provider string
+	Model    string
+	// Tools, instructions and workspace of the session, none when nil
+	Profile *profile
+}
+
+// runSpec is the session spec of the flags, config and profile of this run
+func runSpec() sessionSpec {
+	return sessionSpec{Provider: provider, Model: modelName, Profile: activeProfile}
+}
+
+// newAgentSession creates a session and an agent equipped with toolset,
+// or only the read-only tools if the working directory is not trusted.
+func newAgentSession(ctx context.Context, id string, toolset []tools.ToolDefinition) (*agentSession, error) {
+	return newAgentSessionWith(ctx, id, toolset, runSpec())
+}
+
+// newAgentSessionWith is newAgentSession on the provider, model and profile
+// of spec.
+func newAgentSessionWith(ctx context.Context, id string, toolset []tools.ToolDefinition, spec sessionSpec) (sess *agentSession, err error) {
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	// Without tools nothing in the folder is touched, so there is no need to ask
+	trusted := noTools
+	if !trusted {
+		if trusted, err = folderTrusted(cwd, os.Stdin, os.Stderr); err != nil {
+			return nil, fmt.Errorf("check folder trust: %w", err)
+		}
+	}
+	var ws tools.Workspace
+	closeWorkspace := func() error { return nil }
+	if spec.Profile != nil {
+		toolset = spec.Profile.toolset(toolset)
+		if ws, closeWorkspace, err = spec.Profile.workspace(ctx, cwd); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				closeWorkspace()
+			}
+		}()
+	}
+	if noTools {
+		toolset = nil
+	}
+	if !trusted && !readOnly(toolset) {
+		fmt.Fprintf(os.Stderr, "%s is not trusted, running with read-only tools (see `tinker trust`)\n", cwd)
+		toolset = onlyReadOnly(toolset)
+	}
+
+	v, err := model.ParseVerbosity(verbosity)
+	if err != nil {
+		return nil, err
+	}
+
+	var llm model.Model
+	switch {
+	case replayFile != "":
+		f, err := model.LoadFixture(replayFile)
+		if err != nil {
+			return nil, err
+		}
+		llm = model.NewReplay(f)
+		toolset = model.ReplayTools(toolset)
+	case model.ProviderName(spec.Provider) == model.ProviderMock:
+		if fixturesFile == "" {
+			return nil, fmt.Errorf("create model: the mock provider needs --fixtures")
+		}
+		f, err := model.LoadFixture(fixturesFile)
+		if err != nil {
+			return nil, err
+		}
+		llm = model.NewMock(f)
+	default:
+		if env := model.APIKeyEnv[model.ProviderName(spec.Provider)]; env != "" && os.Getenv(env) == "" {
+			return nil, fmt.Errorf("create model: %s not set, export it or run `tinker setup`", env)
+		}
+		if len(toolset) > 0 {
+			if err := model.RequireTools(model.ModelVersion(spec.Model)); err != nil {
+				return nil, err
+			}
+		}
+		if debugLLM {
+			if err := dumpRequests(); err != nil {
+				return nil, err
+			}
+		}
+		if llm, err = model.New(model.ProviderName(spec.Provider), model.ModelVersion(spec.Model)); err != nil {
+			return nil, fmt.Errorf("create model: %w", err)
+		}
+		if thinkingTokens > 0 {
+			if err := useThinking(llm, model.ModelVersion(spec.Model)); err != nil {
+				return nil, err
+			}
+		}
+		if f, ok := llm.(model.SafetyFilter); ok && geminiSafety != "" {
+			if err := f.SetSafetyThreshold(geminiSafety); err != nil {
+				return nil, fmt.Errorf("gemini_safety in config.json: %w", err)
+			}
+		}
+	}
+	if recordFile != "" {
+		llm = model.NewRecorder(llm, recordFile)
+	}
+
+	db, err := storage.NewSession(dir, id)
+	if err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+
+	cw, err := model.NewContextWindow(ctx, db, llm, id)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	blobs, err := openBlobs()
+	if err != nil {
+		cw.Close()
+		return nil, err
+	}
+	cw.SetArtifacts(blobs, "")
+
+	if activeProject != nil {
+		if err := cw.SetProject(ctx, activeProject.ID); err != nil {
+			cw.Close()
+			return nil, err
+		}
+	}
+
+	style := model.ResponseStyle{Language: language, Verbosity: v}
+	if spec.Profile != nil {
+		style.Instructions = spec.Profile.Instructions
+	}
+	if err := cw.SetResponseStyle(ctx, style); err != nil {
+		cw.Close()
+		return nil, err
+	}
+
+	if ws != nil {
+		toolset = tools.OnWorkspace(toolset, ws)
+		note := fmt.Sprintf("The file and bash tools run on %s, not on the user's machine. Paths are resolved there.", ws)
+		if err := cw.AddSystemNote(ctx, note); err != nil {
+			cw.Close()
+			return nil, err
+		}
+	}
+	if noTools {
+		note := "No tools are available in this session. Answer from the conversation alone, and say what you would need to look at rather than pretending to."
+		if err := cw.AddSystemNote(ctx, note); err != nil {
+			cw.Close()
+			return nil, err
+		}
+	}
+	warnings, err := model.CheckToolSchemas(model.ProviderName(spec.Provider), toolset, strictSchemas)
+	if err != nil {
+		cw.Close()
+		return nil, fmt.Errorf("check tool schemas: %w", err)
+	}
+	log := logger.NewLogger(os.Stderr, verbose)
+	for _, w := range warnings {
+		log.Warn("tool schema downgraded", "detail", w)
+	}
+	for _, t := range toolset {
+		if err := cw.RegisterTool(ctx, t); err != nil {
+			cw.Close()
+			return nil, err
+		}
+	}
+
+	if len(pinFiles) > 0 {
+		if err := cw.Pin(pinFiles...); err != nil {
+			cw.Close()
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Pinned %s\n", strings.Join(cw.Pinned(), ", "))
+		cw.Subscribe(func(ev model.Event) {
+			if ev.Kind == model.EventUnpin {
+				log.Warn("pinned file dropped", "detail", ev.Text)
+			}
+		})
+	}
+
+	var t *transcript
+	if transcriptFile != "" {
+		if t, err = openTranscript(transcriptFile, id, cwd); err != nil {
+			cw.Close()
+			return nil, err
+		}
+		cw.Subscribe(t.Observe)
+	}
+
+	a := agent.New(&agent.Config{
+		ContextWindow: cw,
+		Logger:        log,
+	})
+
+	return &agentSession{ID: id, CW: cw, Agent: a, closeWorkspace: closeWorkspace, transcript: t}, nil
+}
+
+// openBlobs opens the blob store, where tool outputs too large for the
+// context are kept.
+func openBlobs() (*storage.Blobs, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return storage.OpenBlobs(filepath.Join(home, ".tinker", storage.BlobsDir))
+}
+
+// dumpRequests writes the provider requests and responses of the models
+// created afterwards to ~/.tinker/debug.
+func dumpRequests() error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	return model.DumpRequests(filepath.Join(home, ".tinker", "debug"))
+}
+
+// showProgress draws a spinner on stderr that follows the agent until stop
+// is called. Nothing is drawn unless stderr is a terminal, or with verbose
+// logs that would break the line. With --plain the conversation is written
+// as lines instead, terminal or not.
+func (s *agentSession) showProgress(ctx context.Context) (stop func()) {
+	if plain {
+		return s.CW.Subscribe(plainLines(os.Stderr))
+	}
+	if verbose || !isTerminal(os.Stderr) {
+		return func() {}
+	}
+	spinner := progress.NewSpinner(os.Stderr)
+	if asciiSymbols {
+		spinner.UseASCII()
+	}
+	unsubscribe := s.CW.Subscribe(spinner.Observe)
+	stopSpinner := spinner.Start(ctx)
+	return func() {
+		unsubscribe()
+		stopSpinner()
+	}
+}
+
+// useThinking lets llm think for up to --thinking-tokens before each
+// response, if it can.
+func useThinking(llm model.Model, version model.ModelVersion) error {
+	t, ok := llm.(model.Thinker)
+	c, known := model.LookupCapabilities(version)
+	if !ok || (known && !c.Thinking) {
+		return fmt.Errorf("--thinking-tokens: model %s does not support extended thinking", version)
+	}
+	if thinkingTokens < minThinkingTokens {
+		return fmt.Errorf("--thinking-tokens must be at least %d", minThinkingTokens)
+	}
+	if known && thinkingTokens >= c.MaxOutputTokens {
+		return fmt.Errorf("--thinking-tokens must be below the %d output tokens of %s", c.MaxOutputTokens, version)
+	}
+	t.SetThinkingBudget(thinkingTokens)
+	return nil
+}
+
+func readOnly(toolset []tools.ToolDefinition) bool {
+	for _, t := range toolset {
+		if !isReadOnlyTool(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// onlyReadOnly keeps the read-only tools of toolset.
+func onlyReadOnly(toolset []tools.ToolDefinition) []tools.ToolDefinition {
+	return slices.DeleteFunc(slices.Clone(toolset), func(t tools.ToolDefinition) bool { return !isReadOnlyTool(t) })
+}
+
+func isReadOnlyTool(t tools.ToolDefinition) bool {
+	return t.Annotations.Safe()
+}
+
+func (s *agentSession) Close() error {
+	return errors.Join(s.CW.Close(), s.closeWorkspace(), s.transcript.Close())
+}
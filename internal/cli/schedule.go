@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/cron"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// Prompt and repository of `tinker schedule add`
+var (
+	schedulePrompt string
+	scheduleRepo   string
+)
+
+func openSchedules() (*sql.DB, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return storage.OpenSchedules(filepath.Join(home, ".tinker", storage.SchedulesFile))
+}
+
+// ScheduleAddHandler saves a prompt the API server runs as a job in a
+// repository at the times of a cron expression.
+func ScheduleAddHandler(cmd *cobra.Command, args []string) error {
+	spec, err := cron.Parse(args[0])
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(schedulePrompt) == "" {
+		return errors.New("--prompt is required")
+	}
+	repo, err := filepath.Abs(scheduleRepo)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(repo); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", repo)
+	}
+
+	db, err := openSchedules()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s, err := storage.CreateSchedule(cmd.Context(), db, args[0], schedulePrompt, repo)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Added schedule %s in %s, next run %s\n", s.ID, s.Repo, nextRun(spec, time.Now()))
+	fmt.Fprintln(out, "The API server runs it, started with -jobs-root above the repository.")
+	return nil
+}
+
+func ScheduleListHandler(cmd *cobra.Command, args []string) error {
+	db, err := openSchedules()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	schedules, err := storage.ListSchedules(cmd.Context(), db)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	if len(schedules) == 0 {
+		fmt.Fprintln(out, "No schedules. Add one with `tinker schedule add <cron> --prompt <prompt>`.")
+		return nil
+	}
+	now := time.Now()
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCRON\tNEXT\tLAST\tREPO\tPROMPT")
+	for _, s := range schedules {
+		next := "invalid"
+		if spec, err := cron.Parse(s.Cron); err == nil {
+			next = nextRun(spec, now)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.Cron, next, lastRun(s), s.Repo, truncate(strings.Join(strings.Fields(s.Prompt), " "), 40))
+	}
+	return tw.Flush()
+}
+
+func ScheduleRemoveHandler(cmd *cobra.Command, args []string) error {
+	db, err := openSchedules()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := storage.DeleteSchedule(cmd.Context(), db, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Removed schedule %s\n", args[0])
+	return nil
+}
+
+func nextRun(spec cron.Schedule, now time.Time) string {
+	next := spec.Next(now.Local())
+	if next.IsZero() {
+		return "never"
+	}
+	return next.Format("2006-01-02 15:04")
+}
+
+// lastRun describes the latest run of s: the session it recorded, or why
+// it failed.
+func lastRun(s storage.Schedule) string {
+	switch {
+	case s.LastRun == nil:
+		return "-"
+	case s.LastError != "":
+		return fmt.Sprintf("%s failed: %s", s.LastRun.Local().Format("2006-01-02 15:04"), truncate(s.LastError, 40))
+	default:
+		return fmt.Sprintf("%s session %s", s.LastRun.Local().Format("2006-01-02 15:04"), s.LastSession)
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectSessions creates a project sessions directory when none exists yet
+var projectSessions bool
+
+// projectSessionsDir finds the .tinker/sessions directory of the project
+// containing dir, so a team can share the sessions of a repository without
+// mixing in personal ones. With project_sessions set in the config it is
+// created at the git root when missing.
+func projectSessionsDir(dir string) (string, bool) {
+	home, _ := os.UserHomeDir()
+	for d := dir; ; {
+		// ~/.tinker/sessions is the global directory
+		if d == home {
+			break
+		}
+		candidate := filepath.Join(d, ".tinker", "sessions")
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, true
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+
+	if !projectSessions {
+		return "", false
+	}
+	root, err := git("-C", dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", false
+	}
+	candidate := filepath.Join(strings.TrimSpace(root), ".tinker", "sessions")
+	if err := os.MkdirAll(candidate, 0o755); err != nil {
+		return "", false
+	}
+	return candidate, true
+}
@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// annotationModel marks commands that call a model, so a first run of them
+// can set up an API key instead of failing
+const annotationModel = "tinker/model"
+
+// userConfig holds the CLI settings of ~/.tinker/config.json. The file also
+// holds settings of other components, such as the web UI keymap.
+type userConfig struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// API keys by provider, used when the environment has none
+	APIKeys  map[string]string  `json:"api_keys,omitempty"`
+	Profiles map[string]profile `json:"profiles,omitempty"`
+	// Keep sessions in .tinker/sessions at the root of each git repository
+	ProjectSessions bool `json:"project_sessions,omitempty"`
+	// Sessions pruned by `tinker conversation prune` and the API server
+	Retention storage.Retention `json:"retention,omitempty"`
+	// Print ASCII in place of unicode symbols, spinner glyphs and block characters
+	ASCII bool `json:"ascii,omitempty"`
+	// Names that stand for a model wherever one is given, e.g. fast for
+	// gemini-2.5-flash
+	ModelAliases map[string]string `json:"model_aliases,omitempty"`
+	// Threshold of Gemini's safety filters, one of model.SafetyThresholds
+	GeminiSafety string `json:"gemini_safety,omitempty"`
+}
+
+func userConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinker", "config.json"), nil
+}
+
+// loadUserConfig reads the CLI settings and reports whether the file exists.
+func loadUserConfig(path string) (userConfig, bool, error) {
+	var cfg userConfig
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, false, nil
+	}
+	if err != nil {
+		return cfg, false, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, true, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, true, nil
+}
+
+// saveUserConfig writes the CLI settings, keeping the other sections of the file.
+func saveUserConfig(path string, cfg userConfig) error {
+	sections := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &sections); err != nil {
+			return fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+
+	set := func(key string, v any, empty bool) {
+		if empty {
+			delete(sections, key)
+			return
+		}
+		sections[key], _ = json.Marshal(v)
+	}
+	set("provider", cfg.Provider, cfg.Provider == "")
+	set("model", cfg.Model, cfg.Model == "")
+	set("api_keys", cfg.APIKeys, len(cfg.APIKeys) == 0)
+
+	data, err := json.MarshalIndent(sections, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	// The file holds API keys
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// applyUserConfig uses the selected profile, then the settings of the working
+// directory's project, then the saved provider and model, unless they were
+// given as flags, and exports saved API keys the
+// environment does not set.
+func applyUserConfig(cmd *cobra.Command, cfg userConfig) error {
+	flags := cmd.Flags()
+	modelAliases = cfg.ModelAliases
+	wantProvider, wantModel := cfg.Provider, resolveModel(cfg.Model)
+	projectSessions = cfg.ProjectSessions
+	retention = cfg.Retention
+	asciiSymbols = cfg.ASCII
+	geminiSafety = cfg.GeminiSafety
+
+	// A model chosen by a project or profile brings its provider, and a
+	// provider chosen alone brings its default model
+	choose := func(p, m string) {
+		m = resolveModel(m)
+		if m != "" {
+			wantProvider, wantModel = string(model.ProviderOf(model.ModelVersion(m))), m
+		}
+		if p != "" {
+			if p != wantProvider {
+				wantModel = m
+			}
+			wantProvider = p
+		}
+	}
+
+	// The working directory's project sets defaults, which a profile overrides
+	if p, ok := workingProject(cmd.Context()); ok {
+		activeProject = &p
+		choose(p.Settings.Provider, p.Settings.Model)
+		if !flags.Changed("language") && p.Settings.Language != "" {
+			language = p.Settings.Language
+		}
+		if !flags.Changed("verbosity") && p.Settings.Verbosity != "" {
+			verbosity = p.Settings.Verbosity
+		}
+	}
+
+	if profileName != "" {
+		p, ok := cfg.Profiles[profileName]
+		if !ok {
+			return fmt.Errorf("unknown profile %q", profileName)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("profile %s: %w", profileName, err)
+		}
+		activeProfile = &p
+
+		choose(p.Provider, p.Model)
+		if !flags.Changed("language") && p.Language != "" {
+			language = p.Language
+		}
+		if !flags.Changed("verbosity") && p.Verbosity != "" {
+			verbosity = p.Verbosity
+		}
+	}
+
+	if !flags.Changed("provider") && wantProvider != "" {
+		provider = wantProvider
+	}
+	if flags.Changed("model") {
+		modelName = resolveModel(modelName)
+		// As from a project or profile, the model brings its provider
+		if p := model.ProviderOf(model.ModelVersion(modelName)); p != "" && !flags.Changed("provider") {
+			provider = string(p)
+		}
+	} else {
+		switch {
+		case wantModel != "" && model.ProviderOf(model.ModelVersion(wantModel)) == model.ProviderName(provider):
+			modelName = wantModel
+		case model.ProviderOf(model.ModelVersion(modelName)) != model.ProviderName(provider):
+			// The default model belongs to another provider
+			modelName = string(model.DefaultModels[model.ProviderName(provider)])
+		}
+	}
+
+	for p, key := range cfg.APIKeys {
+		env := model.APIKeyEnv[model.ProviderName(p)]
+		if env != "" && os.Getenv(env) == "" {
+			os.Setenv(env, key)
+		}
+	}
+	return nil
+}
+
+// Model aliases of the user config
+var modelAliases map[string]string
+
+// resolveModel returns the model an alias stands for, or name itself. Aliases
+// are not followed further, so they cannot loop.
+func resolveModel(name string) string {
+	if m, ok := modelAliases[name]; ok {
+		return m
+	}
+	return name
+}
+
+// onboard loads the user config and, on the first run of a command that calls
+// a model without an API key, walks the user through setup when stdin is a
+// terminal.
+func onboard(cmd *cobra.Command, args []string) error {
+	path, err := userConfigPath()
+	if err != nil {
+		return nil
+	}
+	cfg, exists, err := loadUserConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := applyUserConfig(cmd, cfg); err != nil {
+		return err
+	}
+
+	if exists || cmd.Annotations[annotationModel] == "" || apiKeySet() || !isTerminal(os.Stdin) {
+		return nil
+	}
+	// Without a prompt the root command only prints help
+	if cmd == cmd.Root() && len(args) == 0 {
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "Welcome to tinker! No API key is set up yet, let's do that first.")
+	return runSetup(cmd, os.Stdin, os.Stderr)
+}
+
+// SetupHandler asks for the provider, API key and default model and saves them.
+func SetupHandler(cmd *cobra.Command, args []string) error {
+	return runSetup(cmd, os.Stdin, os.Stderr)
+}
+
+func runSetup(cmd *cobra.Command, in io.Reader, out io.Writer) error {
+	path, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	cfg, _, err := loadUserConfig(path)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(in)
+	ask := func(question, def string) string {
+		if def != "" {
+			fmt.Fprintf(out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(out, "%s: ", question)
+		}
+		answer, _ := r.ReadString('\n')
+		if answer = strings.TrimSpace(answer); answer == "" {
+			return def
+		}
+		return answer
+	}
+
+	p := model.ProviderName(ask("Provider (anthropic, gemini)", provider))
+	env, ok := model.APIKeyEnv[p]
+	if !ok {
+		return fmt.Errorf("unknown provider %q", p)
+	}
+
+	key, err := askAPIKey(cmd.Context(), p, func() string {
+		return ask("Paste your "+env, "")
+	}, out)
+	if err != nil {
+		return err
+	}
+
+	def := model.DefaultModels[p]
+	if cfg.Model != "" && model.ProviderOf(model.ModelVersion(resolveModel(cfg.Model))) == p {
+		def = model.ModelVersion(cfg.Model)
+	}
+	m := ask("Default model", string(def))
+
+	if cfg.APIKeys == nil {
+		cfg.APIKeys = map[string]string{}
+	}
+	cfg.Provider, cfg.Model, cfg.APIKeys[string(p)] = string(p), m, key
+	if err := saveUserConfig(path, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Saved to %s\n", path)
+
+	// This run uses the new settings too
+	provider, modelName = string(p), resolveModel(m)
+	os.Setenv(env, key)
+
+	if cmd.Name() == "init" {
+		return nil
+	}
+	if _, err := os.Stat(model.ProjectContextFile); !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	answer := strings.ToLower(ask(fmt.Sprintf("Explore this repository and write a %s for future sessions? [y/N]", model.ProjectContextFile), ""))
+	if answer != "y" && answer != "yes" {
+		return nil
+	}
+	id, err := writeProjectContext(cmd.Context())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %s (session %s)\n", model.ProjectContextFile, id)
+	return nil
+}
+
+// askAPIKey asks for a key until the provider accepts one, three times at most.
+func askAPIKey(ctx context.Context, p model.ProviderName, ask func() string, out io.Writer) (string, error) {
+	for range 3 {
+		key := ask()
+		if key == "" {
+			return "", errors.New("no API key given")
+		}
+
+		fmt.Fprint(out, "Checking the key... ")
+		checkCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		err := model.ValidateAPIKey(checkCtx, p, key)
+		cancel()
+		if err == nil {
+			fmt.Fprintln(out, "ok")
+			return key, nil
+		}
+		fmt.Fprintf(out, "rejected: %s\n", firstLine(err.Error()))
+	}
+	return "", errors.New("no valid API key given")
+}
+
+func apiKeySet() bool {
+	env := model.APIKeyEnv[model.ProviderName(provider)]
+	return env != "" && os.Getenv(env) != ""
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var sessionsDir string
+
+// Show per-tool metrics instead of usage
+var statsTools bool
+
+func StatsHandler(cmd *cobra.Command, args []string) error {
+	dir, err := resolveSessionsDir()
+	if err != nil {
+		return err
+	}
+
+	if statsTools {
+		stats, err := storage.ToolStatsSessions(cmd.Context(), dir)
+		if err != nil {
+			return fmt.Errorf("aggregate tool calls: %w", err)
+		}
+		printToolStats(cmd.OutOrStdout(), stats)
+		return nil
+	}
+
+	summary, err := storage.SummarizeSessions(cmd.Context(), dir)
+	if err != nil {
+		return fmt.Errorf("summarize sessions: %w", err)
+	}
+
+	usage, err := storage.UsageSessions(cmd.Context(), dir)
+	if err != nil {
+		return fmt.Errorf("aggregate usage: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Turns:                 %d\n", summary.Turns)
+	fmt.Fprintf(out, "Input tokens:          %d\n", summary.InputTokens)
+	fmt.Fprintf(out, "Output tokens:         %d\n", summary.OutputTokens)
+	fmt.Fprintf(out, "Avg time to 1st token: %.2fs\n", summary.AvgTimeToFirstToken.Seconds())
+	fmt.Fprintf(out, "Avg turn duration:     %.2fs\n", summary.AvgDuration.Seconds())
+	fmt.Fprintf(out, "Avg throughput:        %.1f tok/s\n", summary.AvgTokensPerSecond)
+
+	if len(usage) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	printUsageTable(out, usage)
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Tokens per day: %s\n", sparkline(dailyTokens(usage)))
+
+	var cached int
+	var saved float64
+	for _, u := range usage {
+		cached += u.CachedTokens
+		saved += u.CacheSavings
+	}
+	if cached > 0 {
+		fmt.Fprintf(out, "Cached input tokens: %d, saving $%.4f\n", cached, saved)
+	}
+
+	return nil
+}
+
+func printUsageTable(out io.Writer, usage []storage.Usage) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DAY\tMODEL\tINPUT\tOUTPUT\tCOST\tTURNS\tTOOL CALLS")
+
+	var total storage.Usage
+	for _, u := range usage {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t$%.4f\t%d\t%d\n",
+			u.Day, u.Model, u.InputTokens, u.OutputTokens, u.Cost, u.Turns, u.ToolCalls)
+		total.InputTokens += u.InputTokens
+		total.OutputTokens += u.OutputTokens
+		total.Cost += u.Cost
+		total.Turns += u.Turns
+		total.ToolCalls += u.ToolCalls
+	}
+
+	fmt.Fprintf(tw, "TOTAL\t\t%d\t%d\t$%.4f\t%d\t%d\n",
+		total.InputTokens, total.OutputTokens, total.Cost, total.Turns, total.ToolCalls)
+	tw.Flush()
+}
+
+// printToolStats lists each tool with its calls, failures and median
+// latency, the most called first.
+func printToolStats(out io.Writer, stats []storage.ToolStats) {
+	if len(stats) == 0 {
+		fmt.Fprintln(out, "No tool calls recorded yet")
+		return
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL	CALLS	FAILED	FAILURE RATE	MEDIAN LATENCY")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%s\n",
+			s.Tool, s.Calls, s.Failures, 100*s.FailureRate(), s.MedianLatency.Round(time.Millisecond))
+	}
+	tw.Flush()
+}
+
+// dailyTokens sums total tokens per day across models, in day order.
+// Usage rows are already sorted by day.
+func dailyTokens(usage []storage.Usage) []int {
+	var totals []int
+	var lastDay string
+	for _, u := range usage {
+		if u.Day != lastDay {
+			totals = append(totals, 0)
+			lastDay = u.Day
+		}
+		totals[len(totals)-1] += u.TotalTokens()
+	}
+	return totals
+}
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// Ticks of the sparkline with "ascii": true in config.json
+var asciiSparkTicks = []rune("_.-=+*#@")
+
+// sparkline renders values as a row of block characters scaled to the max.
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	ticks := sparkTicks
+	if asciiSymbols {
+		ticks = asciiSparkTicks
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		idx := 0
+		if max > 0 {
+			idx = v * (len(ticks) - 1) / max
+		}
+		sb.WriteRune(ticks[idx])
+	}
+	return sb.String()
+}
+
+// resolveSessionsDir returns the --sessions-dir flag, the project's sessions
+// directory or the default ~/.tinker/sessions
+func resolveSessionsDir() (string, error) {
+	if sessionsDir != "" {
+		return sessionsDir, nil
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if dir, ok := projectSessionsDir(cwd); ok {
+			return dir, nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home directory: %w", err)
+	}
+	return filepath.Join(home, ".tinker", "sessions"), nil
+}
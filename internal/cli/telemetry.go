@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/telemetry"
+	"github.com/honganh1206/tinker/internal/tracing"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	telemetryEndpoint string
+	clearTelemetry    bool
+)
+
+func newRecorder() (*telemetry.Recorder, error) {
+	dir, err := telemetry.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return telemetry.NewRecorder(dir), nil
+}
+
+// instrument wraps every command under cmd so its outcome is recorded when
+// telemetry is enabled, and traced in a span. Recording failures never fail
+// the command.
+func instrument(cmd *cobra.Command) {
+	for _, sub := range cmd.Commands() {
+		instrument(sub)
+	}
+	if cmd.RunE == nil {
+		return
+	}
+
+	run := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx, span := tracing.Start(cmd.Context(), "command "+commandName(cmd))
+		cmd.SetContext(ctx)
+		err := run(cmd, args)
+		tracing.End(span, err)
+		if r, rerr := newRecorder(); rerr == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			_ = r.Record(ctx, featureName(cmd), err, Version)
+		}
+		return err
+	}
+}
+
+// commandName is the command path without the root, "run" for the root.
+func commandName(cmd *cobra.Command) string {
+	name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name())
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "run"
+	}
+	return name
+}
+
+// featureName is the command path and the names of the flags set, without
+// their values, e.g. "review --json".
+func featureName(cmd *cobra.Command) string {
+	name := commandName(cmd)
+
+	var flags []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		flags = append(flags, "--"+f.Name)
+	})
+	sort.Strings(flags)
+	return strings.Join(append([]string{name}, flags...), " ")
+}
+
+func TelemetryShowHandler(cmd *cobra.Command, args []string) error {
+	r, err := newRecorder()
+	if err != nil {
+		return err
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case !cfg.Enabled:
+		fmt.Fprintln(out, "Telemetry is off. Enable it with `tinker telemetry on`.")
+	case cfg.Endpoint != "":
+		fmt.Fprintf(out, "Telemetry is on, stored locally and sent to %s\n", cfg.Endpoint)
+	default:
+		fmt.Fprintln(out, "Telemetry is on, stored locally only")
+	}
+
+	features, errs, err := r.Summary()
+	if err != nil {
+		return err
+	}
+	if len(features) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FEATURE\tUSES\tFAILURES")
+	for _, f := range features {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", f.Feature, f.Uses, f.Failures)
+	}
+	tw.Flush()
+
+	if len(errs) > 0 {
+		fmt.Fprintln(out)
+		tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ERROR\tCOUNT")
+		for _, e := range errs {
+			fmt.Fprintf(tw, "%s\t%d\n", e.Error, e.Count)
+		}
+		tw.Flush()
+	}
+	return nil
+}
+
+func TelemetryOnHandler(cmd *cobra.Command, args []string) error {
+	r, err := newRecorder()
+	if err != nil {
+		return err
+	}
+	if err := r.SaveConfig(telemetry.Config{Enabled: true, Endpoint: telemetryEndpoint}); err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Telemetry is on")
+	return nil
+}
+
+func TelemetryOffHandler(cmd *cobra.Command, args []string) error {
+	r, err := newRecorder()
+	if err != nil {
+		return err
+	}
+	if err := r.SaveConfig(telemetry.Config{}); err != nil {
+		return err
+	}
+	if clearTelemetry {
+		if err := r.Clear(); err != nil {
+			return fmt.Errorf("clear telemetry: %w", err)
+		}
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Telemetry is off")
+	return nil
+}
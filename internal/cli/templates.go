@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/honganh1206/tinker/internal/prompts"
+	"github.com/spf13/cobra"
+)
+
+var (
+	templateName string
+	templateVars []string
+	templateFile string
+)
+
+func newTemplateStore() (*prompts.Store, error) {
+	path, err := prompts.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return prompts.NewStore(path), nil
+}
+
+// templatePrompt renders the template selected with --template, followed by
+// the prompt given on the command line, if any.
+func templatePrompt(extra string) (string, error) {
+	store, err := newTemplateStore()
+	if err != nil {
+		return "", err
+	}
+	t, err := store.Get(templateName)
+	if err != nil {
+		return "", err
+	}
+
+	vars := make(map[string]string, len(templateVars))
+	for _, kv := range templateVars {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return "", fmt.Errorf("invalid --var %q (expected name=value)", kv)
+		}
+		vars[k] = v
+	}
+
+	prompt, err := prompts.Render(t.Text, vars)
+	if err != nil {
+		return "", fmt.Errorf("template %s: %w", t.Name, err)
+	}
+	if extra != "" {
+		prompt += "\n\n" + extra
+	}
+	return prompt, nil
+}
+
+func TemplatesListHandler(cmd *cobra.Command, args []string) error {
+	store, err := newTemplateStore()
+	if err != nil {
+		return err
+	}
+	templates, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(templates) == 0 {
+		fmt.Fprintln(out, "No templates yet. Add one with `tinker templates add <name> <text>`.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVARIABLES\tTEXT")
+	for _, t := range templates {
+		text, _, _ := strings.Cut(t.Text, "\n")
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", t.Name, strings.Join(prompts.Variables(t.Text), ", "), truncate(text, 60))
+	}
+	return tw.Flush()
+}
+
+func TemplatesAddHandler(cmd *cobra.Command, args []string) error {
+	text := strings.Join(args[1:], " ")
+	if templateFile != "" {
+		var data []byte
+		var err error
+		if templateFile == "-" {
+			data, err = io.ReadAll(cmd.InOrStdin())
+		} else {
+			data, err = os.ReadFile(templateFile)
+		}
+		if err != nil {
+			return fmt.Errorf("read template: %w", err)
+		}
+		text = strings.TrimSpace(string(data))
+	}
+	if text == "" {
+		return fmt.Errorf("template text is empty, pass it as arguments or with --file")
+	}
+
+	store, err := newTemplateStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Save(args[0], text); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Saved template %s\n", args[0])
+	return nil
+}
+
+func TemplatesShowHandler(cmd *cobra.Command, args []string) error {
+	store, err := newTemplateStore()
+	if err != nil {
+		return err
+	}
+	t, err := store.Get(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), t.Text)
+	return nil
+}
+
+func TemplatesRemoveHandler(cmd *cobra.Command, args []string) error {
+	store, err := newTemplateStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Removed template %s\n", args[0])
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + symbols("…")
+	}
+	return s
+}
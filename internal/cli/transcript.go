@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// File --transcript appends the conversation to
+var transcriptFile string
+
+// transcript writes a session to a markdown file as it happens: prompts,
+// one line per tool call and the answers. Runs in the same file follow one
+// another, each under a heading of its own. Writes are not buffered, so a
+// crash loses nothing already shown.
+type transcript struct {
+	mu sync.Mutex
+	f  *os.File
+	// The last line written is a tool call, which the next heading is set
+	// apart from
+	inList bool
+}
+
+func openTranscript(path, sessionID, dir string) (*transcript, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open transcript: %w", err)
+	}
+	fmt.Fprintf(f, "# Session %s\n\n%s in %s\n\n", sessionID, time.Now().Format(time.DateTime), dir)
+	return &transcript{f: f}, nil
+}
+
+// Observe appends what an event shows of the conversation.
+func (t *transcript) Observe(ev model.Event) {
+	switch ev.Kind {
+	case model.EventPrompt:
+		t.section("Prompt", ev.Text)
+	case model.EventToolEnd:
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.inList = true
+		fmt.Fprintf(t.f, "- %s %s\n", tools.Icon(ev.Tool), tools.FormatResult(ev.Tool, ev.Args, ev.Output, ev.Err))
+	case model.EventAnswer:
+		t.section("Answer", ev.Text)
+	}
+}
+
+func (t *transcript) section(heading, text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inList {
+		fmt.Fprintln(t.f)
+		t.inList = false
+	}
+	fmt.Fprintf(t.f, "## %s\n\n%s\n\n", heading, strings.TrimSpace(text))
+}
+
+func (t *transcript) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.f.Close()
+}
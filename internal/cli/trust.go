@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/trust"
+	"github.com/spf13/cobra"
+)
+
+var (
+	trustFolder bool
+	revokeTrust bool
+)
+
+// folderTrusted reports whether the agent may modify dir. On the first run in
+// a folder the user is asked, when stdin is a terminal, and the answer is kept.
+// Without a terminal an undecided folder is untrusted.
+func folderTrusted(dir string, in io.Reader, out io.Writer) (bool, error) {
+	if trustFolder {
+		return true, nil
+	}
+
+	path, err := trust.DefaultPath()
+	if err != nil {
+		return false, err
+	}
+	store := trust.NewStore(path)
+
+	trusted, known, err := store.Lookup(dir)
+	if err != nil || known {
+		return trusted, err
+	}
+	if f, ok := in.(*os.File); !ok || !isTerminal(f) {
+		return false, nil
+	}
+
+	fmt.Fprintf(out, "Do you trust the files in %s?\nUntrusted folders only get read-only tools. [y/N] ", dir)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	trusted = answer == "y" || answer == "yes"
+
+	if err := store.Set(dir, trusted); err != nil {
+		return trusted, err
+	}
+	return trusted, nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// /dev/null is a character device too, e.g. stdin of cron jobs
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// TrustHandler records a trust decision for a folder, the working directory
+// by default.
+func TrustHandler(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	dir = resolveRelative(".", dir)
+
+	path, err := trust.DefaultPath()
+	if err != nil {
+		return err
+	}
+	if err := trust.NewStore(path).Set(dir, !revokeTrust); err != nil {
+		return err
+	}
+
+	if revokeTrust {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s is untrusted, runs there get read-only tools\n", dir)
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s is trusted\n", dir)
+	}
+	return nil
+}
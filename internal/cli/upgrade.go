@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/update"
+	"github.com/spf13/cobra"
+)
+
+var checkOnly bool
+
+// UpgradeHandler replaces the running executable with the latest release.
+func UpgradeHandler(cmd *cobra.Command, args []string) error {
+	client := update.NewClient()
+	rel, err := client.Latest(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if !update.Newer(rel.Version(), Version) {
+		fmt.Fprintf(out, "tinker %s is up to date\n", Version)
+		return nil
+	}
+	if checkOnly {
+		fmt.Fprintln(out, update.Notice(Version, rel))
+		return nil
+	}
+
+	fmt.Fprintf(out, "Downloading tinker %s...\n", rel.Version())
+	data, err := client.Download(cmd.Context(), rel)
+	if err != nil {
+		return err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate tinker executable: %w", err)
+	}
+	if err := update.Replace(self, data); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Upgraded tinker %s -> %s\n", Version, rel.Version())
+	return nil
+}
+
+// updateNotice holds the result of the background release check
+var updateNotice = make(chan string, 1)
+
+// startUpdateCheck looks for a newer release in the background. Builds without
+// a release version and commands that already talk about versions skip it,
+// as does setting TINKER_NO_UPDATE_CHECK.
+func startUpdateCheck(cmd *cobra.Command) {
+	if Version == "dev" || os.Getenv("TINKER_NO_UPDATE_CHECK") != "" {
+		return
+	}
+	if name := cmd.Name(); name == "upgrade" || name == "version" {
+		return
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	cache := filepath.Join(home, ".tinker", "update-check.json")
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		rel, err := update.NewClient().Available(ctx, Version, cache)
+		if err != nil || rel == nil {
+			return
+		}
+		updateNotice <- update.Notice(Version, rel)
+	}()
+}
+
+// printUpdateNotice prints the notice if the check has already finished.
+// It never waits, so a slow network does not delay the command.
+func printUpdateNotice(cmd *cobra.Command) {
+	select {
+	case notice := <-updateNotice:
+		fmt.Fprintln(cmd.ErrOrStderr(), notice)
+	default:
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/users"
+	"github.com/spf13/cobra"
+)
+
+var userSenders []string
+
+func loadUsers() (string, users.Users, error) {
+	path, err := users.DefaultPath()
+	if err != nil {
+		return "", nil, err
+	}
+	u, err := users.Load(path)
+	return path, u, err
+}
+
+// UserAddHandler registers a user of the API server and prints their token.
+func UserAddHandler(cmd *cobra.Command, args []string) error {
+	path, u, err := loadUsers()
+	if err != nil {
+		return err
+	}
+	token, err := u.Add(args[0], userSenders)
+	if err != nil {
+		return err
+	}
+	if err := users.Save(path, u); err != nil {
+		return fmt.Errorf("save users: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Added %s. Their API token, shown only this once:\n\n  %s\n\n", args[0], token)
+	fmt.Fprintln(out, "Send it as `Authorization: Bearer <token>`, or open the web UI at /?token=<token>.")
+	fmt.Fprintln(out, "Restart the API server for it to take effect.")
+	return nil
+}
+
+func UserListHandler(cmd *cobra.Command, args []string) error {
+	_, u, err := loadUsers()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(u) == 0 {
+		fmt.Fprintln(out, "No users. The API server serves only your own sessions.")
+		return nil
+	}
+	for _, user := range u {
+		if len(user.Senders) > 0 {
+			fmt.Fprintf(out, "%s\t%s\n", user.Name, strings.Join(user.Senders, ", "))
+		} else {
+			fmt.Fprintln(out, user.Name)
+		}
+	}
+	return nil
+}
+
+// UserRemoveHandler revokes a user's token. Their sessions are kept.
+func UserRemoveHandler(cmd *cobra.Command, args []string) error {
+	path, u, err := loadUsers()
+	if err != nil {
+		return err
+	}
+	if !u.Remove(args[0]) {
+		return fmt.Errorf("no user named %s", args[0])
+	}
+	if err := users.Save(path, u); err != nil {
+		return fmt.Errorf("save users: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Removed %s, their sessions are kept\n", args[0])
+	return nil
+}
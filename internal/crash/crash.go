@@ -0,0 +1,63 @@
+// Package crash turns panics into errors backed by a crash report on disk,
+// so a bug in one agent turn does not take the whole process down with it.
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError is returned by Guard when the guarded function panicked
+type PanicError struct {
+	Value any
+	// Crash report path, empty if it could not be written
+	Report string
+}
+
+func (e *PanicError) Error() string {
+	if e.Report == "" {
+		return fmt.Sprintf("panic: %v", e.Value)
+	}
+	return fmt.Sprintf("panic: %v (crash report: %s)", e.Value, e.Report)
+}
+
+// DefaultDir is ~/.tinker, where crash-<timestamp>.log files are written.
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return os.TempDir()
+	}
+	return filepath.Join(home, ".tinker")
+}
+
+// Guard runs fn and recovers a panic in it, writing the stack trace to a
+// crash report in dir and returning a *PanicError.
+func Guard(dir string, fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			perr := &PanicError{Value: v}
+			perr.Report, _ = Report(dir, v, debug.Stack())
+			err = perr
+		}
+	}()
+	return fn()
+}
+
+// Report writes a crash report for the recovered value v and returns its path.
+func Report(dir string, v any, stack []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create crash dir: %w", err)
+	}
+
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("crash-%s.log", now.Format("20060102-150405.000")))
+	content := fmt.Sprintf("time: %s\nargs: %q\npanic: %v\n\n%s", now.Format(time.RFC3339), os.Args, v, stack)
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return "", fmt.Errorf("write crash report: %w", err)
+	}
+	return path, nil
+}
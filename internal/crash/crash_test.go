@@ -0,0 +1,38 @@
+package crash
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard_ReturnsError(t *testing.T) {
+	want := errors.New("model call failed")
+
+	err := Guard(t.TempDir(), func() error { return want })
+
+	assert.Equal(t, want, err)
+}
+
+func TestGuard_RecoversPanic(t *testing.T) {
+	dir := t.TempDir()
+
+	err := Guard(dir, func() error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+
+	var perr *PanicError
+	require.ErrorAs(t, err, &perr)
+	require.NotEmpty(t, perr.Report)
+	assert.Contains(t, err.Error(), perr.Report)
+
+	report, err := os.ReadFile(perr.Report)
+	require.NoError(t, err)
+	assert.Contains(t, string(report), "assignment to entry in nil map")
+	assert.Contains(t, string(report), "TestGuard_RecoversPanic")
+}
@@ -0,0 +1,56 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, 10, 14, 7, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 7 * * *", time.Date(2026, 10, 15, 7, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2026, 10, 14, 7, 40, 0, 0, time.UTC)},
+		{"5/15 8 * * *", time.Date(2026, 10, 14, 8, 5, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)},
+		// Either day matches once both are restricted
+		{"0 0 20 * 5", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, s.Next(from), tt.expr)
+	}
+}
+
+func TestNext_HalfHourZone(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	s, err := Parse("0 7 * * *")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 15, 7, 0, 0, 0, ist), s.Next(time.Date(2026, 10, 14, 7, 30, 0, 0, ist)))
+}
+
+func TestMatches(t *testing.T) {
+	s, err := Parse("30 7 * * wed")
+	require.NoError(t, err)
+	assert.True(t, s.Matches(time.Date(2026, 10, 14, 7, 30, 45, 0, time.UTC)))
+	assert.False(t, s.Matches(time.Date(2026, 10, 15, 7, 30, 0, 0, time.UTC)))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "0 7 * *", "60 * * * *", "0 7 * * 8", "0 7 10-5 * *", "*/0 * * * *", "0 7 * foo *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,46 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffStat counts what a diff changes
+type DiffStat struct {
+	Files   int `json:"files"`
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// Stat counts the files and lines changed by diff, a unified diff as Diff
+// returns.
+func Stat(diff string) DiffStat {
+	var s DiffStat
+	// The headers of a file run up to its first hunk, and may look like
+	// changed lines
+	header := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			s.Files++
+			header = true
+		case strings.HasPrefix(line, "@@"):
+			header = false
+		case header:
+		case strings.HasPrefix(line, "+"):
+			s.Added++
+		case strings.HasPrefix(line, "-"):
+			s.Removed++
+		}
+	}
+	return s
+}
+
+// String summarizes the changes like "3 files changed, +42 −7".
+func (s DiffStat) String() string {
+	files := "files"
+	if s.Files == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%d %s changed, +%d −%d", s.Files, files, s.Added, s.Removed)
+}
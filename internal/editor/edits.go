@@ -0,0 +1,174 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// Edits remembers the content files had before the agent first edited them,
+// so the changes of a turn can be returned as one diff.
+type Edits struct {
+	mu sync.Mutex
+	// Content before the first edit, nil for files the agent created
+	before map[string][]byte
+	// Paths in the order they were first edited
+	order []string
+}
+
+func NewEdits() *Edits {
+	return &Edits{before: make(map[string][]byte)}
+}
+
+// Track returns toolset with edit_file recording the files it changes.
+func (e *Edits) Track(toolset []tools.ToolDefinition) []tools.ToolDefinition {
+	tracked := make([]tools.ToolDefinition, len(toolset))
+	for i, t := range toolset {
+		tracked[i] = t
+		if t.Name != tools.ToolNameEditFile {
+			continue
+		}
+		run := t.Function
+		tracked[i].Function = func(ctx context.Context, args json.RawMessage) (string, error) {
+			var in struct {
+				Path string `json:"path"`
+			}
+			if err := json.Unmarshal(args, &in); err == nil && in.Path != "" {
+				e.remember(in.Path)
+			}
+			return run(ctx, args)
+		}
+	}
+	return tracked
+}
+
+func (e *Edits) remember(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.before[abs]; ok {
+		return
+	}
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		content = nil
+	}
+	e.before[abs] = content
+	e.order = append(e.order, abs)
+}
+
+// Diff returns the unified diff of the files edited since they were last
+// forgotten, with paths relative to the working directory.
+func (e *Edits) Diff() (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var diff strings.Builder
+	for _, path := range e.order {
+		d, err := fileDiff(path, e.before[path])
+		if err != nil {
+			return "", err
+		}
+		diff.WriteString(d)
+	}
+	return diff.String(), nil
+}
+
+// Forget starts tracking the following edits afresh.
+func (e *Edits) Forget() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.before, e.order = make(map[string][]byte), nil
+}
+
+// Restore puts the edited files back as they were before their first edit,
+// removing the ones the agent created, and forgets them.
+func (e *Edits) Restore() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, path := range e.order {
+		old := e.before[path]
+		if old == nil {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("restore %s: %w", path, err)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, old, 0o644); err != nil {
+			return fmt.Errorf("restore %s: %w", path, err)
+		}
+	}
+	e.before, e.order = make(map[string][]byte), nil
+	return nil
+}
+
+// fileDiff diffs old against the current content of path with git, which
+// needs no repository for it.
+func fileDiff(path string, old []byte) (string, error) {
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	if bytes.Equal(old, current) {
+		return "", nil
+	}
+
+	rel := path
+	if cwd, err := os.Getwd(); err == nil {
+		if r, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+		}
+	}
+	rel = filepath.ToSlash(strings.TrimPrefix(rel, "/"))
+
+	tmp, err := os.MkdirTemp("", "tinker-diff-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	// The a/ and b/ copies make git print the usual headers
+	oldArg, newArg := "/dev/null", "/dev/null"
+	if old != nil {
+		oldArg = "a/" + rel
+		if err := writeCopy(filepath.Join(tmp, oldArg), old); err != nil {
+			return "", err
+		}
+	}
+	if current != nil {
+		newArg = "b/" + rel
+		if err := writeCopy(filepath.Join(tmp, newArg), current); err != nil {
+			return "", err
+		}
+	}
+
+	c := exec.Command("git", "diff", "--no-index", "--no-color", "--no-prefix", oldArg, newArg)
+	c.Dir = tmp
+	out, err := c.Output()
+	// git exits with 1 when the files differ
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", fmt.Errorf("git diff: %w", err)
+	}
+	return string(out), nil
+}
+
+func writeCopy(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
@@ -0,0 +1,230 @@
+// Package editor serves the agent to editor plugins over stdio, as JSON-RPC
+// 2.0 messages one per line. Editors send selections and diagnostics as
+// prompts and get the answer back with the changes the agent made, streamed
+// as edit notifications and summed up in a diff.
+package editor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	// The agent failed to answer the prompt
+	codeAgentError = -32000
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// Absent for notifications, which get no response
+	ID json.RawMessage `json:"id,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Prompt is the params of the prompt method.
+type Prompt struct {
+	Text string `json:"text"`
+	// File the editor is on, relative to the working directory
+	File        string       `json:"file,omitempty"`
+	Selection   *Selection   `json:"selection,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// Put the files back once the turn ends and leave applying the edits
+	// of the result to the editor
+	Preview bool `json:"preview,omitempty"`
+}
+
+// Selection is a range of lines of the file, 1-based and inclusive.
+type Selection struct {
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Text      string `json:"text"`
+}
+
+// Diagnostic is an error or warning the editor shows on a line of the file.
+type Diagnostic struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Result is the result of the prompt method.
+type Result struct {
+	SessionID string `json:"session_id"`
+	Response  string `json:"response"`
+	// Unified diff of the files changed while answering, empty for none
+	Diff string `json:"diff,omitempty"`
+	// Files and lines Diff changes, set with it
+	Stat *DiffStat `json:"stat,omitempty"`
+	// The changes in the order they were made, each applying to the text
+	// the previous ones left
+	Edits []tools.FileEdit `json:"edits,omitempty"`
+}
+
+// Server answers the prompts of one editor in one session.
+type Server struct {
+	SessionID string
+	// Runs one turn of the session
+	Run   func(ctx context.Context, prompt string) (string, error)
+	Edits *Edits
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	// Edits of the prompt being answered
+	edits []tools.FileEdit
+}
+
+// Edit reports a change the agent made to the editor as an edit
+// notification while the prompt is answered, and adds it to the result.
+func (s *Server) Edit(e tools.FileEdit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.edits = append(s.edits, e)
+	if s.enc != nil {
+		s.enc.Encode(request{JSONRPC: "2.0", Method: "edit", Params: mustMarshal(e)})
+	}
+}
+
+func mustMarshal(v any) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// Serve handles the requests read from r until r ends or the editor calls
+// shutdown. Requests are answered in order, one turn at a time.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.enc = json.NewEncoder(w)
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			// The stream cannot be resynchronized after malformed JSON
+			s.reply(nil, nil, &rpcError{Code: codeParseError, Message: err.Error()})
+			return fmt.Errorf("decode request: %w", err)
+		}
+
+		var req request
+		if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+			s.reply(req.ID, nil, &rpcError{Code: codeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"})
+			continue
+		}
+
+		result, rpcErr := s.handle(ctx, req)
+		if req.ID != nil {
+			s.reply(req.ID, result, rpcErr)
+		}
+		if req.Method == "shutdown" {
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, req request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"name":       "tinker",
+			"session_id": s.SessionID,
+			"methods":    []string{"initialize", "prompt", "shutdown"},
+		}, nil
+
+	case "prompt":
+		var p Prompt
+		if err := json.Unmarshal(req.Params, &p); err != nil || strings.TrimSpace(p.Text) == "" {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "prompt needs params with a text"}
+		}
+		s.mu.Lock()
+		s.edits = nil
+		s.mu.Unlock()
+
+		answer, err := s.Run(ctx, promptText(p))
+		result := Result{SessionID: s.SessionID, Response: answer}
+		s.mu.Lock()
+		result.Edits = s.edits
+		s.mu.Unlock()
+		if s.Edits != nil {
+			// Edits of a failed turn are settled too, the next diff only covers its own
+			var diffErr error
+			result.Diff, diffErr = s.Edits.Diff()
+			err = errors.Join(err, diffErr)
+			if result.Diff != "" {
+				stat := Stat(result.Diff)
+				result.Stat = &stat
+			}
+			if p.Preview {
+				err = errors.Join(err, s.Edits.Restore())
+			} else {
+				s.Edits.Forget()
+			}
+		}
+		if err != nil {
+			return nil, &rpcError{Code: codeAgentError, Message: err.Error()}
+		}
+		return result, nil
+
+	case "shutdown":
+		return map[string]any{}, nil
+
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rpcErr *rpcError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(response{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr})
+}
+
+// promptText puts the editor's context into the prompt.
+func promptText(p Prompt) string {
+	var b strings.Builder
+	b.WriteString(p.Text)
+	if p.File != "" {
+		fmt.Fprintf(&b, "\n\nFile: %s", p.File)
+	}
+	if sel := p.Selection; sel != nil {
+		fmt.Fprintf(&b, "\n\nSelected lines %d-%d:\n```\n%s\n```", sel.StartLine, sel.EndLine, sel.Text)
+	}
+	if len(p.Diagnostics) > 0 {
+		b.WriteString("\n\nDiagnostics:")
+		for _, d := range p.Diagnostics {
+			severity := d.Severity
+			if severity == "" {
+				severity = "error"
+			}
+			fmt.Fprintf(&b, "\n- line %d, %s: %s", d.Line, severity, d.Message)
+		}
+	}
+	return b.String()
+}
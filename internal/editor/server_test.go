@@ -0,0 +1,175 @@
+package editor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve runs s on the requests, one per line, and decodes its responses.
+func serve(t *testing.T, s *Server, requests ...string) []map[string]any {
+	t.Helper()
+	var out strings.Builder
+	require.NoError(t, s.Serve(context.Background(), strings.NewReader(strings.Join(requests, "\n")), &out))
+
+	var responses []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &resp))
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestServe_PromptReturnsDiff(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0o644))
+
+	edits := NewEdits()
+	var editFile tools.ToolDefinition
+	for _, def := range edits.Track(tools.Builtin) {
+		if def.Name == tools.ToolNameEditFile {
+			editFile = def
+		}
+	}
+
+	var prompt string
+	s := &Server{
+		SessionID: "s-1",
+		Edits:     edits,
+		Run: func(ctx context.Context, p string) (string, error) {
+			prompt = p
+			_, err := editFile.Function(ctx, json.RawMessage(`{"path": "main.go", "old_str": "func main() {}", "new_str": "func main() { run() }"}`))
+			return "Done", err
+		},
+	}
+
+	responses := serve(t, s,
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize"}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "prompt", "params": {"text": "call run", "file": "main.go",
+			"selection": {"start_line": 3, "end_line": 3, "text": "func main() {}"},
+			"diagnostics": [{"line": 3, "message": "run is unused"}]}}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "shutdown"}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "initialize"}`,
+	)
+	require.Len(t, responses, 3, "requests after shutdown are not read")
+
+	assert.Equal(t, "s-1", responses[0]["result"].(map[string]any)["session_id"])
+
+	result := responses[1]["result"].(map[string]any)
+	assert.Equal(t, "Done", result["response"])
+	diff := result["diff"].(string)
+	assert.Contains(t, diff, "--- a/main.go\n+++ b/main.go")
+	assert.Contains(t, diff, "-func main() {}\n+func main() { run() }")
+	assert.Equal(t, map[string]any{"files": float64(1), "added": float64(1), "removed": float64(1)}, result["stat"])
+
+	assert.Contains(t, prompt, "File: main.go")
+	assert.Contains(t, prompt, "Selected lines 3-3")
+	assert.Contains(t, prompt, "line 3, error: run is unused")
+}
+
+func TestServe_Errors(t *testing.T) {
+	s := &Server{Run: func(ctx context.Context, p string) (string, error) { return "", nil }}
+
+	responses := serve(t, s,
+		`{"jsonrpc": "2.0", "id": 1, "method": "explain"}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "prompt", "params": {}}`,
+		`{"jsonrpc": "2.0", "method": "prompt", "params": {"text": "notification"}}`,
+		`{"id": 3}`,
+	)
+	require.Len(t, responses, 3, "notifications get no response")
+
+	code := func(i int) float64 { return responses[i]["error"].(map[string]any)["code"].(float64) }
+	assert.Equal(t, float64(codeMethodNotFound), code(0))
+	assert.Equal(t, float64(codeInvalidParams), code(1))
+	assert.Equal(t, float64(codeInvalidRequest), code(2))
+}
+
+func TestEdits_DiffOfCreatedFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	edits := NewEdits()
+	edits.remember(filepath.Join("pkg", "new.go"))
+	require.NoError(t, os.MkdirAll("pkg", 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join("pkg", "new.go"), []byte("package pkg\n"), 0o644))
+
+	diff, err := edits.Diff()
+	require.NoError(t, err)
+	assert.Contains(t, diff, "--- /dev/null\n+++ b/pkg/new.go")
+	assert.Contains(t, diff, "+package pkg")
+
+	// Diffs only cover the edits since they were forgotten
+	edits.Forget()
+	diff, err = edits.Diff()
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
+
+func TestStat(t *testing.T) {
+	diff := `diff --git a/schema.sql b/schema.sql
+--- a/schema.sql
++++ b/schema.sql
+@@ -1,2 +1,2 @@
+--- users
++-- accounts
+ CREATE TABLE users (id INTEGER);
+diff --git /dev/null b/seed.sql
+new file mode 100644
+--- /dev/null
++++ b/seed.sql
+@@ -0,0 +1,2 @@
++++ counters
++INSERT INTO users VALUES (1);
+`
+	stat := Stat(diff)
+	assert.Equal(t, DiffStat{Files: 2, Added: 3, Removed: 1}, stat)
+	assert.Equal(t, "2 files changed, +3 −1", stat.String())
+	assert.Equal(t, "1 file changed, +1 −0", DiffStat{Files: 1, Added: 1}.String())
+}
+
+func TestServe_PreviewRestoresFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("a.txt", []byte("one\n"), 0o644))
+
+	edits := NewEdits()
+	var editFile tools.ToolDefinition
+	for _, def := range edits.Track(tools.Builtin) {
+		if def.Name == tools.ToolNameEditFile {
+			editFile = def
+		}
+	}
+	s := &Server{Edits: edits}
+	s.Run = func(ctx context.Context, p string) (string, error) {
+		ctx = tools.WithEditReporter(ctx, s.Edit)
+		if _, err := editFile.Function(ctx, json.RawMessage(`{"path": "a.txt", "old_str": "one", "new_str": "two"}`)); err != nil {
+			return "", err
+		}
+		_, err := editFile.Function(ctx, json.RawMessage(`{"path": "b.txt", "new_str": "new"}`))
+		return "Done", err
+	}
+
+	responses := serve(t, s, `{"jsonrpc": "2.0", "id": 1, "method": "prompt", "params": {"text": "edit", "preview": true}}`)
+	require.Len(t, responses, 3)
+
+	// Edits are notified as they happen, before the result
+	assert.Equal(t, "edit", responses[0]["method"])
+	assert.Equal(t, "a.txt", responses[0]["params"].(map[string]any)["path"])
+	assert.Equal(t, "edit", responses[1]["method"])
+
+	result := responses[2]["result"].(map[string]any)
+	assert.Len(t, result["edits"], 2)
+	assert.Contains(t, result["diff"], "+++ b/b.txt")
+
+	content, err := os.ReadFile("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "one\n", string(content))
+	assert.NoFileExists(t, "b.txt")
+}
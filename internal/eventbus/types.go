@@ -35,7 +35,9 @@ const (
 	TopicChannelMessageRecv  = "channel.message.received"
 	TopicChannelMessageSend  = "channel.message.send"
 	TopicAgentRunCompleted = "agent.run.completed"
+	TopicAgentRunFailed    = "agent.run.failed"
 	TopicAgentStreamChunk  = "agent.stream.chunk"
+	TopicAgentFileEdit     = "agent.file.edit"
 )
 
 // NewEvent creates a new event with the current timestamp
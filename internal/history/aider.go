@@ -0,0 +1,103 @@
+package history
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+const aiderStarted = "# aider chat started at "
+
+// Aider commands that carry a prompt for the model, the others only change
+// the chat, such as /add
+var aiderPromptCommands = []string{"/ask", "/code", "/architect"}
+
+// readAider converts the chat history aider kept at path, a conversation per
+// time aider was started. Prompts are the "####" lines, the output of aider
+// itself the quoted ones, and the rest the model's answers. The history has
+// no time of its own for each turn, so the turns get that of the chat.
+func readAider(r io.Reader, path string) ([]Conversation, error) {
+	var (
+		convs  []Conversation
+		prompt []string
+		parts  []string
+	)
+	flush := func() {
+		if len(convs) == 0 {
+			return
+		}
+		c := &convs[len(convs)-1]
+		if text, ok := aiderPrompt(strings.Join(prompt, "\n")); ok {
+			c.Records = append(c.Records, storage.Record{Timestamp: c.StartTime, Source: storage.Prompt, Content: text, Live: true})
+		}
+		c.Records = answer(c.Records, []string{strings.Join(parts, "\n")}, c.StartTime, 0, 0)
+		prompt, parts = nil, nil
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, aiderStarted):
+			flush()
+			started, err := time.ParseInLocation(time.DateTime, strings.TrimPrefix(line, aiderStarted), time.Local)
+			if err != nil {
+				return nil, errors.New("not an aider chat history: " + line)
+			}
+			convs = append(convs, Conversation{
+				ID:         uuid.NewSHA1(uuid.NameSpaceURL, []byte("aider:"+path+"@"+started.UTC().String())).String(),
+				StartTime:  started,
+				WorkDir:    filepath.Dir(path),
+				MemoryFile: "CONVENTIONS.md",
+			})
+		case strings.HasPrefix(line, "####"):
+			// A prompt following an answer starts the next turn
+			if len(parts) > 0 {
+				flush()
+			}
+			prompt = append(prompt, strings.TrimPrefix(strings.TrimPrefix(line, "####"), " "))
+		case strings.HasPrefix(line, ">"):
+		default:
+			if len(prompt) > 0 || len(parts) > 0 {
+				parts = append(parts, line)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	var found []Conversation
+	for _, c := range convs {
+		if len(c.Records) > 0 {
+			found = append(found, c)
+		}
+	}
+	if len(found) == 0 {
+		return nil, errors.New("no conversation in the log")
+	}
+	return found, nil
+}
+
+// aiderPrompt returns the prompt of chat input, false for commands that do
+// not talk to the model.
+func aiderPrompt(input string) (string, bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") {
+		return input, input != ""
+	}
+	for _, cmd := range aiderPromptCommands {
+		if rest, ok := strings.CutPrefix(input, cmd+" "); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,198 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// ccLine is a line of a Claude Code session log
+type ccLine struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"sessionId"`
+	Cwd       string    `json:"cwd"`
+	Timestamp time.Time `json:"timestamp"`
+	// Lines of subagents, whose work their caller reports
+	IsSidechain bool `json:"isSidechain"`
+	// Lines Claude Code adds itself, such as caveats about local commands
+	IsMeta  bool `json:"isMeta"`
+	Message struct {
+		ID      string          `json:"id"`
+		Content json.RawMessage `json:"content"`
+		Usage   *struct {
+			InputTokens         int `json:"input_tokens"`
+			CacheCreationTokens int `json:"cache_creation_input_tokens"`
+			CacheReadTokens     int `json:"cache_read_input_tokens"`
+			OutputTokens        int `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// ccBlock is a content block of a Claude Code message, as in the Messages API
+type ccBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// Prompts of local commands such as /clear, which are not the user's
+// conversation with the model
+var ccCommandPrefixes = []string{"<command-name>", "<command-message>", "<local-command-", "[Request interrupted"}
+
+// readClaudeCode converts a Claude Code session log. Text the model wrote
+// between tool calls is joined into the answer of the turn, which is how
+// tinker stores turns.
+func readClaudeCode(r io.Reader) (Conversation, error) {
+	c := Conversation{MemoryFile: "CLAUDE.md"}
+	var (
+		parts    []string
+		partsAt  time.Time
+		toolUses = make(map[string]int)
+		// Tokens of the requests not yet on a record, see storage.Record
+		input, output int
+		seen          = make(map[string]bool)
+	)
+	flush := func() {
+		c.Records = answer(c.Records, parts, partsAt, input, output)
+		if len(parts) > 0 {
+			input, output = 0, 0
+		}
+		parts = nil
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var line ccLine
+		if err := dec.Decode(&line); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return Conversation{}, fmt.Errorf("not a Claude Code session log: %w", err)
+		}
+		if line.IsSidechain || line.IsMeta || (line.Type != "user" && line.Type != "assistant") {
+			continue
+		}
+		if c.ID == "" {
+			c.ID = line.SessionID
+		}
+		if c.WorkDir == "" {
+			c.WorkDir = line.Cwd
+		}
+
+		blocks, text := ccContent(line.Message.Content)
+		if line.Type == "user" {
+			for _, b := range blocks {
+				if i, ok := toolUses[b.ToolUseID]; ok && b.Type == "tool_result" {
+					_, out := ccContent(b.Content)
+					c.Records[i].Output = out
+					if b.IsError {
+						c.Records[i].Status = storage.StatusError
+					}
+				}
+			}
+			if text = strings.TrimSpace(text); text == "" || ccCommand(text) {
+				continue
+			}
+			flush()
+			c.Records = append(c.Records, storage.Record{Timestamp: line.Timestamp, Source: storage.Prompt, Content: text, Live: true})
+			continue
+		}
+
+		// A response is logged a line per content block, each with its usage
+		if u := line.Message.Usage; u != nil && !seen[line.Message.ID] {
+			seen[line.Message.ID] = true
+			input = u.InputTokens + u.CacheCreationTokens + u.CacheReadTokens
+			output += u.OutputTokens
+		}
+		for _, b := range blocks {
+			switch b.Type {
+			case "text":
+				if strings.TrimSpace(b.Text) != "" {
+					parts = append(parts, b.Text)
+					partsAt = line.Timestamp
+				}
+			case "thinking":
+				if b.Thinking != "" {
+					c.Records = append(c.Records, storage.Record{Timestamp: line.Timestamp, Source: storage.Thinking, Content: b.Thinking})
+				}
+			case "tool_use":
+				toolUses[b.ID] = len(c.Records)
+				c.Records = append(c.Records, storage.Record{
+					Timestamp:    line.Timestamp,
+					Source:       storage.ToolUse,
+					Content:      storage.EncodeToolUse(b.Name, b.Input),
+					Live:         true,
+					Status:       storage.StatusOK,
+					Summary:      toolSummary(b.Name, b.Input),
+					InputTokens:  input,
+					OutputTokens: output,
+				})
+				input, output = 0, 0
+			}
+		}
+	}
+	flush()
+
+	if len(c.Records) == 0 {
+		return Conversation{}, errors.New("no conversation in the log")
+	}
+	c.StartTime = c.Records[0].Timestamp
+	return c, nil
+}
+
+// ccContent returns the blocks of message content and their text, content
+// being either a string or an array of blocks.
+func ccContent(raw json.RawMessage) ([]ccBlock, string) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return nil, s
+	}
+	var blocks []ccBlock
+	if json.Unmarshal(raw, &blocks) != nil {
+		return nil, ""
+	}
+	var texts []string
+	for _, b := range blocks {
+		if b.Type == "text" && b.Text != "" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return blocks, strings.Join(texts, "\n")
+}
+
+func ccCommand(text string) bool {
+	for _, prefix := range ccCommandPrefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolSummary describes a tool call of another agent by its name and the
+// argument that says the most about it.
+func toolSummary(name string, input json.RawMessage) string {
+	var args map[string]any
+	if json.Unmarshal(input, &args) != nil {
+		return name
+	}
+	for _, key := range []string{"file_path", "path", "command", "pattern", "url", "query", "description"} {
+		if v, ok := args[key].(string); ok && v != "" {
+			if i := strings.IndexByte(v, '\n'); i >= 0 {
+				v = v[:i] + " …"
+			}
+			return name + " " + v
+		}
+	}
+	return name
+}
@@ -0,0 +1,100 @@
+// Package history reads the conversation logs of other coding agents, so
+// users moving to tinker keep their history.
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// Format is a kind of conversation log
+type Format string
+
+const (
+	// Claude Code's ~/.claude/projects/<dir>/<session>.jsonl
+	FormatClaudeCode Format = "claude-code"
+	// Aider's .aider.chat.history.md, written in the repository it ran in
+	FormatAider Format = "aider"
+)
+
+// Conversation is one conversation of a log, in tinker records
+type Conversation struct {
+	// Stable across imports of the same log, so it is imported once
+	ID        string
+	StartTime time.Time
+	// Directory the conversation ran in, empty if the log does not say
+	WorkDir string
+	// Project instructions file of the agent, relative to WorkDir, the
+	// counterpart of TINKER.md
+	MemoryFile string
+	Records    []storage.Record
+}
+
+// Detect tells the format of the log at path from its name.
+func Detect(path string) (Format, error) {
+	switch {
+	case strings.HasSuffix(path, ".jsonl"):
+		return FormatClaudeCode, nil
+	case strings.HasSuffix(path, ".md"):
+		return FormatAider, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %s, set it with --from", path)
+	}
+}
+
+// Read reads the conversations of the log at path, in format.
+func Read(path string, format Format) ([]Conversation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatClaudeCode:
+		c, err := readClaudeCode(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		// The id names the session file, so a log cannot place it elsewhere
+		if !storage.ValidSessionID(c.ID) {
+			c.ID = uuid.NewSHA1(uuid.NameSpaceURL, []byte("claude-code:"+abs)).String()
+		}
+		return []Conversation{c}, nil
+	case FormatAider:
+		// Aider does not record where it ran, but writes its history there
+		convs, err := readAider(f, abs)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		return convs, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, want %s or %s", format, FormatClaudeCode, FormatAider)
+	}
+}
+
+// answer appends the answer of a turn from its parts, if it has any.
+func answer(records []storage.Record, parts []string, at time.Time, input, output int) []storage.Record {
+	text := strings.TrimSpace(strings.Join(parts, "\n\n"))
+	if text == "" {
+		return records
+	}
+	return append(records, storage.Record{
+		Timestamp:    at,
+		Source:       storage.ModelResp,
+		Content:      text,
+		Live:         true,
+		InputTokens:  input,
+		OutputTokens: output,
+	})
+}
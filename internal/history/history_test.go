@@ -0,0 +1,147 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const claudeCodeLog = `{"type":"summary","summary":"Fix the parser","leafUuid":"x"}
+{"type":"user","isMeta":true,"sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:00:00Z","message":{"role":"user","content":"Caveat: the messages below were generated by the user while running local commands."}}
+{"type":"user","sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:00:01Z","message":{"role":"user","content":"<command-name>/clear</command-name>"}}
+{"type":"user","sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:00:02Z","message":{"role":"user","content":"fix the parser"}}
+{"type":"assistant","sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:00:05Z","message":{"id":"msg_1","role":"assistant","content":[{"type":"thinking","thinking":"look at parse.go"}],"usage":{"input_tokens":10,"cache_read_input_tokens":1000,"output_tokens":30}}}
+{"type":"assistant","sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:00:06Z","message":{"id":"msg_1","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Read","input":{"file_path":"/work/app/parse.go"}}],"usage":{"input_tokens":10,"cache_read_input_tokens":1000,"output_tokens":30}}}
+{"type":"user","sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:00:07Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"package app"}]}}
+{"type":"assistant","isSidechain":true,"sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:00:08Z","message":{"id":"msg_sub","role":"assistant","content":[{"type":"text","text":"subagent noise"}]}}
+{"type":"assistant","sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:00:10Z","message":{"id":"msg_2","role":"assistant","content":[{"type":"text","text":"The parser is fixed."}],"usage":{"input_tokens":5,"cache_read_input_tokens":1100,"output_tokens":8}}}
+{"type":"user","sessionId":"6f1c2d3e-0000-4000-8000-000000000001","cwd":"/work/app","timestamp":"2025-06-01T10:01:00Z","message":{"role":"user","content":[{"type":"text","text":"thanks, add a test"}]}}
+`
+
+const aiderHistory = `
+# aider chat started at 2025-06-01 09:00:00
+
+> Aider v0.80.0
+> Model: sonnet with diff edit format
+
+#### /add parse.go
+
+> Added parse.go to the chat
+
+#### fix the parser
+#### it panics on empty input
+
+Check the length first:
+
+parse.go
+` + "```" + `go
+if len(s) == 0 {
+	return nil
+}
+` + "```" + `
+
+> Applied edit to parse.go
+
+#### /ask why did it panic?
+
+It indexed s[0] without a check.
+
+# aider chat started at 2025-06-02 09:00:00
+
+#### /exit
+`
+
+func writeLog(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestReadClaudeCode(t *testing.T) {
+	convs, err := Read(writeLog(t, "session.jsonl", claudeCodeLog), FormatClaudeCode)
+	require.NoError(t, err)
+	require.Len(t, convs, 1)
+	c := convs[0]
+	assert.Equal(t, "6f1c2d3e-0000-4000-8000-000000000001", c.ID)
+	assert.Equal(t, "/work/app", c.WorkDir)
+	assert.Equal(t, "CLAUDE.md", c.MemoryFile)
+	assert.Equal(t, time.Date(2025, 6, 1, 10, 0, 2, 0, time.UTC), c.StartTime, "the conversation starts with its first prompt")
+
+	var sources []storage.RecordType
+	for _, r := range c.Records {
+		sources = append(sources, r.Source)
+	}
+	require.Equal(t, []storage.RecordType{storage.Prompt, storage.Thinking, storage.ToolUse, storage.ModelResp, storage.Prompt}, sources,
+		"meta lines, local commands and subagents are left out")
+	assert.Equal(t, "fix the parser", c.Records[0].Content)
+	assert.Equal(t, time.Date(2025, 6, 1, 10, 0, 2, 0, time.UTC), c.Records[0].Timestamp)
+
+	use := c.Records[2]
+	block, err := storage.DecodeToolUse(use.Content)
+	require.NoError(t, err)
+	assert.Equal(t, "Read", block.Name)
+	assert.Equal(t, "Read /work/app/parse.go", use.Summary)
+	assert.Equal(t, "package app", use.Output)
+	assert.Equal(t, 1010, use.InputTokens, "cached input counts as prompt")
+	assert.Equal(t, 30, use.OutputTokens, "a response split over lines is counted once")
+
+	assert.Equal(t, "The parser is fixed.", c.Records[3].Content)
+	assert.Equal(t, 1105, c.Records[3].InputTokens)
+	assert.Equal(t, "thanks, add a test", c.Records[4].Content)
+}
+
+func TestReadClaudeCode_UnsafeSessionID(t *testing.T) {
+	log := strings.ReplaceAll(claudeCodeLog, "6f1c2d3e-0000-4000-8000-000000000001", "../../x")
+	path := writeLog(t, "session.jsonl", log)
+	convs, err := Read(path, FormatClaudeCode)
+	require.NoError(t, err)
+	require.Len(t, convs, 1)
+	assert.True(t, storage.ValidSessionID(convs[0].ID), convs[0].ID)
+
+	// The same log gets the same id, so a second import is skipped
+	again, err := Read(path, FormatClaudeCode)
+	require.NoError(t, err)
+	assert.Equal(t, convs[0].ID, again[0].ID)
+}
+
+func TestReadAider(t *testing.T) {
+	path := writeLog(t, ".aider.chat.history.md", aiderHistory)
+	convs, err := Read(path, FormatAider)
+	require.NoError(t, err)
+	require.Len(t, convs, 1, "the chat that only ran /exit has no conversation")
+	c := convs[0]
+	assert.Equal(t, filepath.Dir(path), c.WorkDir)
+	assert.Equal(t, time.Date(2025, 6, 1, 9, 0, 0, 0, time.Local), c.StartTime)
+
+	var contents []string
+	for _, r := range c.Records {
+		contents = append(contents, r.Content)
+	}
+	assert.Equal(t, []string{
+		"fix the parser\nit panics on empty input",
+		"Check the length first:\n\nparse.go\n```go\nif len(s) == 0 {\n\treturn nil\n}\n```",
+		"why did it panic?",
+		"It indexed s[0] without a check.",
+	}, contents)
+
+	again, err := Read(path, FormatAider)
+	require.NoError(t, err)
+	assert.Equal(t, c.ID, again[0].ID, "the same chat imports under the same ID")
+}
+
+func TestDetect(t *testing.T) {
+	f, err := Detect("/home/me/.claude/projects/-work-app/abc.jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, FormatClaudeCode, f)
+	f, err = Detect(".aider.chat.history.md")
+	require.NoError(t, err)
+	assert.Equal(t, FormatAider, f)
+	_, err = Detect("notes.txt")
+	assert.Error(t, err)
+}
@@ -27,6 +27,7 @@ type MCPTool struct {
 	Name        string
 	Description string
 	InputSchema *jsonschema.Schema
+	Annotations *ToolAnnotations
 }
 
 type server struct {
@@ -232,6 +233,7 @@ func (m *Manager) Start(ctx context.Context, configs []ServerConfig) ([]MCPTool,
 				Name:        prefixed,
 				Description: t.Description,
 				InputSchema: t.InputSchema,
+				Annotations: t.Annotations,
 			})
 		}
 	}
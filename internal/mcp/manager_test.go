@@ -115,3 +115,26 @@ func TestManager_Close_Empty(t *testing.T) {
 	m := NewManager()
 	assert.NoError(t, m.Close())
 }
+
+func TestToolAnnotations_Defaults(t *testing.T) {
+	var tools []Tool
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"name": "plain"},
+		{"name": "lookup", "annotations": {"readOnlyHint": true, "openWorldHint": false}},
+		{"name": "upsert", "annotations": {"destructiveHint": false, "idempotentHint": true}}
+	]`), &tools))
+
+	plain, lookup, upsert := tools[0].Annotations, tools[1].Annotations, tools[2].Annotations
+	assert.False(t, plain.ReadOnly())
+	assert.True(t, plain.Destructive())
+	assert.False(t, plain.Idempotent())
+	assert.True(t, plain.OpenWorld())
+
+	assert.True(t, lookup.ReadOnly())
+	assert.False(t, lookup.Destructive())
+	assert.True(t, lookup.Idempotent())
+	assert.False(t, lookup.OpenWorld())
+
+	assert.False(t, upsert.Destructive())
+	assert.True(t, upsert.Idempotent())
+}
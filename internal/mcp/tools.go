@@ -17,4 +17,40 @@ type Tool struct {
 	Name        string             `json:"name"`
 	Description string             `json:"description"`
 	InputSchema *jsonschema.Schema `json:"inputSchema"`
+	Annotations *ToolAnnotations   `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are the hints a server gives about what a tool does. Hints
+// left out take the defaults of the MCP specification, those of a tool that
+// may destroy data and reach the outside world.
+type ToolAnnotations struct {
+	ReadOnlyHint    *bool `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool `json:"openWorldHint,omitempty"`
+}
+
+// ReadOnly reports the read-only hint, false by default.
+func (a *ToolAnnotations) ReadOnly() bool {
+	return a != nil && a.ReadOnlyHint != nil && *a.ReadOnlyHint
+}
+
+// Destructive reports the destructive hint, true by default. Read-only tools
+// are never destructive.
+func (a *ToolAnnotations) Destructive() bool {
+	if a.ReadOnly() {
+		return false
+	}
+	return a == nil || a.DestructiveHint == nil || *a.DestructiveHint
+}
+
+// Idempotent reports the idempotent hint, false by default. Read-only tools
+// are always idempotent.
+func (a *ToolAnnotations) Idempotent() bool {
+	return a.ReadOnly() || (a != nil && a.IdempotentHint != nil && *a.IdempotentHint)
+}
+
+// OpenWorld reports the open world hint, true by default.
+func (a *ToolAnnotations) OpenWorld() bool {
+	return a == nil || a.OpenWorldHint == nil || *a.OpenWorldHint
 }
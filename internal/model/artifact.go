@@ -0,0 +1,85 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// Tool outputs taking more than a tenth of the context are stored as
+// artifacts, and the model is sent their first and last lines
+const artifactShare = 10
+
+// Bytes of an artifact's head and tail shown in place of the output
+const artifactExcerpt = 4096
+
+// SetArtifacts stores the tool outputs too large for the context in blobs,
+// as owner, instead of sending them to the model, which reads them in chunks
+// with read_artifact. The context window closes blobs with its database.
+func (cw *ContextWindow) SetArtifacts(blobs *storage.Blobs, owner string) {
+	cw.blobs = blobs
+	cw.blobOwner = owner
+}
+
+// overflow stores the output of tool name as an artifact when it exceeds
+// the budget of tool outputs, and returns what the model is sent instead.
+func (cw *ContextWindow) overflow(ctx context.Context, name, out string) (string, error) {
+	tokens := storage.TokenCount(out)
+	// Chunks of artifacts were already cut to size by the model
+	if cw.blobs == nil || name == tools.ToolNameReadArtifact || tokens <= cw.contextSize()/artifactShare {
+		return out, nil
+	}
+
+	blob, err := cw.blobs.Put(ctx, cw.blobOwner, []byte(out), "text/plain; charset=utf-8", name+".txt")
+	if err != nil {
+		return "", fmt.Errorf("store %s output: %w", name, err)
+	}
+
+	lines := tools.ArtifactLines(out)
+	head, headLines := excerpt(lines, artifactExcerpt, false)
+	tail, tailLines := excerpt(lines[headLines:], artifactExcerpt, true)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The output of %s is too large for the context (%d bytes, about %d tokens, %d lines). "+
+		"It is stored as artifact %s, read the lines you need with %s.\n\n",
+		name, len(out), tokens, len(lines), blob.Hash, tools.ToolNameReadArtifact)
+	b.WriteString(head)
+	if omitted := len(lines) - headLines - tailLines; omitted > 0 {
+		fmt.Fprintf(&b, "\n... (lines %d to %d omitted)\n", headLines+1, headLines+omitted)
+	}
+	b.WriteString(tail)
+	return b.String(), nil
+}
+
+// readArtifact returns the content of an artifact stored by SetArtifacts.
+func (cw *ContextWindow) readArtifact(ctx context.Context, id string) (string, error) {
+	_, data, err := cw.blobs.Get(ctx, cw.blobOwner, id)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// excerpt joins whole lines from the start of lines, or from their end,
+// up to n bytes and returns how many it took.
+func excerpt(lines []string, n int, fromEnd bool) (string, int) {
+	size, taken := 0, 0
+	for taken < len(lines) {
+		line := lines[taken]
+		if fromEnd {
+			line = lines[len(lines)-1-taken]
+		}
+		if size+len(line)+1 > n {
+			break
+		}
+		size += len(line) + 1
+		taken++
+	}
+	if fromEnd {
+		return strings.Join(lines[len(lines)-taken:], "\n"), taken
+	}
+	return strings.Join(lines[:taken], "\n"), taken
+}
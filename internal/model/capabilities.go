@@ -0,0 +1,102 @@
+package model
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Capabilities describes what a model supports and what it costs
+type Capabilities struct {
+	Provider ProviderName
+	// Calls tools
+	Tools bool
+	// Takes images as input
+	Vision bool
+	// Reasons before answering, billed as output tokens
+	Thinking bool
+	// Tokens an answer can take at most
+	MaxOutputTokens int
+	// Tokens a call can take in, prompt and answer included
+	ContextWindow int
+	// Fewest tokens an explicit cache of the conversation holds, 0 if the
+	// model is not cached explicitly
+	MinCacheTokens int
+	Pricing        Pricing
+}
+
+// capabilities is the registry of the models tinker knows. Other model
+// names are passed to the provider as given, with no capabilities assumed.
+var capabilities = map[ModelVersion]Capabilities{
+	Claude46Opus:   claude(128_000, Pricing{InputPerMTok: 5, OutputPerMTok: 25}),
+	Claude46Sonnet: claude(64_000, Pricing{InputPerMTok: 3, OutputPerMTok: 15}),
+	Claude45Haiku:  claude(64_000, Pricing{InputPerMTok: 1, OutputPerMTok: 5}),
+	Claude45Opus:   claude(64_000, Pricing{InputPerMTok: 5, OutputPerMTok: 25}),
+	Claude45Sonnet: claude(64_000, Pricing{InputPerMTok: 3, OutputPerMTok: 15}),
+	Claude41Opus:   claude(32_000, Pricing{InputPerMTok: 15, OutputPerMTok: 75}),
+	Claude4Opus:    claude(32_000, Pricing{InputPerMTok: 15, OutputPerMTok: 75}),
+	Claude4Sonnet:  claude(64_000, Pricing{InputPerMTok: 3, OutputPerMTok: 15}),
+
+	Gemini3Pro:        gemini(true, 1_048_576, 65_536, 4096, Pricing{InputPerMTok: 2, OutputPerMTok: 12, CachedInputPerMTok: 0.20}),
+	Gemini25Pro:       gemini(true, 1_048_576, 65_536, 4096, Pricing{InputPerMTok: 1.25, OutputPerMTok: 10, CachedInputPerMTok: 0.125}),
+	Gemini25Flash:     gemini(true, 1_048_576, 65_536, 1024, Pricing{InputPerMTok: 0.30, OutputPerMTok: 2.50, CachedInputPerMTok: 0.03}),
+	Gemini20Flash:     gemini(false, 1_048_576, 8_192, 4096, Pricing{InputPerMTok: 0.10, OutputPerMTok: 0.40, CachedInputPerMTok: 0.025}),
+	Gemini20FlashLite: gemini(false, 1_048_576, 8_192, 0, Pricing{InputPerMTok: 0.075, OutputPerMTok: 0.30}),
+	Gemini15Pro:       gemini(false, 2_097_152, 8_192, 32_768, Pricing{InputPerMTok: 1.25, OutputPerMTok: 5, CachedInputPerMTok: 0.3125}),
+	Gemini15Flash:     gemini(false, 1_048_576, 8_192, 32_768, Pricing{InputPerMTok: 0.075, OutputPerMTok: 0.30, CachedInputPerMTok: 0.01875}),
+}
+
+// Every Claude model tinker knows calls tools, sees images, thinks and takes
+// 200k tokens in
+func claude(maxOutput int, p Pricing) Capabilities {
+	return Capabilities{
+		Provider:        ProviderAnthropic,
+		Tools:           true,
+		Vision:          true,
+		Thinking:        true,
+		MaxOutputTokens: maxOutput,
+		ContextWindow:   200_000,
+		Pricing:         p,
+	}
+}
+
+func gemini(thinking bool, contextWindow, maxOutput, minCache int, p Pricing) Capabilities {
+	return Capabilities{
+		Provider:        ProviderGemini,
+		Tools:           true,
+		Vision:          true,
+		Thinking:        thinking,
+		MaxOutputTokens: maxOutput,
+		ContextWindow:   contextWindow,
+		MinCacheTokens:  minCache,
+		Pricing:         p,
+	}
+}
+
+// LookupCapabilities returns the capabilities of version and whether the
+// registry knows it.
+func LookupCapabilities(version ModelVersion) (Capabilities, bool) {
+	c, ok := capabilities[version]
+	return c, ok
+}
+
+// KnownModels returns the models of the registry by provider, then name.
+func KnownModels() []ModelVersion {
+	versions := make([]ModelVersion, 0, len(capabilities))
+	for v := range capabilities {
+		versions = append(versions, v)
+	}
+	slices.SortFunc(versions, func(a, b ModelVersion) int {
+		return cmp.Or(cmp.Compare(capabilities[a].Provider, capabilities[b].Provider), cmp.Compare(a, b))
+	})
+	return versions
+}
+
+// RequireTools fails if version is known not to call tools, which an agent
+// with a toolset needs. Unknown models are given the benefit of the doubt.
+func RequireTools(version ModelVersion) error {
+	if c, ok := capabilities[version]; ok && !c.Tools {
+		return fmt.Errorf("model %s does not support tool use, pick another with --model (see `tinker model`)", version)
+	}
+	return nil
+}
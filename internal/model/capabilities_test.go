@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnownModels(t *testing.T) {
+	versions := KnownModels()
+	require.Len(t, versions, len(capabilities))
+	assert.Equal(t, Claude45Haiku, versions[0])
+
+	for _, v := range versions {
+		c, ok := LookupCapabilities(v)
+		require.True(t, ok, v)
+		assert.Equal(t, ProviderOf(v), c.Provider, v)
+		assert.Positive(t, c.ContextWindow, v)
+		assert.Less(t, c.MaxOutputTokens, c.ContextWindow, v)
+	}
+	for _, v := range DefaultModels {
+		assert.Contains(t, versions, v)
+	}
+}
+
+func TestRequireTools(t *testing.T) {
+	assert.NoError(t, RequireTools(Claude46Sonnet))
+	assert.NoError(t, RequireTools("some-new-model"))
+
+	capabilities["no-tools"] = Capabilities{Provider: ProviderGemini}
+	t.Cleanup(func() { delete(capabilities, "no-tools") })
+	assert.ErrorContains(t, RequireTools("no-tools"), "does not support tool use")
+}
+
+func TestCacheSavings(t *testing.T) {
+	// 1M cached tokens of Gemini 2.5 Pro cost 0.125 instead of 1.25
+	assert.InDelta(t, 1.125, CacheSavings(Gemini25Pro, 1_000_000), 1e-9)
+	assert.Zero(t, CacheSavings(Claude46Sonnet, 1_000_000))
+	assert.Zero(t, CacheSavings("some-new-model", 1_000_000))
+}
@@ -3,8 +3,12 @@ package model
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -29,15 +33,28 @@ type ClaudeModel struct {
 	model        ModelVersion
 	toolExecutor tools.ToolExecutor
 	cache        anthropic.CacheControlEphemeralParam
+	lastUsage    Usage
+	onToolInput  func(ToolInputDelta)
+	outputSchema map[string]any
+	// Tokens the model may think for before each response, 0 to not think
+	thinkingBudget int64
 }
 
+// Beta letting Claude 4 models think between tool calls, not only before
+// the first one
+const interleavedThinkingBeta = "interleaved-thinking-2025-05-14"
+
 func NewClaudeModel(model ModelVersion) (*ClaudeModel, error) {
 	apiKey := os.Getenv("ANTHROPIC_API_KEY")
 	if apiKey == "" {
 		return nil, fmt.Errorf("ANTHROPIC_API_KEY not set")
 	}
 
-	client := anthropic.NewClient(option.WithAPIKey(apiKey))
+	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
+	if c := debugHTTPClient(); c != nil {
+		opts = append(opts, option.WithHTTPClient(c))
+	}
+	client := anthropic.NewClient(opts...)
 	return &ClaudeModel{
 		client: &client,
 		model:  model,
@@ -45,8 +62,53 @@ func NewClaudeModel(model ModelVersion) (*ClaudeModel, error) {
 	}, nil
 }
 
+// validateClaudeKey counts the tokens of a one word message, which is free.
+func validateClaudeKey(ctx context.Context, key string) error {
+	client := anthropic.NewClient(option.WithAPIKey(key))
+	_, err := client.Messages.CountTokens(ctx, anthropic.MessageCountTokensParams{
+		Model:    anthropic.Model(DefaultModels[ProviderAnthropic]),
+		Messages: []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("hi"))},
+	})
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return fmt.Errorf("validate API key: %d %s", apiErr.StatusCode, http.StatusText(apiErr.StatusCode))
+	}
+	if err != nil {
+		return fmt.Errorf("validate API key: %w", err)
+	}
+	return nil
+}
+
 func (c *ClaudeModel) MaxTokens() int {
-	return 300_000
+	if caps, ok := LookupCapabilities(c.model); ok {
+		return caps.ContextWindow
+	}
+	return 200_000
+}
+
+// Version implements the Versioned interface
+func (c *ClaudeModel) Version() ModelVersion {
+	return c.model
+}
+
+// LastUsage implements the UsageReporter interface
+func (c *ClaudeModel) LastUsage() Usage {
+	return c.lastUsage
+}
+
+// SetToolInputHandler implements the Streamer interface
+func (c *ClaudeModel) SetToolInputHandler(handler func(ToolInputDelta)) {
+	c.onToolInput = handler
+}
+
+// SetOutputSchema implements the StructuredOutput interface
+func (c *ClaudeModel) SetOutputSchema(schema map[string]any) {
+	c.outputSchema = schema
+}
+
+// SetThinkingBudget implements the Thinker interface
+func (c *ClaudeModel) SetThinkingBudget(tokens int) {
+	c.thinkingBudget = int64(tokens)
 }
 
 // SetToolExecutor sets the tool executor for the Claude model
@@ -60,28 +122,7 @@ func (c *ClaudeModel) Call(ctx context.Context, inputs []storage.Record) ([]stor
 		availableTools = c.toolExecutor.GetRegisteredTools()
 	}
 
-	var systemBlocks []anthropic.TextBlockParam
-	var messages []anthropic.MessageParam
-
-	for _, rec := range inputs {
-		switch rec.Source {
-		case storage.SystemPrompt:
-			systemBlocks = append(systemBlocks, anthropic.TextBlockParam{
-				Text:         rec.Content,
-				CacheControl: c.cache,
-			})
-		case storage.Prompt:
-			messages = append(messages, anthropic.NewUserMessage(anthropic.NewTextBlock(rec.Content)))
-		case storage.ModelResp:
-			messages = append(messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(rec.Content)))
-		case storage.ToolResult:
-			// Store raw content in a message,
-			// not really efficient so there should be a better solution
-			messages = append(messages, anthropic.NewUserMessage(
-				anthropic.NewTextBlock(rec.Content),
-			))
-		}
-	}
+	systemBlocks, messages := c.toClaudeMessages(inputs)
 
 	params := anthropic.MessageNewParams{
 		Model:     anthropic.Model(c.model),
@@ -94,29 +135,66 @@ func (c *ClaudeModel) Call(ctx context.Context, inputs []storage.Record) ([]stor
 	}
 
 	if len(availableTools) > 0 {
-		tools := getClaudeToolParams(availableTools)
+		tools, err := getClaudeToolParams(availableTools)
+		if err != nil {
+			return nil, 0, err
+		}
 		params.Tools = tools
 	}
 
+	if c.outputSchema != nil {
+		params.OutputConfig = anthropic.OutputConfigParam{
+			Format: anthropic.JSONOutputFormatParam{Schema: c.outputSchema},
+		}
+	}
+
+	if c.thinkingBudget > 0 {
+		// The budget is part of max_tokens, the answer comes on top
+		params.MaxTokens += c.thinkingBudget
+		if caps, ok := LookupCapabilities(c.model); ok {
+			params.MaxTokens = min(params.MaxTokens, int64(caps.MaxOutputTokens))
+		}
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(c.thinkingBudget)
+	}
+
 	// Send the user prompt
-	resp, err := c.client.Messages.New(ctx, params)
+	start := time.Now()
+	resp, err := c.send(ctx, params)
 	if err != nil {
 		return nil, 0, fmt.Errorf("claude api: %w", err)
 	}
 
 	var events []storage.Record
 	totalTokens := int(resp.Usage.InputTokens + resp.Usage.OutputTokens)
+	usage := Usage{
+		InputTokens:   int(resp.Usage.InputTokens),
+		OutputTokens:  int(resp.Usage.OutputTokens),
+		FirstResponse: time.Since(start),
+	}
 
 	for hasToolUse(resp.Content) {
+		first := len(events)
 		var assistantContent []anthropic.ContentBlockParamUnion
 
+		// Thinking blocks go back as they came, signature included, or the
+		// API rejects the tool results that follow them
 		for _, block := range resp.Content {
-			if block.Type == "text" && block.Text != "" {
-				assistantContent = append(assistantContent, anthropic.NewTextBlock(block.Text))
-			} else if block.Type == "tool_use" {
+			switch block.Type {
+			case "thinking":
+				assistantContent = append(assistantContent, anthropic.NewThinkingBlock(block.Signature, block.Thinking))
+			case "redacted_thinking":
+				assistantContent = append(assistantContent, anthropic.NewRedactedThinkingBlock(block.Data))
+			case "text":
+				if block.Text != "" {
+					assistantContent = append(assistantContent, anthropic.NewTextBlock(block.Text))
+				}
+			case "tool_use":
 				assistantContent = append(assistantContent, anthropic.NewToolUseBlock(block.ID, block.Input, block.Name))
 			}
 		}
+		if rec, ok := thinkingRecord(resp.Content); ok {
+			events = append(events, rec)
+		}
 
 		messages = append(messages, anthropic.MessageParam{
 			Role:    anthropic.MessageParamRoleAssistant,
@@ -128,46 +206,66 @@ func (c *ClaudeModel) Call(ctx context.Context, inputs []storage.Record) ([]stor
 		// Execute the tools that the LLM chooses
 		for _, block := range resp.Content {
 			if block.Type == "tool_use" {
-				inputStr := string(block.Input)
 				// Middlewares go here
 				// but since we don't have any use case for it yet
 				out, err := c.toolExecutor.ExecuteTool(ctx, block.Name, block.Input)
+				summary := tools.FormatResult(block.Name, block.Input, out, err)
 				if err != nil {
 					out = fmt.Sprintf("error executing tool: %v", err)
 				}
 
-				call := fmt.Sprintf("%s(%s)", block.Name, inputStr)
+				call := storage.EncodeToolUse(block.Name, block.Input)
+				isErr := err != nil
+				status := storage.StatusOK
+				if isErr {
+					status = storage.StatusError
+				}
 				events = append(events, storage.Record{
 					Source:    storage.ToolUse,
 					Content:   call,
 					Live:      true,
 					EstTokens: storage.TokenCount(call),
+					Status:    status,
+					Summary:   summary,
+					Output:    out,
 				})
-				isErr := err != nil
 				toolResults = append(toolResults, anthropic.NewToolResultBlock(block.ID, out, isErr))
 			}
 		}
 
+		requestTokens(events[first:], int(resp.Usage.InputTokens), int(resp.Usage.OutputTokens))
+
 		// Send the result back to the LLM
 		// and continue using the next tools
 		messages = append(messages, anthropic.NewUserMessage(toolResults...))
 
 		params.Messages = messages
-		resp, err = c.client.Messages.New(ctx, params)
+		resp, err = c.send(ctx, params)
 		if err != nil {
 			return nil, 0, fmt.Errorf("claude api (tool continuation): %w", err)
 		}
 
 		totalTokens += int(resp.Usage.InputTokens + resp.Usage.OutputTokens)
+		usage.InputTokens += int(resp.Usage.InputTokens)
+		usage.OutputTokens += int(resp.Usage.OutputTokens)
+	}
+
+	c.lastUsage = usage
+
+	first := len(events)
+	if rec, ok := thinkingRecord(resp.Content); ok {
+		events = append(events, rec)
 	}
 
-	// Final response from the LLM
-	var responseText string
+	// Final response from the LLM.
+	// Blocks are only separated here, formatting is left to the agent.
+	var textBlocks []string
 	for _, block := range resp.Content {
 		if block.Type == "text" && block.Text != "" {
-			responseText += block.Text
+			textBlocks = append(textBlocks, block.Text)
 		}
 	}
+	responseText := strings.Join(textBlocks, "\n")
 
 	events = append(events, storage.Record{
 		Source:    storage.ModelResp,
@@ -175,10 +273,179 @@ func (c *ClaudeModel) Call(ctx context.Context, inputs []storage.Record) ([]stor
 		Live:      true,
 		EstTokens: storage.TokenCount(responseText),
 	})
+	requestTokens(events[first:], int(resp.Usage.InputTokens), int(resp.Usage.OutputTokens))
 
 	return events, totalTokens, nil
 }
 
+// send issues a request. With a tool input handler set the response is
+// streamed, so tool inputs can be previewed while they are being generated.
+// Thinking streams too, as the SDK refuses max_tokens that large otherwise.
+func (c *ClaudeModel) send(ctx context.Context, params anthropic.MessageNewParams) (*anthropic.Message, error) {
+	var opts []option.RequestOption
+	if c.thinkingBudget > 0 {
+		opts = append(opts, option.WithHeaderAdd("anthropic-beta", interleavedThinkingBeta))
+	}
+	if c.onToolInput == nil && c.thinkingBudget == 0 {
+		return c.client.Messages.New(ctx, params, opts...)
+	}
+
+	stream := c.client.Messages.NewStreaming(ctx, params, opts...)
+	defer stream.Close()
+
+	var msg anthropic.Message
+	tracker := &toolInputTracker{handler: c.onToolInput}
+	for stream.Next() {
+		event := stream.Current()
+		if err := msg.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("accumulate stream: %w", err)
+		}
+		if c.onToolInput != nil {
+			tracker.observe(event)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// toolInputTracker assembles tool inputs from stream events, by block index
+type toolInputTracker struct {
+	handler func(ToolInputDelta)
+	blocks  map[int64]*ToolInputDelta
+}
+
+func (t *toolInputTracker) observe(event anthropic.MessageStreamEventUnion) {
+	if t.blocks == nil {
+		t.blocks = make(map[int64]*ToolInputDelta)
+	}
+
+	switch event.Type {
+	case "content_block_start":
+		if event.ContentBlock.Type != "tool_use" {
+			return
+		}
+		d := &ToolInputDelta{ToolUseID: event.ContentBlock.ID, Name: event.ContentBlock.Name}
+		t.blocks[event.Index] = d
+		t.handler(*d)
+	case "content_block_delta":
+		d, ok := t.blocks[event.Index]
+		if !ok || event.Delta.Type != "input_json_delta" {
+			return
+		}
+		d.PartialInput += event.Delta.PartialJSON
+		t.handler(*d)
+	case "content_block_stop":
+		d, ok := t.blocks[event.Index]
+		if !ok {
+			return
+		}
+		d.Done = true
+		t.handler(*d)
+		delete(t.blocks, event.Index)
+	}
+}
+
+// toClaudeMessages splits records into system blocks and conversation messages.
+func (c *ClaudeModel) toClaudeMessages(inputs []storage.Record) ([]anthropic.TextBlockParam, []anthropic.MessageParam) {
+	var systemBlocks []anthropic.TextBlockParam
+	var messages []anthropic.MessageParam
+
+	for _, rec := range inputs {
+		switch rec.Source {
+		case storage.SystemPrompt:
+			systemBlocks = append(systemBlocks, anthropic.TextBlockParam{
+				Text:         rec.Content,
+				CacheControl: c.cache,
+			})
+		case storage.SystemNote:
+			// Claude has no system turns, so notes extend the system prompt.
+			// They change between calls, so they sit after the cached prefix.
+			systemBlocks = append(systemBlocks, anthropic.TextBlockParam{Text: rec.Content})
+		case storage.Prompt:
+			messages = append(messages, anthropic.NewUserMessage(anthropic.NewTextBlock(rec.Content)))
+		case storage.ModelResp:
+			messages = append(messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(rec.Content)))
+		case storage.ToolResult:
+			// Store raw content in a message,
+			// not really efficient so there should be a better solution
+			messages = append(messages, anthropic.NewUserMessage(
+				anthropic.NewTextBlock(rec.Content),
+			))
+		}
+	}
+
+	return systemBlocks, messages
+}
+
+// CountTokens asks the Anthropic API how many input tokens the records occupy.
+// The system prompt and registered tool definitions are counted too,
+// since they are sent with every call.
+func (c *ClaudeModel) CountTokens(ctx context.Context, inputs []storage.Record) (int, error) {
+	systemBlocks, messages := c.toClaudeMessages(inputs)
+
+	params := anthropic.MessageCountTokensParams{
+		Model:    anthropic.Model(c.model),
+		Messages: messages,
+	}
+
+	if len(systemBlocks) > 0 {
+		params.System = anthropic.MessageCountTokensParamsSystemUnion{OfTextBlockArray: systemBlocks}
+	}
+
+	if c.toolExecutor != nil {
+		if availableTools := c.toolExecutor.GetRegisteredTools(); len(availableTools) > 0 {
+			toolParams, err := getClaudeToolParams(availableTools)
+			if err != nil {
+				return 0, err
+			}
+			params.Tools = toCountTokensTools(toolParams)
+		}
+	}
+
+	resp, err := c.client.Messages.CountTokens(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("claude count tokens: %w", err)
+	}
+
+	return int(resp.InputTokens), nil
+}
+
+// toCountTokensTools converts tool params to the count_tokens union.
+// Both unions share the ToolParam variant, which is all we build.
+func toCountTokensTools(toolParams []anthropic.ToolUnionParam) []anthropic.MessageCountTokensToolUnionParam {
+	var countParams []anthropic.MessageCountTokensToolUnionParam
+	for _, p := range toolParams {
+		if p.OfTool == nil {
+			continue
+		}
+		countParams = append(countParams, anthropic.MessageCountTokensToolUnionParam{OfTool: p.OfTool})
+	}
+	return countParams
+}
+
+// thinkingRecord keeps the reasoning of a response for display. It is not
+// live: earlier turns go back without their thinking, which the API allows,
+// and redacted thinking has nothing to show.
+func thinkingRecord(content []anthropic.ContentBlockUnion) (storage.Record, bool) {
+	var thoughts []string
+	for _, block := range content {
+		if block.Type == "thinking" && block.Thinking != "" {
+			thoughts = append(thoughts, block.Thinking)
+		}
+	}
+	if len(thoughts) == 0 {
+		return storage.Record{}, false
+	}
+	text := strings.Join(thoughts, "\n\n")
+	return storage.Record{
+		Source:    storage.Thinking,
+		Content:   text,
+		EstTokens: storage.TokenCount(text),
+	}, true
+}
+
 func hasToolUse(content []anthropic.ContentBlockUnion) bool {
 	for _, block := range content {
 		if block.Type == "tool_use" {
@@ -188,22 +455,26 @@ func hasToolUse(content []anthropic.ContentBlockUnion) bool {
 	return false
 }
 
-// NOTE: This only supports built-in tools for now
-// so we should handle custom tools with ToolBuilder struct
-func getClaudeToolParams(availableTools []tools.ToolDefinition) []anthropic.ToolUnionParam {
+// getClaudeToolParams converts the tool definitions to Claude's format,
+// failing on a schema it cannot take.
+func getClaudeToolParams(availableTools []tools.ToolDefinition) ([]anthropic.ToolUnionParam, error) {
 	var toolParams []anthropic.ToolUnionParam
 	for _, tool := range availableTools {
-		schema, err := json.Marshal(tool.InputSchema)
+		schema, err := toolSchema(tool)
 		if err != nil {
-			panic(fmt.Sprintf("cannot marshal unified tool schema: %v", err))
+			return nil, err
+		}
+		// Claude takes any JSON schema, rebuilt from the generic form
+		data, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: marshal input schema: %w", tool.Name, err)
 		}
-
 		var anthropicSchema anthropic.ToolInputSchemaParam
-		if err := json.Unmarshal(schema, &anthropicSchema); err != nil {
-			panic(fmt.Sprintf("cannot unmarshal tool definition to Claude format: %v", err))
+		if err := json.Unmarshal(data, &anthropicSchema); err != nil {
+			return nil, fmt.Errorf("tool %s: convert input schema to Claude format: %w", tool.Name, err)
 		}
 
 		toolParams = append(toolParams, anthropic.ToolUnionParamOfTool(anthropicSchema, tool.Name))
 	}
-	return toolParams
+	return toolParams, nil
 }
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToCountTokensTools(t *testing.T) {
+	toolParams, err := getClaudeToolParams([]tools.ToolDefinition{
+		tools.BashDefinition,
+		tools.ReadFileDefinition,
+	})
+	require.NoError(t, err)
+
+	countParams := toCountTokensTools(toolParams)
+
+	require.Len(t, countParams, 2)
+	for i, p := range countParams {
+		require.NotNil(t, p.OfTool)
+		assert.Equal(t, toolParams[i].OfTool.Name, p.OfTool.Name)
+		assert.Equal(t, toolParams[i].OfTool.InputSchema, p.OfTool.InputSchema)
+	}
+}
+
+func TestToolInputTracker(t *testing.T) {
+	var deltas []ToolInputDelta
+	tracker := &toolInputTracker{handler: func(d ToolInputDelta) { deltas = append(deltas, d) }}
+
+	for _, raw := range []string{
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Editing"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"tu_1","name":"edit_file","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\": \"ma"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"in.go\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+	} {
+		var event anthropic.MessageStreamEventUnion
+		require.NoError(t, json.Unmarshal([]byte(raw), &event))
+		tracker.observe(event)
+	}
+
+	require.Len(t, deltas, 4)
+	assert.Equal(t, ToolInputDelta{ToolUseID: "tu_1", Name: "edit_file"}, deltas[0])
+	assert.Equal(t, `{"path": "ma`, deltas[1].PartialInput)
+	assert.Equal(t, `{"path": "main.go"}`, deltas[2].PartialInput)
+	assert.True(t, deltas[3].Done)
+	assert.Empty(t, tracker.blocks)
+}
+
+func TestToClaudeMessages(t *testing.T) {
+	c := &ClaudeModel{}
+
+	systemBlocks, messages := c.toClaudeMessages([]storage.Record{
+		{Source: storage.SystemPrompt, Content: "be helpful"},
+		{Source: storage.Prompt, Content: "hi"},
+		{Source: storage.ModelResp, Content: "hello"},
+		{Source: storage.ToolResult, Content: "ok"},
+	})
+
+	require.Len(t, systemBlocks, 1)
+	assert.Equal(t, "be helpful", systemBlocks[0].Text)
+	assert.Len(t, messages, 3)
+}
+
+func TestToClaudeMessages_SystemNote(t *testing.T) {
+	c := &ClaudeModel{}
+
+	systemBlocks, messages := c.toClaudeMessages([]storage.Record{
+		{Source: storage.SystemPrompt, Content: "be helpful"},
+		{Source: storage.Prompt, Content: "hi"},
+		{Source: storage.SystemNote, Content: "summary of earlier turns"},
+	})
+
+	require.Len(t, systemBlocks, 2)
+	assert.Equal(t, "summary of earlier turns", systemBlocks[1].Text)
+	assert.Len(t, messages, 1)
+}
+
+// sseMessage streams a message with the given content blocks, each block
+// sent whole in its content_block_start event
+func sseMessage(w http.ResponseWriter, stopReason string, blocks ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	send := func(event, data string) { fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data) }
+	send("message_start", `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-6","content":[],"usage":{"input_tokens":10,"output_tokens":0}}}`)
+	for i, b := range blocks {
+		send("content_block_start", fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":%s}`, i, b))
+		send("content_block_stop", fmt.Sprintf(`{"type":"content_block_stop","index":%d}`, i))
+	}
+	send("message_delta", fmt.Sprintf(`{"type":"message_delta","delta":{"stop_reason":%q},"usage":{"output_tokens":5}}`, stopReason))
+	send("message_stop", `{"type":"message_stop"}`)
+}
+
+func TestClaudeCall_Thinking(t *testing.T) {
+	var requests []map[string]any
+	var betas []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+		betas = append(betas, r.Header.Get("anthropic-beta"))
+		if len(requests) == 1 {
+			sseMessage(w, "tool_use",
+				`{"type":"thinking","thinking":"read it first","signature":"sig"}`,
+				`{"type":"tool_use","id":"tu_1","name":"read_file","input":{"path":"main.go"}}`)
+			return
+		}
+		sseMessage(w, "end_turn", `{"type":"text","text":"main.go is fine"}`)
+	}))
+	defer srv.Close()
+
+	client := anthropic.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"))
+	c := &ClaudeModel{client: &client, model: Claude46Sonnet, toolExecutor: &echoExecutor{}}
+	c.SetThinkingBudget(2048)
+
+	events, _, err := c.Call(context.Background(), []storage.Record{{Source: storage.Prompt, Content: "check main.go"}})
+	require.NoError(t, err)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, map[string]any{"type": "enabled", "budget_tokens": float64(2048)}, requests[0]["thinking"])
+	assert.Equal(t, float64(2048+4096), requests[0]["max_tokens"])
+	assert.Equal(t, []string{interleavedThinkingBeta, interleavedThinkingBeta}, betas)
+
+	// The thinking block goes back ahead of the tool use, signature included
+	messages := requests[1]["messages"].([]any)
+	require.Len(t, messages, 3)
+	content := messages[1].(map[string]any)["content"].([]any)
+	require.Len(t, content, 2)
+	assert.Equal(t, map[string]any{"type": "thinking", "thinking": "read it first", "signature": "sig"}, content[0])
+	assert.Equal(t, "tool_use", content[1].(map[string]any)["type"])
+
+	require.Len(t, events, 3)
+	// Each request's tokens go on the first record it produced
+	assert.Equal(t, storage.Record{Source: storage.Thinking, Content: "read it first", EstTokens: storage.TokenCount("read it first"), InputTokens: 10, OutputTokens: 5}, events[0])
+	assert.Equal(t, storage.ToolUse, events[1].Source)
+	assert.Zero(t, events[1].InputTokens)
+	assert.Equal(t, "main.go is fine", events[2].Content)
+	assert.Equal(t, 10, events[2].InputTokens)
+}
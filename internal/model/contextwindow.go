@@ -6,19 +6,28 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	_ "embed"
 
 	"github.com/google/uuid"
 	"github.com/honganh1206/tinker/internal/storage"
 	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/honganh1206/tinker/internal/tracing"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 //go:embed system.md
 var systemPrompt string
 
+// ProjectContextFile holds project knowledge written by `tinker init`.
+// It is read from the working directory, which is where the tools operate.
+const ProjectContextFile = "TINKER.md"
+
 type (
 	ProviderName string
 	ModelVersion string
@@ -33,11 +42,24 @@ type ContextWindow struct {
 	registeredTools map[string]tools.ToolDefinition
 	toolRunners     map[string]tools.ToolRunner
 	metrics         *storage.Metrics
+	lastTurn        storage.TurnStats
+	tokenCounter    TokenCounter
+	style           ResponseStyle
+	observers       observers
+	// Store of the tool outputs too large for the context, see SetArtifacts
+	blobs     *storage.Blobs
+	blobOwner string
+	// Files sent with every model call, see Pin
+	pinned []string
+	// Files the model read or edited, by absolute path, to tell it when
+	// they change behind its back
+	seen map[string]fileState
 }
 
 // NewContextWindow initializes a ContextWindow.
 // The caller is responsible for closing the database.
 func NewContextWindow(
+	ctx context.Context,
 	db *sql.DB,
 	model Model,
 	contextName string,
@@ -61,17 +83,33 @@ func NewContextWindow(
 		toolCapable.SetToolExecutor(cw)
 	}
 
+	// Prefer the counts stored with the records, then the provider's own
+	// count, and estimate locally when both fail
+	local := NewLocalTokenCounter(ProviderOf(versionOf(model)), cw)
+	var fallback TokenCounter = local
+	if counter, ok := model.(TokenCounter); ok {
+		fallback = &FallbackTokenCounter{Primary: counter, Fallback: local}
+	}
+	stored := &StoredTokenCounter{Estimate: &LocalTokenCounter{Calibration: local.Calibration}}
+	cw.tokenCounter = &FallbackTokenCounter{Primary: stored, Fallback: fallback}
+
 	// Check if context exists and to be loaded into the context window
-	_, err := storage.GetContextByName(db, contextName)
+	_, err := storage.GetContextByName(ctx, db, contextName)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			_, err = storage.CreateContext(db, contextName)
+			c, err := storage.CreateContext(ctx, db, contextName)
 			if err != nil {
 				return nil, fmt.Errorf("create context: %w", err)
 			}
+			// Tools resolve relative paths against the working directory
+			if wd, err := os.Getwd(); err == nil {
+				if err := storage.SetContextWorkDir(ctx, db, c.ID, wd); err != nil {
+					return nil, err
+				}
+			}
 			// NOTE: For now, each session only has one context
 			// so we set the system prompt as the 1st record
-			if err := cw.setSystemPrompt(); err != nil {
+			if err := cw.setSystemPrompt(ctx); err != nil {
 				return nil, fmt.Errorf("set system prompt: %w", err)
 			}
 
@@ -91,8 +129,39 @@ func (cw *ContextWindow) HasContext() (bool, error) {
 	return false, nil
 }
 
+// WorkDir is the directory the conversation started in, empty for sessions
+// created before it was recorded.
+func (cw *ContextWindow) WorkDir(ctx context.Context) (string, error) {
+	c, err := storage.GetContextByName(ctx, cw.db, cw.currentContext)
+	if err != nil {
+		return "", fmt.Errorf("work dir: %w", err)
+	}
+	return c.WorkDir, nil
+}
+
+// SetWorkDir records that the conversation continues in dir.
+func (cw *ContextWindow) SetWorkDir(ctx context.Context, dir string) error {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
+	if err != nil {
+		return fmt.Errorf("set work dir: %w", err)
+	}
+	return storage.SetContextWorkDir(ctx, cw.db, contextID, dir)
+}
+
+// SetProject records that the conversation belongs to the project with ID projectID.
+func (cw *ContextWindow) SetProject(ctx context.Context, projectID string) error {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
+	if err != nil {
+		return fmt.Errorf("set project: %w", err)
+	}
+	return storage.SetContextProject(ctx, cw.db, contextID, projectID)
+}
+
 // Close closes the database connection.
 func (cw *ContextWindow) Close() error {
+	if cw.blobs != nil {
+		cw.blobs.Close()
+	}
 	if cw.db == nil {
 		return nil
 	}
@@ -101,12 +170,12 @@ func (cw *ContextWindow) Close() error {
 
 // LiveRecords retrieves all "live" records from the context.
 // NOTE: This is an important function, since it's what gets sent to the LLM.
-func (cw *ContextWindow) LiveRecords() ([]storage.Record, error) {
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+func (cw *ContextWindow) LiveRecords(ctx context.Context) ([]storage.Record, error) {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return nil, fmt.Errorf("live records: %w", err)
 	}
-	recs, err := storage.ListLiveRecords(cw.db, contextID)
+	recs, err := storage.ListLiveRecords(ctx, cw.db, contextID)
 	if err != nil {
 		return nil, fmt.Errorf("live records: %w", err)
 	}
@@ -114,26 +183,51 @@ func (cw *ContextWindow) LiveRecords() ([]storage.Record, error) {
 }
 
 // AddPrompt logs a user prompt to the current context
-func (cw *ContextWindow) AddPrompt(text string) error {
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+func (cw *ContextWindow) AddPrompt(ctx context.Context, text string) error {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return fmt.Errorf("add prompt: %w", err)
 	}
-	_, err = storage.InsertRecord(cw.db, contextID, storage.Prompt, text, true)
+	_, err = storage.InsertRecord(ctx, cw.db, contextID, storage.Prompt, text, true)
 	if err != nil {
 		return fmt.Errorf("add prompt: %w", err)
 	}
+	cw.emit(Event{Kind: EventPrompt, Text: text})
+	return nil
+}
+
+// SetOutputSchema constrains the final answer of model calls to a JSON schema.
+// It fails if the model cannot produce structured output.
+func (cw *ContextWindow) SetOutputSchema(schema map[string]any) error {
+	s, ok := cw.model.(StructuredOutput)
+	if !ok {
+		return fmt.Errorf("model does not support structured output")
+	}
+	s.SetOutputSchema(schema)
+	return nil
+}
+
+// AddSystemNote logs context injected by tinker rather than typed by the user.
+func (cw *ContextWindow) AddSystemNote(ctx context.Context, text string) error {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
+	if err != nil {
+		return fmt.Errorf("add system note: %w", err)
+	}
+	_, err = storage.InsertRecord(ctx, cw.db, contextID, storage.SystemNote, text, true)
+	if err != nil {
+		return fmt.Errorf("add system note: %w", err)
+	}
 	return nil
 }
 
 // AddToolCall logs a tool invocation to the current context.
-func (cw *ContextWindow) AddToolCall(name, args string) error {
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+func (cw *ContextWindow) AddToolCall(ctx context.Context, name, args string) error {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return fmt.Errorf("add tool call: %w", err)
 	}
-	content := fmt.Sprintf("%s(%s)", name, args)
-	_, err = storage.InsertRecord(cw.db, contextID, storage.ToolUse, content, true)
+	content := storage.EncodeToolUse(name, json.RawMessage(args))
+	_, err = storage.InsertRecord(ctx, cw.db, contextID, storage.ToolUse, content, true)
 	if err != nil {
 		return fmt.Errorf("add tool call: %w", err)
 	}
@@ -141,40 +235,74 @@ func (cw *ContextWindow) AddToolCall(name, args string) error {
 }
 
 // AddToolOutput logs a tool's output to the current context.
-func (cw *ContextWindow) AddToolOutput(output string) error {
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+func (cw *ContextWindow) AddToolOutput(ctx context.Context, output string) error {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return fmt.Errorf("add tool output: %w", err)
 	}
-	_, err = storage.InsertRecord(cw.db, contextID, storage.ToolResult, output, true)
+	_, err = storage.InsertRecord(ctx, cw.db, contextID, storage.ToolResult, output, true)
 	if err != nil {
 		return fmt.Errorf("add tool output: %w", err)
 	}
 	return nil
 }
 
-// setSystemPrompt sets the system prompt for the current context.
-func (cw *ContextWindow) setSystemPrompt() error {
+// SetResponseStyle applies the user's response preferences to the context,
+// replacing the live system prompt if it was built with different ones.
+func (cw *ContextWindow) SetResponseStyle(ctx context.Context, style ResponseStyle) error {
+	cw.style = style
+
+	records, err := cw.LiveRecords(ctx)
+	if err != nil {
+		return fmt.Errorf("set response style: %w", err)
+	}
+	want := cw.buildSystemPrompt(ctx)
+	for _, r := range records {
+		if r.Source == storage.SystemPrompt && r.Content == want {
+			return nil
+		}
+	}
+	return cw.setSystemPrompt(ctx)
+}
+
+// buildSystemPrompt assembles the base prompt, the response style and the
+// project context of the conversation's working directory.
+func (cw *ContextWindow) buildSystemPrompt(ctx context.Context) string {
 	sp := strings.TrimSpace(systemPrompt)
+	if style := cw.style.prompt(); style != "" {
+		sp += "\n\n" + style
+	}
+	// The runner and the API server answer conversations of other
+	// directories than their own
+	dir, _ := cw.WorkDir(ctx)
+	if project := projectContext(dir); project != "" {
+		sp += "\n\n# Project context\n\n" + project
+	}
+	return sp
+}
+
+// setSystemPrompt sets the system prompt for the current context.
+func (cw *ContextWindow) setSystemPrompt(ctx context.Context) error {
+	sp := cw.buildSystemPrompt(ctx)
 
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return fmt.Errorf("set system prompt: %w", err)
 	}
 
-	tx, err := cw.db.Begin()
+	tx, err := cw.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("set system prompt: %w", err)
 	}
 	defer tx.Rollback()
 
 	// Set live = false since the system prompt should only be sent once at the start of the session
-	_, err = tx.Exec(`UPDATE records SET live = 0 WHERE context_id = ? AND source = ?`, contextID, storage.SystemPrompt)
+	_, err = tx.ExecContext(ctx, `UPDATE records SET live = 0 WHERE context_id = ? AND source = ?`, contextID, storage.SystemPrompt)
 	if err != nil {
 		return fmt.Errorf("set system prompt: %w", err)
 	}
 
-	_, err = storage.InsertRecordTx(tx, contextID, storage.SystemPrompt, sp, true)
+	_, err = storage.InsertRecordTx(ctx, tx, contextID, storage.SystemPrompt, sp, true)
 	if err != nil {
 		return fmt.Errorf("set system prompt: %w", err)
 	}
@@ -182,18 +310,28 @@ func (cw *ContextWindow) setSystemPrompt() error {
 	return tx.Commit()
 }
 
+// projectContext returns the project context file in dir, the working
+// directory when empty, or "" if there is none.
+func projectContext(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, ProjectContextFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
 // RegisterTool registers a tool with this ContextWindow instance
 // and stores a tool name in the database.
 // Use for new sessions only.
-func (cw *ContextWindow) RegisterTool(toolDef tools.ToolDefinition) error {
+func (cw *ContextWindow) RegisterTool(ctx context.Context, toolDef tools.ToolDefinition) error {
 	cw.LoadTool(toolDef)
 
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return fmt.Errorf("register tool: %w", err)
 	}
 
-	_, err = storage.AddContextTool(cw.db, contextID, toolDef.Name)
+	_, err = storage.AddContextTool(ctx, cw.db, contextID, toolDef.Name)
 	if err != nil {
 		return fmt.Errorf("register tool: %w", err)
 	}
@@ -209,12 +347,56 @@ func (cw *ContextWindow) LoadTool(toolDef tools.ToolDefinition) {
 }
 
 // ExecuteTool implements the ToolExecutor interface
-func (cw *ContextWindow) ExecuteTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+func (cw *ContextWindow) ExecuteTool(ctx context.Context, name string, args json.RawMessage) (out string, err error) {
+	ctx, span := tracing.Start(ctx, "execute_tool "+name, tracing.AttrToolName.String(name))
+	defer func() { tracing.End(span, err) }()
+
 	runner, exists := cw.toolRunners[name]
+	// A former name still runs the tool, with a note to use the current one
+	var note string
+	if current, renamed := tools.Resolve(name); !exists && renamed {
+		runner, exists = cw.toolRunners[current]
+		note = tools.DeprecationNote(name, current)
+	}
 	if !exists {
 		return "", fmt.Errorf("tool %s not registered", name)
 	}
-	return runner.Run(ctx, args)
+
+	cw.emit(Event{Kind: EventToolStart, Tool: name, Args: args})
+	var edited []string
+	ctx = tools.WithEditReporter(ctx, func(e tools.FileEdit) {
+		edited = append(edited, e.Path)
+		cw.emit(Event{Kind: EventFileEdit, Tool: name, Edit: e})
+	})
+	if cw.blobs != nil {
+		ctx = tools.WithArtifacts(ctx, cw.readArtifact)
+	}
+	start := time.Now()
+	out, err = runner.Run(ctx, args)
+	// The model knows the files it edited as they are now, and those of a
+	// conflicting edit as the conflict showed them
+	var conflict *tools.EditConflict
+	if errors.As(err, &conflict) {
+		edited = append(edited, conflict.Path)
+	}
+	for _, path := range edited {
+		cw.trackFile(path)
+	}
+	if err == nil {
+		cw.trackRead(name, args)
+		out, err = cw.overflow(ctx, name, out)
+	}
+	// Metrics that cannot be kept never fail the call
+	storage.RecordToolCall(ctx, cw.db, start, storage.ToolCall{Tool: name, Failed: err != nil, Duration: time.Since(start)})
+	if note != "" {
+		if err != nil {
+			err = fmt.Errorf("%w\n\n%s", err, note)
+		} else {
+			out = note + "\n\n" + out
+		}
+	}
+	cw.emit(Event{Kind: EventToolEnd, Tool: name, Args: args, Err: err, Output: out})
+	return out, err
 }
 
 // GetRegisteredTools returns all registered tool definitions
@@ -227,12 +409,16 @@ func (cw *ContextWindow) GetRegisteredTools() []tools.ToolDefinition {
 }
 
 // HasTool checks if a tool name is available in this context.
-func (cw *ContextWindow) HasTool(name string) (bool, error) {
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+func (cw *ContextWindow) HasTool(ctx context.Context, name string) (bool, error) {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return false, fmt.Errorf("has tool: %w", err)
 	}
-	return storage.HasContextTool(cw.db, contextID, name)
+	has, err := storage.HasContextTool(ctx, cw.db, contextID, name)
+	if current, renamed := tools.Resolve(name); err == nil && !has && renamed {
+		return storage.HasContextTool(ctx, cw.db, contextID, current)
+	}
+	return has, err
 }
 
 // Model returns the underlying Model so callers can invoke Call directly.
@@ -241,12 +427,12 @@ func (cw *ContextWindow) Model() Model {
 }
 
 // AddRecord inserts a record with an arbitrary source type and content.
-func (cw *ContextWindow) AddRecord(source storage.RecordType, content string) error {
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+func (cw *ContextWindow) AddRecord(ctx context.Context, source storage.RecordType, content string) error {
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return fmt.Errorf("add record: %w", err)
 	}
-	_, err = storage.InsertRecord(cw.db, contextID, source, content, true)
+	_, err = storage.InsertRecord(ctx, cw.db, contextID, source, content, true)
 	if err != nil {
 		return fmt.Errorf("add record: %w", err)
 	}
@@ -255,40 +441,190 @@ func (cw *ContextWindow) AddRecord(source storage.RecordType, content string) er
 
 // CallModel drives an LLM.
 // It composes live messages, invokes cw.model.Call(), logs the response, update token count.
-func (cw *ContextWindow) CallModel(ctx context.Context) (string, error) {
-	contextID, err := storage.GetContextIDByName(cw.db, cw.currentContext)
+func (cw *ContextWindow) CallModel(ctx context.Context) (answer string, err error) {
+	ctx, span := tracing.Start(ctx, "llm.call",
+		tracing.AttrModel.String(string(versionOf(cw.model))),
+		tracing.AttrSessionID.String(cw.currentContext),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	contextID, err := storage.GetContextIDByName(ctx, cw.db, cw.currentContext)
 	if err != nil {
 		return "", fmt.Errorf("call model in context: %w", err)
 	}
 
-	recs, err := storage.ListLiveRecords(cw.db, contextID)
+	if note := cw.staleNote(); note != "" {
+		if err := cw.AddSystemNote(ctx, note); err != nil {
+			return "", err
+		}
+	}
+
+	recs, err := storage.ListLiveRecords(ctx, cw.db, contextID)
 	if err != nil {
 		return "", fmt.Errorf("list live records: %w", err)
 	}
+	recs = cw.withPinned(ctx, recs, true)
 
+	start := time.Now()
 	events, tokensUsed, err := cw.Model().Call(ctx, recs)
 	if err != nil {
 		return "", fmt.Errorf("call model: %w", err)
 	}
+	elapsed := time.Since(start)
 
 	cw.metrics.Add(tokensUsed)
 
 	var lastMsg string
 
 	for _, event := range events {
-		_, err := storage.InsertRecord(cw.db, contextID, event.Source, event.Content, event.Live)
+		_, err := storage.InsertEvent(ctx, cw.db, contextID, event)
 		if err != nil {
 			return "", fmt.Errorf("insert model response: %w", err)
 		}
 		lastMsg = event.Content
 	}
 
+	// The answer is stored, metrics that cannot be kept never fail the call
+	stats := turnStats(cw.model, events, elapsed)
+	if saved, err := storage.InsertTurnStats(ctx, cw.db, contextID, stats); err != nil {
+		slog.Warn("failed to record turn stats", "error", err)
+	} else {
+		stats = saved
+	}
+	cw.lastTurn = stats
+	usage := turnUsage(cw.model, stats, events)
+	span.SetAttributes(
+		tracing.AttrInputTokens.Int(stats.InputTokens),
+		tracing.AttrOutputTokens.Int(stats.OutputTokens),
+		tracing.AttrCost.Float64(usage.Cost),
+	)
+
+	if err := storage.RecordUsage(ctx, cw.db, stats.Timestamp, usage); err != nil {
+		slog.Warn("failed to record usage", "error", err)
+	}
+
+	cw.emit(Event{Kind: EventAnswer, Text: lastMsg})
 	return lastMsg, nil
 }
 
+// versionOf returns the model version, or "unknown" if the model doesn't report one.
+func versionOf(m Model) ModelVersion {
+	if v, ok := m.(Versioned); ok {
+		return v.Version()
+	}
+	return "unknown"
+}
+
+// turnUsage converts a turn into a usage row for its model.
+func turnUsage(m Model, stats storage.TurnStats, events []storage.Record) storage.Usage {
+	version := versionOf(m)
+
+	u := storage.Usage{
+		Model:        string(version),
+		InputTokens:  stats.InputTokens,
+		OutputTokens: stats.OutputTokens,
+		Cost:         Cost(version, stats.InputTokens, stats.OutputTokens),
+		Turns:        1,
+	}
+	for _, event := range events {
+		if event.Source == storage.ToolUse {
+			u.ToolCalls++
+		}
+	}
+	if reporter, ok := m.(UsageReporter); ok {
+		u.CachedTokens = reporter.LastUsage().CachedInputTokens
+		u.CacheSavings = CacheSavings(version, u.CachedTokens)
+		u.Cost -= u.CacheSavings
+	}
+	return u
+}
+
+// LastTurnStats returns latency and throughput of the most recent CallModel.
+func (cw *ContextWindow) LastTurnStats() storage.TurnStats {
+	return cw.lastTurn
+}
+
+// turnStats builds the stats of a turn from what the model reports.
+// Models without usage reporting fall back to local token estimates.
+func turnStats(m Model, events []storage.Record, elapsed time.Duration) storage.TurnStats {
+	stats := storage.TurnStats{
+		Duration:         elapsed,
+		TimeToFirstToken: elapsed,
+	}
+
+	if reporter, ok := m.(UsageReporter); ok {
+		usage := reporter.LastUsage()
+		stats.InputTokens = usage.InputTokens
+		stats.OutputTokens = usage.OutputTokens
+		stats.TimeToFirstToken = usage.FirstResponse
+		return stats
+	}
+
+	for _, event := range events {
+		stats.OutputTokens += storage.TokenCount(event.Content)
+	}
+	return stats
+}
+
+// SetTokenCounter overrides how live tokens are counted,
+// e.g. with a LocalTokenCounter to skip the provider round trip per turn.
+func (cw *ContextWindow) SetTokenCounter(counter TokenCounter) {
+	cw.tokenCounter = counter
+}
+
+// CountLiveTokens counts the tokens the next model call would send.
+func (cw *ContextWindow) CountLiveTokens(ctx context.Context) (int, error) {
+	recs, err := cw.LiveRecords(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("count live tokens: %w", err)
+	}
+	n, err := cw.tokenCounter.CountTokens(ctx, cw.withPinned(ctx, recs, false))
+	if err != nil {
+		return 0, fmt.Errorf("count live tokens: %w", err)
+	}
+	return n, nil
+}
+
+// TotalTokens implements storage.TokenReporter
+func (cw *ContextWindow) TotalTokens() int {
+	return cw.metrics.Total()
+}
+
+// LiveTokens implements storage.TokenReporter
+func (cw *ContextWindow) LiveTokens() (int, error) {
+	return cw.CountLiveTokens(context.Background())
+}
+
+// TokenUsage implements storage.TokenReporter
+func (cw *ContextWindow) TokenUsage() (storage.TokenUsage, error) {
+	live, err := cw.LiveTokens()
+	if err != nil {
+		return storage.TokenUsage{}, err
+	}
+
+	max := cw.contextSize()
+	usage := storage.TokenUsage{
+		Live:  live,
+		Total: cw.TotalTokens(),
+		Max:   max,
+	}
+	if max > 0 {
+		usage.Percent = float64(live) / float64(max)
+	}
+	return usage, nil
+}
+
+// contextSize is the number of tokens the model takes in.
+func (cw *ContextWindow) contextSize() int {
+	if m, ok := cw.model.(interface{ MaxTokens() int }); ok {
+		return m.MaxTokens()
+	}
+	return cw.maxTokens
+}
+
 // CreateContext creates a new named context window.
-func (cw *ContextWindow) CreateContext(name string) error {
-	_, err := storage.CreateContext(cw.db, name)
+func (cw *ContextWindow) CreateContext(ctx context.Context, name string) error {
+	_, err := storage.CreateContext(ctx, cw.db, name)
 	if err != nil {
 		return fmt.Errorf("create context: %w", err)
 	}
@@ -296,8 +632,8 @@ func (cw *ContextWindow) CreateContext(name string) error {
 }
 
 // ListContexts returns all available context windows.
-func (cw *ContextWindow) ListContexts() ([]storage.Context, error) {
-	contexts, err := storage.ListContexts(cw.db)
+func (cw *ContextWindow) ListContexts(ctx context.Context) ([]storage.Context, error) {
+	contexts, err := storage.ListContexts(ctx, cw.db)
 	if err != nil {
 		return nil, fmt.Errorf("list contexts: %w", err)
 	}
@@ -305,38 +641,38 @@ func (cw *ContextWindow) ListContexts() ([]storage.Context, error) {
 }
 
 // GetContext retrieves context metadata by name.
-func (cw *ContextWindow) GetContext(name string) (storage.Context, error) {
-	ctx, err := storage.GetContextByName(cw.db, name)
+func (cw *ContextWindow) GetContext(ctx context.Context, name string) (storage.Context, error) {
+	c, err := storage.GetContextByName(ctx, cw.db, name)
 	if err != nil {
 		return storage.Context{}, fmt.Errorf("get context: %w", err)
 	}
-	return ctx, nil
+	return c, nil
 }
 
 // DeleteContext removes a context and all its records.
-func (cw *ContextWindow) DeleteContext(name string) error {
+func (cw *ContextWindow) DeleteContext(ctx context.Context, name string) error {
 	if name == cw.currentContext {
-		contexts, err := storage.ListContexts(cw.db)
+		contexts, err := storage.ListContexts(ctx, cw.db)
 		if err != nil {
 			return fmt.Errorf("list contexts for deletion: %w", err)
 		}
 		if len(contexts) <= 1 {
-			_, err := storage.CreateContext(cw.db, "default")
+			_, err := storage.CreateContext(ctx, cw.db, "default")
 			if err != nil {
 				return fmt.Errorf("create replacement context: %w", err)
 			}
 			cw.currentContext = "default"
 		} else {
-			for _, ctx := range contexts {
-				if ctx.Name != name {
-					cw.currentContext = ctx.Name
+			for _, c := range contexts {
+				if c.Name != name {
+					cw.currentContext = c.Name
 					break
 				}
 			}
 		}
 	}
 
-	err := storage.DeleteContextByName(cw.db, name)
+	err := storage.DeleteContextByName(ctx, cw.db, name)
 	if err != nil {
 		return fmt.Errorf("delete context: %w", err)
 	}
@@ -2,22 +2,35 @@ package model
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/honganh1206/tinker/internal/tracing"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestNewContextWindowAndClose(t *testing.T) {
 	db, err := storage.NewSession(":memory:", "")
 	assert.NoError(t, err)
 
-	cw, err := NewContextWindow(db, &dummyModel{}, "mock")
+	cw, err := NewContextWindow(t.Context(), db, &dummyModel{}, "mock")
 	assert.NoError(t, err)
 	assert.NotNil(t, cw.db)
 
 	// Test record insertion before closing
-	err = cw.AddPrompt("test prompt")
+	err = cw.AddPrompt(t.Context(), "test prompt")
 	assert.NoError(t, err)
 
 	// Close and test error
@@ -25,7 +38,7 @@ func TestNewContextWindowAndClose(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Test adding a prompt after closing
-	err = cw.AddPrompt("should fail")
+	err = cw.AddPrompt(t.Context(), "should fail")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "sql: database is closed")
 }
@@ -38,32 +51,32 @@ func TestContextContinuation(t *testing.T) {
 	model := &MockModel{}
 
 	// Create initial context and add some messages
-	cw1, err := NewContextWindow(db, model, "continuation-test")
+	cw1, err := NewContextWindow(t.Context(), db, model, "continuation-test")
 	assert.NoError(t, err)
 
-	err = cw1.AddPrompt("First message")
+	err = cw1.AddPrompt(t.Context(), "First message")
 	assert.NoError(t, err)
 
-	err = cw1.AddPrompt("Second message")
+	err = cw1.AddPrompt(t.Context(), "Second message")
 	assert.NoError(t, err)
 
 	// "Close" the context by creating a new instance
-	cw2, err := NewContextWindow(db, model, "continuation-test")
+	cw2, err := NewContextWindow(t.Context(), db, model, "continuation-test")
 	assert.NoError(t, err)
 
 	// Verify it loads the existing context
 	// assert.Equal(t, "continuation-test", cw2.GetCurrentContext())
 
 	// Add a new message and verify all history is available
-	err = cw2.AddPrompt("Third message")
+	err = cw2.AddPrompt(t.Context(), "Third message")
 	assert.NoError(t, err)
 
 	// Get context ID to check records
-	contextID, err := storage.GetContextIDByName(db, "continuation-test")
+	contextID, err := storage.GetContextIDByName(t.Context(), db, "continuation-test")
 	assert.NoError(t, err)
 
 	// Verify all messages are present
-	records, err := storage.ListLiveRecords(db, contextID)
+	records, err := storage.ListLiveRecords(t.Context(), db, contextID)
 	assert.NoError(t, err)
 
 	prompts := []string{}
@@ -83,7 +96,7 @@ func TestCallModelInsertRecordError(t *testing.T) {
 	db, err := storage.NewSession(":memory:", "")
 	assert.NoError(t, err)
 	m := &dummyModel{closeDB: true}
-	cw, err := NewContextWindow(db, m, "mock")
+	cw, err := NewContextWindow(t.Context(), db, m, "mock")
 	assert.NoError(t, err)
 	m.cw = cw
 	m.events = []storage.Record{{
@@ -100,19 +113,19 @@ func TestCreateAndListContexts(t *testing.T) {
 	db, err := storage.NewSession(":memory:", "")
 	assert.NoError(t, err)
 
-	cw, err := NewContextWindow(db, &dummyModel{}, "")
+	cw, err := NewContextWindow(t.Context(), db, &dummyModel{}, "")
 	assert.NoError(t, err)
 	defer cw.Close()
 
-	contexts, err := cw.ListContexts()
+	contexts, err := cw.ListContexts(t.Context())
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(contexts))
 	assert.NotEmpty(t, contexts[0].Name)
 
-	err = cw.CreateContext("test-context")
+	err = cw.CreateContext(t.Context(), "test-context")
 	assert.NoError(t, err)
 
-	contexts, err = cw.ListContexts()
+	contexts, err = cw.ListContexts(t.Context())
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(contexts))
 
@@ -125,3 +138,328 @@ func TestCreateAndListContexts(t *testing.T) {
 	}
 	assert.True(t, found)
 }
+
+func TestCallModelRecordsTurnStats(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := &usageModel{usage: Usage{InputTokens: 120, OutputTokens: 30, FirstResponse: time.Millisecond}}
+	m.events = []storage.Record{{Source: storage.ModelResp, Content: "done", Live: true}}
+
+	cw, err := NewContextWindow(t.Context(), db, m, "stats")
+	assert.NoError(t, err)
+
+	_, err = cw.CallModel(context.Background())
+	assert.NoError(t, err)
+
+	last := cw.LastTurnStats()
+	assert.Equal(t, 120, last.InputTokens)
+	assert.Equal(t, 30, last.OutputTokens)
+	assert.Equal(t, time.Millisecond, last.TimeToFirstToken)
+
+	contextID, err := storage.GetContextIDByName(t.Context(), db, "stats")
+	assert.NoError(t, err)
+	stats, err := storage.ListTurnStats(t.Context(), db, contextID)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+}
+
+func TestCallModelKeepsAnswerWithoutMetrics(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := &usageModel{usage: Usage{InputTokens: 120, OutputTokens: 30}}
+	m.events = []storage.Record{{Source: storage.ModelResp, Content: "done", Live: true}}
+	cw, err := NewContextWindow(t.Context(), db, m, "stats")
+	assert.NoError(t, err)
+
+	_, err = db.Exec(`DROP TABLE turn_stats; DROP TABLE usage`)
+	assert.NoError(t, err)
+	answer, err := cw.CallModel(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "done", answer)
+	assert.Equal(t, 120, cw.LastTurnStats().InputTokens)
+}
+
+func TestCallModelPersistsToolStatus(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := &MockModel{events: []storage.Record{
+		{Source: storage.ToolUse, Content: `bash({"command":"false"})`, Live: true, Status: storage.StatusError, Output: "exit status 1"},
+		{Source: storage.ModelResp, Content: "it failed", Live: true},
+	}}
+	cw, err := NewContextWindow(t.Context(), db, m, "status")
+	assert.NoError(t, err)
+
+	_, err = cw.CallModel(context.Background())
+	assert.NoError(t, err)
+
+	recs, err := cw.LiveRecords(t.Context())
+	assert.NoError(t, err)
+
+	var toolUse *storage.Record
+	for i := range recs {
+		if recs[i].Source == storage.ToolUse {
+			toolUse = &recs[i]
+		}
+	}
+	if assert.NotNil(t, toolUse) {
+		assert.Equal(t, storage.StatusError, toolUse.Status)
+		assert.Equal(t, "exit status 1", toolUse.Output)
+	}
+}
+
+func TestCallModelTracesToolsUnderTheCall(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &toolModel{}, "traced")
+	assert.NoError(t, err)
+	defer cw.Close()
+	cw.LoadTool(readFileTool(func() (string, error) { return "module demo", nil }))
+
+	_, err = cw.CallModel(t.Context())
+	assert.NoError(t, err)
+
+	ended := spans.Ended()
+	if assert.Len(t, ended, 2) {
+		tool, call := ended[0], ended[1]
+		assert.Equal(t, "execute_tool read_file", tool.Name())
+		assert.Equal(t, "llm.call", call.Name())
+		assert.Equal(t, call.SpanContext().SpanID(), tool.Parent().SpanID())
+		assert.Contains(t, call.Attributes(), tracing.AttrSessionID.String("traced"))
+	}
+}
+
+func TestSubscribeReportsToolEvents(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	m := &toolModel{}
+	cw, err := NewContextWindow(t.Context(), db, m, "events")
+	assert.NoError(t, err)
+	defer cw.Close()
+	cw.LoadTool(tools.ToolDefinition{
+		Name: "read_file",
+		Function: func(ctx context.Context, args json.RawMessage) (string, error) {
+			return "module example", nil
+		},
+	})
+
+	var kinds []EventKind
+	unsubscribe := cw.Subscribe(func(ev Event) { kinds = append(kinds, ev.Kind) })
+	assert.NotNil(t, m.onToolInput)
+
+	_, err = cw.CallModel(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []EventKind{EventToolInput, EventToolStart, EventToolEnd, EventAnswer}, kinds)
+
+	// Without subscribers the model stops streaming and nothing is reported
+	unsubscribe()
+	assert.Nil(t, m.onToolInput)
+	_, err = cw.CallModel(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, kinds, 4)
+}
+
+func TestExecuteToolReportsFileEdits(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &toolModel{}, "edits")
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	path := filepath.Join(t.TempDir(), "main.go")
+	assert.NoError(t, os.WriteFile(path, []byte("package main\n"), 0o644))
+	cw.LoadTool(tools.EditFileDefinition)
+
+	var events []Event
+	defer cw.Subscribe(func(ev Event) { events = append(events, ev) })()
+
+	args, _ := json.Marshal(tools.EditFileInput{Path: path, OldStr: "main", NewStr: "app"})
+	_, err = cw.ExecuteTool(t.Context(), tools.ToolNameEditFile, args)
+	assert.NoError(t, err)
+
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, EventFileEdit, events[1].Kind)
+		assert.Equal(t, tools.ToolNameEditFile, events[1].Tool)
+		assert.Equal(t, tools.FileEdit{
+			Path:    path,
+			Range:   tools.Range{Start: tools.Position{Character: 8}, End: tools.Position{Character: 12}},
+			NewText: "app",
+		}, events[1].Edit)
+		assert.Equal(t, EventToolEnd, events[2].Kind)
+		assert.Equal(t, "OK", events[2].Output)
+	}
+}
+
+func TestExecuteToolStoresLargeOutputsAsArtifacts(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &toolModel{}, "artifacts")
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	blobs, err := storage.OpenBlobs(t.TempDir())
+	assert.NoError(t, err)
+	cw.SetArtifacts(blobs, "")
+
+	var lines []string
+	for i := range 1000 {
+		lines = append(lines, fmt.Sprintf("line %d of the build log", i+1))
+	}
+	full := strings.Join(lines, "\n")
+	cw.LoadTool(tools.ToolDefinition{Name: "build", Function: func(ctx context.Context, args json.RawMessage) (string, error) {
+		return full, nil
+	}})
+	cw.LoadTool(tools.ReadArtifactDefinition)
+
+	out, err := cw.ExecuteTool(t.Context(), "build", json.RawMessage(`{}`))
+	assert.NoError(t, err)
+	assert.Less(t, len(out), len(full))
+	assert.Contains(t, out, "line 1 of the build log")
+	assert.Contains(t, out, "line 1000 of the build log")
+	assert.NotContains(t, out, "line 500 of the build log")
+
+	id := regexp.MustCompile(`artifact ([0-9a-f]{64})`).FindStringSubmatch(out)
+	if assert.Len(t, id, 2) {
+		args, _ := json.Marshal(tools.ReadArtifactInput{ID: id[1], StartLine: 500, EndLine: 501})
+		chunk, err := cw.ExecuteTool(t.Context(), tools.ToolNameReadArtifact, args)
+		assert.NoError(t, err)
+		assert.Equal(t, "500: line 500 of the build log\n501: line 501 of the build log\n\n(499 lines remaining, artifact has 1000 total lines)", chunk)
+	}
+
+	// Outputs within the budget are sent as they are
+	small, err := cw.overflow(t.Context(), "build", "ok")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", small)
+}
+
+func TestNewContextWindowRecordsWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &dummyModel{}, "mock")
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	got, err := cw.WorkDir(t.Context())
+	assert.NoError(t, err)
+	wd, _ := os.Getwd()
+	assert.Equal(t, wd, got)
+
+	// Reopening the context elsewhere keeps where it started
+	t.Chdir(t.TempDir())
+	cw, err = NewContextWindow(t.Context(), db, &dummyModel{}, "mock")
+	assert.NoError(t, err)
+	got, err = cw.WorkDir(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, wd, got)
+}
+
+func TestSystemPromptIncludesProjectContext(t *testing.T) {
+	t.Chdir(t.TempDir())
+	assert.NoError(t, os.WriteFile(ProjectContextFile, []byte("Run tests with make test.\n"), 0o644))
+
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &dummyModel{}, "mock")
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	records, err := cw.LiveRecords(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, storage.SystemPrompt, records[0].Source)
+	assert.True(t, strings.HasSuffix(records[0].Content, "# Project context\n\nRun tests with make test."))
+}
+
+func TestSystemPromptReadsProjectContextOfWorkDir(t *testing.T) {
+	repo := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(repo, ProjectContextFile), []byte("Run tests with make test.\n"), 0o644))
+	t.Chdir(repo)
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &dummyModel{}, "mock")
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	// As a server started elsewhere continues the conversation
+	t.Chdir(t.TempDir())
+	assert.NoError(t, cw.SetResponseStyle(t.Context(), ResponseStyle{Verbosity: VerbosityConcise}))
+	records, err := cw.LiveRecords(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, storage.SystemPrompt, records[0].Source)
+	assert.True(t, strings.HasSuffix(records[0].Content, "# Project context\n\nRun tests with make test."))
+}
+
+func TestSetResponseStyle(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &dummyModel{}, "mock")
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	style := ResponseStyle{Language: "Vietnamese", Verbosity: VerbosityConcise}
+	assert.NoError(t, cw.SetResponseStyle(t.Context(), style))
+	// Same style again keeps the prompt instead of adding another record
+	assert.NoError(t, cw.SetResponseStyle(t.Context(), style))
+
+	records, err := cw.LiveRecords(t.Context())
+	assert.NoError(t, err)
+	var prompts []string
+	for _, r := range records {
+		if r.Source == storage.SystemPrompt {
+			prompts = append(prompts, r.Content)
+		}
+	}
+	assert.Len(t, prompts, 1)
+	assert.Contains(t, prompts[0], "Always answer in Vietnamese")
+	assert.Contains(t, prompts[0], "Be terse")
+}
+
+func TestParseVerbosity(t *testing.T) {
+	v, err := ParseVerbosity("")
+	assert.NoError(t, err)
+	assert.Equal(t, VerbosityStandard, v)
+
+	v, err = ParseVerbosity("Explanatory")
+	assert.NoError(t, err)
+	assert.Equal(t, VerbosityExplanatory, v)
+
+	_, err = ParseVerbosity("chatty")
+	assert.Error(t, err)
+}
+
+func TestExecuteToolResolvesRenamedTools(t *testing.T) {
+	tools.Aliases["cat_file"] = tools.ToolNameReadFile
+	t.Cleanup(func() { delete(tools.Aliases, "cat_file") })
+
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &toolModel{}, "renamed")
+	assert.NoError(t, err)
+	defer cw.Close()
+	assert.NoError(t, cw.RegisterTool(t.Context(), tools.ReadFileDefinition))
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("hello\n"), 0o644))
+	args, _ := json.Marshal(tools.ReadFileInput{Path: path, StartLine: 1, EndLine: 1})
+
+	out, err := cw.ExecuteTool(t.Context(), "cat_file", args)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "renamed to read_file")
+	assert.Contains(t, out, "1: hello")
+
+	has, err := cw.HasTool(t.Context(), "cat_file")
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.Equal(t, tools.FormatResult(tools.ToolNameReadFile, args, out, nil), tools.FormatResult("cat_file", args, out, nil))
+}
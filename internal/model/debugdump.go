@@ -0,0 +1,182 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dump files roll over past this size, and the oldest are removed past
+// debugFiles of them
+var (
+	debugFileSize int64 = 10 << 20
+	debugFiles          = 10
+)
+
+// Headers and query parameters that carry API keys
+var (
+	secretHeaders = []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key"}
+	secretParams  = []string{"key"}
+)
+
+const redacted = "[REDACTED]"
+
+// debugTransport, when set, is the transport of the provider clients created
+// afterwards
+var debugTransport http.RoundTripper
+
+// DumpRequests writes the exact requests sent to the providers and their raw
+// responses, API keys redacted, to files in dir. It only affects the models
+// created after it is called.
+func DumpRequests(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create debug dir: %w", err)
+	}
+	debugTransport = &dumpTransport{base: http.DefaultTransport, dir: dir}
+	return nil
+}
+
+// debugHTTPClient returns the client dumping requests, nil to use the SDK's own
+func debugHTTPClient() *http.Client {
+	if debugTransport == nil {
+		return nil
+	}
+	return &http.Client{Transport: debugTransport}
+}
+
+// dumpTransport writes each exchange to the current dump file once the
+// response body is closed, so streamed responses reach the caller as they
+// arrive and exchanges are never interleaved.
+type dumpTransport struct {
+	base http.RoundTripper
+	dir  string
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// Files opened, which tells apart files opened in the same microsecond
+	opened int
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is dumped from a copy, which needs GetBody. The SDKs set it
+	// on every request with a body.
+	reqDump, err := httputil.DumpRequestOut(redactRequest(req), req.GetBody != nil)
+	if err != nil {
+		return nil, fmt.Errorf("dump request: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.write(fmt.Appendf(reqDump, "\n\n--- error after %s: %v\n", time.Since(start), err))
+		return nil, err
+	}
+
+	respDump, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("dump response: %w", err)
+	}
+	entry := bytes.NewBuffer(reqDump)
+	fmt.Fprintf(entry, "\n\n--- response after %s\n", time.Since(start))
+	entry.Write(respDump)
+	resp.Body = &teeBody{ReadCloser: resp.Body, entry: entry, done: t.write}
+	return resp, nil
+}
+
+// write appends an exchange to the dump file, rolling over to a new one
+// when it is full. Failing to dump never fails the request.
+func (t *dumpTransport) write(entry []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file == nil || t.size >= debugFileSize {
+		if err := t.rotate(); err != nil {
+			return
+		}
+	}
+	stamp := fmt.Sprintf("=== %s\n", time.Now().Format(time.RFC3339Nano))
+	n, _ := t.file.WriteString(stamp)
+	m, _ := t.file.Write(append(entry, "\n\n"...))
+	t.size += int64(n + m)
+}
+
+// rotate opens a new dump file and removes the oldest past debugFiles.
+func (t *dumpTransport) rotate() error {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+	t.opened++
+	name := filepath.Join(t.dir, fmt.Sprintf("llm-%s-%03d.log", time.Now().Format("20060102-150405.000000"), t.opened%1000))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	t.file, t.size = f, 0
+
+	// File names sort by creation time
+	dumps, err := filepath.Glob(filepath.Join(t.dir, "llm-*.log"))
+	if err != nil || len(dumps) <= debugFiles {
+		return nil
+	}
+	slices.Sort(dumps)
+	for _, old := range dumps[:len(dumps)-debugFiles] {
+		os.Remove(old)
+	}
+	return nil
+}
+
+// teeBody records the response body as it is read and hands the exchange to
+// done when closed.
+type teeBody struct {
+	io.ReadCloser
+	entry *bytes.Buffer
+	done  func([]byte)
+	once  sync.Once
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.entry.Write(p[:n])
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.entry.Bytes()) })
+	return err
+}
+
+// redactRequest returns a copy of req with its API keys masked, and a body
+// of its own.
+func redactRequest(req *http.Request) *http.Request {
+	clone := req.Clone(req.Context())
+	for _, h := range secretHeaders {
+		if clone.Header.Get(h) != "" {
+			clone.Header.Set(h, redacted)
+		}
+	}
+	q := clone.URL.Query()
+	for _, p := range secretParams {
+		if q.Has(p) {
+			q.Set(p, redacted)
+			clone.URL.RawQuery = strings.ReplaceAll(q.Encode(), url.QueryEscape(redacted), redacted)
+		}
+	}
+	clone.Body = nil
+	if req.GetBody != nil {
+		clone.Body, _ = req.GetBody()
+	}
+	return clone
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpTransport_RedactsKeys(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, "echo: "+string(body))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	client := &http.Client{Transport: &dumpTransport{base: http.DefaultTransport, dir: dir}}
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/models?key=secret-1", strings.NewReader(`{"prompt":"hi"}`))
+	require.NoError(t, err)
+	req.Header.Set("X-Api-Key", "secret-2")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, `echo: {"prompt":"hi"}`, string(body))
+
+	dumps, _ := filepath.Glob(filepath.Join(dir, "llm-*.log"))
+	require.Len(t, dumps, 1)
+	data, err := os.ReadFile(dumps[0])
+	require.NoError(t, err)
+	dump := string(data)
+	assert.NotContains(t, dump, "secret")
+	assert.Contains(t, dump, "key=[REDACTED]")
+	assert.Contains(t, dump, "X-Api-Key: [REDACTED]")
+	assert.Contains(t, dump, `{"prompt":"hi"}`)
+	assert.Contains(t, dump, `echo: {"prompt":"hi"}`)
+}
+
+func TestDumpTransport_Rotates(t *testing.T) {
+	size, files := debugFileSize, debugFiles
+	debugFileSize, debugFiles = 1, 2
+	defer func() { debugFileSize, debugFiles = size, files }()
+
+	dir := t.TempDir()
+	tr := &dumpTransport{dir: dir}
+	for range 4 {
+		tr.write([]byte("exchange"))
+	}
+
+	dumps, _ := filepath.Glob(filepath.Join(dir, "llm-*.log"))
+	assert.Len(t, dumps, 2)
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+type EventKind string
+
+const (
+	// The model is generating the input of a tool call
+	EventToolInput EventKind = "tool_input"
+	// A tool started running
+	EventToolStart EventKind = "tool_start"
+	// A tool finished, Err is set if it failed
+	EventToolEnd EventKind = "tool_end"
+	// A tool changed a file, reported before the tool ends
+	EventFileEdit EventKind = "file_edit"
+	// A prompt was added to the conversation
+	EventPrompt EventKind = "prompt"
+	// The model gave its final answer of a turn
+	EventAnswer EventKind = "answer"
+	// A pinned file was unpinned to fit the context, Text tells which
+	EventUnpin EventKind = "unpin"
+)
+
+// Event reports progress of a model call as it happens, so frontends can
+// render it live without the agent knowing about them.
+type Event struct {
+	Kind EventKind
+	// Set for EventToolInput
+	ToolInput ToolInputDelta
+	// Tool name and arguments, set for EventToolStart and EventToolEnd
+	Tool string
+	Args json.RawMessage
+	Err  error
+	// Output of the tool, set for EventToolEnd when it succeeded
+	Output string
+	// Set for EventFileEdit
+	Edit tools.FileEdit
+	// Set for EventPrompt, EventAnswer and EventUnpin
+	Text string
+}
+
+// observers fans events out to the subscribers of a context window
+type observers struct {
+	mu     sync.Mutex
+	nextID int
+	subs   map[int]func(Event)
+}
+
+// Subscribe calls fn for every event of the following model calls until the
+// returned function is called. Tool inputs are only streamed while someone
+// is subscribed, since streaming is slower on some providers.
+func (cw *ContextWindow) Subscribe(fn func(Event)) (unsubscribe func()) {
+	o := &cw.observers
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.subs == nil {
+		o.subs = make(map[int]func(Event))
+	}
+	id := o.nextID
+	o.nextID++
+	o.subs[id] = fn
+	if len(o.subs) == 1 {
+		cw.streamToolInputs(true)
+	}
+
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if _, ok := o.subs[id]; !ok {
+			return
+		}
+		delete(o.subs, id)
+		if len(o.subs) == 0 {
+			cw.streamToolInputs(false)
+		}
+	}
+}
+
+func (cw *ContextWindow) streamToolInputs(on bool) {
+	s, ok := cw.model.(Streamer)
+	if !ok {
+		return
+	}
+	if !on {
+		s.SetToolInputHandler(nil)
+		return
+	}
+	s.SetToolInputHandler(func(d ToolInputDelta) {
+		cw.emit(Event{Kind: EventToolInput, ToolInput: d})
+	})
+}
+
+func (cw *ContextWindow) emit(ev Event) {
+	o := &cw.observers
+	o.mu.Lock()
+	subs := make([]func(Event), 0, len(o.subs))
+	for _, fn := range o.subs {
+		subs = append(subs, fn)
+	}
+	o.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(ev)
+	}
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture is a recorded session: the answer of every model call and the
+// results of the tools the model called on the way, in order.
+type Fixture struct {
+	Model ModelVersion  `yaml:"model,omitempty"`
+	Calls []FixtureCall `yaml:"calls"`
+}
+
+// FixtureCall is one Call of the model.
+type FixtureCall struct {
+	// Latest prompt when the call was made, replays fail if it changes
+	Prompt       string        `yaml:"prompt,omitempty"`
+	Tools        []FixtureTool `yaml:"tools,omitempty"`
+	Response     string        `yaml:"response"`
+	InputTokens  int           `yaml:"input_tokens,omitempty"`
+	OutputTokens int           `yaml:"output_tokens,omitempty"`
+}
+
+// FixtureTool is one tool the model called and what the tool returned.
+type FixtureTool struct {
+	Name   string         `yaml:"name"`
+	Input  map[string]any `yaml:"input,omitempty"`
+	Output string         `yaml:"output,omitempty"`
+	Error  string         `yaml:"error,omitempty"`
+}
+
+// LoadFixture reads a fixture written by a Recorder.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+	var f Fixture
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse fixture %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Save writes the fixture to path. Tool outputs may hold file contents, so
+// only the user can read it.
+func (f *Fixture) Save(path string) error {
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("encode fixture: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("write fixture: %w", err)
+	}
+	return nil
+}
+
+// Recorder wraps a model and writes what it answers and the tools it runs
+// to a fixture after every call, for Replay to play back.
+type Recorder struct {
+	model Model
+	path  string
+
+	mu      sync.Mutex
+	fixture Fixture
+	// Tools run by the call in progress
+	tools []FixtureTool
+}
+
+func NewRecorder(m Model, path string) *Recorder {
+	r := &Recorder{model: m, path: path}
+	if v, ok := m.(Versioned); ok {
+		r.fixture.Model = v.Version()
+	}
+	return r
+}
+
+func (r *Recorder) Call(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+	r.mu.Lock()
+	r.tools = nil
+	r.mu.Unlock()
+
+	events, tokensUsed, err := r.model.Call(ctx, inputs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	call := FixtureCall{Prompt: lastPrompt(inputs), Response: lastResponse(events)}
+	if reporter, ok := r.model.(UsageReporter); ok {
+		u := reporter.LastUsage()
+		call.InputTokens, call.OutputTokens = u.InputTokens, u.OutputTokens
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	call.Tools = r.tools
+	r.fixture.Calls = append(r.fixture.Calls, call)
+	if err := r.fixture.Save(r.path); err != nil {
+		return nil, 0, err
+	}
+	return events, tokensUsed, nil
+}
+
+// SetToolExecutor implements the ToolCapable interface, recording the tools
+// the wrapped model runs
+func (r *Recorder) SetToolExecutor(executor tools.ToolExecutor) {
+	if toolCapable, ok := r.model.(tools.ToolCapable); ok {
+		toolCapable.SetToolExecutor(recordingExecutor{ToolExecutor: executor, r: r})
+	}
+}
+
+// LastUsage implements the UsageReporter interface
+func (r *Recorder) LastUsage() Usage {
+	if reporter, ok := r.model.(UsageReporter); ok {
+		return reporter.LastUsage()
+	}
+	return Usage{}
+}
+
+// SetToolInputHandler implements the Streamer interface
+func (r *Recorder) SetToolInputHandler(handler func(ToolInputDelta)) {
+	if s, ok := r.model.(Streamer); ok {
+		s.SetToolInputHandler(handler)
+	}
+}
+
+// SetOutputSchema implements the StructuredOutput interface
+func (r *Recorder) SetOutputSchema(schema map[string]any) {
+	if s, ok := r.model.(StructuredOutput); ok {
+		s.SetOutputSchema(schema)
+	}
+}
+
+// Version implements the Versioned interface
+func (r *Recorder) Version() ModelVersion {
+	return versionOf(r.model)
+}
+
+// CountTokens implements the TokenCounter interface, failing over to the
+// local estimate when the wrapped model cannot count
+func (r *Recorder) CountTokens(ctx context.Context, inputs []storage.Record) (int, error) {
+	if counter, ok := r.model.(TokenCounter); ok {
+		return counter.CountTokens(ctx, inputs)
+	}
+	return 0, errors.New("model does not count tokens")
+}
+
+type recordingExecutor struct {
+	tools.ToolExecutor
+	r *Recorder
+}
+
+func (e recordingExecutor) ExecuteTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	out, err := e.ToolExecutor.ExecuteTool(ctx, name, args)
+
+	t := FixtureTool{Name: name, Output: out}
+	// Inputs are objects for every tool, anything else is recorded without
+	_ = json.Unmarshal(args, &t.Input)
+	if err != nil {
+		t.Error = err.Error()
+	}
+	e.r.mu.Lock()
+	e.r.tools = append(e.r.tools, t)
+	e.r.mu.Unlock()
+	return out, err
+}
+
+// lastPrompt returns the content of the latest prompt in inputs.
+func lastPrompt(inputs []storage.Record) string {
+	for i := len(inputs) - 1; i >= 0; i-- {
+		if inputs[i].Source == storage.Prompt {
+			return inputs[i].Content
+		}
+	}
+	return ""
+}
+
+// lastResponse returns the final answer among the records of a call.
+func lastResponse(events []storage.Record) string {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Source == storage.ModelResp {
+			return events[i].Content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func readFileTool(run func() (string, error)) tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name: tools.ToolNameReadFile,
+		Function: func(ctx context.Context, args json.RawMessage) (string, error) {
+			return run()
+		},
+	}
+}
+
+func newFixtureWindow(t *testing.T, m Model, tool tools.ToolDefinition) *ContextWindow {
+	t.Helper()
+	db, err := storage.NewSession(":memory:", "")
+	assert.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, m, "fixture")
+	assert.NoError(t, err)
+	t.Cleanup(func() { cw.Close() })
+	cw.LoadTool(tool)
+	return cw
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.yaml")
+
+	cw := newFixtureWindow(t, NewRecorder(&toolModel{}, path), readFileTool(func() (string, error) {
+		return "module demo", nil
+	}))
+	assert.NoError(t, cw.AddPrompt(t.Context(), "which module is this?"))
+	recorded, err := cw.CallModel(t.Context())
+	assert.NoError(t, err)
+
+	f, err := LoadFixture(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []FixtureCall{{
+		Prompt:   "which module is this?",
+		Tools:    []FixtureTool{{Name: tools.ToolNameReadFile, Input: map[string]any{"path": "go.mod"}, Output: "module demo"}},
+		Response: "module demo",
+	}}, f.Calls)
+
+	// The replayed tool answers from the fixture rather than running
+	cw = newFixtureWindow(t, NewReplay(f), ReplayTools([]tools.ToolDefinition{readFileTool(func() (string, error) {
+		t.Error("replayed tool ran")
+		return "", nil
+	})})[0])
+	var kinds []EventKind
+	defer cw.Subscribe(func(ev Event) { kinds = append(kinds, ev.Kind) })()
+
+	assert.NoError(t, cw.AddPrompt(t.Context(), "which module is this?"))
+	replayed, err := cw.CallModel(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+	assert.Equal(t, []EventKind{EventPrompt, EventToolStart, EventToolEnd, EventAnswer}, kinds)
+
+	recs, err := storage.ListLiveRecords(t.Context(), cw.db, mustContextID(t, cw))
+	assert.NoError(t, err)
+	if assert.Len(t, recs, 4) {
+		assert.Equal(t, storage.ToolUse, recs[2].Source)
+		assert.Equal(t, "module demo", recs[2].Output)
+	}
+
+	_, err = cw.CallModel(t.Context())
+	assert.ErrorContains(t, err, "no call left in the fixture, all 1 were made")
+}
+
+func TestReplayFailsOnDivergence(t *testing.T) {
+	f := &Fixture{Calls: []FixtureCall{{
+		Prompt:   "fix the build",
+		Tools:    []FixtureTool{{Name: tools.ToolNameReadFile, Input: map[string]any{"path": "main.go"}, Error: "not found"}},
+		Response: "done",
+	}}}
+
+	cw := newFixtureWindow(t, NewReplay(f), ReplayTools([]tools.ToolDefinition{readFileTool(nil)})[0])
+	assert.NoError(t, cw.AddPrompt(t.Context(), "fix the tests"))
+	_, err := cw.CallModel(t.Context())
+	assert.ErrorContains(t, err, `replay diverged at call 1: prompt "fix the tests", recorded "fix the build"`)
+
+	// A tool missing from the session fails differently than recorded
+	cw = newFixtureWindow(t, NewReplay(&Fixture{Calls: f.Calls}), tools.ToolDefinition{Name: tools.ToolNameBash})
+	assert.NoError(t, cw.AddPrompt(t.Context(), "fix the build"))
+	_, err = cw.CallModel(t.Context())
+	assert.ErrorContains(t, err, `read_file failed with "tool read_file not registered", recorded "not found"`)
+}
+
+func mustContextID(t *testing.T, cw *ContextWindow) string {
+	t.Helper()
+	id, err := storage.GetContextIDByName(t.Context(), cw.db, cw.currentContext)
+	assert.NoError(t, err)
+	return id
+}
+
+func TestMockRunsScriptedTools(t *testing.T) {
+	f := &Fixture{Calls: []FixtureCall{{
+		Prompt:   "ignored by the mock",
+		Tools:    []FixtureTool{{Name: tools.ToolNameReadFile, Input: map[string]any{"path": "go.mod"}, Output: "ignored too"}},
+		Response: "It is the demo module.",
+	}}}
+
+	ran := 0
+	cw := newFixtureWindow(t, NewMock(f), readFileTool(func() (string, error) {
+		ran++
+		return "module demo", nil
+	}))
+	assert.NoError(t, cw.AddPrompt(t.Context(), "which module is this?"))
+	answer, err := cw.CallModel(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "It is the demo module.", answer)
+	assert.Equal(t, 1, ran)
+	assert.Equal(t, ModelVersion(ProviderMock), versionOf(cw.Model()))
+}
@@ -1,5 +1,18 @@
 package model
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"google.golang.org/genai"
+)
+
 const (
 	Gemini3Pro        ModelVersion = "gemini-3-pro-preview"
 	Gemini25Pro       ModelVersion = "gemini-2.5-pro"
@@ -9,3 +22,354 @@ const (
 	Gemini15Pro       ModelVersion = "gemini-1.5-pro"
 	Gemini15Flash     ModelVersion = "gemini-1.5-flash"
 )
+
+// geminiGenerator is the subset of genai.Models used by GeminiModel
+type geminiGenerator interface {
+	GenerateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error)
+}
+
+type GeminiModel struct {
+	client geminiGenerator
+	// Caches the conversation prefix across calls, none if nil
+	caches       geminiCacher
+	cache        *geminiCache
+	model        ModelVersion
+	toolExecutor tools.ToolExecutor
+	lastUsage    Usage
+	outputSchema map[string]any
+	// Thresholds of the safety filters, the API's defaults if nil
+	safety []*genai.SafetySetting
+}
+
+func NewGeminiModel(model ModelVersion) (*GeminiModel, error) {
+	apiKey := os.Getenv("GOOGLE_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("GOOGLE_API_KEY not set")
+	}
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:     apiKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: debugHTTPClient(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create gemini client: %w", err)
+	}
+
+	return &GeminiModel{
+		client: client.Models,
+		caches: client.Caches,
+		model:  model,
+	}, nil
+}
+
+func validateGeminiKey(ctx context.Context, key string) error {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:  key,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return fmt.Errorf("create gemini client: %w", err)
+	}
+	if _, err := client.Models.List(ctx, &genai.ListModelsConfig{PageSize: 1}); err != nil {
+		return fmt.Errorf("validate API key: %w", err)
+	}
+	return nil
+}
+
+func (g *GeminiModel) MaxTokens() int {
+	if caps, ok := LookupCapabilities(g.model); ok {
+		return caps.ContextWindow
+	}
+	return 1_000_000
+}
+
+// Version implements the Versioned interface
+func (g *GeminiModel) Version() ModelVersion {
+	return g.model
+}
+
+// LastUsage implements the UsageReporter interface
+func (g *GeminiModel) LastUsage() Usage {
+	return g.lastUsage
+}
+
+// SetOutputSchema implements the StructuredOutput interface
+func (g *GeminiModel) SetOutputSchema(schema map[string]any) {
+	g.outputSchema = schema
+}
+
+// SetToolExecutor sets the tool executor for the Gemini model
+func (g *GeminiModel) SetToolExecutor(executor tools.ToolExecutor) {
+	g.toolExecutor = executor
+}
+
+func (g *GeminiModel) Call(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+	system, contents := toGeminiContents(inputs)
+
+	config := &genai.GenerateContentConfig{
+		MaxOutputTokens:   8192,
+		SystemInstruction: system,
+		SafetySettings:    g.safety,
+	}
+
+	if g.toolExecutor != nil {
+		if availableTools := g.toolExecutor.GetRegisteredTools(); len(availableTools) > 0 {
+			var err error
+			if config.Tools, err = getGeminiTools(availableTools); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	if g.outputSchema != nil {
+		config.ResponseMIMEType = "application/json"
+		config.ResponseJsonSchema = g.outputSchema
+	}
+
+	contents = g.useCache(ctx, config, contents)
+
+	// Send the user prompt
+	start := time.Now()
+	resp, err := g.client.GenerateContent(ctx, string(g.model), contents, config)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gemini api: %w", err)
+	}
+
+	usage := geminiUsage(resp)
+	usage.FirstResponse = time.Since(start)
+
+	var events []storage.Record
+
+	for {
+		turn, err := modelTurn(resp)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		calls := functionCalls(turn)
+		if len(calls) == 0 {
+			break
+		}
+		first := len(events)
+
+		// Replay the model turn as received. Gemini 3 rejects function calls
+		// of the current turn whose thought signature was dropped or altered,
+		// so parts are never rebuilt from the FunctionCall alone.
+		contents = append(contents, turn)
+
+		var responses []*genai.Part
+		for _, fc := range calls {
+			args, err := json.Marshal(fc.Args)
+			if err != nil {
+				return nil, 0, fmt.Errorf("marshal gemini function args: %w", err)
+			}
+
+			out, err := g.toolExecutor.ExecuteTool(ctx, fc.Name, args)
+			summary := tools.FormatResult(fc.Name, args, out, err)
+
+			call := storage.EncodeToolUse(fc.Name, args)
+			status := storage.StatusOK
+			response := map[string]any{"output": out}
+			if err != nil {
+				status = storage.StatusError
+				response = map[string]any{"error": fmt.Sprintf("error executing tool: %v", err)}
+			}
+			events = append(events, storage.Record{
+				Source:    storage.ToolUse,
+				Content:   call,
+				Live:      true,
+				EstTokens: storage.TokenCount(call),
+				Status:    status,
+				Summary:   summary,
+				Output:    out,
+			})
+			responses = append(responses, functionResponse(fc, response))
+		}
+
+		u := geminiUsage(resp)
+		requestTokens(events[first:], u.InputTokens, u.OutputTokens)
+
+		// Send the results back and continue with the next tools
+		contents = append(contents, genai.NewContentFromParts(responses, genai.RoleUser))
+
+		resp, err = g.client.GenerateContent(ctx, string(g.model), contents, config)
+		if err != nil {
+			return nil, 0, fmt.Errorf("gemini api (tool continuation): %w", err)
+		}
+
+		u = geminiUsage(resp)
+		usage.InputTokens += u.InputTokens
+		usage.OutputTokens += u.OutputTokens
+		usage.CachedInputTokens += u.CachedInputTokens
+	}
+
+	responseText := answerText(resp)
+	// The continuations of the answer are counted with it, their prompts
+	// hold the whole answer so far
+	answer := geminiUsage(resp)
+
+	// An answer cut off at the output limit is asked to go on, and the
+	// parts are joined as one
+	for range maxContinuations {
+		if resp.Candidates[0].FinishReason != genai.FinishReasonMaxTokens {
+			break
+		}
+		turn, err := modelTurn(resp)
+		if err != nil {
+			return nil, 0, err
+		}
+		contents = append(contents, turn, genai.NewContentFromText(continuePrompt, genai.RoleUser))
+		resp, err = g.client.GenerateContent(ctx, string(g.model), contents, config)
+		if err != nil {
+			return nil, 0, fmt.Errorf("gemini api (continuation): %w", err)
+		}
+		if _, err := modelTurn(resp); err != nil {
+			return nil, 0, err
+		}
+
+		u := geminiUsage(resp)
+		usage.InputTokens += u.InputTokens
+		usage.OutputTokens += u.OutputTokens
+		usage.CachedInputTokens += u.CachedInputTokens
+		answer.InputTokens, answer.OutputTokens = u.InputTokens, answer.OutputTokens+u.OutputTokens
+		responseText += answerText(resp)
+	}
+	if resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens {
+		responseText += truncatedNote
+	}
+
+	g.lastUsage = usage
+
+	events = append(events, storage.Record{
+		Source:    storage.ModelResp,
+		Content:   responseText,
+		Live:      true,
+		EstTokens: storage.TokenCount(responseText),
+	})
+	requestTokens(events[len(events)-1:], answer.InputTokens, answer.OutputTokens)
+
+	return events, usage.InputTokens + usage.OutputTokens, nil
+}
+
+// Continuations asked for an answer that keeps reaching the output limit
+const maxContinuations = 3
+
+const continuePrompt = "Your answer was cut off at the output limit. Continue exactly where it stopped, without repeating anything or adding a preamble."
+
+// Ends an answer still cut off after maxContinuations, so it is not taken
+// for a complete one
+const truncatedNote = "\n\n[Answer truncated: it exceeded the output limit]"
+
+// answerText returns the final response of the LLM, thought summaries left out.
+func answerText(resp *genai.GenerateContentResponse) string {
+	var textBlocks []string
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.Text != "" && !part.Thought {
+			textBlocks = append(textBlocks, part.Text)
+		}
+	}
+	return strings.Join(textBlocks, "\n")
+}
+
+// toGeminiContents splits records into the system instruction and conversation contents.
+// Tool calls are not replayed across turns, so neither are their thought signatures.
+func toGeminiContents(inputs []storage.Record) (*genai.Content, []*genai.Content) {
+	var systemParts []*genai.Part
+	var contents []*genai.Content
+
+	for _, rec := range inputs {
+		// The API rejects parts without data
+		if rec.Content == "" {
+			continue
+		}
+
+		switch rec.Source {
+		case storage.SystemPrompt, storage.SystemNote:
+			systemParts = append(systemParts, genai.NewPartFromText(rec.Content))
+		case storage.Prompt, storage.ToolResult:
+			contents = append(contents, genai.NewContentFromText(rec.Content, genai.RoleUser))
+		case storage.ModelResp:
+			contents = append(contents, genai.NewContentFromText(rec.Content, genai.RoleModel))
+		}
+	}
+
+	var system *genai.Content
+	if len(systemParts) > 0 {
+		system = genai.NewContentFromParts(systemParts, genai.RoleUser)
+	}
+
+	return system, contents
+}
+
+// modelTurn returns the content of the first candidate, which is the only one requested.
+// A prompt or answer withheld by the safety filters fails with a BlockedError.
+func modelTurn(resp *genai.GenerateContentResponse) (*genai.Content, error) {
+	if err := blockedError(resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return nil, fmt.Errorf("gemini returned no candidates")
+	}
+
+	turn := resp.Candidates[0].Content
+	if turn.Role == "" {
+		turn.Role = genai.RoleModel
+	}
+	return turn, nil
+}
+
+func functionCalls(turn *genai.Content) []*genai.FunctionCall {
+	var calls []*genai.FunctionCall
+	for _, part := range turn.Parts {
+		if part.FunctionCall != nil {
+			calls = append(calls, part.FunctionCall)
+		}
+	}
+	return calls
+}
+
+// functionResponse answers a function call. The call ID is echoed back so
+// parallel calls to the same function are matched to the right result;
+// responses are matched by name and order only when the API sent no ID.
+func functionResponse(fc *genai.FunctionCall, response map[string]any) *genai.Part {
+	return &genai.Part{
+		FunctionResponse: &genai.FunctionResponse{
+			ID:       fc.ID,
+			Name:     fc.Name,
+			Response: response,
+		},
+	}
+}
+
+// geminiUsage extracts token usage of a response.
+// Thinking tokens are billed as output, so they count as such.
+func geminiUsage(resp *genai.GenerateContentResponse) Usage {
+	if resp.UsageMetadata == nil {
+		return Usage{}
+	}
+	return Usage{
+		InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
+		OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount + resp.UsageMetadata.ThoughtsTokenCount),
+		// Cached tokens are part of the prompt's
+		CachedInputTokens: int(resp.UsageMetadata.CachedContentTokenCount),
+	}
+}
+
+// getGeminiTools converts the tool definitions to Gemini's format, dropping
+// the schema keywords it does not support, see CheckToolSchemas.
+func getGeminiTools(availableTools []tools.ToolDefinition) ([]*genai.Tool, error) {
+	var decls []*genai.FunctionDeclaration
+	for _, tool := range availableTools {
+		schema, err := geminiSchema(tool)
+		if err != nil {
+			return nil, err
+		}
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:                 tool.Name,
+			Description:          tool.Description,
+			ParametersJsonSchema: schema,
+		})
+	}
+	return []*genai.Tool{{FunctionDeclarations: decls}}, nil
+}
@@ -0,0 +1,372 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// fakeGemini replays canned responses and records every request's contents
+type fakeGemini struct {
+	responses []*genai.GenerateContentResponse
+	requests  [][]*genai.Content
+	configs   []genai.GenerateContentConfig
+}
+
+func (f *fakeGemini) GenerateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	// Copy, since the caller keeps appending to the same slice
+	f.requests = append(f.requests, append([]*genai.Content(nil), contents...))
+	f.configs = append(f.configs, *config)
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	return resp, nil
+}
+
+// echoExecutor returns the tool name as output
+type echoExecutor struct {
+	calls []string
+}
+
+func (e *echoExecutor) ExecuteTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	e.calls = append(e.calls, name)
+	return name + " done", nil
+}
+
+func (e *echoExecutor) GetRegisteredTools() []tools.ToolDefinition {
+	return []tools.ToolDefinition{tools.ReadFileDefinition}
+}
+
+func geminiResponse(parts ...*genai.Part) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{{Content: &genai.Content{Role: genai.RoleModel, Parts: parts}}},
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:     10,
+			CandidatesTokenCount: 5,
+		},
+	}
+}
+
+func TestGeminiCall_ReplaysThoughtSignatures(t *testing.T) {
+	first := []byte{0x01, 0xfe, 0x00, 0x7f}
+	second := []byte("second-step")
+
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{
+		geminiResponse(&genai.Part{
+			FunctionCall:     &genai.FunctionCall{Name: tools.ToolNameReadFile, Args: map[string]any{"path": "a.go"}},
+			ThoughtSignature: first,
+		}),
+		geminiResponse(&genai.Part{
+			FunctionCall:     &genai.FunctionCall{Name: tools.ToolNameReadFile, Args: map[string]any{"path": "b.go"}},
+			ThoughtSignature: second,
+		}),
+		geminiResponse(genai.NewPartFromText("done")),
+	}}
+	executor := &echoExecutor{}
+	g := &GeminiModel{client: fake, model: Gemini3Pro, toolExecutor: executor}
+
+	events, tokens, err := g.Call(context.Background(), []storage.Record{
+		{Source: storage.Prompt, Content: "read both files"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, fake.requests, 3)
+	last := fake.requests[2]
+	// prompt, call, response, call, response
+	require.Len(t, last, 5)
+	assert.Equal(t, first, last[1].Parts[0].ThoughtSignature)
+	assert.Equal(t, second, last[3].Parts[0].ThoughtSignature)
+	assert.Equal(t, genai.RoleUser, last[2].Role)
+	assert.NotNil(t, last[2].Parts[0].FunctionResponse)
+
+	assert.Equal(t, []string{tools.ToolNameReadFile, tools.ToolNameReadFile}, executor.calls)
+	require.Len(t, events, 3)
+	assert.Equal(t, storage.ToolUse, events[0].Source)
+	assert.Equal(t, storage.StatusOK, events[0].Status)
+	assert.Equal(t, "done", events[2].Content)
+	assert.Equal(t, 45, tokens)
+}
+
+func TestGeminiCall_ParallelCallsKeepIDs(t *testing.T) {
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{
+		geminiResponse(
+			&genai.Part{FunctionCall: &genai.FunctionCall{ID: "call-a", Name: tools.ToolNameReadFile, Args: map[string]any{"path": "a.go"}}},
+			&genai.Part{FunctionCall: &genai.FunctionCall{ID: "call-b", Name: tools.ToolNameReadFile, Args: map[string]any{"path": "b.go"}}},
+		),
+		geminiResponse(genai.NewPartFromText("done")),
+	}}
+	executor := &echoExecutor{}
+	g := &GeminiModel{client: fake, model: Gemini25Pro, toolExecutor: executor}
+
+	events, _, err := g.Call(context.Background(), []storage.Record{
+		{Source: storage.Prompt, Content: "read both files"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, fake.requests, 2)
+	results := fake.requests[1][2]
+	require.Len(t, results.Parts, 2)
+	for i, id := range []string{"call-a", "call-b"} {
+		fr := results.Parts[i].FunctionResponse
+		require.NotNil(t, fr)
+		assert.Equal(t, id, fr.ID)
+		assert.Equal(t, tools.ToolNameReadFile, fr.Name)
+	}
+
+	require.Len(t, events, 3)
+	assert.Contains(t, events[0].Content, "a.go")
+	assert.Contains(t, events[1].Content, "b.go")
+}
+
+func TestFunctionResponse_WithoutID(t *testing.T) {
+	part := functionResponse(&genai.FunctionCall{Name: "bash"}, map[string]any{"output": "ok"})
+
+	require.NotNil(t, part.FunctionResponse)
+	assert.Empty(t, part.FunctionResponse.ID)
+	assert.Equal(t, "bash", part.FunctionResponse.Name)
+	assert.Equal(t, "ok", part.FunctionResponse.Response["output"])
+}
+
+func TestGeminiCall_SignatureSurvivesJSON(t *testing.T) {
+	// Signatures arrive base64-encoded and must go back out identically
+	raw := `{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"read_file","args":{}},"thoughtSignature":"AQL+/w=="}]}}]}`
+	var resp genai.GenerateContentResponse
+	require.NoError(t, json.Unmarshal([]byte(raw), &resp))
+
+	turn, err := modelTurn(&resp)
+	require.NoError(t, err)
+
+	out, err := json.Marshal(turn)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"thoughtSignature":"AQL+/w=="`)
+}
+
+func TestGeminiCall_ExcludesThoughtText(t *testing.T) {
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{
+		geminiResponse(
+			&genai.Part{Text: "thinking about it", Thought: true},
+			genai.NewPartFromText("answer"),
+		),
+	}}
+	g := &GeminiModel{client: fake, model: Gemini25Flash}
+
+	events, _, err := g.Call(context.Background(), []storage.Record{
+		{Source: storage.Prompt, Content: "question"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, events, 1)
+	assert.Equal(t, "answer", events[0].Content)
+}
+
+func TestModelTurn_NoCandidates(t *testing.T) {
+	_, err := modelTurn(&genai.GenerateContentResponse{
+		PromptFeedback: &genai.GenerateContentResponsePromptFeedback{BlockReason: genai.BlockedReasonSafety},
+	})
+
+	assert.ErrorContains(t, err, "blocked")
+}
+
+func TestToGeminiContents(t *testing.T) {
+	system, contents := toGeminiContents([]storage.Record{
+		{Source: storage.SystemPrompt, Content: "be helpful"},
+		{Source: storage.Prompt, Content: "hi"},
+		{Source: storage.ModelResp, Content: "hello"},
+		{Source: storage.ModelResp, Content: ""},
+		{Source: storage.ToolResult, Content: "ok"},
+		{Source: storage.SystemNote, Content: "summary"},
+	})
+
+	require.NotNil(t, system)
+	require.Len(t, system.Parts, 2)
+	assert.Equal(t, "be helpful", system.Parts[0].Text)
+	assert.Equal(t, "summary", system.Parts[1].Text)
+	require.Len(t, contents, 3)
+	assert.Equal(t, genai.RoleModel, contents[1].Role)
+}
+
+// fakeCaches keeps the caches created, by name
+type fakeCaches struct {
+	created []*genai.CreateCachedContentConfig
+	deleted []string
+	updated []string
+	expires time.Time
+}
+
+func (f *fakeCaches) Create(ctx context.Context, model string, config *genai.CreateCachedContentConfig) (*genai.CachedContent, error) {
+	f.created = append(f.created, config)
+	return &genai.CachedContent{Name: fmt.Sprintf("cachedContents/%d", len(f.created)), ExpireTime: f.expires}, nil
+}
+
+func (f *fakeCaches) Update(ctx context.Context, name string, config *genai.UpdateCachedContentConfig) (*genai.CachedContent, error) {
+	f.updated = append(f.updated, name)
+	return &genai.CachedContent{Name: name, ExpireTime: time.Now().Add(config.TTL)}, nil
+}
+
+func (f *fakeCaches) Delete(ctx context.Context, name string, config *genai.DeleteCachedContentConfig) (*genai.DeleteCachedContentResponse, error) {
+	f.deleted = append(f.deleted, name)
+	return &genai.DeleteCachedContentResponse{}, nil
+}
+
+func TestGeminiCall_CachesHistory(t *testing.T) {
+	cached := geminiResponse(genai.NewPartFromText("ok"))
+	cached.UsageMetadata.CachedContentTokenCount = 8
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{cached, cached, cached}}
+	caches := &fakeCaches{expires: time.Now().Add(time.Hour)}
+	g := &GeminiModel{client: fake, caches: caches, model: Gemini25Flash}
+
+	history := []storage.Record{
+		{Source: storage.SystemPrompt, Content: "be helpful"},
+		{Source: storage.Prompt, Content: strings.Repeat("word ", 2000)},
+		{Source: storage.ModelResp, Content: "noted"},
+		{Source: storage.Prompt, Content: "next"},
+	}
+	_, _, err := g.Call(context.Background(), history)
+	require.NoError(t, err)
+
+	// Everything before the latest prompt is cached, system instruction included
+	require.Len(t, caches.created, 1)
+	assert.Len(t, caches.created[0].Contents, 2)
+	assert.NotNil(t, caches.created[0].SystemInstruction)
+	assert.Equal(t, "cachedContents/1", fake.configs[0].CachedContent)
+	assert.Nil(t, fake.configs[0].SystemInstruction)
+	require.Len(t, fake.requests[0], 1)
+	assert.Equal(t, "next", fake.requests[0][0].Parts[0].Text)
+	assert.Equal(t, 8, g.LastUsage().CachedInputTokens)
+
+	// A short exchange later the same cache still holds the prefix
+	history = append(history, storage.Record{Source: storage.ModelResp, Content: "done"}, storage.Record{Source: storage.Prompt, Content: "again"})
+	_, _, err = g.Call(context.Background(), history)
+	require.NoError(t, err)
+	assert.Len(t, caches.created, 1)
+	assert.Equal(t, "cachedContents/1", fake.configs[1].CachedContent)
+	assert.Len(t, fake.requests[1], 3)
+
+	// A new system instruction needs a new cache, and the old one goes
+	history[0].Content = "be brief"
+	_, _, err = g.Call(context.Background(), history)
+	require.NoError(t, err)
+	require.Len(t, caches.created, 2)
+	assert.Equal(t, []string{"cachedContents/1"}, caches.deleted)
+	assert.Equal(t, "cachedContents/2", fake.configs[2].CachedContent)
+	assert.Empty(t, caches.updated)
+}
+
+func TestGeminiCall_ShortHistoryIsNotCached(t *testing.T) {
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{geminiResponse(genai.NewPartFromText("ok"))}}
+	caches := &fakeCaches{}
+	g := &GeminiModel{client: fake, caches: caches, model: Gemini25Flash}
+
+	_, _, err := g.Call(context.Background(), []storage.Record{
+		{Source: storage.SystemPrompt, Content: "be helpful"},
+		{Source: storage.Prompt, Content: "hi"},
+		{Source: storage.ModelResp, Content: "hello"},
+		{Source: storage.Prompt, Content: "bye"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, caches.created)
+	assert.Empty(t, fake.configs[0].CachedContent)
+	assert.NotNil(t, fake.configs[0].SystemInstruction)
+	assert.Len(t, fake.requests[0], 3)
+}
+
+func TestGeminiCall_RefreshesExpiringCache(t *testing.T) {
+	resp := geminiResponse(genai.NewPartFromText("ok"))
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{resp, resp}}
+	caches := &fakeCaches{expires: time.Now().Add(10 * time.Second)}
+	g := &GeminiModel{client: fake, caches: caches, model: Gemini25Flash}
+
+	history := []storage.Record{
+		{Source: storage.Prompt, Content: strings.Repeat("word ", 2000)},
+		{Source: storage.ModelResp, Content: "noted"},
+		{Source: storage.Prompt, Content: "next"},
+	}
+	for range 2 {
+		_, _, err := g.Call(context.Background(), history)
+		require.NoError(t, err)
+	}
+	assert.Len(t, caches.created, 1)
+	assert.Equal(t, []string{"cachedContents/1"}, caches.updated)
+}
+
+func TestGeminiCall_ContinuesTruncatedAnswer(t *testing.T) {
+	cut := geminiResponse(genai.NewPartFromText("func main() {\n\tfmt.Pri"))
+	cut.Candidates[0].FinishReason = genai.FinishReasonMaxTokens
+	rest := geminiResponse(genai.NewPartFromText("ntln(\"hi\")\n}"))
+	rest.Candidates[0].FinishReason = genai.FinishReasonStop
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{cut, rest}}
+	g := &GeminiModel{client: fake, model: Gemini25Flash}
+
+	events, tokens, err := g.Call(context.Background(), []storage.Record{{Source: storage.Prompt, Content: "write main.go"}})
+	require.NoError(t, err)
+
+	require.Len(t, fake.requests, 2)
+	// prompt, cut off answer, request to continue
+	require.Len(t, fake.requests[1], 3)
+	assert.Equal(t, genai.RoleModel, fake.requests[1][1].Role)
+	assert.Equal(t, continuePrompt, fake.requests[1][2].Parts[0].Text)
+
+	require.Len(t, events, 1)
+	assert.Equal(t, "func main() {\n\tfmt.Println(\"hi\")\n}", events[0].Content)
+	assert.Equal(t, 30, tokens)
+}
+
+func TestGeminiCall_MarksAnswerStillTruncated(t *testing.T) {
+	cut := geminiResponse(genai.NewPartFromText("more"))
+	cut.Candidates[0].FinishReason = genai.FinishReasonMaxTokens
+	fake := &fakeGemini{}
+	for range maxContinuations + 1 {
+		fake.responses = append(fake.responses, cut)
+	}
+	g := &GeminiModel{client: fake, model: Gemini25Flash}
+
+	events, _, err := g.Call(context.Background(), []storage.Record{{Source: storage.Prompt, Content: "go on forever"}})
+	require.NoError(t, err)
+
+	assert.Len(t, fake.requests, maxContinuations+1)
+	assert.Equal(t, strings.Repeat("more", maxContinuations+1)+truncatedNote, events[0].Content)
+}
+
+func TestGeminiCall_BlockedAnswerIsAnError(t *testing.T) {
+	blocked := geminiResponse()
+	blocked.Candidates[0].FinishReason = genai.FinishReasonSafety
+	blocked.Candidates[0].SafetyRatings = []*genai.SafetyRating{
+		{Category: genai.HarmCategoryHarassment},
+		{Category: genai.HarmCategoryDangerousContent, Blocked: true},
+	}
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{blocked}}
+	g := &GeminiModel{client: fake, model: Gemini25Flash}
+
+	_, _, err := g.Call(context.Background(), []storage.Record{{Source: storage.Prompt, Content: "kill the process on port 8080"}})
+
+	var be *BlockedError
+	require.ErrorAs(t, err, &be)
+	assert.False(t, be.Prompt)
+	assert.Equal(t, "SAFETY", be.Reason)
+	assert.Equal(t, []string{"HARM_CATEGORY_DANGEROUS_CONTENT"}, be.Categories)
+	assert.ErrorContains(t, err, "gemini_safety")
+}
+
+func TestGeminiCall_SafetyThreshold(t *testing.T) {
+	fake := &fakeGemini{responses: []*genai.GenerateContentResponse{geminiResponse(genai.NewPartFromText("ok"))}}
+	g := &GeminiModel{client: fake, model: Gemini25Flash}
+
+	assert.Error(t, g.SetSafetyThreshold("lenient"))
+	require.NoError(t, g.SetSafetyThreshold("block_only_high"))
+	_, _, err := g.Call(context.Background(), []storage.Record{{Source: storage.Prompt, Content: "hi"}})
+	require.NoError(t, err)
+
+	settings := fake.configs[0].SafetySettings
+	require.Len(t, settings, len(geminiHarmCategories))
+	for _, s := range settings {
+		assert.Equal(t, genai.HarmBlockThresholdBlockOnlyHigh, s.Threshold)
+	}
+}
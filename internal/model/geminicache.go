@@ -0,0 +1,150 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"google.golang.org/genai"
+)
+
+// geminiCacher is the subset of genai.Caches used by GeminiModel
+type geminiCacher interface {
+	Create(ctx context.Context, model string, config *genai.CreateCachedContentConfig) (*genai.CachedContent, error)
+	Update(ctx context.Context, name string, config *genai.UpdateCachedContentConfig) (*genai.CachedContent, error)
+	Delete(ctx context.Context, name string, config *genai.DeleteCachedContentConfig) (*genai.DeleteCachedContentResponse, error)
+}
+
+// How long a cache lives past its last refresh. Caches are billed for the
+// time they are kept, so they do not outlive an idle session by much.
+const geminiCacheTTL = 10 * time.Minute
+
+// A cache expiring sooner than this is refreshed before it is used
+const geminiCacheMargin = time.Minute
+
+// geminiCache is the explicit cache of a conversation prefix
+type geminiCache struct {
+	name    string
+	expires time.Time
+	// Hash of the system instruction, tools and contents the cache holds
+	key [sha256.Size]byte
+	// Number of contents the cache holds
+	contents int
+}
+
+// useCache moves the system instruction, the tools and the history before
+// the latest prompt into a cached content, and returns the contents still
+// to send. The cache is created when none holds the prefix, on a change of
+// system instruction or tools, or once as many tokens as the model's minimum
+// have been added since, and refreshed when about to expire. Without a cache
+// the conversation is sent whole, so a failing cache never fails a call.
+func (g *GeminiModel) useCache(ctx context.Context, config *genai.GenerateContentConfig, contents []*genai.Content) []*genai.Content {
+	caps, ok := LookupCapabilities(g.model)
+	if g.caches == nil || !ok || caps.MinCacheTokens == 0 || len(contents) < 2 {
+		return contents
+	}
+	// The latest prompt is new every turn
+	prefix := contents[:len(contents)-1]
+
+	if c := g.cache; c != nil && c.contents <= len(prefix) &&
+		c.key == geminiCacheKey(config, prefix[:c.contents]) &&
+		geminiTokens(prefix[c.contents:]...) < caps.MinCacheTokens &&
+		g.refreshCache(ctx) {
+		return cachedConfig(config, c, contents)
+	}
+
+	if geminiTokens(append([]*genai.Content{config.SystemInstruction}, prefix...)...)+geminiToolTokens(config.Tools) < caps.MinCacheTokens {
+		g.dropCache(ctx)
+		return contents
+	}
+	cached, err := g.caches.Create(ctx, string(g.model), &genai.CreateCachedContentConfig{
+		TTL:               geminiCacheTTL,
+		SystemInstruction: config.SystemInstruction,
+		Tools:             config.Tools,
+		Contents:          prefix,
+	})
+	g.dropCache(ctx)
+	if err != nil {
+		return contents
+	}
+	g.cache = &geminiCache{
+		name:     cached.Name,
+		expires:  expiry(cached),
+		key:      geminiCacheKey(config, prefix),
+		contents: len(prefix),
+	}
+	return cachedConfig(config, g.cache, contents)
+}
+
+// refreshCache extends the life of a cache about to expire and reports
+// whether it can still be used.
+func (g *GeminiModel) refreshCache(ctx context.Context) bool {
+	if time.Until(g.cache.expires) > geminiCacheMargin {
+		return true
+	}
+	updated, err := g.caches.Update(ctx, g.cache.name, &genai.UpdateCachedContentConfig{TTL: geminiCacheTTL})
+	if err != nil {
+		return false
+	}
+	g.cache.expires = expiry(updated)
+	return true
+}
+
+// dropCache deletes the current cache, which is then no longer billed.
+// A cache that cannot be deleted expires on its own.
+func (g *GeminiModel) dropCache(ctx context.Context) {
+	if g.cache == nil {
+		return
+	}
+	g.caches.Delete(ctx, g.cache.name, nil)
+	g.cache = nil
+}
+
+// cachedConfig points config to cache, which holds the system instruction
+// and tools the API refuses to also receive, and returns the contents past it.
+func cachedConfig(config *genai.GenerateContentConfig, cache *geminiCache, contents []*genai.Content) []*genai.Content {
+	config.CachedContent = cache.name
+	config.SystemInstruction = nil
+	config.Tools = nil
+	return contents[cache.contents:]
+}
+
+func expiry(c *genai.CachedContent) time.Time {
+	if c.ExpireTime.IsZero() {
+		return time.Now().Add(geminiCacheTTL)
+	}
+	return c.ExpireTime
+}
+
+func geminiCacheKey(config *genai.GenerateContentConfig, contents []*genai.Content) [sha256.Size]byte {
+	data, _ := json.Marshal([]any{config.SystemInstruction, config.Tools, contents})
+	return sha256.Sum256(data)
+}
+
+// geminiTokens estimates the tokens of contents with the built-in tokenizer
+func geminiTokens(contents ...*genai.Content) int {
+	n := 0
+	for _, c := range contents {
+		if c == nil {
+			continue
+		}
+		for _, part := range c.Parts {
+			n += storage.TokenCount(part.Text)
+			if part.FunctionCall != nil || part.FunctionResponse != nil {
+				data, _ := json.Marshal(part)
+				n += storage.TokenCount(string(data))
+			}
+		}
+	}
+	return n
+}
+
+func geminiToolTokens(tools []*genai.Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	data, _ := json.Marshal(tools)
+	return storage.TokenCount(string(data))
+}
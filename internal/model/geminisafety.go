@@ -0,0 +1,104 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Categories the safety threshold applies to, the ones Gemini filters text on
+var geminiHarmCategories = []genai.HarmCategory{
+	genai.HarmCategoryHarassment,
+	genai.HarmCategoryHateSpeech,
+	genai.HarmCategorySexuallyExplicit,
+	genai.HarmCategoryDangerousContent,
+}
+
+// SafetyThresholds are the thresholds SetSafetyThreshold takes, from the
+// most to the least permissive
+var SafetyThresholds = []string{"off", "block_none", "block_only_high", "block_medium_and_above", "block_low_and_above"}
+
+// Finish reasons of an answer withheld by the filters of the provider
+var geminiBlockedFinishes = []genai.FinishReason{
+	genai.FinishReasonSafety,
+	genai.FinishReasonBlocklist,
+	genai.FinishReasonProhibitedContent,
+	genai.FinishReasonSPII,
+}
+
+// BlockedError reports a prompt or answer the provider's safety filters
+// withheld, rather than an empty answer.
+type BlockedError struct {
+	// The prompt was blocked, not the answer
+	Prompt bool `json:"prompt"`
+	// Reason given by the provider, such as SAFETY
+	Reason string `json:"reason"`
+	// Harm categories that tripped the filters, if reported
+	Categories []string `json:"categories,omitempty"`
+}
+
+func (e *BlockedError) Error() string {
+	what := "answer"
+	if e.Prompt {
+		what = "prompt"
+	}
+	msg := fmt.Sprintf("gemini blocked the %s: %s", what, e.Reason)
+	if len(e.Categories) > 0 {
+		msg += " (" + strings.Join(e.Categories, ", ") + ")"
+	}
+	// Only the harm categories can be relaxed, prohibited content cannot
+	if e.Reason == string(genai.FinishReasonSafety) {
+		msg += `, set "gemini_safety" in config.json to a more permissive threshold to let it through`
+	}
+	return msg
+}
+
+// SetSafetyThreshold implements the SafetyFilter interface
+func (g *GeminiModel) SetSafetyThreshold(threshold string) error {
+	if !slices.Contains(SafetyThresholds, threshold) {
+		return fmt.Errorf("unknown safety threshold %q, want one of %s", threshold, strings.Join(SafetyThresholds, ", "))
+	}
+	g.safety = nil
+	for _, c := range geminiHarmCategories {
+		g.safety = append(g.safety, &genai.SafetySetting{
+			Category:  c,
+			Threshold: genai.HarmBlockThreshold(strings.ToUpper(threshold)),
+		})
+	}
+	return nil
+}
+
+// blockedError returns the reason resp was withheld by the safety filters,
+// nil if it was not.
+func blockedError(resp *genai.GenerateContentResponse) *BlockedError {
+	if len(resp.Candidates) == 0 {
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+			return &BlockedError{
+				Prompt:     true,
+				Reason:     string(resp.PromptFeedback.BlockReason),
+				Categories: blockedCategories(resp.PromptFeedback.SafetyRatings),
+			}
+		}
+		return nil
+	}
+	c := resp.Candidates[0]
+	if !slices.Contains(geminiBlockedFinishes, c.FinishReason) {
+		return nil
+	}
+	return &BlockedError{
+		Reason:     string(c.FinishReason),
+		Categories: blockedCategories(c.SafetyRatings),
+	}
+}
+
+func blockedCategories(ratings []*genai.SafetyRating) []string {
+	var categories []string
+	for _, r := range ratings {
+		if r.Blocked {
+			categories = append(categories, string(r.Category))
+		}
+	}
+	return categories
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/honganh1206/tinker/internal/storage"
 	_ "github.com/mattn/go-sqlite3"
@@ -13,4 +15,105 @@ type Model interface {
 	Call(ctx context.Context, inputs []storage.Record) (events []storage.Record, tokenUsed int, err error)
 }
 
+// New creates the model serving version on provider
+func New(provider ProviderName, version ModelVersion) (Model, error) {
+	switch provider {
+	case ProviderAnthropic:
+		return NewClaudeModel(version)
+	case ProviderGemini:
+		return NewGeminiModel(version)
+	case ProviderMock:
+		return nil, fmt.Errorf("provider %q needs fixtures", provider)
+	default:
+		return nil, fmt.Errorf("unknown provider %q", provider)
+	}
+}
+
+// DefaultModels is the model used for each provider when none is chosen
+var DefaultModels = map[ProviderName]ModelVersion{
+	ProviderAnthropic: Claude46Sonnet,
+	ProviderGemini:    Gemini25Pro,
+}
+
+// APIKeyEnv names the environment variable each provider reads its API key from
+var APIKeyEnv = map[ProviderName]string{
+	ProviderAnthropic: "ANTHROPIC_API_KEY",
+	ProviderGemini:    "GOOGLE_API_KEY",
+}
+
+// ValidateAPIKey checks that the provider accepts key, with a request that
+// costs no tokens.
+func ValidateAPIKey(ctx context.Context, provider ProviderName, key string) error {
+	switch provider {
+	case ProviderAnthropic:
+		return validateClaudeKey(ctx, key)
+	case ProviderGemini:
+		return validateGeminiKey(ctx, key)
+	default:
+		return fmt.Errorf("unknown provider %q", provider)
+	}
+}
+
+// Usage breaks down the token usage and latency of a single Call
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+	// Input tokens read from a cache, counted in InputTokens too
+	CachedInputTokens int
+	// Latency until the provider returned its first response
+	FirstResponse time.Duration
+}
+
+// requestTokens sets the tokens of one provider request on the first of the
+// records it produced, see storage.Record.
+func requestTokens(recs []storage.Record, input, output int) {
+	if len(recs) > 0 {
+		recs[0].InputTokens, recs[0].OutputTokens = input, output
+	}
+}
+
+// UsageReporter is an optional interface models can implement
+// to report the usage of their most recent Call.
+type UsageReporter interface {
+	LastUsage() Usage
+}
+
+// ToolInputDelta reports the input of a tool call while the model is still generating it
+type ToolInputDelta struct {
+	ToolUseID string
+	Name      string
+	// Input received so far, usually incomplete JSON
+	PartialInput string
+	// Set once the input is complete
+	Done bool
+}
+
+// Streamer is an optional interface models can implement
+// to report tool inputs as they are generated.
+type Streamer interface {
+	SetToolInputHandler(handler func(ToolInputDelta))
+}
+
+// StructuredOutput is an optional interface models can implement
+// to constrain their final answer to a JSON schema. A nil schema lifts the constraint.
+type StructuredOutput interface {
+	SetOutputSchema(schema map[string]any)
+}
+
+// Thinker is an optional interface models can implement
+// to reason for up to tokens before each response, between tool calls too.
+type Thinker interface {
+	SetThinkingBudget(tokens int)
+}
 
+// SafetyFilter is an optional interface models can implement
+// to set how readily the provider withholds prompts and answers it deems harmful.
+type SafetyFilter interface {
+	SetSafetyThreshold(threshold string) error
+}
+
+// Versioned is an optional interface models can implement
+// to report which model version serves their calls.
+type Versioned interface {
+	Version() ModelVersion
+}
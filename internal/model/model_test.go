@@ -2,8 +2,10 @@ package model
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
 )
 
 type MockModel struct {
@@ -28,3 +30,39 @@ func (m *dummyModel) Call(ctx context.Context, inputs []storage.Record) ([]stora
 	}
 	return m.events, 0, nil
 }
+
+type usageModel struct {
+	MockModel
+	usage Usage
+}
+
+func (m *usageModel) LastUsage() Usage {
+	return m.usage
+}
+
+// toolModel runs one tool through its executor during Call and streams its input
+type toolModel struct {
+	MockModel
+	executor    tools.ToolExecutor
+	onToolInput func(ToolInputDelta)
+}
+
+func (m *toolModel) SetToolExecutor(e tools.ToolExecutor) {
+	m.executor = e
+}
+
+func (m *toolModel) SetToolInputHandler(handler func(ToolInputDelta)) {
+	m.onToolInput = handler
+}
+
+func (m *toolModel) Call(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+	args := json.RawMessage(`{"path":"go.mod"}`)
+	if m.onToolInput != nil {
+		m.onToolInput(ToolInputDelta{ToolUseID: "t1", Name: "read_file", PartialInput: string(args), Done: true})
+	}
+	out, err := m.executor.ExecuteTool(ctx, "read_file", args)
+	if err != nil {
+		return nil, 0, err
+	}
+	return []storage.Record{{Source: storage.ModelResp, Content: out, Live: true}}, 0, nil
+}
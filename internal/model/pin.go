@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// Share of the model's context the live records and pinned files may fill
+// before files are unpinned, leaving room for the answer and tool results
+const pinBudget = 0.9
+
+// Pin keeps the files at paths in the context of the following model calls,
+// read again before each one so the model never works from a stale copy.
+// Pinned files are not stored with the conversation.
+func (cw *ContextWindow) Pin(paths ...string) error {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("pin %s: %w", p, err)
+		}
+		if !slices.Contains(cw.pinned, p) {
+			cw.pinned = append(cw.pinned, p)
+		}
+	}
+	return nil
+}
+
+// Unpin stops sending the file at path.
+func (cw *ContextWindow) Unpin(path string) {
+	cw.pinned = slices.DeleteFunc(cw.pinned, func(p string) bool { return p == path })
+}
+
+// Pinned returns the paths of the pinned files, in the order they were pinned.
+func (cw *ContextWindow) Pinned() []string {
+	return slices.Clone(cw.pinned)
+}
+
+// withPinned returns recs followed by a note holding the current content of
+// the pinned files. With fit, the most recently pinned files are unpinned
+// until the records fit the budget, each reported as an EventUnpin. The
+// records are sized with the token counter of the window.
+func (cw *ContextWindow) withPinned(ctx context.Context, recs []storage.Record, fit bool) []storage.Record {
+	if len(cw.pinned) == 0 {
+		return recs
+	}
+	note := cw.pinnedNote()
+	if fit {
+		live, err := cw.tokenCounter.CountTokens(ctx, recs)
+		if err != nil {
+			live = 0
+			for _, r := range recs {
+				live += storage.TokenCount(r.Content)
+			}
+		}
+		budget := int(pinBudget * float64(cw.contextSize()))
+		for len(cw.pinned) > 0 {
+			used := live + storage.TokenCount(note)
+			if used <= budget {
+				break
+			}
+			path := cw.pinned[len(cw.pinned)-1]
+			cw.pinned = cw.pinned[:len(cw.pinned)-1]
+			cw.emit(Event{Kind: EventUnpin, Text: fmt.Sprintf("unpinned %s, the context is almost full (%d of %d tokens)", path, used, cw.contextSize())})
+			note = cw.pinnedNote()
+		}
+		if len(cw.pinned) == 0 {
+			return recs
+		}
+	}
+	return append(slices.Clone(recs), storage.Record{Source: storage.SystemNote, Content: note, Live: true})
+}
+
+// pinnedNote lists the pinned files with their content as of now.
+func (cw *ContextWindow) pinnedNote() string {
+	var b strings.Builder
+	b.WriteString("# Pinned files\n\nThe user pinned these files, shown as they are now. They supersede any earlier read of them.\n")
+	for _, p := range cw.pinned {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			fmt.Fprintf(&b, "\n## %s\n\n(not readable: %v)\n", p, err)
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n```\n%s\n```\n", p, strings.TrimRight(string(data), "\n"))
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inputsModel keeps the records of its last call
+type inputsModel struct {
+	dummyModel
+	inputs    []storage.Record
+	maxTokens int
+}
+
+func (m *inputsModel) Call(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+	m.inputs = inputs
+	return m.dummyModel.Call(ctx, inputs)
+}
+
+func (m *inputsModel) MaxTokens() int {
+	return m.maxTokens
+}
+
+func (m *inputsModel) pinnedNote() string {
+	for _, r := range m.inputs {
+		if r.Source == storage.SystemNote && strings.HasPrefix(r.Content, "# Pinned files") {
+			return r.Content
+		}
+	}
+	return ""
+}
+
+func TestPin_SendsCurrentContentEveryCall(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("notes.md", []byte("first\n"), 0o644))
+
+	db, err := storage.NewSession(":memory:", "")
+	require.NoError(t, err)
+	m := &inputsModel{maxTokens: 100_000}
+	cw, err := NewContextWindow(t.Context(), db, m, "pin")
+	require.NoError(t, err)
+	defer cw.Close()
+
+	require.NoError(t, cw.Pin("notes.md"))
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+	assert.Contains(t, m.pinnedNote(), "## notes.md\n\n```\nfirst\n```")
+
+	require.NoError(t, os.WriteFile("notes.md", []byte("second\n"), 0o644))
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+	assert.Contains(t, m.pinnedNote(), "second")
+	assert.NotContains(t, m.pinnedNote(), "first")
+
+	// The note is not stored with the conversation
+	records, err := cw.LiveRecords(t.Context())
+	require.NoError(t, err)
+	for _, r := range records {
+		assert.NotContains(t, r.Content, "# Pinned files")
+	}
+
+	cw.Unpin("notes.md")
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, m.pinnedNote())
+}
+
+func TestPin_MissingFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	db, err := storage.NewSession(":memory:", "")
+	require.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &inputsModel{}, "pin")
+	require.NoError(t, err)
+	defer cw.Close()
+
+	assert.Error(t, cw.Pin("missing.go"))
+	assert.Empty(t, cw.Pinned())
+}
+
+func TestPin_UnpinsLatestWhenContextIsTight(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("small.go", []byte("package small\n"), 0o644))
+	require.NoError(t, os.WriteFile("large.go", []byte(strings.Repeat("var x = 1\n", 2000)), 0o644))
+
+	db, err := storage.NewSession(":memory:", "")
+	require.NoError(t, err)
+	m := &inputsModel{}
+	cw, err := NewContextWindow(t.Context(), db, m, "pin")
+	require.NoError(t, err)
+	defer cw.Close()
+	// Room for the system prompt and the small file only
+	live, err := cw.CountLiveTokens(t.Context())
+	require.NoError(t, err)
+	m.maxTokens = live * 2
+
+	var unpinned []string
+	cw.Subscribe(func(ev Event) {
+		if ev.Kind == EventUnpin {
+			unpinned = append(unpinned, ev.Text)
+		}
+	})
+
+	require.NoError(t, cw.Pin("small.go", "large.go"))
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"small.go"}, cw.Pinned())
+	require.Len(t, unpinned, 1)
+	assert.Contains(t, unpinned[0], "unpinned large.go")
+	assert.Contains(t, m.pinnedNote(), "package small")
+}
+
+func TestPin_BudgetUsesTokenCounter(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("small.go", []byte("package small\n"), 0o644))
+
+	db, err := storage.NewSession(":memory:", "")
+	require.NoError(t, err)
+	m := &inputsModel{}
+	cw, err := NewContextWindow(t.Context(), db, m, "pin")
+	require.NoError(t, err)
+	defer cw.Close()
+	// Plenty of room by the local estimate, none by the provider's count
+	live, err := cw.CountLiveTokens(t.Context())
+	require.NoError(t, err)
+	m.maxTokens = live * 4
+	cw.SetTokenCounter(fixedCounter(m.maxTokens))
+
+	require.NoError(t, cw.Pin("small.go"))
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, cw.Pinned())
+}
@@ -0,0 +1,31 @@
+package model
+
+// Pricing is the list price of a model in USD per million tokens
+type Pricing struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+	// Price of input tokens read from a cache, 0 if none are reported
+	CachedInputPerMTok float64
+}
+
+// Cost estimates the USD cost of a call. Unknown models cost nothing
+// rather than guessing, so usage reports under-report instead of lie.
+func Cost(version ModelVersion, inputTokens, outputTokens int) float64 {
+	c, ok := capabilities[version]
+	if !ok {
+		return 0
+	}
+	p := c.Pricing
+	return (float64(inputTokens)*p.InputPerMTok + float64(outputTokens)*p.OutputPerMTok) / 1_000_000
+}
+
+// CacheSavings is what cachedTokens of the input of a call saved, compared to
+// sending them uncached. Cost does not take it off.
+func CacheSavings(version ModelVersion, cachedTokens int) float64 {
+	c, ok := capabilities[version]
+	if !ok || c.Pricing.CachedInputPerMTok == 0 {
+		return 0
+	}
+	p := c.Pricing
+	return float64(cachedTokens) * (p.InputPerMTok - p.CachedInputPerMTok) / 1_000_000
+}
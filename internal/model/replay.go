@@ -0,0 +1,163 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// Replay plays a fixture back in place of a provider. Every Call answers
+// with the next recorded call, running its tools through the executor so
+// the agent loop and its events behave as they did when recording. Calls
+// fail once the session diverges from the recording.
+type Replay struct {
+	fixture  *Fixture
+	executor tools.ToolExecutor
+	// Answer whatever the prompts and tools outcomes, see NewMock
+	scripted bool
+
+	mu    sync.Mutex
+	next  int
+	usage Usage
+}
+
+func NewReplay(f *Fixture) *Replay {
+	return &Replay{fixture: f}
+}
+
+// NewMock returns the model of the mock provider, which answers with the
+// calls scripted in f in order whatever it is asked. The tools of the script
+// run for real, their recorded outputs are ignored.
+func NewMock(f *Fixture) *Replay {
+	scripted := *f
+	if scripted.Model == "" {
+		scripted.Model = ModelVersion(ProviderMock)
+	}
+	return &Replay{fixture: &scripted, scripted: true}
+}
+
+func (r *Replay) Call(ctx context.Context, inputs []storage.Record) ([]storage.Record, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.fixture.Calls) {
+		return nil, 0, fmt.Errorf("no call left in the fixture, all %d were made", len(r.fixture.Calls))
+	}
+	n := r.next
+	call := r.fixture.Calls[n]
+	r.next++
+
+	if prompt := lastPrompt(inputs); !r.scripted && call.Prompt != "" && prompt != call.Prompt {
+		return nil, 0, fmt.Errorf("replay diverged at call %d: prompt %q, recorded %q", n+1, prompt, call.Prompt)
+	}
+
+	var events []storage.Record
+	for _, t := range call.Tools {
+		if r.executor == nil {
+			return nil, 0, fmt.Errorf("replay: call %d runs %s but no tools are registered", n+1, t.Name)
+		}
+		args := json.RawMessage("{}")
+		if t.Input != nil {
+			var err error
+			if args, err = json.Marshal(t.Input); err != nil {
+				return nil, 0, fmt.Errorf("encode %s input: %w", t.Name, err)
+			}
+		}
+
+		out, err := r.executor.ExecuteTool(withReplayedTool(ctx, t), t.Name, args)
+		if got := errorText(err); !r.scripted && got != t.Error {
+			return nil, 0, fmt.Errorf("replay diverged at call %d: %s failed with %q, recorded %q", n+1, t.Name, got, t.Error)
+		}
+		events = append(events, toolUseRecord(t.Name, args, out, err))
+	}
+
+	events = append(events, storage.Record{
+		Source:    storage.ModelResp,
+		Content:   call.Response,
+		Live:      true,
+		EstTokens: storage.TokenCount(call.Response),
+	})
+	r.usage = Usage{InputTokens: call.InputTokens, OutputTokens: call.OutputTokens}
+	return events, call.InputTokens + call.OutputTokens, nil
+}
+
+// SetToolExecutor implements the ToolCapable interface
+func (r *Replay) SetToolExecutor(executor tools.ToolExecutor) {
+	r.executor = executor
+}
+
+// LastUsage implements the UsageReporter interface, with the recorded usage
+func (r *Replay) LastUsage() Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage
+}
+
+// SetOutputSchema implements the StructuredOutput interface. The recorded
+// answers already conform to the schema they were recorded with.
+func (r *Replay) SetOutputSchema(schema map[string]any) {}
+
+// Version implements the Versioned interface, with the recorded model
+func (r *Replay) Version() ModelVersion {
+	return r.fixture.Model
+}
+
+type replayedToolKey struct{}
+
+func withReplayedTool(ctx context.Context, t FixtureTool) context.Context {
+	return context.WithValue(ctx, replayedToolKey{}, t)
+}
+
+// ReplayTools returns toolset with its tools answering the calls of a Replay
+// with the recorded results instead of running, so replays need no network
+// and leave the workspace alone.
+func ReplayTools(toolset []tools.ToolDefinition) []tools.ToolDefinition {
+	stubbed := make([]tools.ToolDefinition, len(toolset))
+	for i, t := range toolset {
+		stubbed[i] = t
+		run := t.Function
+		stubbed[i].Function = func(ctx context.Context, args json.RawMessage) (string, error) {
+			recorded, ok := ctx.Value(replayedToolKey{}).(FixtureTool)
+			if !ok {
+				return run(ctx, args)
+			}
+			if recorded.Error != "" {
+				return recorded.Output, errors.New(recorded.Error)
+			}
+			return recorded.Output, nil
+		}
+	}
+	return stubbed
+}
+
+// toolUseRecord is the record of a tool call, as providers store them.
+func toolUseRecord(name string, args json.RawMessage, out string, err error) storage.Record {
+	summary := tools.FormatResult(name, args, out, err)
+	status := storage.StatusOK
+	if err != nil {
+		out = fmt.Sprintf("error executing tool: %v", err)
+		status = storage.StatusError
+	}
+	call := storage.EncodeToolUse(name, args)
+	return storage.Record{
+		Source:    storage.ToolUse,
+		Content:   call,
+		Live:      true,
+		EstTokens: storage.TokenCount(call),
+		Status:    status,
+		Summary:   summary,
+		Output:    out,
+	}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// fileState is a file as the model last saw it, by a read or its own edit
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// trackFile records the state of the file at path as the one the model
+// knows. Files that cannot be stat'ed, such as those of a remote workspace,
+// are not tracked.
+func (cw *ContextWindow) trackFile(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return
+	}
+	if cw.seen == nil {
+		cw.seen = make(map[string]fileState)
+	}
+	cw.seen[abs] = fileState{modTime: info.ModTime(), size: info.Size()}
+}
+
+// trackRead tracks the file read by a read_file call with args.
+func (cw *ContextWindow) trackRead(name string, args json.RawMessage) {
+	if current, _ := tools.Resolve(name); current != tools.ToolNameReadFile {
+		return
+	}
+	var input tools.ReadFileInput
+	if json.Unmarshal(args, &input) == nil && input.Path != "" {
+		cw.trackFile(input.Path)
+	}
+}
+
+// staleNote tells the model which of the files it read or edited changed on
+// disk since, "" if none did. Each change is reported once.
+func (cw *ContextWindow) staleNote() string {
+	var stale []string
+	for path, seen := range cw.seen {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			stale = append(stale, path+" (deleted)")
+			delete(cw.seen, path)
+		case !info.ModTime().Equal(seen.modTime) || info.Size() != seen.size:
+			stale = append(stale, path)
+			cw.seen[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	if len(stale) == 0 {
+		return ""
+	}
+	slices.Sort(stale)
+	return fmt.Sprintf("These files changed on disk since you last read or edited them. What you know of them is stale, read them again before relying on or editing them:\n- %s",
+		strings.Join(stale, "\n- "))
+}
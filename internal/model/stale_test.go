@@ -0,0 +1,104 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (m *inputsModel) staleNotes() []string {
+	var notes []string
+	for _, r := range m.inputs {
+		if r.Source == storage.SystemNote && strings.HasPrefix(r.Content, "These files changed on disk") {
+			notes = append(notes, r.Content)
+		}
+	}
+	return notes
+}
+
+func TestCallModelReportsFilesChangedSinceRead(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	t.Chdir(dir)
+	read := filepath.Join(dir, "read.go")
+	edited := filepath.Join(dir, "edited.go")
+	require.NoError(t, os.WriteFile(read, []byte("package a\n"), 0o644))
+	require.NoError(t, os.WriteFile(edited, []byte("package b\n"), 0o644))
+
+	db, err := storage.NewSession(":memory:", "")
+	require.NoError(t, err)
+	m := &inputsModel{}
+	cw, err := NewContextWindow(t.Context(), db, m, "stale")
+	require.NoError(t, err)
+	defer cw.Close()
+	require.NoError(t, cw.RegisterTool(t.Context(), tools.ReadFileDefinition))
+	require.NoError(t, cw.RegisterTool(t.Context(), tools.EditFileDefinition))
+
+	args, _ := json.Marshal(tools.ReadFileInput{Path: read})
+	_, err = cw.ExecuteTool(t.Context(), tools.ToolNameReadFile, args)
+	require.NoError(t, err)
+	args, _ = json.Marshal(tools.EditFileInput{Path: edited, OldStr: "package b", NewStr: "package c"})
+	_, err = cw.ExecuteTool(t.Context(), tools.ToolNameEditFile, args)
+	require.NoError(t, err)
+
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, m.staleNotes(), "nothing changed behind the model's back")
+
+	// Changed on disk by someone else
+	require.NoError(t, os.WriteFile(read, []byte("package a // changed\n"), 0o644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(read, later, later))
+	require.NoError(t, os.Remove(edited))
+
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+	notes := m.staleNotes()
+	require.Len(t, notes, 1)
+	assert.Contains(t, notes[0], "- "+read)
+	assert.Contains(t, notes[0], "- "+edited+" (deleted)")
+
+	// Each change is reported once
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, m.staleNotes(), 1)
+}
+
+func TestCallModelTrustsConflictsToShowTheCurrentContent(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	t.Chdir(dir)
+	path := filepath.Join(dir, "a.go")
+	require.NoError(t, os.WriteFile(path, []byte("package a\n"), 0o644))
+
+	db, err := storage.NewSession(":memory:", "")
+	require.NoError(t, err)
+	m := &inputsModel{}
+	cw, err := NewContextWindow(t.Context(), db, m, "stale")
+	require.NoError(t, err)
+	defer cw.Close()
+	require.NoError(t, cw.RegisterTool(t.Context(), tools.ReadFileDefinition))
+	require.NoError(t, cw.RegisterTool(t.Context(), tools.EditFileDefinition))
+
+	args, _ := json.Marshal(tools.ReadFileInput{Path: path})
+	_, err = cw.ExecuteTool(t.Context(), tools.ToolNameReadFile, args)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("package b\n"), 0o644))
+	args, _ = json.Marshal(tools.EditFileInput{Path: path, OldStr: "package a", NewStr: "package c"})
+	_, err = cw.ExecuteTool(t.Context(), tools.ToolNameEditFile, args)
+	var conflict *tools.EditConflict
+	require.ErrorAs(t, err, &conflict)
+
+	_, err = cw.CallModel(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, m.staleNotes())
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verbosity is how much the model explains in its answers
+type Verbosity string
+
+const (
+	VerbosityConcise     Verbosity = "concise"
+	VerbosityStandard    Verbosity = "standard"
+	VerbosityExplanatory Verbosity = "explanatory"
+)
+
+var verbosityGuidance = map[Verbosity]string{
+	VerbosityConcise:     "Be terse. Give the answer or the change with at most a sentence of context, no summaries of what you did.",
+	VerbosityStandard:    "",
+	VerbosityExplanatory: "Explain your reasoning and the trade-offs you considered, and walk through non-obvious changes so the user can learn from them.",
+}
+
+// ParseVerbosity validates a verbosity name, "" meaning standard.
+func ParseVerbosity(s string) (Verbosity, error) {
+	if s == "" {
+		return VerbosityStandard, nil
+	}
+	v := Verbosity(strings.ToLower(s))
+	if _, ok := verbosityGuidance[v]; !ok {
+		return "", fmt.Errorf("unknown verbosity %q (expected concise, standard or explanatory)", s)
+	}
+	return v, nil
+}
+
+// ResponseStyle is the user's preferred language, verbosity and standing
+// instructions, appended to the system prompt. The zero value keeps the defaults.
+type ResponseStyle struct {
+	// Natural language of the answers, e.g. "Vietnamese". Code, identifiers
+	// and commit messages stay as the project has them.
+	Language  string
+	Verbosity Verbosity
+	// Free-form instructions, e.g. from a profile, added as they are
+	Instructions string
+}
+
+// prompt renders the style as a system prompt section, "" for the defaults.
+func (s ResponseStyle) prompt() string {
+	var lines []string
+	if s.Language != "" {
+		lines = append(lines, fmt.Sprintf("Always answer in %s, whatever language the user writes in. Keep code, identifiers and quoted output unchanged.", s.Language))
+	}
+	if guidance := verbosityGuidance[s.Verbosity]; guidance != "" {
+		lines = append(lines, guidance)
+	}
+	var sections []string
+	if len(lines) > 0 {
+		sections = append(sections, "# Response style\n\n"+strings.Join(lines, "\n"))
+	}
+	if instructions := strings.TrimSpace(s.Instructions); instructions != "" {
+		sections = append(sections, "# Instructions\n\n"+instructions)
+	}
+	return strings.Join(sections, "\n\n")
+}
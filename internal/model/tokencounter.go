@@ -0,0 +1,148 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+const (
+	ProviderAnthropic ProviderName = "anthropic"
+	ProviderGemini    ProviderName = "gemini"
+	// Answers with scripted fixtures, see NewMock
+	ProviderMock ProviderName = "mock"
+)
+
+// calibration scales local cl100k_base estimates to each provider's tokenizer.
+// Claude tokenizes code noticeably denser than cl100k, Gemini roughly the same.
+var calibration = map[ProviderName]float64{
+	ProviderAnthropic: 1.15,
+	ProviderGemini:    1.0,
+}
+
+// ProviderOf infers the provider serving a model version from its name.
+func ProviderOf(version ModelVersion) ProviderName {
+	switch {
+	case strings.HasPrefix(string(version), "claude"):
+		return ProviderAnthropic
+	case strings.HasPrefix(string(version), "gemini"):
+		return ProviderGemini
+	default:
+		return ""
+	}
+}
+
+// TokenCounter counts how many input tokens records occupy for a model
+type TokenCounter interface {
+	CountTokens(ctx context.Context, inputs []storage.Record) (int, error)
+}
+
+// LocalTokenCounter estimates token counts offline with the built-in tokenizer.
+// It never fails, so it doubles as the fallback when provider APIs do.
+type LocalTokenCounter struct {
+	// Multiplier applied to raw estimates, see NewLocalTokenCounter
+	Calibration float64
+	// Optional source of tool definitions, which are sent with every call
+	Tools tools.ToolExecutor
+}
+
+// NewLocalTokenCounter creates a local estimator calibrated for provider.
+// Unknown providers get an uncalibrated estimator.
+func NewLocalTokenCounter(provider ProviderName, executor tools.ToolExecutor) *LocalTokenCounter {
+	factor, ok := calibration[provider]
+	if !ok {
+		factor = 1.0
+	}
+	return &LocalTokenCounter{Calibration: factor, Tools: executor}
+}
+
+func (l *LocalTokenCounter) CountTokens(ctx context.Context, inputs []storage.Record) (int, error) {
+	n := 0
+	for _, rec := range inputs {
+		n += storage.TokenCount(rec.Content)
+	}
+
+	if l.Tools != nil {
+		for _, def := range l.Tools.GetRegisteredTools() {
+			n += toolTokens(def)
+		}
+	}
+
+	factor := l.Calibration
+	if factor <= 0 {
+		factor = 1.0
+	}
+	return int(math.Ceil(float64(n) * factor)), nil
+}
+
+// toolTokens estimates what a tool definition costs in the request.
+func toolTokens(def tools.ToolDefinition) int {
+	n := storage.TokenCount(def.Name) + storage.TokenCount(def.Description)
+	if def.InputSchema != nil {
+		if schema, err := json.Marshal(def.InputSchema); err == nil {
+			n += storage.TokenCount(string(schema))
+		}
+	}
+	return n
+}
+
+// FallbackTokenCounter tries Primary first and falls back on failure
+type FallbackTokenCounter struct {
+	Primary  TokenCounter
+	Fallback TokenCounter
+}
+
+func (f *FallbackTokenCounter) CountTokens(ctx context.Context, inputs []storage.Record) (int, error) {
+	n, err := f.Primary.CountTokens(ctx, inputs)
+	if err == nil {
+		return n, nil
+	}
+	return f.Fallback.CountTokens(ctx, inputs)
+}
+
+// StoredTokenCounter sizes records from the tokens the provider counted for
+// the latest request among them, see storage.Record, so a resumed
+// conversation is sized without asking the provider. Only what was added
+// since that request is estimated. It fails when no record has counts, such
+// as in a new conversation or one recorded before counts were stored.
+type StoredTokenCounter struct {
+	// Estimates the records added since, without tool definitions
+	// which the request held already
+	Estimate TokenCounter
+}
+
+var errNoStoredTokens = errors.New("no stored token counts")
+
+func (s *StoredTokenCounter) CountTokens(ctx context.Context, inputs []storage.Record) (int, error) {
+	last := -1
+	for i, rec := range inputs {
+		if rec.InputTokens > 0 {
+			last = i
+		}
+	}
+	if last < 0 {
+		return 0, errNoStoredTokens
+	}
+
+	// What the model answered to the request is its completion, and notes
+	// that are never stored, such as pinned files, were sent with it
+	var added []storage.Record
+	for _, rec := range inputs[last+1:] {
+		switch {
+		case rec.ID == 0:
+		case rec.Source == storage.ModelResp, rec.Source == storage.ToolUse, rec.Source == storage.Thinking:
+		default:
+			added = append(added, rec)
+		}
+	}
+	n, err := s.Estimate.CountTokens(ctx, added)
+	if err != nil {
+		return 0, err
+	}
+	return inputs[last].InputTokens + inputs[last].OutputTokens + n, nil
+}
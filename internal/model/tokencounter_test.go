@@ -0,0 +1,109 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/honganh1206/tinker/internal/storage"
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingCounter struct{}
+
+func (failingCounter) CountTokens(ctx context.Context, inputs []storage.Record) (int, error) {
+	return 0, errors.New("count tokens api down")
+}
+
+type fixedCounter int
+
+func (f fixedCounter) CountTokens(ctx context.Context, inputs []storage.Record) (int, error) {
+	return int(f), nil
+}
+
+func TestLocalTokenCounter_Calibration(t *testing.T) {
+	inputs := []storage.Record{{Content: "the quick brown fox jumps over the lazy dog"}}
+	raw := storage.TokenCount(inputs[0].Content)
+
+	got, err := (&LocalTokenCounter{Calibration: 2}).CountTokens(context.Background(), inputs)
+	require.NoError(t, err)
+	assert.Equal(t, raw*2, got)
+
+	got, err = (&LocalTokenCounter{}).CountTokens(context.Background(), inputs)
+	require.NoError(t, err)
+	assert.Equal(t, raw, got, "zero calibration should not scale")
+}
+
+func TestLocalTokenCounter_CountsTools(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	require.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &MockModel{}, "tools")
+	require.NoError(t, err)
+	defer cw.Close()
+
+	without, err := NewLocalTokenCounter("", nil).CountTokens(context.Background(), nil)
+	require.NoError(t, err)
+
+	cw.LoadTool(tools.BashDefinition)
+	with, err := NewLocalTokenCounter("", cw).CountTokens(context.Background(), nil)
+	require.NoError(t, err)
+
+	assert.Zero(t, without)
+	assert.Greater(t, with, 0)
+}
+
+func TestFallbackTokenCounter(t *testing.T) {
+	f := &FallbackTokenCounter{Primary: failingCounter{}, Fallback: fixedCounter(7)}
+	n, err := f.CountTokens(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+
+	f = &FallbackTokenCounter{Primary: fixedCounter(3), Fallback: fixedCounter(7)}
+	n, err = f.CountTokens(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestStoredTokenCounter(t *testing.T) {
+	stored := &StoredTokenCounter{Estimate: &LocalTokenCounter{}}
+
+	_, err := stored.CountTokens(context.Background(), []storage.Record{{ID: 1, Source: storage.Prompt, Content: "hi"}})
+	assert.Error(t, err, "nothing was counted by the provider yet")
+
+	prompt := "and now the tests"
+	inputs := []storage.Record{
+		{ID: 1, Source: storage.Prompt, Content: "fix the bug"},
+		{ID: 2, Source: storage.ToolUse, Content: "read_file", InputTokens: 1000, OutputTokens: 20},
+		{ID: 3, Source: storage.ModelResp, Content: "fixed it"},
+		{ID: 4, Source: storage.Prompt, Content: prompt},
+		// Pinned files, sent with the request too
+		{Source: storage.SystemNote, Content: "# Pinned files"},
+	}
+	n, err := stored.CountTokens(context.Background(), inputs)
+	require.NoError(t, err)
+	assert.Equal(t, 1020+storage.TokenCount(prompt), n)
+}
+
+func TestProviderOf(t *testing.T) {
+	assert.Equal(t, ProviderAnthropic, ProviderOf(Claude46Sonnet))
+	assert.Equal(t, ProviderGemini, ProviderOf(Gemini25Flash))
+	assert.Equal(t, ProviderName(""), ProviderOf("unknown"))
+}
+
+func TestContextWindowTokenUsage(t *testing.T) {
+	db, err := storage.NewSession(":memory:", "")
+	require.NoError(t, err)
+	cw, err := NewContextWindow(t.Context(), db, &MockModel{}, "usage")
+	require.NoError(t, err)
+	defer cw.Close()
+
+	cw.SetTokenCounter(fixedCounter(1024))
+
+	usage, err := cw.TokenUsage()
+	require.NoError(t, err)
+	assert.Equal(t, 1024, usage.Live)
+	assert.Equal(t, 4096, usage.Max)
+	assert.InDelta(t, 0.25, usage.Percent, 1e-9)
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/tools"
+)
+
+// geminiKeywords are the JSON schema keywords Gemini honors in function
+// parameters. It rejects or ignores the others, so they are dropped.
+var geminiKeywords = []string{
+	"$schema", "$id", "$defs", "$ref", "$anchor",
+	"type", "format", "title", "description", "enum",
+	"items", "prefixItems", "minItems", "maxItems", "minimum", "maximum",
+	"anyOf", "oneOf", "properties", "additionalProperties", "required", "propertyOrdering",
+}
+
+// schemaIssues lists the constructs of def's input schema that provider
+// does not support and drops, by their path in the schema. It fails on a
+// schema no provider can take.
+func schemaIssues(provider ProviderName, def tools.ToolDefinition) ([]string, error) {
+	schema, err := toolSchema(def)
+	if err != nil {
+		return nil, err
+	}
+	if provider != ProviderGemini {
+		return nil, nil
+	}
+	return dropUnsupported(schema, "$"), nil
+}
+
+// toolSchema decodes the input schema of def, which must describe an object
+// as the tool input is one.
+func toolSchema(def tools.ToolDefinition) (map[string]any, error) {
+	schema := map[string]any{"type": "object"}
+	if def.InputSchema == nil {
+		return schema, nil
+	}
+	data, err := json.Marshal(def.InputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: marshal input schema: %w", def.Name, err)
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("tool %s: decode input schema: %w", def.Name, err)
+	}
+	if t, ok := schema["type"]; ok && t != "object" {
+		return nil, fmt.Errorf("tool %s: input schema has type %v, want object", def.Name, t)
+	}
+	return schema, nil
+}
+
+// geminiSchema returns def's input schema with the keywords Gemini does not
+// support dropped.
+func geminiSchema(def tools.ToolDefinition) (map[string]any, error) {
+	schema, err := toolSchema(def)
+	if err != nil {
+		return nil, err
+	}
+	dropUnsupported(schema, "$")
+	return schema, nil
+}
+
+// dropUnsupported removes the keywords Gemini does not support from schema
+// and its subschemas, and returns their paths.
+func dropUnsupported(schema map[string]any, path string) []string {
+	var dropped []string
+	for key := range schema {
+		if !slices.Contains(geminiKeywords, key) {
+			dropped = append(dropped, path+"."+key)
+			delete(schema, key)
+		}
+	}
+
+	sub := func(v any, path string) {
+		if s, ok := v.(map[string]any); ok {
+			dropped = append(dropped, dropUnsupported(s, path)...)
+		}
+	}
+	for _, key := range []string{"properties", "$defs"} {
+		if m, ok := schema[key].(map[string]any); ok {
+			for name, v := range m {
+				sub(v, path+"."+key+"."+name)
+			}
+		}
+	}
+	for _, key := range []string{"items", "additionalProperties"} {
+		sub(schema[key], path+"."+key)
+	}
+	for _, key := range []string{"prefixItems", "anyOf", "oneOf"} {
+		if list, ok := schema[key].([]any); ok {
+			for i, v := range list {
+				sub(v, fmt.Sprintf("%s.%s[%d]", path, key, i))
+			}
+		}
+	}
+
+	slices.Sort(dropped)
+	return dropped
+}
+
+// CheckToolSchemas fails on the first tool of toolset whose input schema
+// provider cannot take, or with strict, drops any construct of. Otherwise
+// it returns the dropped constructs as warnings.
+func CheckToolSchemas(provider ProviderName, toolset []tools.ToolDefinition, strict bool) ([]string, error) {
+	var warnings []string
+	for _, def := range toolset {
+		issues, err := schemaIssues(provider, def)
+		if err != nil {
+			return nil, err
+		}
+		if len(issues) == 0 {
+			continue
+		}
+		if strict {
+			return nil, fmt.Errorf("tool %s: %s does not support %s", def.Name, provider, strings.Join(issues, ", "))
+		}
+		for _, issue := range issues {
+			warnings = append(warnings, fmt.Sprintf("tool %s: %s drops %s", def.Name, provider, issue))
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/honganh1206/tinker/internal/tools"
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func schemaTool(t *testing.T, raw string) tools.ToolDefinition {
+	t.Helper()
+	var schema jsonschema.Schema
+	require.NoError(t, json.Unmarshal([]byte(raw), &schema))
+	return tools.ToolDefinition{Name: "query", InputSchema: &schema}
+}
+
+const richSchema = `{
+	"type": "object",
+	"properties": {
+		"filter": {"type": "object", "patternProperties": {"^x-": {"type": "string"}}},
+		"tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}},
+		"limit": {"type": "integer", "minimum": 1, "maximum": 100}
+	},
+	"required": ["filter"]
+}`
+
+func TestCheckToolSchemas_BuiltinsConvertCleanly(t *testing.T) {
+	for _, provider := range []ProviderName{ProviderAnthropic, ProviderGemini} {
+		warnings, err := CheckToolSchemas(provider, tools.Builtin, true)
+		require.NoError(t, err, provider)
+		assert.Empty(t, warnings, provider)
+	}
+	_, err := getClaudeToolParams(tools.Builtin)
+	require.NoError(t, err)
+	_, err = getGeminiTools(tools.Builtin)
+	require.NoError(t, err)
+}
+
+func TestCheckToolSchemas_ReportsDroppedConstructs(t *testing.T) {
+	def := schemaTool(t, richSchema)
+
+	warnings, err := CheckToolSchemas(ProviderGemini, []tools.ToolDefinition{def}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"tool query: gemini drops $.properties.filter.patternProperties",
+		"tool query: gemini drops $.properties.tags.items.pattern",
+	}, warnings)
+
+	warnings, err = CheckToolSchemas(ProviderAnthropic, []tools.ToolDefinition{def}, true)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestCheckToolSchemas_StrictFails(t *testing.T) {
+	_, err := CheckToolSchemas(ProviderGemini, []tools.ToolDefinition{schemaTool(t, richSchema)}, true)
+
+	assert.ErrorContains(t, err, "gemini does not support $.properties.filter.patternProperties")
+}
+
+func TestToolSchema_RejectsNonObject(t *testing.T) {
+	def := schemaTool(t, `{"type": "string"}`)
+
+	_, err := CheckToolSchemas(ProviderAnthropic, []tools.ToolDefinition{def}, false)
+	assert.ErrorContains(t, err, "want object")
+	_, err = getClaudeToolParams([]tools.ToolDefinition{def})
+	assert.Error(t, err)
+	_, err = getGeminiTools([]tools.ToolDefinition{def})
+	assert.Error(t, err)
+}
+
+func TestGeminiSchema_DropsUnsupported(t *testing.T) {
+	schema, err := geminiSchema(schemaTool(t, richSchema))
+	require.NoError(t, err)
+
+	props := schema["properties"].(map[string]any)
+	assert.NotContains(t, props["filter"], "patternProperties")
+	assert.NotContains(t, props["tags"].(map[string]any)["items"], "pattern")
+	assert.Equal(t, map[string]any{"type": "integer", "minimum": 1.0, "maximum": 100.0}, props["limit"])
+}
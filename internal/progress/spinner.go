@@ -0,0 +1,108 @@
+// Package progress shows what the agent is doing while a turn runs.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/model"
+)
+
+// PhaseThinking is shown while waiting for the model
+const PhaseThinking = "Thinking"
+
+// Interrupting kills the process, the session keeps everything up to then
+const interruptHint = "Ctrl+C to interrupt"
+
+var frames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Spinner redraws a single status line on a terminal
+type Spinner struct {
+	w        io.Writer
+	interval time.Duration
+
+	// Draw ASCII frames and symbols
+	ascii bool
+
+	mu    sync.Mutex
+	phase string
+}
+
+func NewSpinner(w io.Writer) *Spinner {
+	return &Spinner{w: w, interval: 100 * time.Millisecond, phase: PhaseThinking}
+}
+
+// UseASCII draws the spinner with ASCII characters only. Call it before Start.
+func (s *Spinner) UseASCII() {
+	s.ascii = true
+}
+
+// Start draws the spinner until ctx is done or stop is called. stop waits for
+// the line to be cleared, so nothing is drawn once it returns, and can be
+// called more than once.
+func (s *Spinner) Start(ctx context.Context) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	start := time.Now()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		frames := frames
+		if s.ascii {
+			frames = asciiFrames
+		}
+		for frame := 0; ; frame++ {
+			s.draw(frames[frame%len(frames)], time.Since(start))
+			select {
+			case <-ctx.Done():
+				fmt.Fprint(s.w, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+}
+
+// SetPhase changes the text shown next to the spinner, e.g. "Running bash".
+func (s *Spinner) SetPhase(phase string) {
+	s.mu.Lock()
+	s.phase = phase
+	s.mu.Unlock()
+}
+
+// Observe follows the phases of a turn from context window events.
+func (s *Spinner) Observe(ev model.Event) {
+	switch ev.Kind {
+	case model.EventToolInput:
+		s.SetPhase("Preparing " + ev.ToolInput.Name)
+	case model.EventToolStart:
+		s.SetPhase("Running " + ev.Tool)
+	case model.EventToolEnd:
+		s.SetPhase(PhaseThinking)
+	}
+}
+
+func (s *Spinner) draw(frame string, elapsed time.Duration) {
+	s.mu.Lock()
+	phase := s.phase
+	s.mu.Unlock()
+	line := fmt.Sprintf("%s %s… (%s · %s)", frame, phase, elapsed.Truncate(time.Second), interruptHint)
+	if s.ascii {
+		line = ASCII(line)
+	}
+	fmt.Fprint(s.w, "\r\033[K"+line)
+}
@@ -0,0 +1,99 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/honganh1206/tinker/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is written by the spinner goroutine and read by the test
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newTestSpinner() (*Spinner, *syncBuffer) {
+	out := &syncBuffer{}
+	s := NewSpinner(out)
+	s.interval = time.Millisecond
+	return s, out
+}
+
+func TestSpinner_StopClearsLineAndStopsDrawing(t *testing.T) {
+	s, out := newTestSpinner()
+
+	stop := s.Start(context.Background())
+	s.Observe(model.Event{Kind: model.EventToolStart, Tool: "bash"})
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "Running bash… (0s · Ctrl+C to interrupt)")
+	}, time.Second, time.Millisecond)
+
+	stop()
+	stop()
+
+	written := out.String()
+	assert.True(t, strings.HasSuffix(written, "\r\033[K"))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, written, out.String())
+}
+
+func TestSpinner_StopsWithContext(t *testing.T) {
+	s, out := newTestSpinner()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stop := s.Start(ctx)
+	cancel()
+
+	require.Eventually(t, func() bool {
+		return strings.HasSuffix(out.String(), "\r\033[K")
+	}, time.Second, time.Millisecond)
+	stop()
+}
+
+func TestSpinner_Observe(t *testing.T) {
+	s := NewSpinner(&bytes.Buffer{})
+	assert.Equal(t, PhaseThinking, s.phase)
+
+	s.Observe(model.Event{Kind: model.EventToolInput, ToolInput: model.ToolInputDelta{Name: "edit_file"}})
+	assert.Equal(t, "Preparing edit_file", s.phase)
+
+	s.Observe(model.Event{Kind: model.EventToolStart, Tool: "edit_file"})
+	assert.Equal(t, "Running edit_file", s.phase)
+
+	s.Observe(model.Event{Kind: model.EventToolEnd, Tool: "edit_file"})
+	assert.Equal(t, PhaseThinking, s.phase)
+}
+
+func TestSpinner_UseASCII(t *testing.T) {
+	s, out := newTestSpinner()
+	s.UseASCII()
+
+	stop := s.Start(context.Background())
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "| Thinking... (0s - Ctrl+C to interrupt)")
+	}, time.Second, time.Millisecond)
+	stop()
+
+	for _, r := range out.String() {
+		assert.Less(t, r, rune(0x80))
+	}
+}
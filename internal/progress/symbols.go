@@ -0,0 +1,26 @@
+package progress
+
+import "strings"
+
+// Spinner frames drawn with "ascii": true in config.json
+var asciiFrames = []string{"|", "/", "-", "\\"}
+
+var asciiSymbols = strings.NewReplacer(
+	"…", "...",
+	"·", "-",
+	"−", "-",
+	"✓", "ok",
+	"✗", "x",
+	"○", "o",
+	"●", "*",
+	"▲", "!",
+	"─", "-",
+	"│", "|",
+)
+
+// ASCII replaces the unicode symbols tinker prints, such as the ellipsis of
+// truncated text and the minus of edit summaries, with ASCII equivalents for
+// terminals and fonts that render them poorly. Other text is left alone.
+func ASCII(s string) string {
+	return asciiSymbols.Replace(s)
+}
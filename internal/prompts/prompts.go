@@ -0,0 +1,177 @@
+// Package prompts stores reusable prompt templates. Templates hold
+// {{variable}} placeholders filled in when they are used, e.g.
+// "Write table-driven tests for {{file}}".
+package prompts
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrNotFound is returned for a template name that is not stored
+var ErrNotFound = errors.New("template not found")
+
+type Template struct {
+	Name      string    `json:"name"`
+	Text      string    `json:"text"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store keeps templates in a SQLite database
+type Store struct {
+	path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath is ~/.tinker/templates.db.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinker", "templates.db"), nil
+}
+
+// Save creates the template or replaces the text of an existing one.
+func (s *Store) Save(name, text string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid template name %q", name)
+	}
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`INSERT INTO templates (name, text, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET text = excluded.text, updated_at = excluded.updated_at`,
+		name, text, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("save template: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Get(name string) (Template, error) {
+	db, err := s.open()
+	if err != nil {
+		return Template{}, err
+	}
+	defer db.Close()
+
+	var t Template
+	err = db.QueryRow(`SELECT name, text, updated_at FROM templates WHERE name = ?`, name).
+		Scan(&t.Name, &t.Text, &t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Template{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return Template{}, fmt.Errorf("get template: %w", err)
+	}
+	return t, nil
+}
+
+// List returns all templates by name.
+func (s *Store) List() ([]Template, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT name, text, updated_at FROM templates ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []Template
+	for rows.Next() {
+		var t Template
+		if err := rows.Scan(&t.Name, &t.Text, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("list templates: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+func (s *Store) Delete(name string) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	res, err := db.Exec(`DELETE FROM templates WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("delete template: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return nil
+}
+
+func (s *Store) open() (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, fmt.Errorf("create templates dir: %w", err)
+	}
+	db, err := sql.Open("sqlite3", s.path)
+	if err != nil {
+		return nil, fmt.Errorf("open templates db: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS templates (
+		name       TEXT PRIMARY KEY,
+		text       TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init templates schema: %w", err)
+	}
+	return db, nil
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// Variables lists the placeholders of text in order of appearance.
+func Variables(text string) []string {
+	var vars []string
+	for _, m := range placeholder.FindAllStringSubmatch(text, -1) {
+		if !slices.Contains(vars, m[1]) {
+			vars = append(vars, m[1])
+		}
+	}
+	return vars
+}
+
+// Render fills in the placeholders of text. Every placeholder needs a value,
+// a prompt with a literal "{{file}}" left in it is never what was meant.
+func Render(text string, vars map[string]string) (string, error) {
+	var missing []string
+	for _, v := range Variables(text) {
+		if _, ok := vars[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+
+	return placeholder.ReplaceAllStringFunc(text, func(m string) string {
+		return vars[placeholder.FindStringSubmatch(m)[1]]
+	}), nil
+}
@@ -0,0 +1,45 @@
+package prompts
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "templates.db"))
+
+	require.NoError(t, s.Save("tests", "Write table-driven tests for {{file}}"))
+	require.NoError(t, s.Save("fix", "Fix this error: {{error}}"))
+	require.NoError(t, s.Save("tests", "Write table-driven tests for {{ file }}"))
+
+	got, err := s.Get("tests")
+	require.NoError(t, err)
+	assert.Equal(t, "Write table-driven tests for {{ file }}", got.Text)
+
+	list, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "fix", list[0].Name)
+
+	require.NoError(t, s.Delete("fix"))
+	_, err = s.Get("fix")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Delete("fix"), ErrNotFound)
+
+	assert.Error(t, s.Save("two words", "x"))
+}
+
+func TestRender(t *testing.T) {
+	text := "Fix {{error}} in {{file}}, see {{ file }}"
+	assert.Equal(t, []string{"error", "file"}, Variables(text))
+
+	got, err := Render(text, map[string]string{"file": "main.go", "error": "nil map"})
+	require.NoError(t, err)
+	assert.Equal(t, "Fix nil map in main.go, see main.go", got)
+
+	_, err = Render(text, map[string]string{"file": "main.go"})
+	assert.EqualError(t, err, "missing template variables: error")
+}
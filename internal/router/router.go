@@ -58,11 +58,12 @@ func (r *Router) handleCompleted(ctx context.Context, event *eventbus.Event) {
 		"text", truncateForLog(completed.FinalMessage, 80))
 
 	outbound := channel.OutboundMessage{
-		Channel: completed.Channel,
-		ChatID:  completed.ChatID,
+		Channel:  completed.Channel,
+		ChatID:   completed.ChatID,
 		ThreadID: completed.ThreadID,
-		Text:    completed.FinalMessage,
-		ReplyTo: completed.ReplyTo,
+		Text:     completed.FinalMessage,
+		ReplyTo:  completed.ReplyTo,
+		Footer:   completed.Stats,
 	}
 
 	outEvent, err := eventbus.NewEvent(eventbus.TopicChannelMessageSend, event.Metadata, outbound)
@@ -0,0 +1,253 @@
+// Package secret provides the key sessions are encrypted with, derived from
+// a passphrase or kept in the OS keychain, and the user's choice of either.
+package secret
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/honganh1206/tinker/internal/storage"
+)
+
+// Where the key comes from
+const (
+	ModePassphrase = "passphrase"
+	ModeKeychain   = "keychain"
+)
+
+// PassphraseEnv holds the passphrase for runs without a terminal to ask on
+const PassphraseEnv = "TINKER_PASSPHRASE"
+
+// keySize is the AES-256 key length
+const keySize = 32
+
+// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+const pbkdf2Iterations = 600_000
+
+// Keychain entry the generated key is stored under
+const (
+	keychainService = "tinker"
+	keychainAccount = "session-key"
+)
+
+// ErrWrongPassphrase is returned when the key does not match the one
+// sessions were encrypted with
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// Config is the user's encryption choice. Mode and salt are kept after
+// encryption is turned off, so sessions encrypted earlier stay readable.
+type Config struct {
+	// Encrypt new payloads
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode,omitempty"`
+	// Salt the passphrase is derived with
+	Salt []byte `json:"salt,omitempty"`
+	// HMAC of a fixed message with the key, to tell a wrong passphrase
+	// from a corrupted record
+	Check string `json:"check,omitempty"`
+}
+
+// DefaultPath is ~/.tinker/encryption.json.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinker", "encryption.json"), nil
+}
+
+// Load returns the saved choice, off when none was made.
+func Load(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("read encryption config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse encryption config: %w", err)
+	}
+	return cfg, nil
+}
+
+func Save(path string, cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal encryption config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// New sets up a key for mode and returns the config encrypting with it. A
+// keychain key is generated and stored, a passphrase one is derived from
+// the passphrase ask returns.
+func New(mode string, ask func() (string, error)) (Config, error) {
+	cfg := Config{Enabled: true, Mode: mode}
+
+	var key []byte
+	switch mode {
+	case ModeKeychain:
+		key = make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return cfg, fmt.Errorf("generate key: %w", err)
+		}
+		if err := keychainStore(hex.EncodeToString(key)); err != nil {
+			return cfg, err
+		}
+	case ModePassphrase:
+		cfg.Salt = make([]byte, 16)
+		if _, err := rand.Read(cfg.Salt); err != nil {
+			return cfg, fmt.Errorf("generate salt: %w", err)
+		}
+		passphrase, err := ask()
+		if err != nil {
+			return cfg, err
+		}
+		if passphrase == "" {
+			return cfg, errors.New("passphrase cannot be empty")
+		}
+		if key, err = derive(passphrase, cfg.Salt); err != nil {
+			return cfg, err
+		}
+	default:
+		return cfg, fmt.Errorf("unknown encryption mode %q (expected %s or %s)", mode, ModePassphrase, ModeKeychain)
+	}
+
+	cfg.Check = check(key)
+	return cfg, nil
+}
+
+// Key returns the key of cfg. The passphrase is read from TINKER_PASSPHRASE,
+// else from ask when it is not nil.
+func (cfg Config) Key(ask func() (string, error)) ([]byte, error) {
+	var key []byte
+	switch cfg.Mode {
+	case ModeKeychain:
+		h, err := keychainLookup()
+		if err != nil {
+			return nil, err
+		}
+		if key, err = hex.DecodeString(h); err != nil || len(key) != keySize {
+			return nil, errors.New("keychain entry is not a tinker key")
+		}
+	case ModePassphrase:
+		passphrase := os.Getenv(PassphraseEnv)
+		if passphrase == "" && ask != nil {
+			var err error
+			if passphrase, err = ask(); err != nil {
+				return nil, err
+			}
+		}
+		if passphrase == "" {
+			return nil, fmt.Errorf("sessions are encrypted, set %s to the passphrase", PassphraseEnv)
+		}
+		var err error
+		if key, err = derive(passphrase, cfg.Salt); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, storage.ErrNoKey
+	}
+
+	if !hmac.Equal([]byte(check(key)), []byte(cfg.Check)) {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+// Encryption returns the storage encryption of cfg, asking for the
+// passphrase with ask the first time a payload needs the key.
+func (cfg Config) Encryption(ask func() (string, error)) storage.Encryption {
+	e := storage.Encryption{Enabled: cfg.Enabled}
+	if cfg.Mode != "" {
+		e.Key = func() ([]byte, error) { return cfg.Key(ask) }
+	}
+	return e
+}
+
+// Configure applies the saved choice to every session this process opens.
+// Processes without a terminal pass a nil ask and read the passphrase from
+// TINKER_PASSPHRASE.
+func Configure(ask func() (string, error)) error {
+	path, err := DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		return err
+	}
+	storage.SetEncryption(cfg.Encryption(ask))
+	return nil
+}
+
+func derive(passphrase string, salt []byte) ([]byte, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	return key, nil
+}
+
+func check(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("tinker session key"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func keychainLookup() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+	default:
+		return "", fmt.Errorf("no supported keychain on %s, use a passphrase", runtime.GOOS)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("read key from keychain: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keychainStore(key string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", keychainAccount, "-w", key)
+	case "linux":
+		// secret-tool reads the secret from stdin, keeping it out of the process list
+		cmd = exec.Command("secret-tool", "store", "--label", "tinker session key", "service", keychainService, "account", keychainAccount)
+		cmd.Stdin = strings.NewReader(key)
+	default:
+		return fmt.Errorf("no supported keychain on %s, use a passphrase", runtime.GOOS)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("store key in keychain: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
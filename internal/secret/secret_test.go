@@ -0,0 +1,55 @@
+package secret
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassphraseKey(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	ask := func(p string) func() (string, error) {
+		return func() (string, error) { return p, nil }
+	}
+
+	cfg, err := New(ModePassphrase, ask("correct horse"))
+	require.NoError(t, err)
+	assert.True(t, cfg.Enabled)
+	assert.Len(t, cfg.Salt, 16)
+
+	path := filepath.Join(t.TempDir(), "encryption.json")
+	require.NoError(t, Save(path, cfg))
+	cfg, err = Load(path)
+	require.NoError(t, err)
+
+	key, err := cfg.Key(ask("correct horse"))
+	require.NoError(t, err)
+	assert.Len(t, key, keySize)
+
+	_, err = cfg.Key(ask("battery staple"))
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+
+	// The environment wins over asking
+	t.Setenv(PassphraseEnv, "correct horse")
+	again, err := cfg.Key(nil)
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+}
+
+func TestPassphraseKey_NoTerminal(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	cfg, err := New(ModePassphrase, func() (string, error) { return "pw", nil })
+	require.NoError(t, err)
+
+	_, err = cfg.Key(nil)
+	assert.ErrorContains(t, err, PassphraseEnv)
+}
+
+func TestLoad_Missing(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "encryption.json"))
+	require.NoError(t, err)
+	assert.False(t, cfg.Enabled)
+	assert.Empty(t, cfg.Mode)
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// BlobsDir is the directory, next to the config file, blobs are kept in.
+// Files are named after the SHA-256 of their content, so storing the same
+// attachment twice keeps one copy.
+const BlobsDir = "blobs"
+
+// ErrBlobNotFound is returned for blobs that are not stored, or not stored
+// by the owner asking
+var ErrBlobNotFound = errors.New("blob not found")
+
+var blobHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Blob describes a stored file: an attachment of a message, a tool output
+// too large for the context or an exported artifact.
+type Blob struct {
+	// Hex SHA-256 of the content
+	Hash      string `json:"hash"`
+	Size      int64  `json:"size"`
+	MediaType string `json:"media_type"`
+	// File name the blob was stored under, if any
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Blobs is a content-addressed file store with an index of who stored what.
+type Blobs struct {
+	dir string
+	db  *sql.DB
+}
+
+// OpenBlobs opens the blob store in dir, creating it if needed.
+func OpenBlobs(dir string) (*Blobs, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create blobs dir: %w", err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "index.db"))
+	if err != nil {
+		return nil, fmt.Errorf("open blob index: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS blobs (
+			hash       TEXT NOT NULL,
+			owner      TEXT NOT NULL,
+			size       INTEGER NOT NULL,
+			media_type TEXT NOT NULL,
+			name       TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (hash, owner)
+		);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create blobs table: %w", err)
+	}
+	return &Blobs{dir: dir, db: db}, nil
+}
+
+func (b *Blobs) Close() error {
+	return b.db.Close()
+}
+
+// Put stores data for owner, empty for the server's own user, and returns
+// its blob. Storing content again only updates its media type and name.
+// Like record payloads, the content is encrypted when encryption is enabled.
+func (b *Blobs) Put(ctx context.Context, owner string, data []byte, mediaType, name string) (Blob, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if name != "" {
+		// Names are shown and offered for download, never used as paths
+		name = filepath.Base(name)
+	}
+	sum := sha256.Sum256(data)
+	blob := Blob{
+		Hash:      hex.EncodeToString(sum[:]),
+		Size:      int64(len(data)),
+		MediaType: mediaType,
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	path := b.path(blob.Hash)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := b.write(path, data); err != nil {
+			return Blob{}, err
+		}
+	} else if err != nil {
+		return Blob{}, fmt.Errorf("stat blob: %w", err)
+	}
+
+	_, err := b.db.ExecContext(ctx, `
+		INSERT INTO blobs (hash, owner, size, media_type, name, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (hash, owner) DO UPDATE SET media_type = excluded.media_type, name = excluded.name`,
+		blob.Hash, owner, blob.Size, blob.MediaType, blob.Name, blob.CreatedAt,
+	)
+	if err != nil {
+		return Blob{}, fmt.Errorf("index blob: %w", err)
+	}
+	return b.Stat(ctx, owner, blob.Hash)
+}
+
+// write stores a blob file atomically, so readers never see part of one.
+func (b *Blobs) write(path string, data []byte) error {
+	sealed, err := sealPayload(string(data))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create blob dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("write blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(sealed); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write blob: %w", err)
+	}
+	return nil
+}
+
+// Stat returns the blob with hash stored by owner.
+func (b *Blobs) Stat(ctx context.Context, owner, hash string) (Blob, error) {
+	if !blobHash.MatchString(hash) {
+		return Blob{}, fmt.Errorf("%w: %s", ErrBlobNotFound, hash)
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	blob := Blob{Hash: hash}
+	err := b.db.QueryRowContext(ctx,
+		`SELECT size, media_type, name, created_at FROM blobs WHERE hash = ? AND owner = ?`, hash, owner,
+	).Scan(&blob.Size, &blob.MediaType, &blob.Name, &blob.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Blob{}, fmt.Errorf("%w: %s", ErrBlobNotFound, hash)
+	}
+	if err != nil {
+		return Blob{}, fmt.Errorf("stat blob: %w", err)
+	}
+	return blob, nil
+}
+
+// Get returns the blob with hash stored by owner and its content.
+func (b *Blobs) Get(ctx context.Context, owner, hash string) (Blob, []byte, error) {
+	blob, err := b.Stat(ctx, owner, hash)
+	if err != nil {
+		return Blob{}, nil, err
+	}
+	data, err := os.ReadFile(b.path(hash))
+	if err != nil {
+		return Blob{}, nil, fmt.Errorf("read blob: %w", err)
+	}
+	content, err := openPayload(string(data))
+	if err != nil {
+		return Blob{}, nil, err
+	}
+	return blob, []byte(content), nil
+}
+
+// path spreads blobs over directories named after the first byte of their
+// hash, so no directory grows too large.
+func (b *Blobs) path(hash string) string {
+	return filepath.Join(b.dir, hash[:2], hash)
+}
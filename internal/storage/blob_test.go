@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobs_PutGet(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), BlobsDir)
+	blobs, err := OpenBlobs(dir)
+	require.NoError(t, err)
+	defer blobs.Close()
+
+	blob, err := blobs.Put(t.Context(), "alice", []byte("hello"), "text/plain", "../notes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", blob.Hash)
+	assert.Equal(t, int64(5), blob.Size)
+	assert.Equal(t, "notes.txt", blob.Name)
+
+	got, data, err := blobs.Get(t.Context(), "alice", blob.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, "text/plain", got.MediaType)
+
+	// The same content stored by another owner is kept once, and only
+	// readable by the owners who stored it
+	_, _, err = blobs.Get(t.Context(), "bob", blob.Hash)
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+	_, err = blobs.Put(t.Context(), "bob", []byte("hello"), "text/markdown", "")
+	require.NoError(t, err)
+	entries, err := os.ReadDir(filepath.Join(dir, blob.Hash[:2]))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, _, err = blobs.Get(t.Context(), "alice", "../index.db")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+}
+
+func TestBlobs_Encrypted(t *testing.T) {
+	setTestEncryption(t, true)
+	dir := t.TempDir()
+	blobs, err := OpenBlobs(dir)
+	require.NoError(t, err)
+	defer blobs.Close()
+
+	blob, err := blobs.Put(t.Context(), "", []byte("proprietary code"), "text/x-go", "main.go")
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(blobs.path(blob.Hash))
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "proprietary")
+
+	_, data, err := blobs.Get(t.Context(), "", blob.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, "proprietary code", string(data))
+}
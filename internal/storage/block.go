@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Block types persisted inside record content
+const (
+	BlockToolUse = "tool_use"
+)
+
+// Current version of each block payload. Bump it when a payload changes
+// shape and teach decodeBlock to migrate the previous version.
+var blockVersions = map[string]int{
+	BlockToolUse: 1,
+}
+
+// ErrUnknownBlock is returned for content written by a newer tinker.
+// Callers should fall back to showing the raw content.
+var ErrUnknownBlock = errors.New("unknown content block")
+
+// blockEnvelope is the stable JSON form of structured record content
+type blockEnvelope struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// ToolUseBlock is the content of a ToolUse record
+type ToolUseBlock struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+// EncodeToolUse renders a tool invocation as ToolUse record content. Input
+// that is not JSON, such as arguments cut off at the token limit, is kept
+// as a JSON string.
+func EncodeToolUse(name string, input json.RawMessage) string {
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	} else if !json.Valid(input) {
+		input, _ = json.Marshal(string(input))
+	}
+	return encodeBlock(BlockToolUse, ToolUseBlock{Name: name, Input: input})
+}
+
+// DecodeToolUse parses ToolUse record content, including records written
+// before content was versioned, which were stored as `name(input)`.
+func DecodeToolUse(content string) (ToolUseBlock, error) {
+	var block ToolUseBlock
+
+	env, ok := parseEnvelope(content)
+	if !ok {
+		return decodeLegacyToolUse(content)
+	}
+	if err := decodeBlock(env, BlockToolUse, &block); err != nil {
+		return ToolUseBlock{}, err
+	}
+	return block, nil
+}
+
+func encodeBlock(blockType string, payload any) string {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		panic(fmt.Sprintf("cannot marshal %s block: %v", blockType, err))
+	}
+
+	env, err := json.Marshal(blockEnvelope{
+		Type:    blockType,
+		Version: blockVersions[blockType],
+		Data:    data,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("cannot marshal %s envelope: %v", blockType, err))
+	}
+	return string(env)
+}
+
+func parseEnvelope(content string) (blockEnvelope, bool) {
+	var env blockEnvelope
+	if !strings.HasPrefix(content, "{") {
+		return env, false
+	}
+	if err := json.Unmarshal([]byte(content), &env); err != nil || env.Type == "" {
+		return env, false
+	}
+	return env, true
+}
+
+// decodeBlock unmarshals an envelope of the wanted type into out,
+// migrating older payload versions on the way.
+func decodeBlock(env blockEnvelope, want string, out any) error {
+	current, known := blockVersions[env.Type]
+	if !known || env.Type != want || env.Version > current {
+		return fmt.Errorf("%w: %s v%d", ErrUnknownBlock, env.Type, env.Version)
+	}
+
+	// Only version 1 exists so far, migrations go here
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("decode %s block: %w", env.Type, err)
+	}
+	return nil
+}
+
+func decodeLegacyToolUse(content string) (ToolUseBlock, error) {
+	open := strings.IndexByte(content, '(')
+	if open < 0 || !strings.HasSuffix(content, ")") {
+		return ToolUseBlock{}, fmt.Errorf("decode tool use: unrecognized content %q", content)
+	}
+
+	input := json.RawMessage(content[open+1 : len(content)-1])
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	return ToolUseBlock{Name: content[:open], Input: input}, nil
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolUseRoundTrip(t *testing.T) {
+	content := EncodeToolUse("bash", json.RawMessage(`{"command":"ls"}`))
+	assert.JSONEq(t, `{"type":"tool_use","version":1,"data":{"name":"bash","input":{"command":"ls"}}}`, content)
+
+	block, err := DecodeToolUse(content)
+	require.NoError(t, err)
+	assert.Equal(t, "bash", block.Name)
+	assert.JSONEq(t, `{"command":"ls"}`, string(block.Input))
+}
+
+func TestToolUse_InvalidInput(t *testing.T) {
+	// Cut off at the token limit
+	content := EncodeToolUse("bash", json.RawMessage(`{"command":"l`))
+
+	block, err := DecodeToolUse(content)
+	require.NoError(t, err)
+	assert.Equal(t, "bash", block.Name)
+	var input string
+	require.NoError(t, json.Unmarshal(block.Input, &input))
+	assert.Equal(t, `{"command":"l`, input)
+}
+
+func TestDecodeToolUse_Legacy(t *testing.T) {
+	block, err := DecodeToolUse(`read_file({"path":"a (copy).go"})`)
+	require.NoError(t, err)
+	assert.Equal(t, "read_file", block.Name)
+	assert.JSONEq(t, `{"path":"a (copy).go"}`, string(block.Input))
+
+	block, err = DecodeToolUse("list_files()")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(block.Input))
+}
+
+func TestDecodeToolUse_NewerVersion(t *testing.T) {
+	_, err := DecodeToolUse(`{"type":"tool_use","version":99,"data":{}}`)
+	assert.ErrorIs(t, err, ErrUnknownBlock)
+
+	_, err = DecodeToolUse(`{"type":"image","version":1,"data":{}}`)
+	assert.ErrorIs(t, err, ErrUnknownBlock)
+}
+
+func TestDecodeToolUse_Garbage(t *testing.T) {
+	_, err := DecodeToolUse("not a tool call")
+	assert.Error(t, err)
+}
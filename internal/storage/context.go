@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -15,16 +16,39 @@ const (
 	ToolUse
 	ToolResult
 	SystemPrompt
+	// Context injected by tinker itself, such as summaries,
+	// which providers receive as system content rather than as user turns
+	SystemNote
+	// Reasoning a model showed before responding, kept for display and
+	// never sent back
+	Thinking
+)
+
+// queryTimeout bounds every statement, so a session locked by another
+// process fails the call instead of hanging it
+const queryTimeout = 10 * time.Second
+
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, queryTimeout)
+}
+
+// Outcomes persisted on ToolUse records
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
 )
 
 // CreateContext creates a new context
-func CreateContext(db *sql.DB, name string) (Context, error) {
+func CreateContext(ctx context.Context, db *sql.DB, name string) (Context, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if name == "" {
 		return Context{}, fmt.Errorf("context name cannot be empty")
 	}
 
 	// Check existing context
-	_, err := GetContextByName(db, name)
+	_, err := GetContextByName(ctx, db, name)
 	if err == nil {
 		return Context{}, fmt.Errorf("context %q already exists", name)
 	}
@@ -35,7 +59,7 @@ func CreateContext(db *sql.DB, name string) (Context, error) {
 	id := uuid.New().String()
 	now := time.Now().UTC()
 
-	_, err = db.Exec(
+	_, err = db.ExecContext(ctx,
 		`INSERT INTO contexts (id, name, start_time) VALUES (?, ?, ?)`, id, name, now,
 	)
 	if err != nil {
@@ -50,18 +74,46 @@ func CreateContext(db *sql.DB, name string) (Context, error) {
 }
 
 func InsertRecord(
+	ctx context.Context,
 	db *sql.DB,
 	contextID string,
 	source RecordType,
 	content string,
 	live bool,
 ) (Record, error) {
+	return InsertRecordWithStatus(ctx, db, contextID, source, content, live, "")
+}
+
+// InsertRecordWithStatus inserts a record along with its outcome status,
+// so resumed sessions can tell failed tool calls from successful ones.
+func InsertRecordWithStatus(
+	ctx context.Context,
+	db *sql.DB,
+	contextID string,
+	source RecordType,
+	content string,
+	live bool,
+	status string,
+) (Record, error) {
+	return InsertEvent(ctx, db, contextID, Record{Source: source, Content: content, Live: live, Status: status})
+}
+
+// InsertEvent persists a record produced by a model call, keeping the tool
+// outcome, display summary and raw output alongside the content.
+func InsertEvent(ctx context.Context, db *sql.DB, contextID string, rec Record) (Record, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	now := time.Now().UTC()
-	t := TokenCount(content)
-	res, err := db.Exec(
-		`INSERT INTO records (context_id, ts, source, content, live, est_tokens) 
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		contextID, now, int(source), content, live, t,
+	t := TokenCount(rec.Content)
+	sealed, err := sealRecord(rec)
+	if err != nil {
+		return Record{}, err
+	}
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO records (context_id, ts, source, content, live, est_tokens, status, summary, output, input_tokens, output_tokens) 
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		contextID, now, int(rec.Source), sealed.Content, rec.Live, t, rec.Status, sealed.Summary, sealed.Output, rec.InputTokens, rec.OutputTokens,
 	)
 	if err != nil {
 		return Record{}, fmt.Errorf("insert record: %w", err)
@@ -73,29 +125,42 @@ func InsertRecord(
 		return Record{}, fmt.Errorf("get last insert id: %w", err)
 	}
 	return Record{
-		ID:        id,
-		Timestamp: now,
-		Source:    source,
-		Content:   content,
-		Live:      live,
-		EstTokens: t,
-		ContextID: contextID,
+		ID:           id,
+		Timestamp:    now,
+		Source:       rec.Source,
+		Content:      rec.Content,
+		Live:         rec.Live,
+		EstTokens:    t,
+		ContextID:    contextID,
+		Status:       rec.Status,
+		Summary:      rec.Summary,
+		Output:       rec.Output,
+		InputTokens:  rec.InputTokens,
+		OutputTokens: rec.OutputTokens,
 	}, nil
 }
 
 func InsertRecordTx(
+	ctx context.Context,
 	tx *sql.Tx,
 	contextID string,
 	source RecordType,
 	content string,
 	live bool,
 ) (Record, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	now := time.Now().UTC()
 	t := TokenCount(content)
-	res, err := tx.Exec(
+	sealed, err := sealPayload(content)
+	if err != nil {
+		return Record{}, err
+	}
+	res, err := tx.ExecContext(ctx,
 		`INSERT INTO records (context_id, ts, source, content, live, est_tokens) 
 		 VALUES (?, ?, ?, ?, ?, ?)`,
-		contextID, now, int(source), content, live, t,
+		contextID, now, int(source), sealed, live, t,
 	)
 	if err != nil {
 		return Record{}, fmt.Errorf("insert record tx: %w", err)
@@ -115,23 +180,40 @@ func InsertRecordTx(
 	}, nil
 }
 
-func GetContext(db *sql.DB, contextID string) (Context, error) {
+func GetContext(ctx context.Context, db *sql.DB, contextID string) (Context, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	var c Context
-	err := db.QueryRow(
-		`SELECT id, name, start_time
+	err := db.QueryRowContext(ctx,
+		`SELECT id, name, start_time, work_dir, project_id
 		 FROM contexts WHERE id = ?`,
 		contextID,
-	).Scan(&c.ID, &c.Name, &c.StartTime)
+	).Scan(&c.ID, &c.Name, &c.StartTime, &c.WorkDir, &c.ProjectID)
 	if err != nil {
 		return Context{}, fmt.Errorf("get context %s: %w", contextID, err)
 	}
 	return c, nil
 }
 
+// SetContextWorkDir records the directory a context's conversation runs in.
+func SetContextWorkDir(ctx context.Context, db *sql.DB, contextID, dir string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `UPDATE contexts SET work_dir = ? WHERE id = ?`, dir, contextID); err != nil {
+		return fmt.Errorf("set context work dir: %w", err)
+	}
+	return nil
+}
+
 // GetContextIDByName gets the internal UUID by context name.
-func GetContextIDByName(db *sql.DB, name string) (string, error) {
+func GetContextIDByName(ctx context.Context, db *sql.DB, name string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	var id string
-	err := db.QueryRow(`SELECT id FROM contexts WHERE name = ?`, name).Scan(&id)
+	err := db.QueryRowContext(ctx, `SELECT id FROM contexts WHERE name = ?`, name).Scan(&id)
 	if err != nil {
 		return "", fmt.Errorf("get context ID for '%s': %w", name, err)
 	}
@@ -139,13 +221,16 @@ func GetContextIDByName(db *sql.DB, name string) (string, error) {
 }
 
 // GetContextByName retrieves a context by name.
-func GetContextByName(db *sql.DB, name string) (Context, error) {
+func GetContextByName(ctx context.Context, db *sql.DB, name string) (Context, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	var c Context
-	err := db.QueryRow(
-		`SELECT id, name, start_time
+	err := db.QueryRowContext(ctx,
+		`SELECT id, name, start_time, work_dir, project_id
 		 FROM contexts WHERE name = ?`,
 		name,
-	).Scan(&c.ID, &c.Name, &c.StartTime)
+	).Scan(&c.ID, &c.Name, &c.StartTime, &c.WorkDir, &c.ProjectID)
 	if err != nil {
 		return Context{}, fmt.Errorf("get context '%s': %w", name, err)
 	}
@@ -153,9 +238,12 @@ func GetContextByName(db *sql.DB, name string) (Context, error) {
 }
 
 // AddContextTool adds a tool name to a specific context
-func AddContextTool(db *sql.DB, contextID, toolName string) (ContextTool, error) {
+func AddContextTool(ctx context.Context, db *sql.DB, contextID, toolName string) (ContextTool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	now := time.Now().UTC()
-	res, err := db.Exec(
+	res, err := db.ExecContext(ctx,
 		`INSERT INTO context_tools (context_id, tool_name, created_at)
 		 VALUES (?, ?, ?)`,
 		contextID, toolName, now,
@@ -176,9 +264,12 @@ func AddContextTool(db *sql.DB, contextID, toolName string) (ContextTool, error)
 }
 
 // HasContextTool checks if a specific tool is available in a context.
-func HasContextTool(db *sql.DB, contextID, toolName string) (bool, error) {
+func HasContextTool(ctx context.Context, db *sql.DB, contextID, toolName string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	var exists bool
-	err := db.QueryRow(
+	err := db.QueryRowContext(ctx,
 		`SELECT 1 FROM context_tools WHERE context_id = ? AND tool_name = ?`,
 		contextID, toolName,
 	).Scan(&exists)
@@ -188,19 +279,26 @@ func HasContextTool(db *sql.DB, contextID, toolName string) (bool, error) {
 	return exists, nil
 }
 
+// ListRecords returns all records in a context, live or not, in a timestamp order
+func ListRecords(ctx context.Context, db *sql.DB, contextID string) ([]Record, error) {
+	return listRecordsWhere(ctx, db, "context_id = ?", contextID)
+}
 
 // ListLiveRecords returns all live records in a context in a timestamp order
-func ListLiveRecords(db *sql.DB, contextID string) ([]Record, error) {
-	return listRecordsWhere(db, "context_id = ? AND live = 1", contextID)
+func ListLiveRecords(ctx context.Context, db *sql.DB, contextID string) ([]Record, error) {
+	return listRecordsWhere(ctx, db, "context_id = ? AND live = 1", contextID)
 }
 
-func listRecordsWhere(db *sql.DB, whereClause string, args ...any) ([]Record, error) {
+func listRecordsWhere(ctx context.Context, db *sql.DB, whereClause string, args ...any) ([]Record, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := fmt.Sprintf(`
-		SELECT id, context_id, ts, source, content, live, est_tokens 
+		SELECT id, context_id, ts, source, content, live, est_tokens, status, summary, output, input_tokens, output_tokens
 		 FROM records WHERE %s ORDER BY ts ASC
 		`, whereClause,
 	)
-	rows, err := db.Query(query, args...)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query records: %w", err)
 	}
@@ -218,11 +316,19 @@ func listRecordsWhere(db *sql.DB, whereClause string, args ...any) ([]Record, er
 			&r.Content,
 			&r.Live,
 			&r.EstTokens,
+			&r.Status,
+			&r.Summary,
+			&r.Output,
+			&r.InputTokens,
+			&r.OutputTokens,
 		); err != nil {
 			return nil, fmt.Errorf("scan record: %w", err)
 		}
 		// Type assertion?
 		r.Source = RecordType(src)
+		if r, err = openRecord(r); err != nil {
+			return nil, fmt.Errorf("record %d: %w", r.ID, err)
+		}
 		recs = append(recs, r)
 	}
 	if err := rows.Err(); err != nil {
@@ -232,9 +338,12 @@ func listRecordsWhere(db *sql.DB, whereClause string, args ...any) ([]Record, er
 }
 
 // ListContexts returns all contexts ordered by start time.
-func ListContexts(db *sql.DB) ([]Context, error) {
-	rows, err := db.Query(
-		`SELECT id, name, start_time
+func ListContexts(ctx context.Context, db *sql.DB) ([]Context, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, name, start_time, work_dir, project_id
 		 FROM contexts ORDER BY start_time DESC`,
 	)
 	if err != nil {
@@ -245,7 +354,7 @@ func ListContexts(db *sql.DB) ([]Context, error) {
 	var contexts []Context
 	for rows.Next() {
 		var c Context
-		if err := rows.Scan(&c.ID, &c.Name, &c.StartTime); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.StartTime, &c.WorkDir, &c.ProjectID); err != nil {
 			return nil, fmt.Errorf("scan context: %w", err)
 		}
 		contexts = append(contexts, c)
@@ -257,28 +366,36 @@ func ListContexts(db *sql.DB) ([]Context, error) {
 }
 
 // DeleteContextByName removes a context and all its records by name.
-func DeleteContextByName(db *sql.DB, name string) error {
-	ctx, err := GetContextByName(db, name)
+func DeleteContextByName(ctx context.Context, db *sql.DB, name string) error {
+	c, err := GetContextByName(ctx, db, name)
 	if err != nil {
 		return err
 	}
-	return DeleteContext(db, ctx.ID)
+	return DeleteContext(ctx, db, c.ID)
 }
 
 // DeleteContext removes a context and all its records by ID.
-func DeleteContext(db *sql.DB, contextID string) error {
-	tx, err := db.Begin()
+func DeleteContext(ctx context.Context, db *sql.DB, contextID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(`DELETE FROM records WHERE context_id = ?`, contextID)
+	_, err = tx.ExecContext(ctx, `DELETE FROM records WHERE context_id = ?`, contextID)
 	if err != nil {
 		return fmt.Errorf("delete context records: %w", err)
 	}
 
-	_, err = tx.Exec(`DELETE FROM contexts WHERE id = ?`, contextID)
+	_, err = tx.ExecContext(ctx, `DELETE FROM turn_stats WHERE context_id = ?`, contextID)
+	if err != nil {
+		return fmt.Errorf("delete context turn stats: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM contexts WHERE id = ?`, contextID)
 	if err != nil {
 		return fmt.Errorf("delete context: %w", err)
 	}
@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"testing"
+	"time"
 )
 
 func newTestDB(t *testing.T) *sql.DB {
@@ -19,7 +22,7 @@ func newTestDB(t *testing.T) *sql.DB {
 func TestCreateContext(t *testing.T) {
 	db := newTestDB(t)
 
-	ctx, err := CreateContext(db, "my-context")
+	ctx, err := CreateContext(t.Context(), db, "my-context")
 	if err != nil {
 		t.Fatalf("create context: %v", err)
 	}
@@ -37,7 +40,7 @@ func TestCreateContext(t *testing.T) {
 func TestCreateContext_EmptyName(t *testing.T) {
 	db := newTestDB(t)
 
-	_, err := CreateContext(db, "")
+	_, err := CreateContext(t.Context(), db, "")
 	if err == nil {
 		t.Fatal("expected error for empty name")
 	}
@@ -46,12 +49,12 @@ func TestCreateContext_EmptyName(t *testing.T) {
 func TestCreateContext_Duplicate(t *testing.T) {
 	db := newTestDB(t)
 
-	_, err := CreateContext(db, "dup")
+	_, err := CreateContext(t.Context(), db, "dup")
 	if err != nil {
 		t.Fatalf("first create: %v", err)
 	}
 
-	_, err = CreateContext(db, "dup")
+	_, err = CreateContext(t.Context(), db, "dup")
 	if err == nil {
 		t.Fatal("expected error for duplicate context name")
 	}
@@ -60,12 +63,12 @@ func TestCreateContext_Duplicate(t *testing.T) {
 func TestGetContext(t *testing.T) {
 	db := newTestDB(t)
 
-	created, err := CreateContext(db, "lookup")
+	created, err := CreateContext(t.Context(), db, "lookup")
 	if err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	got, err := GetContext(db, created.ID)
+	got, err := GetContext(t.Context(), db, created.ID)
 	if err != nil {
 		t.Fatalf("get: %v", err)
 	}
@@ -77,10 +80,30 @@ func TestGetContext(t *testing.T) {
 	}
 }
 
+func TestSetContextWorkDir(t *testing.T) {
+	db := newTestDB(t)
+
+	created, err := CreateContext(t.Context(), db, "workdir")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := SetContextWorkDir(t.Context(), db, created.ID, "/home/me/project"); err != nil {
+		t.Fatalf("set work dir: %v", err)
+	}
+
+	got, err := GetContextByName(t.Context(), db, "workdir")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.WorkDir != "/home/me/project" {
+		t.Errorf("expected work dir %q, got %q", "/home/me/project", got.WorkDir)
+	}
+}
+
 func TestGetContext_NotFound(t *testing.T) {
 	db := newTestDB(t)
 
-	_, err := GetContext(db, "nonexistent-id")
+	_, err := GetContext(t.Context(), db, "nonexistent-id")
 	if err == nil {
 		t.Fatal("expected error for missing context")
 	}
@@ -89,12 +112,12 @@ func TestGetContext_NotFound(t *testing.T) {
 func TestGetContextByName(t *testing.T) {
 	db := newTestDB(t)
 
-	created, err := CreateContext(db, "named")
+	created, err := CreateContext(t.Context(), db, "named")
 	if err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	got, err := GetContextByName(db, "named")
+	got, err := GetContextByName(t.Context(), db, "named")
 	if err != nil {
 		t.Fatalf("get by name: %v", err)
 	}
@@ -106,7 +129,7 @@ func TestGetContextByName(t *testing.T) {
 func TestGetContextByName_NotFound(t *testing.T) {
 	db := newTestDB(t)
 
-	_, err := GetContextByName(db, "missing")
+	_, err := GetContextByName(t.Context(), db, "missing")
 	if err == nil {
 		t.Fatal("expected error for missing name")
 	}
@@ -115,12 +138,12 @@ func TestGetContextByName_NotFound(t *testing.T) {
 func TestGetContextIDByName(t *testing.T) {
 	db := newTestDB(t)
 
-	created, err := CreateContext(db, "id-lookup")
+	created, err := CreateContext(t.Context(), db, "id-lookup")
 	if err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	id, err := GetContextIDByName(db, "id-lookup")
+	id, err := GetContextIDByName(t.Context(), db, "id-lookup")
 	if err != nil {
 		t.Fatalf("get id: %v", err)
 	}
@@ -132,12 +155,12 @@ func TestGetContextIDByName(t *testing.T) {
 func TestInsertRecord(t *testing.T) {
 	db := newTestDB(t)
 
-	ctx, err := CreateContext(db, "rec-ctx")
+	ctx, err := CreateContext(t.Context(), db, "rec-ctx")
 	if err != nil {
 		t.Fatalf("create context: %v", err)
 	}
 
-	rec, err := InsertRecord(db, ctx.ID, Prompt, "hello world", true)
+	rec, err := InsertRecord(t.Context(), db, ctx.ID, Prompt, "hello world", true)
 	if err != nil {
 		t.Fatalf("insert record: %v", err)
 	}
@@ -161,7 +184,7 @@ func TestInsertRecord(t *testing.T) {
 func TestInsertRecordTx(t *testing.T) {
 	db := newTestDB(t)
 
-	ctx, err := CreateContext(db, "tx-ctx")
+	ctx, err := CreateContext(t.Context(), db, "tx-ctx")
 	if err != nil {
 		t.Fatalf("create context: %v", err)
 	}
@@ -170,7 +193,7 @@ func TestInsertRecordTx(t *testing.T) {
 	if err != nil {
 		t.Fatalf("begin tx: %v", err)
 	}
-	rec, err := InsertRecordTx(tx, ctx.ID, ModelResp, "response text", true)
+	rec, err := InsertRecordTx(t.Context(), tx, ctx.ID, ModelResp, "response text", true)
 	if err != nil {
 		tx.Rollback()
 		t.Fatalf("insert record tx: %v", err)
@@ -187,24 +210,138 @@ func TestInsertRecordTx(t *testing.T) {
 	}
 }
 
+func TestInsertRecordWithStatus(t *testing.T) {
+	db := newTestDB(t)
+
+	ctx, err := CreateContext(t.Context(), db, "status-ctx")
+	if err != nil {
+		t.Fatalf("create context: %v", err)
+	}
+
+	_, err = InsertRecordWithStatus(t.Context(), db, ctx.ID, ToolUse, `bash({"command":"false"})`, true, StatusError)
+	if err != nil {
+		t.Fatalf("insert record: %v", err)
+	}
+	_, err = InsertRecord(t.Context(), db, ctx.ID, Prompt, "no status", true)
+	if err != nil {
+		t.Fatalf("insert record: %v", err)
+	}
+
+	records, err := ListLiveRecords(t.Context(), db, ctx.ID)
+	if err != nil {
+		t.Fatalf("list live: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].Status != StatusError {
+		t.Errorf("expected status %q, got %q", StatusError, records[0].Status)
+	}
+	if records[1].Status != "" {
+		t.Errorf("expected empty status, got %q", records[1].Status)
+	}
+}
+
+func TestInsertEvent(t *testing.T) {
+	db := newTestDB(t)
+
+	ctx, err := CreateContext(t.Context(), db, "event-ctx")
+	if err != nil {
+		t.Fatalf("create context: %v", err)
+	}
+
+	_, err = InsertEvent(t.Context(), db, ctx.ID, Record{
+		Source:  ToolUse,
+		Content: `read_file({"path":"main.go"})`,
+		Live:    true,
+		Status:  StatusOK,
+		Summary: "Read main.go (12 lines)",
+		Output:  "1: package main",
+		// As counted by the provider for the request
+		InputTokens:  1200,
+		OutputTokens: 40,
+	})
+	if err != nil {
+		t.Fatalf("insert event: %v", err)
+	}
+
+	records, err := ListLiveRecords(t.Context(), db, ctx.ID)
+	if err != nil {
+		t.Fatalf("list live: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	if records[0].Summary != "Read main.go (12 lines)" {
+		t.Errorf("unexpected summary %q", records[0].Summary)
+	}
+	if records[0].Output != "1: package main" {
+		t.Errorf("unexpected output %q", records[0].Output)
+	}
+	if records[0].EstTokens == 0 {
+		t.Error("expected token estimate to be set")
+	}
+	if records[0].InputTokens != 1200 || records[0].OutputTokens != 40 {
+		t.Errorf("unexpected token counts %d/%d", records[0].InputTokens, records[0].OutputTokens)
+	}
+}
+
+func TestImportContext(t *testing.T) {
+	db := newTestDB(t)
+
+	started := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	c, err := ImportContext(t.Context(), db, "imported", Context{StartTime: started, WorkDir: "/work/app"}, []Record{
+		{Timestamp: started, Source: Prompt, Content: "fix the parser", Live: true},
+		{Timestamp: started.Add(time.Minute), Source: ModelResp, Content: "fixed", Live: true, InputTokens: 900, OutputTokens: 12},
+	})
+	if err != nil {
+		t.Fatalf("import context: %v", err)
+	}
+
+	got, err := GetContextByName(t.Context(), db, "imported")
+	if err != nil {
+		t.Fatalf("get context: %v", err)
+	}
+	if got.ID != c.ID || !got.StartTime.Equal(started) || got.WorkDir != "/work/app" {
+		t.Errorf("unexpected context %+v", got)
+	}
+	records, err := ListLiveRecords(t.Context(), db, c.ID)
+	if err != nil {
+		t.Fatalf("list live: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if !records[1].Timestamp.Equal(started.Add(time.Minute)) {
+		t.Errorf("expected the recorded time, got %v", records[1].Timestamp)
+	}
+	if records[1].InputTokens != 900 || records[1].EstTokens == 0 {
+		t.Errorf("unexpected token counts %+v", records[1])
+	}
+
+	if _, err := ImportContext(t.Context(), db, "", Context{}, nil); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+}
+
 func TestListLiveRecords(t *testing.T) {
 	db := newTestDB(t)
 
-	ctx, err := CreateContext(db, "live-ctx")
+	ctx, err := CreateContext(t.Context(), db, "live-ctx")
 	if err != nil {
 		t.Fatalf("create context: %v", err)
 	}
 
-	_, err = InsertRecord(db, ctx.ID, Prompt, "live msg", true)
+	_, err = InsertRecord(t.Context(), db, ctx.ID, Prompt, "live msg", true)
 	if err != nil {
 		t.Fatalf("insert live: %v", err)
 	}
-	_, err = InsertRecord(db, ctx.ID, Prompt, "dead msg", false)
+	_, err = InsertRecord(t.Context(), db, ctx.ID, Prompt, "dead msg", false)
 	if err != nil {
 		t.Fatalf("insert dead: %v", err)
 	}
 
-	records, err := ListLiveRecords(db, ctx.ID)
+	records, err := ListLiveRecords(t.Context(), db, ctx.ID)
 	if err != nil {
 		t.Fatalf("list live: %v", err)
 	}
@@ -219,16 +356,16 @@ func TestListLiveRecords(t *testing.T) {
 func TestListContexts(t *testing.T) {
 	db := newTestDB(t)
 
-	_, err := CreateContext(db, "ctx-a")
+	_, err := CreateContext(t.Context(), db, "ctx-a")
 	if err != nil {
 		t.Fatalf("create a: %v", err)
 	}
-	_, err = CreateContext(db, "ctx-b")
+	_, err = CreateContext(t.Context(), db, "ctx-b")
 	if err != nil {
 		t.Fatalf("create b: %v", err)
 	}
 
-	contexts, err := ListContexts(db)
+	contexts, err := ListContexts(t.Context(), db)
 	if err != nil {
 		t.Fatalf("list: %v", err)
 	}
@@ -240,12 +377,12 @@ func TestListContexts(t *testing.T) {
 func TestAddContextTool(t *testing.T) {
 	db := newTestDB(t)
 
-	ctx, err := CreateContext(db, "tool-ctx")
+	ctx, err := CreateContext(t.Context(), db, "tool-ctx")
 	if err != nil {
 		t.Fatalf("create context: %v", err)
 	}
 
-	ct, err := AddContextTool(db, ctx.ID, "bash")
+	ct, err := AddContextTool(t.Context(), db, ctx.ID, "bash")
 	if err != nil {
 		t.Fatalf("add tool: %v", err)
 	}
@@ -260,17 +397,17 @@ func TestAddContextTool(t *testing.T) {
 func TestAddContextTool_Duplicate(t *testing.T) {
 	db := newTestDB(t)
 
-	ctx, err := CreateContext(db, "dup-tool-ctx")
+	ctx, err := CreateContext(t.Context(), db, "dup-tool-ctx")
 	if err != nil {
 		t.Fatalf("create context: %v", err)
 	}
 
-	_, err = AddContextTool(db, ctx.ID, "bash")
+	_, err = AddContextTool(t.Context(), db, ctx.ID, "bash")
 	if err != nil {
 		t.Fatalf("first add: %v", err)
 	}
 
-	_, err = AddContextTool(db, ctx.ID, "bash")
+	_, err = AddContextTool(t.Context(), db, ctx.ID, "bash")
 	if err == nil {
 		t.Fatal("expected error for duplicate tool")
 	}
@@ -279,21 +416,21 @@ func TestAddContextTool_Duplicate(t *testing.T) {
 func TestDeleteContext(t *testing.T) {
 	db := newTestDB(t)
 
-	ctx, err := CreateContext(db, "del-ctx")
+	ctx, err := CreateContext(t.Context(), db, "del-ctx")
 	if err != nil {
 		t.Fatalf("create: %v", err)
 	}
-	_, err = InsertRecord(db, ctx.ID, Prompt, "some content", true)
+	_, err = InsertRecord(t.Context(), db, ctx.ID, Prompt, "some content", true)
 	if err != nil {
 		t.Fatalf("insert: %v", err)
 	}
 
-	err = DeleteContext(db, ctx.ID)
+	err = DeleteContext(t.Context(), db, ctx.ID)
 	if err != nil {
 		t.Fatalf("delete: %v", err)
 	}
 
-	_, err = GetContext(db, ctx.ID)
+	_, err = GetContext(t.Context(), db, ctx.ID)
 	if err == nil {
 		t.Fatal("expected error after delete")
 	}
@@ -302,17 +439,17 @@ func TestDeleteContext(t *testing.T) {
 func TestDeleteContextByName(t *testing.T) {
 	db := newTestDB(t)
 
-	_, err := CreateContext(db, "named-del")
+	_, err := CreateContext(t.Context(), db, "named-del")
 	if err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
-	err = DeleteContextByName(db, "named-del")
+	err = DeleteContextByName(t.Context(), db, "named-del")
 	if err != nil {
 		t.Fatalf("delete by name: %v", err)
 	}
 
-	_, err = GetContextByName(db, "named-del")
+	_, err = GetContextByName(t.Context(), db, "named-del")
 	if err == nil {
 		t.Fatal("expected error after delete")
 	}
@@ -321,8 +458,26 @@ func TestDeleteContextByName(t *testing.T) {
 func TestDeleteContextByName_NotFound(t *testing.T) {
 	db := newTestDB(t)
 
-	err := DeleteContextByName(db, "ghost")
+	err := DeleteContextByName(t.Context(), db, "ghost")
 	if err == nil {
 		t.Fatal("expected error for missing context name")
 	}
 }
+
+func TestQueries_HonorCancellation(t *testing.T) {
+	db := newTestDB(t)
+	c, err := CreateContext(t.Context(), db, "cancelled")
+	if err != nil {
+		t.Fatalf("create context: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	if _, err := InsertRecord(ctx, db, c.ID, Prompt, "hi", true); !errors.Is(err, context.Canceled) {
+		t.Fatalf("insert with cancelled context: got %v, want context.Canceled", err)
+	}
+	if _, err := ListLiveRecords(ctx, db, c.ID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("list with cancelled context: got %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix marks an encrypted payload, followed by the base64 of the
+// nonce and the AES-GCM sealed payload
+const encryptedPrefix = "enc:v1:"
+
+// ErrNoKey is returned when reading an encrypted payload without a key
+var ErrNoKey = errors.New("session is encrypted and no key is configured")
+
+// Encryption configures encryption of record payloads: their content,
+// summary and output.
+type Encryption struct {
+	// Encrypt payloads written from now on. Encrypted payloads are
+	// decrypted either way and plain ones are read as they are.
+	Enabled bool
+	// Key returns the 32-byte AES key. It is called once, the first time a
+	// payload is encrypted or decrypted, so a passphrase is only asked for
+	// when it is needed.
+	Key func() ([]byte, error)
+}
+
+var encryption struct {
+	mu   sync.Mutex
+	cfg  Encryption
+	aead cipher.AEAD
+	err  error
+	done bool
+}
+
+// SetEncryption replaces the payload encryption of every session opened by this process.
+func SetEncryption(e Encryption) {
+	encryption.mu.Lock()
+	defer encryption.mu.Unlock()
+	encryption.cfg = e
+	encryption.aead, encryption.err, encryption.done = nil, nil, false
+}
+
+func payloadCipher() (cipher.AEAD, error) {
+	encryption.mu.Lock()
+	defer encryption.mu.Unlock()
+	if encryption.done {
+		return encryption.aead, encryption.err
+	}
+	encryption.done = true
+
+	if encryption.cfg.Key == nil {
+		encryption.err = ErrNoKey
+		return nil, encryption.err
+	}
+	key, err := encryption.cfg.Key()
+	if err != nil {
+		encryption.err = fmt.Errorf("load encryption key: %w", err)
+		return nil, encryption.err
+	}
+	encryption.aead, encryption.err = newAEAD(key)
+	return encryption.aead, encryption.err
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptionEnabled() bool {
+	encryption.mu.Lock()
+	defer encryption.mu.Unlock()
+	return encryption.cfg.Enabled
+}
+
+// sealPayload encrypts s when encryption is enabled. Empty payloads stay empty.
+func sealPayload(s string) (string, error) {
+	if s == "" || !encryptionEnabled() {
+		return s, nil
+	}
+	aead, err := payloadCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("encrypt payload: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(s), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openPayload decrypts s if it was encrypted and returns it unchanged otherwise.
+func openPayload(s string) (string, error) {
+	if !strings.HasPrefix(s, encryptedPrefix) {
+		return s, nil
+	}
+	aead, err := payloadCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("decrypt payload: malformed ciphertext")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt payload: wrong key or corrupted record")
+	}
+	return string(plain), nil
+}
+
+// sealRecord encrypts the payloads of rec.
+func sealRecord(rec Record) (Record, error) {
+	var err error
+	for _, f := range []*string{&rec.Content, &rec.Summary, &rec.Output} {
+		if *f, err = sealPayload(*f); err != nil {
+			return rec, err
+		}
+	}
+	return rec, nil
+}
+
+// openRecord decrypts the payloads of rec read from a session.
+func openRecord(rec Record) (Record, error) {
+	var err error
+	for _, f := range []*string{&rec.Content, &rec.Summary, &rec.Output} {
+		if *f, err = openPayload(*f); err != nil {
+			return rec, err
+		}
+	}
+	return rec, nil
+}
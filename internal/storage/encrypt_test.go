@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setTestEncryption(t *testing.T, enabled bool) {
+	t.Helper()
+	key := bytes.Repeat([]byte{7}, 32)
+	SetEncryption(Encryption{Enabled: enabled, Key: func() ([]byte, error) { return key, nil }})
+	t.Cleanup(func() { SetEncryption(Encryption{}) })
+}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	db, err := NewSession(":memory:", "")
+	require.NoError(t, err)
+	defer db.Close()
+	ctx, err := CreateContext(t.Context(), db, "secret")
+	require.NoError(t, err)
+
+	// Written before encryption was turned on
+	_, err = InsertRecord(t.Context(), db, ctx.ID, Prompt, "plain prompt", true)
+	require.NoError(t, err)
+
+	setTestEncryption(t, true)
+	_, err = InsertEvent(t.Context(), db, ctx.ID, Record{Source: ToolUse, Content: "proprietary code", Live: true, Summary: "read_file(main.go)", Output: "package main"})
+	require.NoError(t, err)
+
+	var raw string
+	require.NoError(t, db.QueryRow(`SELECT content FROM records WHERE source = ?`, ToolUse).Scan(&raw))
+	assert.NotContains(t, raw, "proprietary")
+	assert.Contains(t, raw, encryptedPrefix)
+
+	records, err := ListLiveRecords(t.Context(), db, ctx.ID)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "plain prompt", records[0].Content)
+	assert.Equal(t, "proprietary code", records[1].Content)
+	assert.Equal(t, "read_file(main.go)", records[1].Summary)
+	assert.Equal(t, "package main", records[1].Output)
+
+	// Turning encryption off keeps encrypted records readable
+	setTestEncryption(t, false)
+	records, err = ListLiveRecords(t.Context(), db, ctx.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "proprietary code", records[1].Content)
+
+	SetEncryption(Encryption{})
+	_, err = ListLiveRecords(t.Context(), db, ctx.ID)
+	assert.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestEncryption_WrongKey(t *testing.T) {
+	setTestEncryption(t, true)
+	sealed, err := sealPayload("hello")
+	require.NoError(t, err)
+
+	other := bytes.Repeat([]byte{8}, 32)
+	SetEncryption(Encryption{Key: func() ([]byte, error) { return other, nil }})
+	_, err = openPayload(sealed)
+	assert.ErrorContains(t, err, "wrong key")
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ImportContext stores a conversation recorded elsewhere as a new context
+// named name, keeping its start time, directory, project and the timestamps
+// of its records, all or nothing.
+func ImportContext(ctx context.Context, db *sql.DB, name string, c Context, records []Record) (Context, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if name == "" {
+		return Context{}, fmt.Errorf("context name cannot be empty")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Context{}, fmt.Errorf("import context: %w", err)
+	}
+	defer tx.Rollback()
+
+	c.ID = uuid.New().String()
+	c.Name = name
+	c.StartTime = c.StartTime.UTC()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO contexts (id, name, start_time, work_dir, project_id) VALUES (?, ?, ?, ?, ?)`,
+		c.ID, c.Name, c.StartTime, c.WorkDir, c.ProjectID,
+	)
+	if err != nil {
+		return Context{}, fmt.Errorf("import context: %w", err)
+	}
+
+	for _, rec := range records {
+		sealed, err := sealRecord(rec)
+		if err != nil {
+			return Context{}, err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO records (context_id, ts, source, content, live, est_tokens, status, summary, output, input_tokens, output_tokens)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			c.ID, rec.Timestamp.UTC(), int(rec.Source), sealed.Content, rec.Live, TokenCount(rec.Content),
+			rec.Status, sealed.Summary, sealed.Output, rec.InputTokens, rec.OutputTokens,
+		)
+		if err != nil {
+			return Context{}, fmt.Errorf("import record: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Context{}, fmt.Errorf("import context: %w", err)
+	}
+	return c, nil
+}
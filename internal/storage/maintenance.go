@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupsDir is the directory inside a sessions directory where sessions
+// are copied before their schema is migrated.
+const BackupsDir = "backups"
+
+// Problem is an integrity issue found in one session
+type Problem struct {
+	Session string `json:"session"`
+	Message string `json:"message"`
+}
+
+// BackupSessions copies every session in dir to dest as a consistent
+// snapshot, safe to take while sessions are in use, and returns how many
+// were copied.
+func BackupSessions(dir, dest string) (int, error) {
+	ids, err := sessionIDs(dir)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return 0, fmt.Errorf("create backup dir: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := backupSession(filepath.Join(dir, id+".db"), filepath.Join(dest, id+".db")); err != nil {
+			return 0, fmt.Errorf("back up session %s: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+// VacuumSessions rebuilds every session in dir, reclaiming the space left
+// by deleted contexts, and returns how many bytes were freed.
+func VacuumSessions(dir string) (int64, error) {
+	ids, err := sessionIDs(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var freed int64
+	for _, id := range ids {
+		path := filepath.Join(dir, id+".db")
+		before := fileSize(path)
+
+		db, err := sql.Open("sqlite3", path)
+		if err != nil {
+			return freed, fmt.Errorf("open session %s: %w", id, err)
+		}
+		_, err = db.Exec(`VACUUM`)
+		db.Close()
+		if err != nil {
+			return freed, fmt.Errorf("vacuum session %s: %w", id, err)
+		}
+
+		freed += before - fileSize(path)
+	}
+	return freed, nil
+}
+
+// CheckSessions runs SQLite's integrity check on every session in dir.
+// Sessions that cannot be opened at all are reported as problems too.
+func CheckSessions(dir string) ([]Problem, error) {
+	ids, err := sessionIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	for _, id := range ids {
+		msgs, err := checkSession(filepath.Join(dir, id+".db"))
+		if err != nil {
+			msgs = []string{err.Error()}
+		}
+		for _, msg := range msgs {
+			problems = append(problems, Problem{Session: id, Message: msg})
+		}
+	}
+	return problems, nil
+}
+
+func checkSession(path string) ([]string, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		if msg != "ok" {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, rows.Err()
+}
+
+// backupBeforeMigration copies the session db is opened on into the backups
+// directory next to it and returns the copy's path, empty for in-memory
+// sessions.
+func backupBeforeMigration(db *sql.DB) (string, error) {
+	var seq int
+	var name, path string
+	if err := db.QueryRow(`PRAGMA database_list`).Scan(&seq, &name, &path); err != nil {
+		return "", fmt.Errorf("locate session file: %w", err)
+	}
+	if path == "" {
+		return "", nil
+	}
+
+	id := strings.TrimSuffix(filepath.Base(path), ".db")
+	backups := filepath.Join(filepath.Dir(path), BackupsDir)
+	if err := os.MkdirAll(backups, 0o755); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+	// VACUUM INTO only writes to a missing or empty file, so migrations
+	// within the same second each get their own
+	f, err := os.CreateTemp(backups, fmt.Sprintf("%s-%s-*.db", id, time.Now().Format("20060102-150405")))
+	if err != nil {
+		return "", fmt.Errorf("create backup: %w", err)
+	}
+	dest := f.Name()
+	f.Close()
+	if _, err := db.Exec(`VACUUM INTO ?`, dest); err != nil {
+		os.Remove(dest)
+		return "", fmt.Errorf("back up session before migration: %w", err)
+	}
+	return dest, nil
+}
+
+func backupSession(src, dest string) error {
+	db, err := sql.Open("sqlite3", src)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// VACUUM INTO refuses to overwrite an existing file
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err = db.Exec(`VACUUM INTO ?`, dest)
+	return err
+}
+
+// sessionIDs lists the ids of the sessions stored in dir.
+func sessionIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session directory does not exist: %w", err)
+		}
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".db") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), ".db"))
+	}
+	return ids, nil
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
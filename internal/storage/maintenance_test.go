@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSession_BacksUpBeforeMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "old.db"))
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		context_id TEXT NOT NULL,
+		ts DATETIME NOT NULL,
+		source INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		live BOOLEAN NOT NULL,
+		est_tokens INTEGER NOT NULL
+	)`)
+	require.NoError(t, err)
+	db.Close()
+
+	db, err = OpenSession(dir, "old")
+	require.NoError(t, err)
+	db.Close()
+
+	backups, err := filepath.Glob(filepath.Join(dir, BackupsDir, "old-*.db"))
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	// Opening the migrated session again leaves the backups alone
+	db, err = OpenSession(dir, "old")
+	require.NoError(t, err)
+	db.Close()
+	backups, err = filepath.Glob(filepath.Join(dir, BackupsDir, "*.db"))
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+
+	// Backups are not listed as sessions
+	sessions, err := ListSessions(t.Context(), dir)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestBackupBeforeMigration_SameSecond(t *testing.T) {
+	db, err := NewSession(t.TempDir(), "a")
+	require.NoError(t, err)
+	defer db.Close()
+
+	first, err := backupBeforeMigration(db)
+	require.NoError(t, err)
+	second, err := backupBeforeMigration(db)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	assert.FileExists(t, second)
+}
+
+func TestReadingOldSession_LeavesItAlone(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "old.db")
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		CREATE TABLE contexts (id TEXT PRIMARY KEY, name TEXT NOT NULL, start_time DATETIME NOT NULL);
+		CREATE TABLE records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			context_id TEXT NOT NULL,
+			ts DATETIME NOT NULL,
+			source INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			live BOOLEAN NOT NULL,
+			est_tokens INTEGER NOT NULL
+		);
+		INSERT INTO contexts VALUES ('c1', 'old', '2025-01-02 03:04:05');
+		INSERT INTO records (context_id, ts, source, content, live, est_tokens)
+			VALUES ('c1', '2025-01-02 03:04:05', 0, 'hello', 1, 1);`)
+	require.NoError(t, err)
+	db.Close()
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	sessions, err := ListSessions(t.Context(), dir)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "old", sessions[0].Name)
+
+	detail, err := GetSessionHistory(t.Context(), dir, "old")
+	require.NoError(t, err)
+	require.Len(t, detail.Records, 1)
+	assert.Equal(t, "hello", detail.Records[0].Content)
+	assert.Empty(t, detail.Contexts[0].WorkDir)
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "reading does not migrate the session")
+	assert.NoDirExists(t, filepath.Join(dir, BackupsDir))
+}
+
+func TestNewSession_NoBackup(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := NewSession(dir, "new")
+	require.NoError(t, err)
+	db.Close()
+
+	_, err = os.Stat(filepath.Join(dir, BackupsDir))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBackupSessions(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewSession(dir, "a")
+	require.NoError(t, err)
+	seedSession(t, db, "sess-1")
+	db.Close()
+
+	dest := t.TempDir()
+	n, err := BackupSessions(dir, dest)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Backing up again replaces the earlier copy
+	_, err = BackupSessions(dir, dest)
+	require.NoError(t, err)
+
+	detail, err := GetSession(t.Context(), dest, "a")
+	require.NoError(t, err)
+	assert.NotEmpty(t, detail.Contexts)
+}
+
+func TestVacuumAndCheckSessions(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewSession(dir, "a")
+	require.NoError(t, err)
+	seedSession(t, db, "sess-1")
+	db.Close()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.db"), []byte("not a database"), 0o644))
+
+	problems, err := CheckSessions(dir)
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "broken", problems[0].Session)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "broken.db")))
+	_, err = VacuumSessions(dir)
+	require.NoError(t, err)
+
+	problems, err = CheckSessions(dir)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
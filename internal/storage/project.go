@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProjectsFile is the database, next to the config file, projects are kept in.
+// Unlike sessions they are shared by every conversation, so they get their own.
+const ProjectsFile = "projects.db"
+
+// ErrProjectNotFound is returned when no project has the given name or ID
+var ErrProjectNotFound = errors.New("project not found")
+
+// ProjectSettings are the defaults of runs in a project. Flags and profiles
+// override them.
+type ProjectSettings struct {
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Verbosity string `json:"verbosity,omitempty"`
+}
+
+// Project is a directory conversations run in, usually a repository root
+type Project struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Path      string          `json:"path"`
+	Settings  ProjectSettings `json:"settings"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// OpenProjects opens the projects database at path, creating it if needed.
+func OpenProjects(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create config dir: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("open projects: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS projects (
+			id         TEXT PRIMARY KEY,
+			name       TEXT NOT NULL UNIQUE,
+			path       TEXT NOT NULL UNIQUE,
+			settings   TEXT NOT NULL DEFAULT '{}',
+			created_at DATETIME NOT NULL
+		);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create projects table: %w", err)
+	}
+	return db, nil
+}
+
+// CreateProject adds a project rooted at path, which is made absolute.
+func CreateProject(ctx context.Context, db *sql.DB, name, path string, settings ProjectSettings) (Project, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Project{}, fmt.Errorf("resolve project path: %w", err)
+	}
+	p := Project{ID: uuid.New().String(), Name: name, Path: path, Settings: settings, CreatedAt: time.Now().UTC()}
+	data, err := json.Marshal(p.Settings)
+	if err != nil {
+		return Project{}, fmt.Errorf("marshal project settings: %w", err)
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO projects (id, name, path, settings, created_at) VALUES (?, ?, ?, ?, ?)`,
+		p.ID, p.Name, p.Path, string(data), p.CreatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return Project{}, fmt.Errorf("create project: a project named %s or at %s already exists", name, path)
+		}
+		return Project{}, fmt.Errorf("create project: %w", err)
+	}
+	return p, nil
+}
+
+// ListProjects returns every project by name.
+func ListProjects(ctx context.Context, db *sql.DB) ([]Project, error) {
+	return listProjectsWhere(ctx, db, "1 = 1")
+}
+
+// GetProject returns the project with the given name or ID.
+func GetProject(ctx context.Context, db *sql.DB, nameOrID string) (Project, error) {
+	projects, err := listProjectsWhere(ctx, db, "name = ? OR id = ?", nameOrID, nameOrID)
+	if err != nil {
+		return Project{}, err
+	}
+	if len(projects) == 0 {
+		return Project{}, fmt.Errorf("%w: %s", ErrProjectNotFound, nameOrID)
+	}
+	return projects[0], nil
+}
+
+// ProjectForDir returns the project dir belongs to, the one with the
+// deepest path when projects are nested. ok is false outside any project.
+func ProjectForDir(ctx context.Context, db *sql.DB, dir string) (p Project, ok bool, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return Project{}, false, err
+	}
+	projects, err := ListProjects(ctx, db)
+	if err != nil {
+		return Project{}, false, err
+	}
+	for _, candidate := range projects {
+		rel, err := filepath.Rel(candidate.Path, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if !ok || len(candidate.Path) > len(p.Path) {
+			p, ok = candidate, true
+		}
+	}
+	return p, ok, nil
+}
+
+// UpdateProject saves the name, path and settings of p.
+func UpdateProject(ctx context.Context, db *sql.DB, p Project) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(p.Settings)
+	if err != nil {
+		return fmt.Errorf("marshal project settings: %w", err)
+	}
+	res, err := db.ExecContext(ctx,
+		`UPDATE projects SET name = ?, path = ?, settings = ? WHERE id = ?`,
+		p.Name, p.Path, string(data), p.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update project: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrProjectNotFound, p.ID)
+	}
+	return nil
+}
+
+// DeleteProject removes the project with the given name or ID. Conversations
+// keep referring to it by ID.
+func DeleteProject(ctx context.Context, db *sql.DB, nameOrID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, `DELETE FROM projects WHERE name = ? OR id = ?`, nameOrID, nameOrID)
+	if err != nil {
+		return fmt.Errorf("delete project: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrProjectNotFound, nameOrID)
+	}
+	return nil
+}
+
+func listProjectsWhere(ctx context.Context, db *sql.DB, whereClause string, args ...any) ([]Project, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf(`SELECT id, name, path, settings, created_at FROM projects WHERE %s ORDER BY name`, whereClause),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		var p Project
+		var settings string
+		if err := rows.Scan(&p.ID, &p.Name, &p.Path, &settings, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan project: %w", err)
+		}
+		if err := json.Unmarshal([]byte(settings), &p.Settings); err != nil {
+			return nil, fmt.Errorf("project %s settings: %w", p.Name, err)
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("projects rows: %w", err)
+	}
+	return projects, nil
+}
+
+// ProjectSessions returns the IDs of the sessions in dir with a
+// conversation in the project with ID projectID.
+func ProjectSessions(ctx context.Context, dir, projectID string) ([]string, error) {
+	ids, err := sessionIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, id := range ids {
+		db, err := openSessionForReading(filepath.Join(dir, id+".db"))
+		if err != nil {
+			continue
+		}
+		var found bool
+		err = db.QueryRowContext(ctx, `SELECT 1 FROM contexts WHERE project_id = ? LIMIT 1`, projectID).Scan(&found)
+		db.Close()
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("session %s: %w", id, err)
+		}
+		if found {
+			matched = append(matched, id)
+		}
+	}
+	return matched, nil
+}
+
+// SetContextProject records the project a context's conversation belongs to.
+func SetContextProject(ctx context.Context, db *sql.DB, contextID, projectID string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `UPDATE contexts SET project_id = ? WHERE id = ?`, projectID, contextID); err != nil {
+		return fmt.Errorf("set context project: %w", err)
+	}
+	return nil
+}
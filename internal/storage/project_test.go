@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjects_CRUD(t *testing.T) {
+	db, err := OpenProjects(filepath.Join(t.TempDir(), ProjectsFile))
+	require.NoError(t, err)
+	defer db.Close()
+
+	root := t.TempDir()
+	p, err := CreateProject(t.Context(), db, "tinker", root, ProjectSettings{Model: "claude-sonnet-4-6"})
+	require.NoError(t, err)
+
+	_, err = CreateProject(t.Context(), db, "tinker", t.TempDir(), ProjectSettings{})
+	assert.ErrorContains(t, err, "already exists")
+
+	got, err := GetProject(t.Context(), db, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "claude-sonnet-4-6", got.Settings.Model)
+
+	got.Settings.Language = "French"
+	require.NoError(t, UpdateProject(t.Context(), db, got))
+	got, err = GetProject(t.Context(), db, "tinker")
+	require.NoError(t, err)
+	assert.Equal(t, "French", got.Settings.Language)
+
+	require.NoError(t, DeleteProject(t.Context(), db, "tinker"))
+	_, err = GetProject(t.Context(), db, "tinker")
+	assert.True(t, errors.Is(err, ErrProjectNotFound))
+}
+
+func TestProjectForDir_DeepestWins(t *testing.T) {
+	db, err := OpenProjects(filepath.Join(t.TempDir(), ProjectsFile))
+	require.NoError(t, err)
+	defer db.Close()
+
+	root := t.TempDir()
+	_, err = CreateProject(t.Context(), db, "mono", root, ProjectSettings{})
+	require.NoError(t, err)
+	_, err = CreateProject(t.Context(), db, "web", filepath.Join(root, "web"), ProjectSettings{})
+	require.NoError(t, err)
+
+	p, ok, err := ProjectForDir(t.Context(), db, filepath.Join(root, "web", "src"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "web", p.Name)
+
+	p, ok, err = ProjectForDir(t.Context(), db, filepath.Join(root, "api"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "mono", p.Name)
+
+	// A sibling sharing the prefix is outside
+	_, ok, err = ProjectForDir(t.Context(), db, root+"-other")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestProjectSessions(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"in", "out"} {
+		db, err := NewSession(dir, id)
+		require.NoError(t, err)
+		c, err := CreateContext(t.Context(), db, id)
+		require.NoError(t, err)
+		if id == "in" {
+			require.NoError(t, SetContextProject(t.Context(), db, c.ID, "p1"))
+		}
+		db.Close()
+	}
+
+	ids, err := ProjectSessions(t.Context(), dir, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"in"}, ids)
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ArchiveDir is the directory inside a sessions directory pruned sessions
+// are moved to when they are archived rather than deleted.
+const ArchiveDir = "archive"
+
+// Retention limits how many sessions are kept. Zero fields impose no limit.
+type Retention struct {
+	// Prune sessions without activity for this many days
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+	// Prune all but this many most recently active sessions
+	KeepLast int `json:"keep_last,omitempty"`
+	// Move pruned sessions to the archive directory instead of deleting them
+	Archive bool `json:"archive,omitempty"`
+}
+
+// IsZero reports whether r keeps every session.
+func (r Retention) IsZero() bool {
+	return r.MaxAgeDays <= 0 && r.KeepLast <= 0
+}
+
+// PrunedSession is a session removed by PruneSessions
+type PrunedSession struct {
+	ID         string    `json:"id"`
+	LastActive time.Time `json:"last_active"`
+}
+
+// PruneSessions deletes or archives the sessions in dir that r does not
+// keep, as of now, and returns them oldest first. With dryRun the sessions
+// are only returned.
+func PruneSessions(dir string, r Retention, now time.Time, dryRun bool) ([]PrunedSession, error) {
+	if r.IsZero() {
+		return nil, nil
+	}
+	ids, err := sessionIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]PrunedSession, 0, len(ids))
+	for _, id := range ids {
+		sessions = append(sessions, PrunedSession{ID: id, LastActive: lastActive(filepath.Join(dir, id+".db"))})
+	}
+	// Most recently active first
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActive.After(sessions[j].LastActive)
+	})
+
+	var pruned []PrunedSession
+	cutoff := now.AddDate(0, 0, -r.MaxAgeDays)
+	for i, s := range sessions {
+		tooMany := r.KeepLast > 0 && i >= r.KeepLast
+		tooOld := r.MaxAgeDays > 0 && s.LastActive.Before(cutoff)
+		if tooMany || tooOld {
+			pruned = append(pruned, s)
+		}
+	}
+	sort.Slice(pruned, func(i, j int) bool {
+		return pruned[i].LastActive.Before(pruned[j].LastActive)
+	})
+	if dryRun {
+		return pruned, nil
+	}
+
+	for _, s := range pruned {
+		path := filepath.Join(dir, s.ID+".db")
+		if r.Archive {
+			err = archiveSession(dir, path)
+		} else {
+			err = DeleteSession(dir, s.ID)
+		}
+		if err != nil {
+			return pruned, fmt.Errorf("prune session %s: %w", s.ID, err)
+		}
+	}
+	return pruned, nil
+}
+
+func archiveSession(dir, path string) error {
+	archive := filepath.Join(dir, ArchiveDir)
+	if err := os.MkdirAll(archive, 0o755); err != nil {
+		return fmt.Errorf("create archive dir: %w", err)
+	}
+	return os.Rename(path, filepath.Join(archive, filepath.Base(path)))
+}
+
+// lastActive returns the time of the latest record of the session at path,
+// its start when it has none and the file's modification time when it
+// cannot be read.
+func lastActive(path string) time.Time {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return modTime
+	}
+	defer db.Close()
+
+	// MAX() loses the column's DATETIME type, so order instead
+	var last time.Time
+	err = db.QueryRow(`SELECT ts FROM records ORDER BY ts DESC LIMIT 1`).Scan(&last)
+	if err == nil {
+		return last
+	}
+	err = db.QueryRow(`SELECT start_time FROM contexts ORDER BY start_time DESC LIMIT 1`).Scan(&last)
+	if err == nil {
+		return last
+	}
+	return modTime
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSession creates a session whose only record is at ts.
+func newTestSession(t *testing.T, dir, id string, ts time.Time) {
+	t.Helper()
+	db, err := NewSession(dir, id)
+	require.NoError(t, err)
+	defer db.Close()
+	ctx, err := CreateContext(t.Context(), db, id)
+	require.NoError(t, err)
+	rec, err := InsertRecord(t.Context(), db, ctx.ID, Prompt, "hi", true)
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE records SET ts = ? WHERE id = ?`, ts.UTC(), rec.ID)
+	require.NoError(t, err)
+}
+
+func prunedIDs(pruned []PrunedSession) []string {
+	var ids []string
+	for _, p := range pruned {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestPruneSessions(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	newTestSession(t, dir, "old", now.AddDate(0, 0, -40))
+	newTestSession(t, dir, "mid", now.AddDate(0, 0, -10))
+	newTestSession(t, dir, "new", now.Add(-time.Hour))
+
+	pruned, err := PruneSessions(dir, Retention{MaxAgeDays: 30}, now, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old"}, prunedIDs(pruned))
+	assert.FileExists(t, filepath.Join(dir, "old.db"))
+
+	pruned, err = PruneSessions(dir, Retention{MaxAgeDays: 30, KeepLast: 1}, now, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old", "mid"}, prunedIDs(pruned))
+
+	sessions, err := ListSessions(t.Context(), dir)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "new", sessions[0].ID)
+}
+
+func TestPruneSessions_Archive(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	newTestSession(t, dir, "old", now.AddDate(0, 0, -40))
+
+	_, err := PruneSessions(dir, Retention{MaxAgeDays: 30, Archive: true}, now, false)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "old.db"))
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, filepath.Join(dir, ArchiveDir, "old.db"))
+}
+
+func TestPruneSessions_NoLimits(t *testing.T) {
+	dir := t.TempDir()
+	newTestSession(t, dir, "old", time.Now().AddDate(-1, 0, 0))
+
+	pruned, err := PruneSessions(dir, Retention{}, time.Now(), false)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+	assert.FileExists(t, filepath.Join(dir, "old.db"))
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchedulesFile is the database, next to the config file, the recurring
+// tasks the API server runs are kept in.
+const SchedulesFile = "schedules.db"
+
+// ErrScheduleNotFound is returned when no schedule has the given ID
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// Schedule is a prompt run as a job in a repository at the times of a cron
+// expression
+type Schedule struct {
+	ID     string `json:"id"`
+	Cron   string `json:"cron"`
+	Prompt string `json:"prompt"`
+	// Absolute path of the repository the job runs in
+	Repo      string    `json:"repo"`
+	CreatedAt time.Time `json:"created_at"`
+	// Outcome of the latest run, which recorded its conversation in
+	// LastSession
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastSession string     `json:"last_session,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// OpenSchedules opens the schedules database at path, creating it if needed.
+func OpenSchedules(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create config dir: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("open schedules: %w", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS schedules (
+			id           TEXT PRIMARY KEY,
+			cron         TEXT NOT NULL,
+			prompt       TEXT NOT NULL,
+			repo         TEXT NOT NULL,
+			created_at   DATETIME NOT NULL,
+			last_run     DATETIME,
+			last_session TEXT NOT NULL DEFAULT '',
+			last_error   TEXT NOT NULL DEFAULT ''
+		);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schedules table: %w", err)
+	}
+	return db, nil
+}
+
+// CreateSchedule adds a schedule running prompt in repo, which is made
+// absolute, at the times of cron. The expression is not checked here.
+func CreateSchedule(ctx context.Context, db *sql.DB, cron, prompt, repo string) (Schedule, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	repo, err := filepath.Abs(repo)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("resolve schedule repo: %w", err)
+	}
+	// Short enough to type in `tinker schedule rm`
+	s := Schedule{ID: uuid.NewString()[:8], Cron: cron, Prompt: prompt, Repo: repo, CreatedAt: time.Now().UTC()}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO schedules (id, cron, prompt, repo, created_at) VALUES (?, ?, ?, ?, ?)`,
+		s.ID, s.Cron, s.Prompt, s.Repo, s.CreatedAt,
+	)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("create schedule: %w", err)
+	}
+	return s, nil
+}
+
+// ListSchedules returns every schedule, oldest first.
+func ListSchedules(ctx context.Context, db *sql.DB) ([]Schedule, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, cron, prompt, repo, created_at, last_run, last_session, last_error FROM schedules ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("query schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []Schedule
+	for rows.Next() {
+		var s Schedule
+		var lastRun sql.NullTime
+		if err := rows.Scan(&s.ID, &s.Cron, &s.Prompt, &s.Repo, &s.CreatedAt, &lastRun, &s.LastSession, &s.LastError); err != nil {
+			return nil, fmt.Errorf("scan schedule: %w", err)
+		}
+		if lastRun.Valid {
+			s.LastRun = &lastRun.Time
+		}
+		schedules = append(schedules, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("schedules rows: %w", err)
+	}
+	return schedules, nil
+}
+
+// RecordScheduleRun saves the outcome of a run of the schedule id at at,
+// the session it recorded and its error, "" if it succeeded.
+func RecordScheduleRun(ctx context.Context, db *sql.DB, id string, at time.Time, sessionID, runErr string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx,
+		`UPDATE schedules SET last_run = ?, last_session = ?, last_error = ? WHERE id = ?`,
+		at.UTC(), sessionID, runErr, id,
+	)
+	if err != nil {
+		return fmt.Errorf("record schedule run: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	return nil
+}
+
+// DeleteSchedule removes the schedule with the given ID.
+func DeleteSchedule(ctx context.Context, db *sql.DB, id string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete schedule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	return nil
+}
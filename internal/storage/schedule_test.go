@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedules_CRUD(t *testing.T) {
+	db, err := OpenSchedules(filepath.Join(t.TempDir(), SchedulesFile))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := t.TempDir()
+	s, err := CreateSchedule(t.Context(), db, "0 7 * * *", "update deps and open a PR", repo)
+	require.NoError(t, err)
+	assert.Len(t, s.ID, 8)
+
+	schedules, err := ListSchedules(t.Context(), db)
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, repo, schedules[0].Repo)
+	assert.Nil(t, schedules[0].LastRun)
+
+	at := time.Date(2026, 10, 14, 7, 0, 0, 0, time.UTC)
+	require.NoError(t, RecordScheduleRun(t.Context(), db, s.ID, at, "session-1", ""))
+	schedules, err = ListSchedules(t.Context(), db)
+	require.NoError(t, err)
+	require.NotNil(t, schedules[0].LastRun)
+	assert.True(t, at.Equal(*schedules[0].LastRun))
+	assert.Equal(t, "session-1", schedules[0].LastSession)
+
+	require.NoError(t, DeleteSchedule(t.Context(), db, s.ID))
+	err = DeleteSchedule(t.Context(), db, s.ID)
+	assert.True(t, errors.Is(err, ErrScheduleNotFound))
+	err = RecordScheduleRun(t.Context(), db, s.ID, at, "", "")
+	assert.True(t, errors.Is(err, ErrScheduleNotFound))
+}
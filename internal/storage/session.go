@@ -1,15 +1,36 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrInvalidSessionID is returned for an ID naming a file outside the
+// sessions directory
+var ErrInvalidSessionID = errors.New("invalid session id")
+
+// ValidSessionID reports whether id names a session file inside its
+// sessions directory, a single path element not starting with a dot.
+func ValidSessionID(id string) bool {
+	return id == filepath.Base(id) && !strings.HasPrefix(id, ".")
+}
+
+// SessionPath is the database of session id in dir.
+func SessionPath(dir, id string) (string, error) {
+	if !ValidSessionID(id) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSessionID, id)
+	}
+	return filepath.Join(dir, id+".db"), nil
+}
+
 // OpenSession opens a database (a session) to store context window in.
 // NOTE: LLM conversations are stored in SQLite.
 // If you don't care about persistent storage for your context,
@@ -20,10 +41,13 @@ func OpenSession(dir, id string) (*sql.DB, error) {
 	if dir == ":memory:" {
 		dbPath = ":memory:"
 	} else {
+		var err error
+		if dbPath, err = SessionPath(dir, id); err != nil {
+			return nil, err
+		}
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return nil, fmt.Errorf("create sessions dir: %w", err)
 		}
-		dbPath = filepath.Join(dir, id+".db")
 	}
 
 	db, err := sql.Open("sqlite3", dbPath)
@@ -31,6 +55,12 @@ func OpenSession(dir, id string) (*sql.DB, error) {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
 
+	// Sessions created by older versions may miss newer tables
+	if err = initializeSchema(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
+
 	return db, nil
 }
 
@@ -41,10 +71,13 @@ func NewSession(dir, id string) (*sql.DB, error) {
 	if dir == ":memory:" {
 		dbPath = ":memory:"
 	} else {
+		var err error
+		if dbPath, err = SessionPath(dir, id); err != nil {
+			return nil, err
+		}
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return nil, fmt.Errorf("create sessions dir: %w", err)
 		}
-		dbPath = filepath.Join(dir, id+".db")
 	}
 
 	db, err := sql.Open("sqlite3", dbPath)
@@ -88,9 +121,45 @@ func initializeSchema(db *sql.DB) error {
 			FOREIGN KEY (context_id) REFERENCES contexts(id) ON DELETE CASCADE,
 			UNIQUE(context_id, tool_name)
 		);
+
+		CREATE TABLE IF NOT EXISTS turn_stats (
+			id            INTEGER PRIMARY KEY AUTOINCREMENT,
+			context_id    TEXT NOT NULL,
+			ts            DATETIME NOT NULL,
+			ttft_ms       INTEGER NOT NULL,
+			duration_ms   INTEGER NOT NULL,
+			input_tokens  INTEGER NOT NULL,
+			output_tokens INTEGER NOT NULL,
+			FOREIGN KEY (context_id) REFERENCES contexts(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS usage (
+			day           TEXT NOT NULL,
+			model         TEXT NOT NULL,
+			input_tokens  INTEGER NOT NULL DEFAULT 0,
+			output_tokens INTEGER NOT NULL DEFAULT 0,
+			cost          REAL NOT NULL DEFAULT 0,
+			turns         INTEGER NOT NULL DEFAULT 0,
+			tool_calls    INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (day, model)
+		);
+
+		CREATE TABLE IF NOT EXISTS tool_calls (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts          DATETIME NOT NULL,
+			tool        TEXT NOT NULL,
+			failed      BOOLEAN NOT NULL,
+			duration_ms INTEGER NOT NULL
+		);
 `
 
-	_, err := db.Exec(baseTables)
+	// Only sessions written by older versions have anything worth backing up
+	existing, err := hasColumn(db, "records", "id")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(baseTables)
 	if err != nil {
 		return fmt.Errorf("create base tables: %w", err)
 	}
@@ -99,16 +168,148 @@ func initializeSchema(db *sql.DB) error {
 		CREATE INDEX IF NOT EXISTS idx_context_live ON records(context_id, live);
 		CREATE INDEX IF NOT EXISTS idx_context_ts ON records(context_id, ts);
 		CREATE INDEX IF NOT EXISTS idx_context_tools_context ON context_tools(context_id);
+		CREATE INDEX IF NOT EXISTS idx_turn_stats_context ON turn_stats(context_id);
 `
 	_, err = db.Exec(indexes)
 	if err != nil {
 		return fmt.Errorf("create indexes: %w", err)
 	}
 
+	var missing []addedColumn
+	for _, c := range addedColumns {
+		ok, err := hasColumn(db, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Keep a copy of the session as it was before it is altered
+	if existing {
+		if _, err := backupBeforeMigration(db); err != nil {
+			return err
+		}
+	}
+	for _, c := range missing {
+		_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, c.decl))
+		if err != nil {
+			return fmt.Errorf("add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	return nil
+}
+
+type addedColumn struct {
+	table, column, decl string
+}
+
+// Columns added after the base tables shipped
+var addedColumns = []addedColumn{
+	{"records", "status", "TEXT NOT NULL DEFAULT ''"},
+	{"records", "summary", "TEXT NOT NULL DEFAULT ''"},
+	{"records", "output", "TEXT NOT NULL DEFAULT ''"},
+	{"records", "input_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"records", "output_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"contexts", "work_dir", "TEXT NOT NULL DEFAULT ''"},
+	{"contexts", "project_id", "TEXT NOT NULL DEFAULT ''"},
+	{"usage", "cached_tokens", "INTEGER NOT NULL DEFAULT 0"},
+	{"usage", "cache_savings", "REAL NOT NULL DEFAULT 0"},
+}
+
+// openSessionForReading opens the session at path to list or show it,
+// without writing to it. Columns added since an older version wrote the
+// session read as their defaults, the session is only migrated when it is
+// opened to be written.
+func openSessionForReading(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	// The views standing in for old tables only exist on the connection
+	// that created them
+	db.SetMaxOpenConns(1)
+	if err := viewMissingColumns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// viewMissingColumns shadows each table that misses some of addedColumns
+// with a temporary view of it adding them at their default.
+func viewMissingColumns(db *sql.DB) error {
+	var tables []string
+	defaults := map[string][]string{}
+	for _, c := range addedColumns {
+		ok, err := hasColumn(db, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if _, seen := defaults[c.table]; !seen {
+			tables = append(tables, c.table)
+		}
+		_, dflt, _ := strings.Cut(c.decl, "DEFAULT ")
+		defaults[c.table] = append(defaults[c.table], dflt+" AS "+c.column)
+	}
+
+	for _, table := range tables {
+		// Tables older versions did not have at all stay missing
+		var n int
+		if err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n); err != nil {
+			return fmt.Errorf("inspect %s: %w", table, err)
+		}
+		if n == 0 {
+			continue
+		}
+		_, err := db.Exec(fmt.Sprintf(`CREATE TEMP VIEW %s AS SELECT *, %s FROM main.%s`,
+			table, strings.Join(defaults[table], ", "), table))
+		if err != nil {
+			return fmt.Errorf("view %s: %w", table, err)
+		}
+	}
 	return nil
 }
 
-func ListSessions(dir string) ([]*Session, error) {
+// hasColumn reports whether table already has column.
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			ctype   string
+			notNull bool
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			return false, fmt.Errorf("scan %s columns: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("%s columns rows: %w", table, err)
+	}
+	return false, nil
+}
+
+func ListSessions(ctx context.Context, dir string) ([]*Session, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -126,53 +327,69 @@ func ListSessions(dir string) ([]*Session, error) {
 		id := strings.TrimSuffix(entry.Name(), ".db")
 		dbPath := filepath.Join(dir, entry.Name())
 
-		ss, err := sql.Open("sqlite3", dbPath)
+		// A session that cannot be read is still listed, so it can be deleted
+		session := &Session{ID: id}
+		sessions = append(sessions, session)
+
+		ss, err := openSessionForReading(dbPath)
 		if err != nil {
 			continue
 		}
-
-		ctxs, err := ListContexts(ss)
+		ctxs, err := ListContexts(ctx, ss)
+		ss.Close()
 		if err != nil {
-			ss.Close()
 			continue
 		}
 
-		session := &Session{
-			ID:           id,
-			ContextCount: len(ctxs),
-		}
+		session.ContextCount = len(ctxs)
 		if len(ctxs) > 0 {
 			session.Name = ctxs[0].Name
 			session.StartTime = ctxs[0].StartTime.Format("2006-01-02T15:04:05Z")
 		}
-
-		ss.Close()
-		sessions = append(sessions, session)
 	}
 
 	return sessions, nil
 }
 
-func GetSession(dir, id string) (*Session, error) {
-	dbPath := filepath.Join(dir, id+".db")
+func GetSession(ctx context.Context, dir, id string) (*Session, error) {
+	return getSession(ctx, dir, id, ListLiveRecords)
+}
+
+// GetSessionHistory returns session id with every record it ever held, those
+// no longer sent to the model included, in time order.
+func GetSessionHistory(ctx context.Context, dir, id string) (*Session, error) {
+	s, err := getSession(ctx, dir, id, ListRecords)
+	if err != nil {
+		return nil, err
+	}
+	// Records of the contexts are listed one context after the other
+	slices.SortStableFunc(s.Records, func(a, b Record) int { return a.Timestamp.Compare(b.Timestamp) })
+	return s, nil
+}
+
+func getSession(ctx context.Context, dir, id string, list func(context.Context, *sql.DB, string) ([]Record, error)) (*Session, error) {
+	dbPath, err := SessionPath(dir, id)
+	if err != nil {
+		return nil, err
+	}
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("session %s not found: %w", id, err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := openSessionForReading(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
-
 	defer db.Close()
-	contexts, err := ListContexts(db)
+
+	contexts, err := ListContexts(ctx, db)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list contexts: %w", err)
 	}
 
 	var allRecords []Record
-	for _, ctx := range contexts {
-		records, err := ListLiveRecords(db, ctx.ID)
+	for _, c := range contexts {
+		records, err := list(ctx, db, c.ID)
 		if err != nil {
 			continue
 		}
@@ -187,7 +404,10 @@ func GetSession(dir, id string) (*Session, error) {
 }
 
 func DeleteSession(dir, id string) error {
-	dbPath := filepath.Join(dir, id+".db")
+	dbPath, err := SessionPath(dir, id)
+	if err != nil {
+		return err
+	}
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		return fmt.Errorf("session %s not found: %w", id, err)
 	}
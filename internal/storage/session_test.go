@@ -45,7 +45,7 @@ func TestOpenSession_ReopensExistingDB(t *testing.T) {
 	if err != nil {
 		t.Fatalf("first open: %v", err)
 	}
-	_, err = CreateContext(db, "test-context")
+	_, err = CreateContext(t.Context(), db, "test-context")
 	if err != nil {
 		t.Fatalf("create context: %v", err)
 	}
@@ -58,12 +58,46 @@ func TestOpenSession_ReopensExistingDB(t *testing.T) {
 	}
 	defer db.Close()
 
-	_, err = GetContextByName(db, "test-context")
+	_, err = GetContextByName(t.Context(), db, "test-context")
 	if err != nil {
 		t.Fatalf("context not found after reopen: %v", err)
 	}
 }
 
+func TestOpenSession_MigratesOldSchema(t *testing.T) {
+	dir := t.TempDir()
+	id := "old"
+
+	// Records table as shipped before the status column existed
+	db, err := sql.Open("sqlite3", filepath.Join(dir, id+".db"))
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE records (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		context_id TEXT NOT NULL,
+		ts DATETIME NOT NULL,
+		source INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		live BOOLEAN NOT NULL,
+		est_tokens INTEGER NOT NULL
+	)`)
+	require.NoError(t, err)
+	db.Close()
+
+	db, err = OpenSession(dir, id)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, err := CreateContext(t.Context(), db, "migrated")
+	require.NoError(t, err)
+	_, err = InsertRecordWithStatus(t.Context(), db, ctx.ID, ToolUse, "bash()", true, StatusOK)
+	require.NoError(t, err)
+
+	records, err := ListLiveRecords(t.Context(), db, ctx.ID)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, StatusOK, records[0].Status)
+}
+
 func TestListSessions(t *testing.T) {
 	dir := t.TempDir()
 	id := "1234567890"
@@ -72,7 +106,7 @@ func TestListSessions(t *testing.T) {
 
 	seedSession(t, s, "sess-1")
 
-	sessions, err := ListSessions(dir)
+	sessions, err := ListSessions(t.Context(), dir)
 	require.NoError(t, err)
 	assert.Len(t, sessions, 1)
 }
@@ -84,11 +118,21 @@ func TestListSessions_Empty(t *testing.T) {
 	require.NoError(t, err)
 	db.Close()
 
-	sessions, err := ListSessions(dir)
+	sessions, err := ListSessions(t.Context(), dir)
 	require.NoError(t, err)
 	assert.Len(t, sessions, 1)
 }
 
+func TestListSessions_Unreadable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.db"), []byte("not a database"), 0o644))
+
+	sessions, err := ListSessions(t.Context(), dir)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "broken", sessions[0].ID)
+}
+
 func TestGetSession(t *testing.T) {
 	dir := t.TempDir()
 	id := "1234567890"
@@ -97,22 +141,63 @@ func TestGetSession(t *testing.T) {
 
 	seedSession(t, s, "sess-1")
 
-	detail, err := GetSession(dir, id)
+	detail, err := GetSession(t.Context(), dir, id)
 	require.NoError(t, err)
 	assert.Equal(t, id, detail.ID)
 	assert.NotEmpty(t, detail.Contexts)
 }
 
+func TestGetSessionHistory_IncludesCompactedRecords(t *testing.T) {
+	dir := t.TempDir()
+	id := "1234567890"
+	s, err := NewSession(dir, id)
+	require.NoError(t, err)
+
+	c, err := CreateContext(t.Context(), s, "test-context")
+	require.NoError(t, err)
+	_, err = InsertRecord(t.Context(), s, c.ID, Prompt, "compacted away", false)
+	require.NoError(t, err)
+	_, err = InsertRecord(t.Context(), s, c.ID, Prompt, "hello", true)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	live, err := GetSession(t.Context(), dir, id)
+	require.NoError(t, err)
+	require.Len(t, live.Records, 1)
+
+	history, err := GetSessionHistory(t.Context(), dir, id)
+	require.NoError(t, err)
+	require.Len(t, history.Records, 2)
+	assert.Equal(t, "compacted away", history.Records[0].Content)
+	assert.Equal(t, "hello", history.Records[1].Content)
+}
+
 func TestGetSession_NotFound(t *testing.T) {
 	dir := t.TempDir()
-	_, err := NewSession(dir, "")
+	_, err := NewSession(dir, "existing")
 	require.NoError(t, err)
 
-	_, err = GetSession(dir, "nonexistent")
+	_, err = GetSession(t.Context(), dir, "nonexistent")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "not found")
 }
 
+func TestSession_InvalidID(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "alice")
+	for _, id := range []string{"../bob/secret", "..", ".hidden", "a/b", ""} {
+		assert.False(t, ValidSessionID(id), id)
+		_, err := NewSession(dir, id)
+		assert.ErrorIs(t, err, ErrInvalidSessionID, id)
+		_, err = OpenSession(dir, id)
+		assert.ErrorIs(t, err, ErrInvalidSessionID, id)
+		_, err = GetSession(t.Context(), dir, id)
+		assert.ErrorIs(t, err, ErrInvalidSessionID, id)
+		assert.ErrorIs(t, DeleteSession(dir, id), ErrInvalidSessionID, id)
+	}
+	assert.NoDirExists(t, filepath.Join(filepath.Dir(dir), "bob"))
+	assert.True(t, ValidSessionID("0b6e2f64-7f1c-4f0e-9a55-3c1d2b7e8a90"))
+}
+
 func TestDeleteSession(t *testing.T) {
 	dir := t.TempDir()
 	id := "1234567890"
@@ -124,7 +209,7 @@ func TestDeleteSession(t *testing.T) {
 	err = DeleteSession(dir, id)
 	require.NoError(t, err)
 
-	_, err = GetSession(dir, id)
+	_, err = GetSession(t.Context(), dir, id)
 	assert.Error(t, err)
 }
 
@@ -142,10 +227,10 @@ func TestDeleteSession_NotFound(t *testing.T) {
 func seedSession(t *testing.T, db *sql.DB, id string) {
 	t.Helper()
 
-	_, err := CreateContext(db, "test-context")
+	_, err := CreateContext(t.Context(), db, "test-context")
 	require.NoError(t, err)
 
-	_, err = InsertRecord(db, "test-context", Prompt, "hello", true)
+	_, err = InsertRecord(t.Context(), db, "test-context", Prompt, "hello", true)
 	require.NoError(t, err)
 	require.NoError(t, db.Close())
 }
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TurnStats captures latency and throughput of a single model turn
+type TurnStats struct {
+	ID        int64     `json:"id"`
+	ContextID string    `json:"context_id"`
+	Timestamp time.Time `json:"timestamp"`
+	// Time until the provider returned its first response.
+	// Calls are not streamed, so this is the latency of the first round trip.
+	TimeToFirstToken time.Duration `json:"time_to_first_token"`
+	// Wall-clock time of the whole turn, tool-use loop included
+	Duration     time.Duration `json:"duration"`
+	InputTokens  int           `json:"input_tokens"`
+	OutputTokens int           `json:"output_tokens"`
+}
+
+// TokensPerSecond returns the output throughput of the turn.
+func (s TurnStats) TokensPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.OutputTokens) / s.Duration.Seconds()
+}
+
+// String renders the stats as a compact one-line summary.
+func (s TurnStats) String() string {
+	return fmt.Sprintf("%.1fs · first token %.1fs · %d tokens · %.1f tok/s",
+		s.Duration.Seconds(),
+		s.TimeToFirstToken.Seconds(),
+		s.OutputTokens,
+		s.TokensPerSecond(),
+	)
+}
+
+// StatsSummary aggregates turn stats across contexts and sessions
+type StatsSummary struct {
+	Turns               int           `json:"turns"`
+	InputTokens         int           `json:"input_tokens"`
+	OutputTokens        int           `json:"output_tokens"`
+	AvgTimeToFirstToken time.Duration `json:"avg_time_to_first_token"`
+	AvgDuration         time.Duration `json:"avg_duration"`
+	AvgTokensPerSecond  float64       `json:"avg_tokens_per_second"`
+}
+
+// InsertTurnStats records the stats of a finished turn
+func InsertTurnStats(ctx context.Context, db *sql.DB, contextID string, stats TurnStats) (TurnStats, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO turn_stats (context_id, ts, ttft_ms, duration_ms, input_tokens, output_tokens)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		contextID, now,
+		stats.TimeToFirstToken.Milliseconds(),
+		stats.Duration.Milliseconds(),
+		stats.InputTokens,
+		stats.OutputTokens,
+	)
+	if err != nil {
+		return TurnStats{}, fmt.Errorf("insert turn stats: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return TurnStats{}, fmt.Errorf("get last insert id: %w", err)
+	}
+
+	stats.ID = id
+	stats.ContextID = contextID
+	stats.Timestamp = now
+	return stats, nil
+}
+
+// ListTurnStats returns the stats of all turns in a context in timestamp order
+func ListTurnStats(ctx context.Context, db *sql.DB, contextID string) ([]TurnStats, error) {
+	return listTurnStatsWhere(ctx, db, "context_id = ?", contextID)
+}
+
+func listTurnStatsWhere(ctx context.Context, db *sql.DB, whereClause string, args ...any) ([]TurnStats, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		SELECT id, context_id, ts, ttft_ms, duration_ms, input_tokens, output_tokens
+		 FROM turn_stats WHERE %s ORDER BY ts ASC
+		`, whereClause,
+	)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query turn stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []TurnStats
+	for rows.Next() {
+		var s TurnStats
+		var ttftMs, durationMs int64
+		if err := rows.Scan(
+			&s.ID,
+			&s.ContextID,
+			&s.Timestamp,
+			&ttftMs,
+			&durationMs,
+			&s.InputTokens,
+			&s.OutputTokens,
+		); err != nil {
+			return nil, fmt.Errorf("scan turn stats: %w", err)
+		}
+		s.TimeToFirstToken = time.Duration(ttftMs) * time.Millisecond
+		s.Duration = time.Duration(durationMs) * time.Millisecond
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("turn stats rows: %w", err)
+	}
+	return stats, nil
+}
+
+// Summarize aggregates a list of turn stats.
+func Summarize(stats []TurnStats) StatsSummary {
+	var sum StatsSummary
+	if len(stats) == 0 {
+		return sum
+	}
+
+	var ttft, duration time.Duration
+	var tps float64
+	for _, s := range stats {
+		sum.Turns++
+		sum.InputTokens += s.InputTokens
+		sum.OutputTokens += s.OutputTokens
+		ttft += s.TimeToFirstToken
+		duration += s.Duration
+		tps += s.TokensPerSecond()
+	}
+
+	n := time.Duration(sum.Turns)
+	sum.AvgTimeToFirstToken = ttft / n
+	sum.AvgDuration = duration / n
+	sum.AvgTokensPerSecond = tps / float64(sum.Turns)
+	return sum
+}
+
+// SummarizeSessions aggregates turn stats across every session in dir.
+// Sessions that cannot be read are skipped, as in ListSessions.
+func SummarizeSessions(ctx context.Context, dir string) (StatsSummary, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return StatsSummary{}, fmt.Errorf("session directory does not exist: %w", err)
+		}
+		return StatsSummary{}, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	var all []TurnStats
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".db") {
+			continue
+		}
+
+		ss, err := sql.Open("sqlite3", filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		stats, err := listTurnStatsWhere(ctx, ss, "1 = 1")
+		ss.Close()
+		if err != nil {
+			continue
+		}
+		all = append(all, stats...)
+	}
+
+	return Summarize(all), nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInsertAndListTurnStats(t *testing.T) {
+	db := newTestDB(t)
+
+	ctx, err := CreateContext(t.Context(), db, "stats-ctx")
+	if err != nil {
+		t.Fatalf("create context: %v", err)
+	}
+
+	_, err = InsertTurnStats(t.Context(), db, ctx.ID, TurnStats{
+		TimeToFirstToken: 500 * time.Millisecond,
+		Duration:         2 * time.Second,
+		InputTokens:      100,
+		OutputTokens:     40,
+	})
+	if err != nil {
+		t.Fatalf("insert turn stats: %v", err)
+	}
+
+	stats, err := ListTurnStats(t.Context(), db, ctx.ID)
+	if err != nil {
+		t.Fatalf("list turn stats: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 turn, got %d", len(stats))
+	}
+	if stats[0].Duration != 2*time.Second {
+		t.Errorf("expected duration 2s, got %v", stats[0].Duration)
+	}
+	if stats[0].TimeToFirstToken != 500*time.Millisecond {
+		t.Errorf("expected ttft 500ms, got %v", stats[0].TimeToFirstToken)
+	}
+	if got := stats[0].TokensPerSecond(); got != 20 {
+		t.Errorf("expected 20 tok/s, got %v", got)
+	}
+}
+
+func TestTokensPerSecond_ZeroDuration(t *testing.T) {
+	s := TurnStats{OutputTokens: 10}
+	if got := s.TokensPerSecond(); got != 0 {
+		t.Errorf("expected 0 tok/s, got %v", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	summary := Summarize([]TurnStats{
+		{TimeToFirstToken: time.Second, Duration: 2 * time.Second, OutputTokens: 20},
+		{TimeToFirstToken: 3 * time.Second, Duration: 4 * time.Second, OutputTokens: 80},
+	})
+
+	if summary.Turns != 2 {
+		t.Errorf("expected 2 turns, got %d", summary.Turns)
+	}
+	if summary.OutputTokens != 100 {
+		t.Errorf("expected 100 output tokens, got %d", summary.OutputTokens)
+	}
+	if summary.AvgTimeToFirstToken != 2*time.Second {
+		t.Errorf("expected avg ttft 2s, got %v", summary.AvgTimeToFirstToken)
+	}
+	if summary.AvgDuration != 3*time.Second {
+		t.Errorf("expected avg duration 3s, got %v", summary.AvgDuration)
+	}
+	if summary.AvgTokensPerSecond != 15 {
+		t.Errorf("expected avg 15 tok/s, got %v", summary.AvgTokensPerSecond)
+	}
+}
+
+func TestSummarizeSessions(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, id := range []string{"a", "b"} {
+		db, err := NewSession(dir, id)
+		if err != nil {
+			t.Fatalf("new session: %v", err)
+		}
+		ctx, err := CreateContext(t.Context(), db, "ctx-"+id)
+		if err != nil {
+			t.Fatalf("create context: %v", err)
+		}
+		if _, err := InsertTurnStats(t.Context(), db, ctx.ID, TurnStats{Duration: time.Second, OutputTokens: 10}); err != nil {
+			t.Fatalf("insert turn stats: %v", err)
+		}
+		db.Close()
+	}
+
+	summary, err := SummarizeSessions(t.Context(), dir)
+	if err != nil {
+		t.Fatalf("summarize sessions: %v", err)
+	}
+	if summary.Turns != 2 {
+		t.Errorf("expected 2 turns, got %d", summary.Turns)
+	}
+	if summary.OutputTokens != 20 {
+		t.Errorf("expected 20 output tokens, got %d", summary.OutputTokens)
+	}
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ToolCall is one invocation of a tool, as kept for metrics
+type ToolCall struct {
+	Tool     string
+	Failed   bool
+	Duration time.Duration
+}
+
+// ToolStats aggregates the invocations of one tool
+type ToolStats struct {
+	Tool          string        `json:"tool"`
+	Calls         int           `json:"calls"`
+	Failures      int           `json:"failures"`
+	MedianLatency time.Duration `json:"median_latency"`
+}
+
+// FailureRate returns the share of calls that failed, between 0 and 1.
+func (s ToolStats) FailureRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Calls)
+}
+
+// RecordToolCall keeps a tool invocation made at ts.
+func RecordToolCall(ctx context.Context, db *sql.DB, ts time.Time, c ToolCall) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO tool_calls (ts, tool, failed, duration_ms) VALUES (?, ?, ?, ?)`,
+		ts.UTC(), c.Tool, c.Failed, c.Duration.Milliseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("record tool call: %w", err)
+	}
+	return nil
+}
+
+// ListToolCalls returns the tool invocations of a session in the order made
+func ListToolCalls(ctx context.Context, db *sql.DB) ([]ToolCall, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `SELECT tool, failed, duration_ms FROM tool_calls ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("query tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []ToolCall
+	for rows.Next() {
+		var c ToolCall
+		var ms int64
+		if err := rows.Scan(&c.Tool, &c.Failed, &ms); err != nil {
+			return nil, fmt.Errorf("scan tool call: %w", err)
+		}
+		c.Duration = time.Duration(ms) * time.Millisecond
+		calls = append(calls, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("tool call rows: %w", err)
+	}
+	return calls, nil
+}
+
+// SummarizeToolCalls aggregates calls per tool, the most called first.
+func SummarizeToolCalls(calls []ToolCall) []ToolStats {
+	latencies := map[string][]time.Duration{}
+	stats := map[string]*ToolStats{}
+	for _, c := range calls {
+		s, ok := stats[c.Tool]
+		if !ok {
+			s = &ToolStats{Tool: c.Tool}
+			stats[c.Tool] = s
+		}
+		s.Calls++
+		if c.Failed {
+			s.Failures++
+		}
+		latencies[c.Tool] = append(latencies[c.Tool], c.Duration)
+	}
+
+	out := make([]ToolStats, 0, len(stats))
+	for tool, s := range stats {
+		s.MedianLatency = median(latencies[tool])
+		out = append(out, *s)
+	}
+	slices.SortFunc(out, func(a, b ToolStats) int {
+		return cmp.Or(cmp.Compare(b.Calls, a.Calls), cmp.Compare(a.Tool, b.Tool))
+	})
+	return out
+}
+
+func median(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	slices.Sort(ds)
+	mid := len(ds) / 2
+	if len(ds)%2 == 0 {
+		return (ds[mid-1] + ds[mid]) / 2
+	}
+	return ds[mid]
+}
+
+// ToolStatsSessions aggregates tool calls across every session in dir.
+// Sessions that cannot be read are skipped, as in ListSessions.
+func ToolStatsSessions(ctx context.Context, dir string) ([]ToolStats, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session directory does not exist: %w", err)
+		}
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	var all []ToolCall
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".db") {
+			continue
+		}
+
+		ss, err := sql.Open("sqlite3", filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		calls, err := ListToolCalls(ctx, ss)
+		ss.Close()
+		if err != nil {
+			continue
+		}
+		all = append(all, calls...)
+	}
+
+	return SummarizeToolCalls(all), nil
+}
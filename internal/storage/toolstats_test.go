@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeToolCalls(t *testing.T) {
+	db := newTestDB(t)
+
+	now := time.Now()
+	for _, c := range []ToolCall{
+		{Tool: "read_file", Duration: 10 * time.Millisecond},
+		{Tool: "bash", Duration: 300 * time.Millisecond},
+		{Tool: "read_file", Duration: 30 * time.Millisecond},
+		{Tool: "read_file", Failed: true, Duration: 20 * time.Millisecond},
+		{Tool: "bash", Failed: true, Duration: 100 * time.Millisecond},
+	} {
+		if err := RecordToolCall(t.Context(), db, now, c); err != nil {
+			t.Fatalf("record tool call: %v", err)
+		}
+	}
+
+	calls, err := ListToolCalls(t.Context(), db)
+	if err != nil {
+		t.Fatalf("list tool calls: %v", err)
+	}
+	stats := SummarizeToolCalls(calls)
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 tools, got %+v", stats)
+	}
+
+	read, bash := stats[0], stats[1]
+	if read.Tool != "read_file" || read.Calls != 3 || read.Failures != 1 || read.MedianLatency != 20*time.Millisecond {
+		t.Errorf("unexpected read_file stats: %+v", read)
+	}
+	if bash.Tool != "bash" || bash.Calls != 2 || bash.FailureRate() != 0.5 || bash.MedianLatency != 200*time.Millisecond {
+		t.Errorf("unexpected bash stats: %+v", bash)
+	}
+}
@@ -16,6 +16,18 @@ type Record struct {
 	// Estimated number of tokens, counted by built-in tokenizer
 	EstTokens int    `json:"est_tokens"`
 	ContextID string `json:"context_id"`
+	// Outcome of a tool invocation, empty for other record types
+	Status string `json:"status,omitempty"`
+	// Human-readable line describing a tool invocation, empty for other record types
+	Summary string `json:"summary,omitempty"`
+	// Raw tool output, kept for inspection only and never sent back to the model
+	Output string `json:"output,omitempty"`
+	// Tokens the provider counted for the request that produced the record,
+	// its prompt and its completion, set on the first record of a request
+	// only. Summed over a turn they are its usage, and those of the latest
+	// request size the context without asking the provider again.
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
 }
 
 // Context represents a named context window with metadata
@@ -23,6 +35,10 @@ type Context struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	StartTime time.Time `json:"start_time"`
+	// Directory the conversation started in, which relative paths in it refer to
+	WorkDir string `json:"work_dir,omitempty"`
+	// ID of the project the directory belongs to, empty outside any
+	ProjectID string `json:"project_id,omitempty"`
 }
 
 // ContextTool represents a tool available in a specific context
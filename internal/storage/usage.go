@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// usageDayFormat keys usage rows by calendar day (UTC)
+const usageDayFormat = "2006-01-02"
+
+// Usage is the aggregated usage of one model on one day
+type Usage struct {
+	Day          string  `json:"day"`
+	Model        string  `json:"model"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+	Turns        int     `json:"turns"`
+	ToolCalls    int     `json:"tool_calls"`
+	// Input tokens read from a provider cache, counted in InputTokens too
+	CachedTokens int `json:"cached_tokens"`
+	// USD the cached tokens saved, already taken off Cost
+	CacheSavings float64 `json:"cache_savings"`
+}
+
+// TotalTokens returns input and output tokens combined.
+func (u Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// RecordUsage adds a turn's usage to the row of its day and model.
+// Day is derived from ts so callers don't have to agree on a format.
+func RecordUsage(ctx context.Context, db *sql.DB, ts time.Time, u Usage) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	day := ts.UTC().Format(usageDayFormat)
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO usage (day, model, input_tokens, output_tokens, cost, turns, tool_calls, cached_tokens, cache_savings)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(day, model) DO UPDATE SET
+			input_tokens  = input_tokens + excluded.input_tokens,
+			output_tokens = output_tokens + excluded.output_tokens,
+			cost          = cost + excluded.cost,
+			turns         = turns + excluded.turns,
+			tool_calls    = tool_calls + excluded.tool_calls,
+			cached_tokens = cached_tokens + excluded.cached_tokens,
+			cache_savings = cache_savings + excluded.cache_savings`,
+		day, u.Model, u.InputTokens, u.OutputTokens, u.Cost, u.Turns, u.ToolCalls, u.CachedTokens, u.CacheSavings,
+	)
+	if err != nil {
+		return fmt.Errorf("record usage: %w", err)
+	}
+	return nil
+}
+
+// ListUsage returns all usage rows ordered by day, then model
+func ListUsage(ctx context.Context, db *sql.DB) ([]Usage, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT day, model, input_tokens, output_tokens, cost, turns, tool_calls, cached_tokens, cache_savings
+		 FROM usage ORDER BY day ASC, model ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []Usage
+	for rows.Next() {
+		var u Usage
+		if err := rows.Scan(
+			&u.Day,
+			&u.Model,
+			&u.InputTokens,
+			&u.OutputTokens,
+			&u.Cost,
+			&u.Turns,
+			&u.ToolCalls,
+			&u.CachedTokens,
+			&u.CacheSavings,
+		); err != nil {
+			return nil, fmt.Errorf("scan usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("usage rows: %w", err)
+	}
+	return usage, nil
+}
+
+// MergeUsage sums usage rows sharing the same day and model.
+func MergeUsage(rows []Usage) []Usage {
+	type key struct{ day, model string }
+	merged := make(map[key]*Usage)
+	for _, u := range rows {
+		k := key{u.Day, u.Model}
+		m, ok := merged[k]
+		if !ok {
+			m = &Usage{Day: u.Day, Model: u.Model}
+			merged[k] = m
+		}
+		m.InputTokens += u.InputTokens
+		m.OutputTokens += u.OutputTokens
+		m.Cost += u.Cost
+		m.Turns += u.Turns
+		m.ToolCalls += u.ToolCalls
+		m.CachedTokens += u.CachedTokens
+		m.CacheSavings += u.CacheSavings
+	}
+
+	out := make([]Usage, 0, len(merged))
+	for _, m := range merged {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Day != out[j].Day {
+			return out[i].Day < out[j].Day
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// UsageSessions aggregates usage across every session in dir.
+// Sessions that cannot be read are skipped, as in ListSessions.
+func UsageSessions(ctx context.Context, dir string) ([]Usage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session directory does not exist: %w", err)
+		}
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	var all []Usage
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".db") {
+			continue
+		}
+
+		ss, err := sql.Open("sqlite3", filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		usage, err := ListUsage(ctx, ss)
+		ss.Close()
+		if err != nil {
+			continue
+		}
+		all = append(all, usage...)
+	}
+
+	return MergeUsage(all), nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordUsage_AccumulatesPerDayAndModel(t *testing.T) {
+	db := newTestDB(t)
+
+	day := time.Date(2026, 4, 1, 10, 0, 0, 0, time.UTC)
+	for range 2 {
+		err := RecordUsage(t.Context(), db, day, Usage{Model: "m1", InputTokens: 10, OutputTokens: 5, Cost: 0.5, Turns: 1, ToolCalls: 2, CachedTokens: 4, CacheSavings: 0.25})
+		if err != nil {
+			t.Fatalf("record usage: %v", err)
+		}
+	}
+	if err := RecordUsage(t.Context(), db, day, Usage{Model: "m2", InputTokens: 1, Turns: 1}); err != nil {
+		t.Fatalf("record usage: %v", err)
+	}
+	if err := RecordUsage(t.Context(), db, day.AddDate(0, 0, 1), Usage{Model: "m1", InputTokens: 3, Turns: 1}); err != nil {
+		t.Fatalf("record usage: %v", err)
+	}
+
+	usage, err := ListUsage(t.Context(), db)
+	if err != nil {
+		t.Fatalf("list usage: %v", err)
+	}
+	if len(usage) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(usage))
+	}
+
+	first := usage[0]
+	if first.Day != "2026-04-01" || first.Model != "m1" {
+		t.Fatalf("unexpected first row: %+v", first)
+	}
+	if first.InputTokens != 20 || first.OutputTokens != 10 || first.Turns != 2 || first.ToolCalls != 4 {
+		t.Errorf("usage not accumulated: %+v", first)
+	}
+	if first.Cost != 1 {
+		t.Errorf("expected cost 1, got %v", first.Cost)
+	}
+	if first.CachedTokens != 8 || first.CacheSavings != 0.5 {
+		t.Errorf("cache usage not accumulated: %+v", first)
+	}
+}
+
+func TestMergeUsage(t *testing.T) {
+	merged := MergeUsage([]Usage{
+		{Day: "2026-04-02", Model: "m1", InputTokens: 1},
+		{Day: "2026-04-01", Model: "m1", InputTokens: 2},
+		{Day: "2026-04-02", Model: "m1", InputTokens: 3},
+	})
+
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(merged))
+	}
+	if merged[0].Day != "2026-04-01" {
+		t.Errorf("expected rows sorted by day, got %q first", merged[0].Day)
+	}
+	if merged[1].InputTokens != 4 {
+		t.Errorf("expected merged input tokens 4, got %d", merged[1].InputTokens)
+	}
+}
@@ -0,0 +1,242 @@
+// Package telemetry records which features are used and how they fail,
+// only after the user opts in. Events stay in a local SQLite database and
+// are sent to an endpoint only if the user configures one.
+//
+// Events are anonymized: they hold command and flag names, never flag
+// values, prompts, paths or error details beyond the failing step.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Config is the user's telemetry choice
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Optional URL events are POSTed to as JSON
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Event is one command invocation
+type Event struct {
+	Time    time.Time `json:"time"`
+	Feature string    `json:"feature"`
+	// Failing step, e.g. "create model", empty on success
+	Error   string `json:"error,omitempty"`
+	Version string `json:"version"`
+}
+
+// Recorder stores events in dir, holding config.json and telemetry.db
+type Recorder struct {
+	dir  string
+	http *http.Client
+}
+
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{dir: dir, http: &http.Client{Timeout: 2 * time.Second}}
+}
+
+// DefaultDir is ~/.tinker/telemetry.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinker", "telemetry"), nil
+}
+
+// Config returns the saved choice, disabled when none was made.
+func (r *Recorder) Config() (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filepath.Join(r.dir, "config.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("read telemetry config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse telemetry config: %w", err)
+	}
+	return cfg, nil
+}
+
+func (r *Recorder) SaveConfig(cfg Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal telemetry config: %w", err)
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return fmt.Errorf("create telemetry dir: %w", err)
+	}
+	return os.WriteFile(filepath.Join(r.dir, "config.json"), data, 0o644)
+}
+
+// Record stores the outcome of feature if telemetry is enabled, and sends it
+// to the configured endpoint.
+func (r *Recorder) Record(ctx context.Context, feature string, runErr error, version string) error {
+	cfg, err := r.Config()
+	if err != nil || !cfg.Enabled {
+		return err
+	}
+
+	ev := Event{Time: time.Now().UTC(), Feature: feature, Error: ErrorStep(runErr), Version: version}
+
+	db, err := r.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`INSERT INTO events (time, feature, error, version) VALUES (?, ?, ?, ?)`,
+		ev.Time, ev.Feature, ev.Error, ev.Version)
+	if err != nil {
+		return fmt.Errorf("insert telemetry event: %w", err)
+	}
+
+	if cfg.Endpoint != "" {
+		return r.send(ctx, cfg.Endpoint, ev)
+	}
+	return nil
+}
+
+func (r *Recorder) send(ctx context.Context, endpoint string, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("send telemetry: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("send telemetry: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("send telemetry: %s", resp.Status)
+	}
+	return nil
+}
+
+// FeatureStats aggregates the events of one feature
+type FeatureStats struct {
+	Feature  string
+	Uses     int
+	Failures int
+}
+
+// ErrorStats counts one failing step
+type ErrorStats struct {
+	Error string
+	Count int
+}
+
+// Summary aggregates all recorded events, most used features and most
+// frequent errors first.
+func (r *Recorder) Summary() ([]FeatureStats, []ErrorStats, error) {
+	db, err := r.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT feature, COUNT(*), SUM(error != '') FROM events
+		GROUP BY feature ORDER BY COUNT(*) DESC, feature`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("summarize features: %w", err)
+	}
+	defer rows.Close()
+
+	var features []FeatureStats
+	for rows.Next() {
+		var f FeatureStats
+		if err := rows.Scan(&f.Feature, &f.Uses, &f.Failures); err != nil {
+			return nil, nil, fmt.Errorf("summarize features: %w", err)
+		}
+		features = append(features, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("summarize features: %w", err)
+	}
+
+	errRows, err := db.Query(`SELECT error, COUNT(*) FROM events WHERE error != ''
+		GROUP BY error ORDER BY COUNT(*) DESC, error LIMIT 10`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("summarize errors: %w", err)
+	}
+	defer errRows.Close()
+
+	var errs []ErrorStats
+	for errRows.Next() {
+		var e ErrorStats
+		if err := errRows.Scan(&e.Error, &e.Count); err != nil {
+			return nil, nil, fmt.Errorf("summarize errors: %w", err)
+		}
+		errs = append(errs, e)
+	}
+	return features, errs, errRows.Err()
+}
+
+// Clear deletes every recorded event.
+func (r *Recorder) Clear() error {
+	err := os.Remove(filepath.Join(r.dir, "telemetry.db"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (r *Recorder) open() (*sql.DB, error) {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create telemetry dir: %w", err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(r.dir, "telemetry.db"))
+	if err != nil {
+		return nil, fmt.Errorf("open telemetry db: %w", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time DATETIME NOT NULL,
+		feature TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		version TEXT NOT NULL DEFAULT ''
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init telemetry schema: %w", err)
+	}
+	return db, nil
+}
+
+// quoted matches user-supplied values quoted in error messages
+var quoted = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+
+// ErrorStep keeps the outermost context of a wrapped error, e.g. "create model"
+// for "create model: ANTHROPIC_API_KEY not set". Errors in this codebase are
+// wrapped as "step: cause", and the causes are what may hold paths or prompts.
+// Quoted values are dropped as well.
+func ErrorStep(err error) string {
+	if err == nil {
+		return ""
+	}
+	step, _, _ := strings.Cut(err.Error(), ":")
+	return strings.TrimSpace(quoted.ReplaceAllString(step, "…"))
+}
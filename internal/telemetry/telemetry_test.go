@@ -0,0 +1,71 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_DisabledByDefault(t *testing.T) {
+	r := NewRecorder(t.TempDir())
+
+	require.NoError(t, r.Record(context.Background(), "run", nil, "0.2.6"))
+
+	features, _, err := r.Summary()
+	require.NoError(t, err)
+	assert.Empty(t, features)
+}
+
+func TestRecord_Summary(t *testing.T) {
+	r := NewRecorder(t.TempDir())
+	require.NoError(t, r.SaveConfig(Config{Enabled: true}))
+	ctx := context.Background()
+
+	require.NoError(t, r.Record(ctx, "run", nil, "0.2.6"))
+	require.NoError(t, r.Record(ctx, "run", errors.New("create model: ANTHROPIC_API_KEY not set"), "0.2.6"))
+	require.NoError(t, r.Record(ctx, "review --json", nil, "0.2.6"))
+
+	features, errs, err := r.Summary()
+	require.NoError(t, err)
+	assert.Equal(t, []FeatureStats{
+		{Feature: "run", Uses: 2, Failures: 1},
+		{Feature: "review --json", Uses: 1},
+	}, features)
+	assert.Equal(t, []ErrorStats{{Error: "create model", Count: 1}}, errs)
+
+	require.NoError(t, r.Clear())
+	features, _, err = r.Summary()
+	require.NoError(t, err)
+	assert.Empty(t, features)
+}
+
+func TestRecord_SendsToEndpoint(t *testing.T) {
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	r := NewRecorder(t.TempDir())
+	require.NoError(t, r.SaveConfig(Config{Enabled: true, Endpoint: srv.URL}))
+
+	err := r.Record(context.Background(), "batch", errors.New("read batch file: open /home/me/secret.yaml: no such file"), "0.2.6")
+	require.NoError(t, err)
+
+	assert.Equal(t, "batch", got.Feature)
+	assert.Equal(t, "read batch file", got.Error)
+}
+
+func TestErrorStep(t *testing.T) {
+	assert.Equal(t, "", ErrorStep(nil))
+	assert.Equal(t, "nothing staged to commit", ErrorStep(errors.New("nothing staged to commit")))
+	assert.Equal(t, "model call", ErrorStep(errors.New("model call: context deadline exceeded")))
+	assert.Equal(t, "unknown command … for …", ErrorStep(errors.New(`unknown command "fix my repo" for "tinker"`)))
+}
@@ -0,0 +1,24 @@
+package tools
+
+import "fmt"
+
+// Aliases maps the former names of renamed tools to their current ones, so
+// conversations and fixtures recorded before a rename still resolve. Renaming
+// a tool adds its old name here instead of breaking them.
+var Aliases = map[string]string{}
+
+// Resolve returns the current name of a tool and whether name is a former
+// one. Current and unknown names are returned as they are.
+func Resolve(name string) (string, bool) {
+	current, ok := Aliases[name]
+	if !ok {
+		return name, false
+	}
+	return current, true
+}
+
+// DeprecationNote tells the model that it called a tool by a former name,
+// so it uses the current one from then on.
+func DeprecationNote(alias, name string) string {
+	return fmt.Sprintf("Note: the %s tool was renamed to %s. Call %s from now on.", alias, name, name)
+}
@@ -6,15 +6,12 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
 )
 
 //go:embed bash.md
 var bashPrompt string
 
-const maxOutputLines = 200
-
 type BashInput struct {
 	Command string `json:"command" jsonschema_description:"The bash command to execute."`
 }
@@ -26,6 +23,7 @@ var BashDefinition = ToolDefinition{
 	Description: bashPrompt,
 	InputSchema: BashInputSchema,
 	Function:    RunBashTool,
+	Annotations: Annotations{Destructive: true, OpenWorld: true},
 }
 
 func RunBashTool(ctx context.Context, args json.RawMessage) (string, error) {
@@ -34,19 +32,14 @@ func RunBashTool(ctx context.Context, args json.RawMessage) (string, error) {
 		return "", fmt.Errorf("parse bash input: %w", err)
 	}
 
-	cmd := exec.Command("bash", "-c", bashInput.Command)
+	cmd := command(ctx, "bash", "-c", bashInput.Command)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Sprintf("Command failed with error: %s\nOutput: %s", err.Error(), string(output)), nil
 	}
 
-	result := strings.TrimSpace(string(output))
-	lines := strings.Split(result, "\n")
-	if len(lines) > maxOutputLines {
-		truncated := len(lines) - maxOutputLines
-		result = fmt.Sprintf("(%d lines truncated)\n%s", truncated, strings.Join(lines[truncated:], "\n"))
-	}
-
-	return result, err
+	// Outputs too large for the context are stored as artifacts by the
+	// context window, see ContextWindow.SetArtifacts
+	return strings.TrimSpace(string(output)), err
 }
@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Chunks longer than this are split, so one oversized function cannot take
+// the whole budget of a search
+const maxChunkLines = 120
+
+// Chunk is a span of a file that reads on its own: a declaration, a class
+// or, for files without known boundaries, a window of lines.
+type Chunk struct {
+	// 1-indexed lines, both included
+	StartLine int
+	EndLine   int
+	// Declaration the chunk holds, empty for windows
+	Symbol string
+}
+
+// definitionLine matches the lines starting a top-level definition in the
+// languages without a parser here, by file extension
+var definitionLine = map[string]*regexp.Regexp{
+	".py":   regexp.MustCompile(`^(?:async\s+def|def|class)\s+(\w+)`),
+	".rb":   regexp.MustCompile(`^(?:def|class|module)\s+([\w.:]+)`),
+	".rs":   regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:fn|struct|enum|trait|impl|mod)\s+(?:<[^>]*>\s*)?(\w+)`),
+	".js":   jsDefinition,
+	".jsx":  jsDefinition,
+	".ts":   jsDefinition,
+	".tsx":  jsDefinition,
+	".java": jvmDefinition,
+	".kt":   jvmDefinition,
+	".cs":   jvmDefinition,
+	".c":    cDefinition,
+	".h":    cDefinition,
+	".cc":   cDefinition,
+	".cpp":  cDefinition,
+	".hpp":  cDefinition,
+}
+
+var (
+	jsDefinition  = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?(?:function\*?|class|interface|type|enum|const|let)\s+(\w+)`)
+	jvmDefinition = regexp.MustCompile(`^\s{0,4}(?:(?:public|private|protected|internal|static|final|abstract|open|data|sealed|override|suspend)\s+)*(?:class|interface|enum|record|object|fun|void|[\w<>\[\],]+)\s+(\w+)\s*[({<:]`)
+	cDefinition   = regexp.MustCompile(`^(?:struct|class|enum|union|typedef|namespace|[\w:*&<>]+(?:\s+[\w:*&<>]+)*)\s+\**(\w+)\s*[({]`)
+)
+
+// ChunkFile splits the content of the file at path on the boundaries of its
+// declarations: Go files are parsed, files of other known languages split
+// where a top-level definition starts, and other files make windows of
+// maxChunkLines lines.
+func ChunkFile(path string, content []byte) []Chunk {
+	lines := strings.Count(string(content), "\n") + 1
+	ext := strings.ToLower(filepath.Ext(path))
+
+	var chunks []Chunk
+	if ext == ".go" {
+		chunks = goChunks(content)
+	} else if re, ok := definitionLine[ext]; ok {
+		chunks = definitionChunks(string(content), re)
+	}
+	return splitChunks(fillChunks(chunks, lines))
+}
+
+// goChunks makes a chunk of every top-level declaration with its doc comment.
+func goChunks(content []byte) []Chunk {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	var chunks []Chunk
+	for _, decl := range f.Decls {
+		start := decl.Pos()
+		var symbol string
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			symbol = "func " + d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				symbol = "func (" + receiverType(d.Recv.List[0].Type) + ") " + d.Name.Name
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			symbol = d.Tok.String()
+			if len(d.Specs) == 1 {
+				switch s := d.Specs[0].(type) {
+				case *ast.TypeSpec:
+					symbol += " " + s.Name.Name
+				case *ast.ValueSpec:
+					symbol += " " + s.Names[0].Name
+				}
+			}
+		}
+		chunks = append(chunks, Chunk{
+			StartLine: fset.Position(start).Line,
+			EndLine:   fset.Position(decl.End()).Line,
+			Symbol:    symbol,
+		})
+	}
+	return chunks
+}
+
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+// definitionChunks starts a chunk at every line re matches, keeping the
+// comments and decorators right above it in the chunk.
+func definitionChunks(content string, re *regexp.Regexp) []Chunk {
+	lines := strings.Split(content, "\n")
+	var chunks []Chunk
+	for i, line := range lines {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		start := i
+		for start > 0 && isPreamble(lines[start-1]) {
+			start--
+		}
+		if n := len(chunks); n > 0 {
+			if start+1 <= chunks[n-1].StartLine {
+				start = chunks[n-1].StartLine
+			}
+			chunks[n-1].EndLine = start
+		}
+		chunks = append(chunks, Chunk{StartLine: start + 1, EndLine: len(lines), Symbol: m[1]})
+	}
+	return chunks
+}
+
+// isPreamble reports whether a line belongs to the definition below it.
+func isPreamble(line string) bool {
+	line = strings.TrimSpace(line)
+	for _, p := range []string{"#", "//", "/*", "*", "@", "///"} {
+		if strings.HasPrefix(line, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// fillChunks covers the lines between chunks, such as imports, with chunks
+// of their own, so every line of the file belongs to one.
+func fillChunks(chunks []Chunk, lines int) []Chunk {
+	var filled []Chunk
+	next := 1
+	for _, c := range chunks {
+		if c.StartLine < next {
+			c.StartLine = next
+		}
+		if c.EndLine < c.StartLine {
+			continue
+		}
+		if c.StartLine > next {
+			filled = append(filled, Chunk{StartLine: next, EndLine: c.StartLine - 1})
+		}
+		filled = append(filled, c)
+		next = c.EndLine + 1
+	}
+	if next <= lines {
+		filled = append(filled, Chunk{StartLine: next, EndLine: lines})
+	}
+	return filled
+}
+
+// splitChunks cuts the chunks longer than maxChunkLines into windows.
+func splitChunks(chunks []Chunk) []Chunk {
+	var split []Chunk
+	for _, c := range chunks {
+		for c.EndLine-c.StartLine+1 > maxChunkLines {
+			split = append(split, Chunk{StartLine: c.StartLine, EndLine: c.StartLine + maxChunkLines - 1, Symbol: c.Symbol})
+			c.StartLine += maxChunkLines
+		}
+		split = append(split, c)
+	}
+	return split
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chunkGoSource = `package config
+
+import "os"
+
+// Config is read from the environment
+type Config struct {
+	Home string
+}
+
+// Load reads the config.
+func Load() Config {
+	return Config{Home: os.Getenv("HOME")}
+}
+
+func (c *Config) Validate() error {
+	return nil
+}
+`
+
+func TestChunkFile_Go(t *testing.T) {
+	chunks := ChunkFile("config.go", []byte(chunkGoSource))
+
+	assert.Equal(t, []Chunk{
+		{StartLine: 1, EndLine: 2},
+		{StartLine: 3, EndLine: 3, Symbol: "import"},
+		{StartLine: 4, EndLine: 4},
+		{StartLine: 5, EndLine: 8, Symbol: "type Config"},
+		{StartLine: 9, EndLine: 9},
+		{StartLine: 10, EndLine: 13, Symbol: "func Load"},
+		{StartLine: 14, EndLine: 14},
+		{StartLine: 15, EndLine: 17, Symbol: "func (*Config) Validate"},
+		{StartLine: 18, EndLine: 18},
+	}, chunks)
+}
+
+func TestChunkFile_Python(t *testing.T) {
+	source := "import os\n\n@cache\ndef home():\n    return os.environ['HOME']\n\nclass Config:\n    pass\n"
+	chunks := ChunkFile("config.py", []byte(source))
+
+	assert.Equal(t, []Chunk{
+		{StartLine: 1, EndLine: 2},
+		{StartLine: 3, EndLine: 6, Symbol: "home"},
+		{StartLine: 7, EndLine: 9, Symbol: "Config"},
+	}, chunks)
+}
+
+func TestChunkFile_SplitsLongChunks(t *testing.T) {
+	source := strings.Repeat("plain text\n", 300)
+	chunks := ChunkFile("notes.txt", []byte(source))
+
+	require.Len(t, chunks, 3)
+	assert.Equal(t, Chunk{StartLine: 1, EndLine: maxChunkLines}, chunks[0])
+	assert.Equal(t, Chunk{StartLine: 2*maxChunkLines + 1, EndLine: 301}, chunks[2])
+}
+
+func TestChunkResults_GroupsMatchesByChunk(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("config.go", []byte(chunkGoSource), 0o644))
+
+	// Two matches in Load, one in Config
+	output := "./config.go:6:type Config struct {\n./config.go:11:func Load() Config {\n./config.go:12:\treturn Config{Home: os.Getenv(\"HOME\")}\n"
+	result := chunkResults(t.Context(), output)
+
+	assert.True(t, strings.HasPrefix(result, "config.go:10-13 (func Load)\n10: // Load reads the config.\n"), result)
+	assert.Contains(t, result, "config.go:5-8 (type Config)\n5: // Config is read from the environment\n")
+	assert.NotContains(t, result, "Validate")
+	assert.Equal(t, `Find "config" (2 results)`, FormatResult(ToolNameFinder, []byte(`{"query": "config"}`), result, nil))
+}
+
+func TestChunkResults_StaysWithinBudget(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var output strings.Builder
+	for i := range 100 {
+		name := fmt.Sprintf("file%d.txt", i)
+		require.NoError(t, os.WriteFile(filepath.Join(".", name), []byte(strings.Repeat("needle in a haystack\n", 100)), 0o644))
+		fmt.Fprintf(&output, "./%s:1:needle in a haystack\n", name)
+	}
+
+	result := chunkResults(t.Context(), output.String())
+	assert.LessOrEqual(t, len(result), maxFinderBytes+4096)
+	assert.Contains(t, result, "more chunks with matches: ")
+}
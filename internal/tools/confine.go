@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// pathArgs are the input fields the built-in tools read paths from
+var pathArgs = []string{"path", "directory"}
+
+// Confine returns toolset restricted to the directory root: paths are resolved
+// against root and refused outside it, and bash, which no path check can
+// contain, is left out. The tools still run in the process's working
+// directory, which callers set to root for tools such as finder that take no
+// path.
+func Confine(toolset []ToolDefinition, root string) []ToolDefinition {
+	root = resolvedPath(root)
+
+	confined := slices.DeleteFunc(slices.Clone(toolset), func(t ToolDefinition) bool {
+		return t.Name == ToolNameBash
+	})
+	for i, t := range confined {
+		run := t.Function
+		confined[i].Function = func(ctx context.Context, args json.RawMessage) (string, error) {
+			args, err := confineArgs(args, root)
+			if err != nil {
+				return "", err
+			}
+			return run(ctx, args)
+		}
+	}
+	return confined
+}
+
+// confineArgs makes the path arguments of args absolute under root.
+func confineArgs(args json.RawMessage, root string) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(args, &fields); err != nil {
+		// The tool reports malformed input itself
+		return args, nil
+	}
+
+	changed := false
+	for _, name := range pathArgs {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var p string
+		if err := json.Unmarshal(raw, &p); err != nil {
+			continue
+		}
+		p, err := ConfinedPath(root, p)
+		if err != nil {
+			return nil, err
+		}
+		fields[name], _ = json.Marshal(p)
+		changed = true
+	}
+	if !changed {
+		return args, nil
+	}
+	return json.Marshal(fields)
+}
+
+// ConfinedPath resolves p, relative to root unless absolute, and fails if it
+// is outside root once symlinks are followed.
+func ConfinedPath(root, p string) (string, error) {
+	root = resolvedPath(root)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	p = resolvedPath(p)
+	if rel, err := filepath.Rel(root, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the workspace %s", p, root)
+	}
+	return p, nil
+}
+
+// resolvedPath cleans p and follows its symlinks, so a link inside the
+// workspace cannot point out of it. Paths that do not exist yet, such as
+// files about to be created, are resolved through their nearest existing
+// parent.
+func resolvedPath(p string) string {
+	p = filepath.Clean(p)
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	parent := filepath.Dir(p)
+	if parent == p {
+		return p
+	}
+	return filepath.Join(resolvedPath(parent), filepath.Base(p))
+}
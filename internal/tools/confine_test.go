@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func confinedTool(t *testing.T, name string, root string) ToolDefinition {
+	t.Helper()
+	for _, def := range Confine(Builtin, root) {
+		if def.Name == name {
+			return def
+		}
+	}
+	t.Fatalf("tool %s not in confined toolset", name)
+	return ToolDefinition{}
+}
+
+func TestConfine_DropsBash(t *testing.T) {
+	for _, def := range Confine(Builtin, t.TempDir()) {
+		assert.NotEqual(t, ToolNameBash, def.Name)
+	}
+}
+
+func TestConfine_ResolvesPathsUnderRoot(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0o644))
+
+	out, err := confinedTool(t, ToolNameReadFile, root).Function(context.Background(), json.RawMessage(`{"path": "a.txt"}`))
+	require.NoError(t, err)
+	assert.Contains(t, out, "hello")
+}
+
+func TestConfine_RefusesPathsOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "link")))
+
+	readFile := confinedTool(t, ToolNameReadFile, root)
+	for _, path := range []string{
+		filepath.Join(outside, "secret.txt"),
+		"../" + filepath.Base(outside) + "/secret.txt",
+		"link/secret.txt",
+	} {
+		args, _ := json.Marshal(map[string]string{"path": path})
+		_, err := readFile.Function(context.Background(), args)
+		assert.ErrorContains(t, err, "outside the workspace", path)
+	}
+
+	_, err := confinedTool(t, ToolNameGrepSearch, root).Function(context.Background(), json.RawMessage(`{"pattern": "x", "directory": "/"}`))
+	assert.ErrorContains(t, err, "outside the workspace")
+}
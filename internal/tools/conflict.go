@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lines of the current content shown on each side of the likely target of
+// a conflicting edit
+const conflictContext = 20
+
+// EditConflict is the error of an edit whose old_str is not in the file as it
+// is now, because the file changed since the model read it or the model
+// misremembers it. The file is left as is, and the current content of the
+// region the edit targeted is returned so the model can plan it again.
+type EditConflict struct {
+	Path string `json:"path"`
+	// 1-indexed lines of Current in the file
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+	// The lines as they are now, numbered like read_file output
+	Current string `json:"current"`
+}
+
+func (e *EditConflict) Error() string {
+	return fmt.Sprintf("edit conflict: old_str not found in file %s, nothing was changed. "+
+		"Lines %d-%d as they are now follow, plan the edit again from them:\n%s",
+		e.Path, e.StartLine, e.EndLine, e.Current)
+}
+
+// editConflict returns the conflict of replacing old in content, showing the
+// lines around the first line of old that is still in the file, or the
+// start of the file if none is.
+func editConflict(path, content, old string) *EditConflict {
+	lines := strings.Split(content, "\n")
+	target := 0
+	for _, want := range strings.Split(old, "\n") {
+		want = strings.TrimSpace(want)
+		if want == "" {
+			continue
+		}
+		if i := lineContaining(lines, want); i >= 0 {
+			target = i
+			break
+		}
+	}
+
+	start := max(target-conflictContext, 0)
+	end := min(target+conflictContext+1, len(lines))
+	var sb strings.Builder
+	for i := start; i < end; i++ {
+		fmt.Fprintf(&sb, "%d: %s\n", i+1, lines[i])
+	}
+	return &EditConflict{Path: path, StartLine: start + 1, EndLine: end, Current: sb.String()}
+}
+
+func lineContaining(lines []string, s string) int {
+	for i, line := range lines {
+		if strings.Contains(line, s) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Docker is a workspace in a container, reached with the docker client.
+// Bash commands then only reach what the container does.
+type Docker struct {
+	// Name or ID of a running container
+	Container string
+	// Directory in the container the tools run in
+	Directory string
+	// User the tools run as, the container's default when empty
+	User string
+	// Remove the container with Close, for containers StartContainer started
+	started bool
+}
+
+// StartContainer starts a container of image with the directory repo of this
+// machine mounted at dir, for the tools to run in until Close.
+func StartContainer(ctx context.Context, image, repo, dir, user string) (*Docker, error) {
+	args := []string{"run", "--detach", "--rm", "--init", "--volume", repo + ":" + dir, "--workdir", dir}
+	if user != "" {
+		args = append(args, "--user", user)
+	}
+	// Images whose entrypoint runs a server or exits would not stay up for the tools
+	args = append(args, "--entrypoint", "tail", image, "-f", "/dev/null")
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("start container of %s: %s", image, msg)
+		}
+		return nil, fmt.Errorf("start container of %s: %w", image, err)
+	}
+	return &Docker{Container: strings.TrimSpace(string(out)), Directory: dir, User: user, started: true}, nil
+}
+
+func (d *Docker) Dir() string { return d.Directory }
+
+func (d *Docker) String() string {
+	name := d.Container
+	if d.started {
+		// Started containers are only known by their long ID
+		name = name[:min(len(name), 12)]
+	}
+	return "container " + name + ":" + d.Directory
+}
+
+func (d *Docker) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	dockerArgs := []string{"exec", "--interactive", "--workdir", d.Directory}
+	if d.User != "" {
+		dockerArgs = append(dockerArgs, "--user", d.User)
+	}
+	dockerArgs = append(dockerArgs, d.Container, name)
+	return exec.CommandContext(ctx, "docker", append(dockerArgs, args...)...)
+}
+
+// Close removes the container if StartContainer started it.
+func (d *Docker) Close() error {
+	if !d.started {
+		return nil
+	}
+	if out, err := exec.Command("docker", "rm", "--force", d.Container).CombinedOutput(); err != nil {
+		return fmt.Errorf("remove container %s: %w: %s", d, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
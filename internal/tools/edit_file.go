@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path"
 	"strings"
 )
 
@@ -39,13 +38,14 @@ func RunEditFileTool(ctx context.Context, args json.RawMessage) (string, error)
 		return "", fmt.Errorf("invalid input parameters")
 	}
 
-	content, err := os.ReadFile(editFileInput.Path)
+	content, err := readFile(ctx, editFileInput.Path)
 	if err != nil {
 		if os.IsNotExist(err) && editFileInput.OldStr == "" {
-			result, err := createNewFile(editFileInput.Path, editFileInput.NewStr)
+			result, err := createNewFile(ctx, editFileInput.Path, editFileInput.NewStr)
 			if err != nil {
 				return "", fmt.Errorf("error cannot create new file: %w", err)
 			}
+			reportEdit(ctx, FileEdit{Path: editFileInput.Path, NewText: editFileInput.NewStr, Created: true})
 			return result, nil
 		}
 		return "", fmt.Errorf("error reading file: %w", err)
@@ -55,29 +55,23 @@ func RunEditFileTool(ctx context.Context, args json.RawMessage) (string, error)
 	newContent := strings.ReplaceAll(oldContent, editFileInput.OldStr, editFileInput.NewStr)
 
 	if oldContent == newContent && editFileInput.OldStr != "" {
-		return "", fmt.Errorf("old_str not found in file")
+		return "", editConflict(editFileInput.Path, oldContent, editFileInput.OldStr)
 	}
 
-	err = os.WriteFile(editFileInput.Path, []byte(newContent), 0o644)
+	err = writeFile(ctx, editFileInput.Path, []byte(newContent))
 	if err != nil {
 		return "", err
 	}
+	for _, e := range replacementEdits(editFileInput.Path, oldContent, editFileInput.OldStr, editFileInput.NewStr) {
+		reportEdit(ctx, e)
+	}
 
 	return "OK", nil
 }
 
-func createNewFile(filePath, content string) (string, error) {
-	dir := path.Dir(filePath)
-	if dir != "." {
-		// Default permission for dir
-		err := os.MkdirAll(dir, 0o755)
-		if err != nil {
-			return "", fmt.Errorf("failed to create directory: %w", err)
-		}
-	}
-
-	// Permission to read and write file
-	err := os.WriteFile(filePath, []byte(content), 0o644)
+func createNewFile(ctx context.Context, filePath, content string) (string, error) {
+	// Creates the missing directories of the path too
+	err := writeFile(ctx, filePath, []byte(content))
 	if err != nil {
 		return "", fmt.Errorf("failed to create file: %w", err)
 	}
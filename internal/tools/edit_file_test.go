@@ -3,11 +3,15 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"unicode/utf16"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Helper functions for edit_file tests
@@ -136,6 +140,31 @@ func TestEditFile_OldStrNotFound(t *testing.T) {
 	assert.Equal(t, content, string(originalContent))
 }
 
+func TestEditFile_ConflictShowsCurrentContent(t *testing.T) {
+	var content strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	filePath := createTestFileForEdit(t, content.String())
+
+	// Line 60 was changed since the model read it
+	input := EditFileInput{Path: filePath, OldStr: "line 59\nline 60 (old)\n", NewStr: "replacement"}
+	inputJSON, _ := json.Marshal(input)
+
+	_, err := RunEditFileTool(context.Background(), inputJSON)
+
+	var conflict *EditConflict
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, filePath, conflict.Path)
+	assert.Equal(t, 39, conflict.StartLine)
+	assert.Equal(t, 79, conflict.EndLine)
+	assert.True(t, strings.HasPrefix(conflict.Current, "39: line 39\n"))
+	assert.Contains(t, conflict.Current, "60: line 60\n")
+	assert.Contains(t, err.Error(), conflict.Current)
+	assert.Equal(t, "edit_file conflict: "+filePath+" is not as expected, nothing was edited",
+		FormatResult(ToolNameEditFile, inputJSON, "", err))
+}
+
 func TestEditFile_NonexistentFile(t *testing.T) {
 	input := EditFileInput{
 		Path:   "/nonexistent/path/file.txt",
@@ -385,3 +414,54 @@ func TestEditFile_VariousReplacements(t *testing.T) {
 		})
 	}
 }
+
+// applyEdits applies edits in order the way an editor applies them to a buffer.
+func applyEdits(t *testing.T, content string, edits []FileEdit) string {
+	t.Helper()
+	offset := func(p Position) int {
+		lines := strings.SplitAfter(content, "\n")
+		o := 0
+		for _, l := range lines[:p.Line] {
+			o += len(l)
+		}
+		units := utf16.Encode([]rune(lines[p.Line]))
+		return o + len(string(utf16.Decode(units[:p.Character])))
+	}
+	for _, e := range edits {
+		start, end := offset(e.Range.Start), offset(e.Range.End)
+		content = content[:start] + e.NewText + content[end:]
+	}
+	return content
+}
+
+func TestEditFile_ReportsEdits(t *testing.T) {
+	content := "héllo 👋 world\nsay world\n"
+	filePath := createTestFileForEdit(t, content)
+
+	var edits []FileEdit
+	ctx := WithEditReporter(context.Background(), func(e FileEdit) { edits = append(edits, e) })
+	inputJSON, _ := json.Marshal(EditFileInput{Path: filePath, OldStr: "world", NewStr: "there, world"})
+	_, err := RunEditFileTool(ctx, inputJSON)
+	assert.NoError(t, err)
+
+	if assert.Len(t, edits, 2) {
+		// Last first, so each range is valid once the previous edits are applied
+		assert.Equal(t, Range{Start: Position{Line: 1, Character: 4}, End: Position{Line: 1, Character: 9}}, edits[0].Range)
+		assert.Equal(t, Range{Start: Position{Line: 0, Character: 9}, End: Position{Line: 0, Character: 14}}, edits[1].Range)
+	}
+	written, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(written), applyEdits(t, content, edits))
+}
+
+func TestEditFile_ReportsCreatedFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "new.txt")
+
+	var edits []FileEdit
+	ctx := WithEditReporter(context.Background(), func(e FileEdit) { edits = append(edits, e) })
+	inputJSON, _ := json.Marshal(EditFileInput{Path: filePath, NewStr: "hello"})
+	_, err := RunEditFileTool(ctx, inputJSON)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []FileEdit{{Path: filePath, NewText: "hello", Created: true}}, edits)
+}
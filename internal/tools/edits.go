@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+// FileEdit is one change a tool made to a file, in the shape editors apply
+// edits to their buffers: NewText replaces Range.
+type FileEdit struct {
+	Path    string `json:"path"`
+	Range   Range  `json:"range"`
+	NewText string `json:"new_text"`
+	// The file did not exist before, Range is empty at its start
+	Created bool `json:"created,omitempty"`
+}
+
+// Range spans text between two positions, End excluded.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Position is a place in a file as editors count it: zero-based lines and
+// UTF-16 code units within the line, like the Language Server Protocol.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type editReporterKey struct{}
+
+// WithEditReporter makes the tools run with ctx report the edits they make
+// to fn as they write them.
+func WithEditReporter(ctx context.Context, fn func(FileEdit)) context.Context {
+	return context.WithValue(ctx, editReporterKey{}, fn)
+}
+
+func reportEdit(ctx context.Context, e FileEdit) {
+	if fn, ok := ctx.Value(editReporterKey{}).(func(FileEdit)); ok {
+		fn(e)
+	}
+}
+
+// replacementEdits returns the edits replacing every old in content with
+// new, last first, so each applies to the text the previous ones left.
+func replacementEdits(path, content, old, new string) []FileEdit {
+	if old == "" {
+		// Replacing nothing rewrites the whole file
+		return []FileEdit{{
+			Path:    path,
+			Range:   Range{End: position(content, len(content))},
+			NewText: strings.ReplaceAll(content, old, new),
+		}}
+	}
+	var edits []FileEdit
+	for offset := 0; ; {
+		i := strings.Index(content[offset:], old)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		end := start + len(old)
+		edits = append(edits, FileEdit{
+			Path:    path,
+			Range:   Range{Start: position(content, start), End: position(content, end)},
+			NewText: new,
+		})
+		offset = end
+	}
+	slices.Reverse(edits)
+	return edits
+}
+
+// position converts a byte offset in content to a Position.
+func position(content string, offset int) Position {
+	before := content[:offset]
+	line := strings.Count(before, "\n")
+	lineStart := strings.LastIndex(before, "\n") + 1
+	return Position{Line: line, Character: len(utf16.Encode([]rune(before[lineStart:])))}
+}
@@ -6,6 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -17,6 +20,7 @@ var FinderDefinition = ToolDefinition{
 	Description: finderPrompt,
 	InputSchema: FinderInputSchema,
 	Function:    RunFinderTool,
+	Annotations: Annotations{ReadOnly: true, Idempotent: true},
 }
 
 type FinderInput struct {
@@ -31,7 +35,12 @@ func RunFinderTool(ctx context.Context, args json.RawMessage) (string, error) {
 		return "", fmt.Errorf("failed to parse finder input: %w", err)
 	}
 
-	cmd := exec.Command("rg", "--no-heading", "--line-number", "--color", "never", "-e", finderInput.Query, ".")
+	ignoreArgs, err := rgIgnoreArgs(ctx)
+	if err != nil {
+		return "", err
+	}
+	searchArgs := append([]string{"--no-heading", "--line-number", "--color", "never"}, ignoreArgs...)
+	cmd := command(ctx, "rg", append(searchArgs, "-e", finderInput.Query, ".")...)
 	output, err := cmd.CombinedOutput()
 	result := string(output)
 
@@ -42,10 +51,95 @@ func RunFinderTool(ctx context.Context, args json.RawMessage) (string, error) {
 		return "", fmt.Errorf("search failed: %w", err)
 	}
 
-	lines := strings.Split(result, "\n")
-	if len(lines) > 100 {
-		result = strings.Join(lines[:100], "\n") + fmt.Sprintf("\n... (%d more results truncated)", len(lines)-100)
+	return chunkResults(ctx, result), nil
+}
+
+// The chunks of a search are cut once they take more than this many bytes,
+// about 6000 tokens
+const maxFinderBytes = 24 << 10
+
+var reMatchLine = regexp.MustCompile(`^(.+?):(\d+):`)
+
+// chunkResults replaces the matching lines of ripgrep with the chunks of the
+// files holding them, the chunks with the most matches first, so the model
+// reads whole declarations rather than lines out of context.
+func chunkResults(ctx context.Context, output string) string {
+	type hit struct {
+		path    string
+		chunk   Chunk
+		matches int
+	}
+	var hits []*hit
+	seen := map[string]*hit{}
+	files := map[string][]string{}
+	chunks := map[string][]Chunk{}
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		m := reMatchLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		path, n := m[1], atoi(m[2])
+		if _, ok := files[path]; !ok {
+			content, err := readFile(ctx, path)
+			if err != nil {
+				continue
+			}
+			files[path] = strings.Split(string(content), "\n")
+			chunks[path] = ChunkFile(path, content)
+		}
+		for _, c := range chunks[path] {
+			if n < c.StartLine || n > c.EndLine {
+				continue
+			}
+			key := fmt.Sprintf("%s:%d", path, c.StartLine)
+			if h, ok := seen[key]; ok {
+				h.matches++
+			} else {
+				seen[key] = &hit{path: path, chunk: c, matches: 1}
+				hits = append(hits, seen[key])
+			}
+			break
+		}
+	}
+	if len(hits) == 0 {
+		return output
+	}
+	slices.SortStableFunc(hits, func(a, b *hit) int { return b.matches - a.matches })
+
+	var b strings.Builder
+	var skipped []string
+	for _, h := range hits {
+		header := fmt.Sprintf("%s:%d-%d", strings.TrimPrefix(h.path, "./"), h.chunk.StartLine, h.chunk.EndLine)
+		lines := files[h.path][h.chunk.StartLine-1 : min(h.chunk.EndLine, len(files[h.path]))]
+		size := len(header)
+		for _, l := range lines {
+			size += len(l) + 8
+		}
+		if b.Len() > 0 && b.Len()+size > maxFinderBytes {
+			skipped = append(skipped, header)
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(header)
+		if h.chunk.Symbol != "" {
+			fmt.Fprintf(&b, " (%s)", h.chunk.Symbol)
+		}
+		b.WriteString("\n")
+		for i, l := range lines {
+			fmt.Fprintf(&b, "%d: %s\n", h.chunk.StartLine+i, l)
+		}
 	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, "\n... (%d more chunks with matches: %s)", len(skipped), strings.Join(skipped, ", "))
+	}
+	return b.String()
+}
 
-	return result, nil
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
 }
@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ResultFormatter renders a one-line display summary of a tool call
+// from its raw input and output.
+type ResultFormatter func(args json.RawMessage, output string) string
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]ResultFormatter{
+		ToolNameReadFile:     formatReadFile,
+		ToolNameEditFile:     formatEditFile,
+		ToolNameBash:         formatBash,
+		ToolNameGrepSearch:   formatGrepSearch,
+		ToolNameListFiles:    formatListFiles,
+		ToolNameFinder:       formatFinder,
+		ToolNameWebSearch:    formatWebSearch,
+		ToolNameReadWebPage:  formatReadWebPage,
+		ToolNameReadArtifact: formatReadArtifact,
+	}
+)
+
+var (
+	annotationsMu sync.RWMutex
+	annotations   = builtinAnnotations()
+)
+
+func builtinAnnotations() map[string]Annotations {
+	m := make(map[string]Annotations, len(Builtin))
+	for _, t := range Builtin {
+		m[t.Name] = t.Annotations
+	}
+	return m
+}
+
+// RegisterAnnotations sets the annotations of a tool not built in, which
+// Icon reads.
+func RegisterAnnotations(name string, a Annotations) {
+	annotationsMu.Lock()
+	defer annotationsMu.Unlock()
+	annotations[name] = a
+}
+
+// Icon marks the category of a tool in summaries: ○ for read-only tools,
+// ▲ for destructive ones and ● for the others, unknown tools included.
+func Icon(name string) string {
+	annotationsMu.RLock()
+	a, ok := annotations[name]
+	if !ok {
+		current, _ := Resolve(name)
+		a = annotations[current]
+	}
+	annotationsMu.RUnlock()
+	switch {
+	case a.ReadOnly:
+		return "○"
+	case a.Destructive:
+		return "▲"
+	default:
+		return "●"
+	}
+}
+
+// RegisterFormatter sets the display formatter of a tool, replacing any existing one.
+func RegisterFormatter(name string, f ResultFormatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	formatters[name] = f
+}
+
+// FormatResult summarizes a finished tool call for display.
+// Failed calls and tools without a formatter fall back to the tool name.
+func FormatResult(name string, args json.RawMessage, output string, err error) string {
+	// The content of a conflict is for the model, one line is enough here
+	var conflict *EditConflict
+	if errors.As(err, &conflict) {
+		return fmt.Sprintf("%s conflict: %s is not as expected, nothing was edited", name, conflict.Path)
+	}
+	if err != nil {
+		return fmt.Sprintf("%s failed: %v", name, err)
+	}
+
+	formattersMu.RLock()
+	f, ok := formatters[name]
+	if !ok {
+		current, _ := Resolve(name)
+		f, ok = formatters[current]
+	}
+	formattersMu.RUnlock()
+	if !ok {
+		return name
+	}
+	return f(args, output)
+}
+
+// MCPFormatter summarizes a tool served by an MCP server. Their output is
+// opaque to us, so only its size is shown.
+func MCPFormatter(name string) ResultFormatter {
+	return func(args json.RawMessage, output string) string {
+		return fmt.Sprintf("MCP %s (%s)", name, plural(len(output), "char"))
+	}
+}
+
+var reNumberedLine = regexp.MustCompile(`(?m)^\d+: `)
+
+func formatReadFile(args json.RawMessage, output string) string {
+	in, _ := decode[ReadFileInput](args)
+	lines := len(reNumberedLine.FindAllStringIndex(output, -1))
+	return fmt.Sprintf("Read %s (%s)", in.Path, plural(lines, "line"))
+}
+
+func formatReadArtifact(args json.RawMessage, output string) string {
+	in, _ := decode[ReadArtifactInput](args)
+	lines := len(reNumberedLine.FindAllStringIndex(output, -1))
+	return fmt.Sprintf("Read artifact %.12s (%s)", in.ID, plural(lines, "line"))
+}
+
+func formatEditFile(args json.RawMessage, output string) string {
+	in, _ := decode[EditFileInput](args)
+	if strings.HasPrefix(output, "successfully created") {
+		return fmt.Sprintf("Create %s (+%d)", in.Path, countLines(in.NewStr))
+	}
+	return fmt.Sprintf("Edit %s (+%d −%d)", in.Path, countLines(in.NewStr), countLines(in.OldStr))
+}
+
+var reExitStatus = regexp.MustCompile(`exit status (\d+)`)
+
+func formatBash(args json.RawMessage, output string) string {
+	in, _ := decode[BashInput](args)
+	exit := "0"
+	if strings.HasPrefix(output, "Command failed with error:") {
+		exit = "?"
+		if m := reExitStatus.FindStringSubmatch(output); m != nil {
+			exit = m[1]
+		}
+	}
+	return fmt.Sprintf("Run `%s` (exit %s)", firstLine(in.Command), exit)
+}
+
+func formatGrepSearch(args json.RawMessage, output string) string {
+	in, _ := decode[GrepSearchInput](args)
+
+	var messages []struct {
+		Type string `json:"type"`
+	}
+	matches := 0
+	if err := json.Unmarshal([]byte(output), &messages); err == nil {
+		for _, m := range messages {
+			if m.Type == "match" {
+				matches++
+			}
+		}
+	}
+	return fmt.Sprintf("Search /%s/ (%s)", in.Pattern, plural(matches, "match"))
+}
+
+func formatListFiles(args json.RawMessage, output string) string {
+	in, _ := decode[ListFilesInput](args)
+	path := in.Path
+	if path == "" {
+		path = "."
+	}
+
+	var entries []string
+	_ = json.Unmarshal([]byte(output), &entries)
+	return fmt.Sprintf("List %s (%s)", path, plural(len(entries), "entry"))
+}
+
+// reFinderChunk matches the headers of the chunks finder returns
+var reFinderChunk = regexp.MustCompile(`(?m)^\S+:\d+-\d+(?: \(.*\))?$`)
+
+func formatFinder(args json.RawMessage, output string) string {
+	in, _ := decode[FinderInput](args)
+	results := 0
+	if !strings.HasPrefix(output, "No results found") {
+		results = len(reFinderChunk.FindAllStringIndex(output, -1))
+	}
+	return fmt.Sprintf("Find %q (%s)", in.Query, plural(results, "result"))
+}
+
+var reSearchResult = regexp.MustCompile(`(?m)^\d+\. `)
+
+func formatWebSearch(args json.RawMessage, output string) string {
+	in, _ := decode[WebSearchInput](args)
+	results := len(reSearchResult.FindAllStringIndex(output, -1))
+	return fmt.Sprintf("Search web %q (%s)", in.Query, plural(results, "result"))
+}
+
+func formatReadWebPage(args json.RawMessage, output string) string {
+	in, _ := decode[ReadWebPageInput](args)
+	return fmt.Sprintf("Fetch %s (%s)", in.URL, plural(len(output), "char"))
+}
+
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	return strings.Count(s, "\n") + 1
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + "…"
+	}
+	return s
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	switch {
+	case strings.HasSuffix(noun, "ch"):
+		return fmt.Sprintf("%d %ses", n, noun)
+	case strings.HasSuffix(noun, "y"):
+		return fmt.Sprintf("%d %sies", n, strings.TrimSuffix(noun, "y"))
+	default:
+		return fmt.Sprintf("%d %ss", n, noun)
+	}
+}
@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		args   any
+		output string
+		want   string
+	}{
+		{
+			name:   "read file counts lines",
+			tool:   ToolNameReadFile,
+			args:   ReadFileInput{Path: "main.go"},
+			output: "1: package main\n2: \n3: func main() {}\n\n(7 lines remaining, file has 10 total lines)",
+			want:   "Read main.go (3 lines)",
+		},
+		{
+			name:   "edit file shows diff stat",
+			tool:   ToolNameEditFile,
+			args:   EditFileInput{Path: "a.go", OldStr: "x := 1", NewStr: "x := 1\ny := 2"},
+			output: "OK",
+			want:   "Edit a.go (+2 −1)",
+		},
+		{
+			name:   "edit file creating",
+			tool:   ToolNameEditFile,
+			args:   EditFileInput{Path: "new.go", NewStr: "package new\n"},
+			output: "successfully created file new.go",
+			want:   "Create new.go (+2)",
+		},
+		{
+			name:   "bash success",
+			tool:   ToolNameBash,
+			args:   BashInput{Command: "go test ./..."},
+			output: "ok",
+			want:   "Run `go test ./...` (exit 0)",
+		},
+		{
+			name:   "bash failure",
+			tool:   ToolNameBash,
+			args:   BashInput{Command: "exit 3"},
+			output: "Command failed with error: exit status 3\nOutput: ",
+			want:   "Run `exit 3` (exit 3)",
+		},
+		{
+			name:   "grep counts matches",
+			tool:   ToolNameGrepSearch,
+			args:   GrepSearchInput{Pattern: "TODO"},
+			output: `[{"type":"begin"},{"type":"match"},{"type":"match"},{"type":"end"}]`,
+			want:   "Search /TODO/ (2 matches)",
+		},
+		{
+			name:   "list files defaults to cwd",
+			tool:   ToolNameListFiles,
+			args:   ListFilesInput{},
+			output: `["a.go","b/"]`,
+			want:   "List . (2 entries)",
+		},
+		{
+			name:   "finder without results",
+			tool:   ToolNameFinder,
+			args:   FinderInput{Query: "nothing"},
+			output: "No results found for: nothing",
+			want:   `Find "nothing" (0 results)`,
+		},
+		{
+			name:   "unknown tool falls back to name",
+			tool:   "mystery",
+			args:   map[string]string{},
+			output: "whatever",
+			want:   "mystery",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, _ := json.Marshal(tt.args)
+			assert.Equal(t, tt.want, FormatResult(tt.tool, args, tt.output, nil))
+		})
+	}
+}
+
+func TestFormatResult_Error(t *testing.T) {
+	args, _ := json.Marshal(ReadFileInput{Path: "missing.go"})
+
+	got := FormatResult(ToolNameReadFile, args, "", errors.New("no such file"))
+
+	assert.Equal(t, "read_file failed: no such file", got)
+}
+
+func TestRegisterFormatter_MCP(t *testing.T) {
+	RegisterFormatter("srv_lookup", MCPFormatter("srv_lookup"))
+	t.Cleanup(func() {
+		formattersMu.Lock()
+		delete(formatters, "srv_lookup")
+		formattersMu.Unlock()
+	})
+
+	got := FormatResult("srv_lookup", json.RawMessage(`{}`), "hello", nil)
+
+	assert.Equal(t, "MCP srv_lookup (5 chars)", got)
+}
+
+func TestIcon(t *testing.T) {
+	RegisterAnnotations("srv_drop", Annotations{Destructive: true})
+	t.Cleanup(func() {
+		annotationsMu.Lock()
+		delete(annotations, "srv_drop")
+		annotationsMu.Unlock()
+	})
+
+	assert.Equal(t, "○", Icon(ToolNameReadFile))
+	assert.Equal(t, "●", Icon(ToolNameEditFile))
+	assert.Equal(t, "▲", Icon(ToolNameBash))
+	assert.Equal(t, "▲", Icon("srv_drop"))
+	assert.Equal(t, "●", Icon("unknown"))
+}
+
+func TestReadOnly_FromAnnotations(t *testing.T) {
+	var names []string
+	for _, d := range ReadOnly {
+		names = append(names, d.Name)
+	}
+
+	assert.Equal(t, []string{ToolNameReadFile, ToolNameListFiles, ToolNameGrepSearch, ToolNameFinder, ToolNameReadArtifact}, names)
+}
@@ -17,6 +17,7 @@ var GrepSearchDefinition = ToolDefinition{
 	Description: grepSearchPrompt,
 	InputSchema: GrepSearchInputSchema,
 	Function:    RunGrepSearchTool,
+	Annotations: Annotations{ReadOnly: true, Idempotent: true},
 }
 
 type GrepSearchInput struct {
@@ -36,13 +37,18 @@ func RunGrepSearchTool(ctx context.Context, args json.RawMessage) (string, error
 		return "", fmt.Errorf("invalid pattern parameter")
 	}
 
-	searchArgs := []string{"rg", "--json", searchInput.Pattern}
+	ignoreArgs, err := rgIgnoreArgs(ctx)
+	if err != nil {
+		return "", err
+	}
+	searchArgs := append([]string{"rg", "--json"}, ignoreArgs...)
+	searchArgs = append(searchArgs, "-e", searchInput.Pattern)
 
 	if searchInput.Directory != "" {
 		searchArgs = append(searchArgs, searchInput.Directory)
 	}
 
-	cmd := exec.Command(searchArgs[0], searchArgs[1:]...)
+	cmd := command(ctx, searchArgs[0], searchArgs[1:]...)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		exitErr, ok := err.(*exec.ExitError)
 		if ok && exitErr.ExitCode() == 1 {
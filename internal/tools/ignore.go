@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile lists, in .gitignore syntax, the paths of the working directory
+// the search tools never show the model, such as generated directories and
+// secrets .gitignore misses.
+const IgnoreFile = ".tinkerignore"
+
+// Ignore matches paths against the patterns of an IgnoreFile.
+type Ignore struct {
+	// Directory the patterns are relative to
+	root     string
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	re *regexp.Regexp
+	// Patterns starting with ! include back what earlier ones ignored
+	negate bool
+	// Patterns ending with / only match directories
+	dirOnly bool
+}
+
+// LoadIgnore reads the IgnoreFile of root. Without one nothing is ignored.
+func LoadIgnore(root string) (*Ignore, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return readIgnore(context.Background(), abs)
+}
+
+// loadIgnore reads the IgnoreFile of the directory the tools run in.
+func loadIgnore(ctx context.Context) (*Ignore, error) {
+	root, err := workspaceDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return readIgnore(ctx, root)
+}
+
+func readIgnore(ctx context.Context, root string) (*Ignore, error) {
+	ig := &Ignore{root: root}
+	data, err := readFile(ctx, filepath.Join(root, IgnoreFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ig, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", IgnoreFile, err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if p, ok := parseIgnorePattern(line); ok {
+			ig.patterns = append(ig.patterns, p)
+		}
+	}
+	return ig, nil
+}
+
+// Path returns the IgnoreFile the patterns were read from, empty when there
+// is none.
+func (ig *Ignore) Path() string {
+	if len(ig.patterns) == 0 {
+		return ""
+	}
+	return filepath.Join(ig.root, IgnoreFile)
+}
+
+// Ignored reports whether path, a file or a directory as isDir says, is
+// ignored. Relative paths are relative to the root, and paths outside the
+// root are not ignored.
+func (ig *Ignore) Ignored(path string, isDir bool) bool {
+	if len(ig.patterns) == 0 {
+		return false
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ig.root, path)
+	}
+	rel, err := filepath.Rel(ig.root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	// Like git, nothing under an ignored directory can be included back
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i < len(parts); i++ {
+		if ig.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return ig.match(strings.Join(parts, "/"), isDir)
+}
+
+// rgIgnoreArgs are the ripgrep flags honoring the IgnoreFile of the
+// directory the tools run in.
+func rgIgnoreArgs(ctx context.Context) ([]string, error) {
+	ig, err := loadIgnore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ig.Path() == "" {
+		return nil, nil
+	}
+	// ripgrep matches the patterns relative to its working directory
+	return []string{"--ignore-file", ig.Path()}, nil
+}
+
+// match applies the patterns to rel in order, the last matching one winning.
+func (ig *Ignore) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, p := range ig.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// \# and \! start patterns with those characters
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	// Patterns with a slash other than a trailing one are relative to the
+	// root, others match at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	prefix := "^(?:.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+	re, err := regexp.Compile(prefix + globRegexp(line) + "$")
+	if err != nil {
+		return ignorePattern{}, false
+	}
+	p.re = re
+	return p, true
+}
+
+// globRegexp translates a gitignore glob into a regular expression.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(glob[i+1:], ']'); end >= 0 {
+				class := glob[i+1 : i+1+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += end + 1
+				continue
+			}
+			b.WriteString(`\[`)
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestDirectoryWithIgnore makes a project with a .tinkerignore, and
+// moves into it like the tools run in the working directory.
+func createTestDirectoryWithIgnore(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		IgnoreFile:                   "# generated\ngen/\n*.pem\n!public.pem\n/config/secrets.yaml\ndocs/**/draft-*\n",
+		"main.go":                    "package main // needle",
+		"gen/api.go":                 "package gen // needle",
+		"certs/server.pem":           "needle",
+		"certs/public.pem":           "needle",
+		"config/secrets.yaml":        "token: needle",
+		"config/app.yaml":            "name: needle",
+		"docs/a/b/draft-1.md":        "needle",
+		"docs/a/final.md":            "needle",
+		"nested/config/secrets.yaml": "needle",
+	}
+	for path, content := range files {
+		full := filepath.Join(tmpDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0o644))
+	}
+	t.Chdir(tmpDir)
+	return tmpDir
+}
+
+func TestIgnore_Patterns(t *testing.T) {
+	createTestDirectoryWithIgnore(t)
+	ig, err := LoadIgnore(".")
+	require.NoError(t, err)
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"main.go", false, false},
+		{"gen", true, true},
+		{"gen/api.go", false, true},
+		{"gen", false, false},
+		{"certs/server.pem", false, true},
+		{"certs/public.pem", false, false},
+		{"config/secrets.yaml", false, true},
+		{"nested/config/secrets.yaml", false, false},
+		{"config/app.yaml", false, false},
+		{"docs/a/b/draft-1.md", false, true},
+		{"docs/a/final.md", false, false},
+		{"../outside.pem", false, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ignored, ig.Ignored(tt.path, tt.isDir), tt.path)
+	}
+}
+
+func TestIgnore_NoFile(t *testing.T) {
+	ig, err := LoadIgnore(t.TempDir())
+	require.NoError(t, err)
+	assert.False(t, ig.Ignored("anything", false))
+	assert.Equal(t, "", ig.Path())
+}
+
+func TestListFiles_HonorsIgnoreFile(t *testing.T) {
+	createTestDirectoryWithIgnore(t)
+
+	result, err := RunListFilesTool(context.Background(), json.RawMessage(`{}`))
+	require.NoError(t, err)
+	var files []string
+	require.NoError(t, json.Unmarshal([]byte(result), &files))
+
+	assert.Contains(t, files, "main.go")
+	assert.Contains(t, files, "certs/public.pem")
+	assert.NotContains(t, files, "gen/")
+	assert.NotContains(t, files, "gen/api.go")
+	assert.NotContains(t, files, "certs/server.pem")
+	assert.NotContains(t, files, "config/secrets.yaml")
+
+	inputJSON, _ := json.Marshal(ListFilesInput{Path: "config"})
+	result, err = RunListFilesTool(context.Background(), inputJSON)
+	require.NoError(t, err)
+	assert.Equal(t, `["app.yaml"]`, result)
+}
+
+func TestSearchTools_HonorIgnoreFile(t *testing.T) {
+	if !isRipgrepAvailable() {
+		t.Skip("ripgrep (rg) not available, skipping test")
+	}
+	createTestDirectoryWithIgnore(t)
+
+	inputJSON, _ := json.Marshal(GrepSearchInput{Pattern: "needle"})
+	result, err := RunGrepSearchTool(context.Background(), inputJSON)
+	require.NoError(t, err)
+	assert.Contains(t, result, "main.go")
+	assert.NotContains(t, result, "gen/api.go")
+	assert.NotContains(t, result, "server.pem")
+
+	inputJSON, _ = json.Marshal(FinderInput{Query: "needle"})
+	result, err = RunFinderTool(context.Background(), inputJSON)
+	require.NoError(t, err)
+	assert.Contains(t, result, "config/app.yaml")
+	assert.NotContains(t, result, "config/secrets.yaml")
+	assert.NotContains(t, result, "draft-1.md")
+}
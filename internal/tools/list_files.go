@@ -27,6 +27,7 @@ var ListFilesDefinition = ToolDefinition{
 	Description: "List files and directories at a given path. If no path is provided, list files in the current directory",
 	InputSchema: ListFilesInputSchema,
 	Function:    RunListFilesTool,
+	Annotations: Annotations{ReadOnly: true, Idempotent: true},
 }
 
 type ListFilesInput struct {
@@ -46,9 +47,14 @@ func RunListFilesTool(ctx context.Context, args json.RawMessage) (string, error)
 		dir = listFilesInput.Path
 	}
 
+	ignore, err := loadIgnore(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	var fileNames []string
 
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	err = walk(ctx, dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -56,6 +62,12 @@ func RunListFilesTool(ctx context.Context, args json.RawMessage) (string, error)
 		if info.IsDir() && path != dir && (skipDirs[info.Name()] || strings.HasPrefix(info.Name(), ".")) {
 			return filepath.SkipDir
 		}
+		if path != dir && ignore.Ignored(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 
 		relPath, err := filepath.Rel(dir, path)
 		if err != nil {
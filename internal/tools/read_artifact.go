@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//go:embed read_artifact.md
+var readArtifactPrompt string
+
+// Lines of artifacts are wrapped past this many bytes, so outputs without
+// line breaks, such as minified JSON, can still be read in chunks
+const maxArtifactLine = 2000
+
+type ReadArtifactInput struct {
+	ID        string `json:"id" jsonschema_description:"The ID of the artifact, as given by the truncated tool result."`
+	StartLine int    `json:"start_line" jsonschema_description:"The 1-indexed line number to start reading from. Defaults to 1."`
+	EndLine   int    `json:"end_line" jsonschema_description:"The 1-indexed line number to stop reading at (inclusive). Defaults to start_line + 99."`
+}
+
+var ReadArtifactInputSchema = generate[ReadArtifactInput]()
+
+var ReadArtifactDefinition = ToolDefinition{
+	Name:        ToolNameReadArtifact,
+	Description: readArtifactPrompt,
+	InputSchema: ReadArtifactInputSchema,
+	Function:    RunReadArtifactTool,
+	Annotations: Annotations{ReadOnly: true, Idempotent: true},
+}
+
+type artifactReaderKey struct{}
+
+// WithArtifacts lets read_artifact, run with ctx, read the artifacts read
+// returns by ID.
+func WithArtifacts(ctx context.Context, read func(ctx context.Context, id string) (string, error)) context.Context {
+	return context.WithValue(ctx, artifactReaderKey{}, read)
+}
+
+// ArtifactLines splits an artifact into the lines read_artifact numbers.
+func ArtifactLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		for len(line) > maxArtifactLine {
+			cut := maxArtifactLine
+			// Keep UTF-8 sequences whole
+			for cut > 0 && line[cut]&0xC0 == 0x80 {
+				cut--
+			}
+			lines = append(lines, line[:cut])
+			line = line[cut:]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func RunReadArtifactTool(ctx context.Context, args json.RawMessage) (string, error) {
+	input, err := decode[ReadArtifactInput](args)
+	if err != nil {
+		return "", fmt.Errorf("parse read_artifact input: %w", err)
+	}
+	read, ok := ctx.Value(artifactReaderKey{}).(func(context.Context, string) (string, error))
+	if !ok {
+		return "", errors.New("this session has no artifacts")
+	}
+
+	content, err := read(ctx, input.ID)
+	if err != nil {
+		return "", err
+	}
+
+	lines := ArtifactLines(content)
+	totalLines := len(lines)
+
+	start := input.StartLine
+	if start <= 0 {
+		start = 1
+	}
+
+	end := input.EndLine
+	if end <= 0 {
+		end = start + defaultMaxLines - 1
+	}
+
+	if start > totalLines {
+		return fmt.Sprintf("(Artifact has %d lines, start_line %d is beyond its end)", totalLines, start), nil
+	}
+
+	if end > totalLines {
+		end = totalLines
+	}
+
+	var sb strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&sb, "%d: %s\n", i, lines[i-1])
+	}
+
+	if end < totalLines {
+		fmt.Fprintf(&sb, "\n(%d lines remaining, artifact has %d total lines)", totalLines-end, totalLines)
+	}
+
+	return sb.String(), nil
+}
@@ -5,7 +5,6 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 )
 
@@ -27,6 +26,7 @@ var ReadFileDefinition = ToolDefinition{
 	Description: readFilePrompt,
 	InputSchema: ReadFileInputSchema,
 	Function:    RunReadFileTool,
+	Annotations: Annotations{ReadOnly: true, Idempotent: true},
 }
 
 func RunReadFileTool(ctx context.Context, args json.RawMessage) (string, error) {
@@ -35,7 +35,7 @@ func RunReadFileTool(ctx context.Context, args json.RawMessage) (string, error)
 		return "", fmt.Errorf("parse read_file input: %w", err)
 	}
 
-	content, err := os.ReadFile(readFileInput.Path)
+	content, err := readFile(ctx, readFileInput.Path)
 	if err != nil {
 		return "", err
 	}
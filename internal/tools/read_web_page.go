@@ -20,6 +20,7 @@ var ReadWebPageDefinition = ToolDefinition{
 	Description: readWebPagePrompt,
 	InputSchema: ReadWebPageInputSchema,
 	Function:    RunReadWebPageTool,
+	Annotations: Annotations{ReadOnly: true, OpenWorld: true},
 }
 
 type ReadWebPageInput struct {
@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SSH is a workspace on a remote host, reached with the ssh client so the
+// user's ~/.ssh/config, keys and agent apply. Connections are shared between
+// tool calls for a minute.
+type SSH struct {
+	// Destination as ssh takes it, e.g. "dev" or "me@dev.example.com"
+	Host string
+	// Port of the SSH server, the ssh client's default when 0
+	Port int
+	// Absolute directory on the host the tools run in
+	Directory string
+}
+
+func (s *SSH) Dir() string { return s.Directory }
+
+func (s *SSH) String() string { return s.Host + ":" + s.Directory }
+
+func (s *SSH) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	// Tools cannot answer prompts, so ssh fails rather than asking for a
+	// password or to trust a new host key
+	sshArgs := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(os.TempDir(), "tinker-ssh-%C"),
+		"-o", "ControlPersist=60s",
+	}
+	if s.Port != 0 {
+		sshArgs = append(sshArgs, "-p", strconv.Itoa(s.Port))
+	}
+	// ssh runs its command with the remote shell, which needs it as one string
+	remote := "cd " + shellQuote(s.Directory) + " && " + strings.Join(shellQuoteAll(append([]string{name}, args...)), " ")
+	sshArgs = append(sshArgs, s.Host, "--", remote)
+	return exec.CommandContext(ctx, "ssh", sshArgs...)
+}
@@ -4,27 +4,67 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"slices"
 
 	"github.com/invopop/jsonschema"
 )
 
 const (
-	ToolNameBash        = "bash"
-	ToolNameReadFile    = "read_file"
-	ToolNameEditFile    = "edit_file"
-	ToolNameGrepSearch  = "grep_search"
-	ToolNameListFiles   = "list_files"
-	ToolNameFinder      = "finder"
-	ToolNameWebSearch   = "web_search"
-	ToolNameReadWebPage = "read_web_page"
+	ToolNameBash         = "bash"
+	ToolNameReadFile     = "read_file"
+	ToolNameEditFile     = "edit_file"
+	ToolNameGrepSearch   = "grep_search"
+	ToolNameListFiles    = "list_files"
+	ToolNameFinder       = "finder"
+	ToolNameWebSearch    = "web_search"
+	ToolNameReadWebPage  = "read_web_page"
+	ToolNameReadArtifact = "read_artifact"
 )
 
+// Builtin lists the tools every agent run is equipped with
+var Builtin = []ToolDefinition{
+	ReadFileDefinition,
+	ListFilesDefinition,
+	EditFileDefinition,
+	GrepSearchDefinition,
+	FinderDefinition,
+	BashDefinition,
+	WebSearchDefinition,
+	ReadWebPageDefinition,
+	ReadArtifactDefinition,
+}
+
+// ReadOnly lists the built-in tools that cannot change the workspace and
+// stay on the machine
+var ReadOnly = slices.DeleteFunc(slices.Clone(Builtin), func(t ToolDefinition) bool { return !t.Annotations.Safe() })
+
 // ToolDefinition represents a tool that can be called by the model
 type ToolDefinition struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	InputSchema *jsonschema.Schema
 	Function    ToolRunnerFunc
+	Annotations Annotations `json:"annotations"`
+}
+
+// Annotations describe what a tool does to its environment, so policies such
+// as folder trust need not know tools by name. The zero value is a tool that
+// changes the workspace.
+type Annotations struct {
+	// Changes nothing
+	ReadOnly bool `json:"read_only,omitempty"`
+	// May delete or overwrite data beyond what its input names
+	Destructive bool `json:"destructive,omitempty"`
+	// Calling it again with the same input has no further effect
+	Idempotent bool `json:"idempotent,omitempty"`
+	// Reaches outside the machine, such as the web
+	OpenWorld bool `json:"open_world,omitempty"`
+}
+
+// Safe reports whether the tool can run without asking, in folders that are
+// not trusted: it changes nothing and stays on the machine.
+func (a Annotations) Safe() bool {
+	return a.ReadOnly && !a.OpenWorld
 }
 
 // ToolRunner defines an interface for executing a tool
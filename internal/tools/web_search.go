@@ -21,6 +21,7 @@ var WebSearchDefinition = ToolDefinition{
 	Description: webSearchPrompt,
 	InputSchema: WebSearchInputSchema,
 	Function:    RunWebSearchTool,
+	Annotations: Annotations{ReadOnly: true, OpenWorld: true},
 }
 
 type WebSearchInput struct {
@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Workspace is where the file and bash tools operate when it is not the
+// local working directory, e.g. a remote host, see OnWorkspace. Files are
+// read, written and listed with POSIX commands run in the workspace.
+type Workspace interface {
+	// Dir is the directory the commands run in, which relative paths
+	// resolve against
+	Dir() string
+	// Command returns the command running name with args in Dir
+	Command(ctx context.Context, name string, args ...string) *exec.Cmd
+	// String names the workspace for the model and the logs
+	String() string
+}
+
+type workspaceKey struct{}
+
+// OnWorkspace returns toolset with its tools operating in ws rather than on
+// the local machine. Tools that touch no files, such as web_search, are
+// unaffected.
+func OnWorkspace(toolset []ToolDefinition, ws Workspace) []ToolDefinition {
+	moved := slices.Clone(toolset)
+	for i, t := range moved {
+		run := t.Function
+		moved[i].Function = func(ctx context.Context, args json.RawMessage) (string, error) {
+			return run(context.WithValue(ctx, workspaceKey{}, ws), args)
+		}
+	}
+	return moved
+}
+
+func workspaceOf(ctx context.Context) Workspace {
+	ws, _ := ctx.Value(workspaceKey{}).(Workspace)
+	return ws
+}
+
+// command returns the command running name with args in the workspace of
+// ctx, the working directory without one.
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if ws := workspaceOf(ctx); ws != nil {
+		return ws.Command(ctx, name, args...)
+	}
+	return exec.Command(name, args...)
+}
+
+// workspaceDir is the directory relative paths resolve against in ctx.
+func workspaceDir(ctx context.Context) (string, error) {
+	if ws := workspaceOf(ctx); ws != nil {
+		return ws.Dir(), nil
+	}
+	return os.Getwd()
+}
+
+// Exit status of the read script when the file does not exist
+const exitNotExist = 44
+
+func readFile(ctx context.Context, name string) ([]byte, error) {
+	ws := workspaceOf(ctx)
+	if ws == nil {
+		return os.ReadFile(name)
+	}
+
+	var stderr bytes.Buffer
+	cmd := ws.Command(ctx, "sh", "-c", fmt.Sprintf(`[ -e "$1" ] || exit %d; cat -- "$1"`, exitNotExist), "sh", name)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitNotExist {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		return nil, remoteError("read "+name, ws, err, stderr.String())
+	}
+	return out, nil
+}
+
+// writeFile writes data to name, creating its parent directories.
+func writeFile(ctx context.Context, name string, data []byte) error {
+	ws := workspaceOf(ctx)
+	if ws == nil {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(name, data, 0o644)
+	}
+
+	var stderr bytes.Buffer
+	cmd := ws.Command(ctx, "sh", "-c", `mkdir -p "$(dirname -- "$1")" && cat > "$1"`, "sh", name)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return remoteError("write "+name, ws, err, stderr.String())
+	}
+	return nil
+}
+
+// walk walks the tree at root like filepath.Walk, in the workspace of ctx.
+// Directories skipped with filepath.SkipDir are not listed remotely when
+// skipDirs or a hidden name says so.
+func walk(ctx context.Context, root string, fn filepath.WalkFunc) error {
+	ws := workspaceOf(ctx)
+	if ws == nil {
+		return filepath.Walk(root, fn)
+	}
+
+	prune := []string{"-name", ".?*"}
+	for name := range skipDirs {
+		prune = append(prune, "-o", "-name", name)
+	}
+	// Directories and other files are listed apart to tell them apart
+	// without GNU find's -printf
+	script := `find "$1" ! -path "$1" -type d \( ` + strings.Join(shellQuoteAll(prune), " ") + ` \) -prune -o -type d -print | sed 's/^/d /'; ` +
+		`find "$1" ! -path "$1" -type d \( ` + strings.Join(shellQuoteAll(prune), " ") + ` \) -prune -o ! -type d -print | sed 's/^/f /'`
+	var stderr bytes.Buffer
+	cmd := ws.Command(ctx, "sh", "-c", script, "sh", root)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fn(root, nil, remoteError("list "+root, ws, err, stderr.String()))
+	}
+
+	var entries []remoteEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		kind, p, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		entries = append(entries, remoteEntry{path: path.Clean(p), dir: kind == "d"})
+	}
+	// In the order of filepath.Walk: lexical, directories before their files
+	slices.SortFunc(entries, func(a, b remoteEntry) int {
+		return slices.Compare(strings.Split(a.path, "/"), strings.Split(b.path, "/"))
+	})
+
+	var skipped []string
+	for _, e := range entries {
+		if slices.ContainsFunc(skipped, func(dir string) bool { return strings.HasPrefix(e.path, dir+"/") }) {
+			continue
+		}
+		err := fn(e.path, e, nil)
+		if errors.Is(err, filepath.SkipDir) && e.dir {
+			skipped = append(skipped, e.path)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remoteEntry describes a listed file to WalkFuncs, only by name and kind.
+type remoteEntry struct {
+	path string
+	dir  bool
+}
+
+func (e remoteEntry) Name() string       { return path.Base(e.path) }
+func (e remoteEntry) Size() int64        { return 0 }
+func (e remoteEntry) ModTime() time.Time { return time.Time{} }
+func (e remoteEntry) IsDir() bool        { return e.dir }
+func (e remoteEntry) Sys() any           { return nil }
+
+func (e remoteEntry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func remoteError(op string, ws Workspace, err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("%s on %s: %s", op, ws, msg)
+	}
+	return fmt.Errorf("%s on %s: %w", op, ws, err)
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:@,+%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellQuoteAll(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return quoted
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dirWorkspace runs the tools' commands in a local directory, the way a
+// remote workspace runs them on its host.
+type dirWorkspace struct{ dir string }
+
+func (w dirWorkspace) Dir() string    { return w.dir }
+func (w dirWorkspace) String() string { return "test:" + w.dir }
+
+func (w dirWorkspace) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = w.dir
+	return cmd
+}
+
+func runOn(t *testing.T, ws Workspace, def ToolDefinition, input any) (string, error) {
+	t.Helper()
+	args, err := json.Marshal(input)
+	require.NoError(t, err)
+	return OnWorkspace([]ToolDefinition{def}, ws)[0].Function(t.Context(), args)
+}
+
+func TestOnWorkspace_FileTools(t *testing.T) {
+	ws := dirWorkspace{dir: t.TempDir()}
+	// The tools must not touch the local working directory
+	t.Chdir(t.TempDir())
+
+	out, err := runOn(t, ws, EditFileDefinition, EditFileInput{Path: "src/main.go", NewStr: "package main\n"})
+	require.NoError(t, err)
+	assert.Contains(t, out, "successfully created")
+	_, err = runOn(t, ws, EditFileDefinition, EditFileInput{Path: "src/main.go", OldStr: "main", NewStr: "app"})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(ws.dir, "src/main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package app\n", string(data))
+	assert.NoFileExists(t, "src/main.go")
+
+	out, err = runOn(t, ws, ReadFileDefinition, ReadFileInput{Path: "src/main.go"})
+	require.NoError(t, err)
+	assert.Equal(t, "1: package app\n2: \n", out)
+
+	_, err = runOn(t, ws, ReadFileDefinition, ReadFileInput{Path: "missing.go"})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	out, err = runOn(t, ws, BashDefinition, BashInput{Command: "pwd"})
+	require.NoError(t, err)
+	resolved, _ := filepath.EvalSymlinks(ws.dir)
+	assert.Contains(t, []string{ws.dir, resolved}, out)
+}
+
+func TestOnWorkspace_ListFiles(t *testing.T) {
+	ws := dirWorkspace{dir: createTestDirectoryForList(t)}
+	for _, path := range []string{".git/config", "node_modules/pkg/index.js", "secrets/key.pem"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(ws.dir, filepath.Dir(path)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(ws.dir, path), nil, 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(ws.dir, IgnoreFile), []byte("secrets/\n"), 0o644))
+	t.Chdir(t.TempDir())
+
+	remote, err := runOn(t, ws, ListFilesDefinition, ListFilesInput{})
+	require.NoError(t, err)
+
+	// Listing the directory locally gives the same files
+	t.Chdir(ws.dir)
+	local, err := RunListFilesTool(t.Context(), json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, local, remote)
+	assert.NotContains(t, remote, "node_modules")
+	assert.NotContains(t, remote, "secrets")
+}
+
+func TestSSH_Command(t *testing.T) {
+	s := &SSH{Host: "me@dev", Port: 2222, Directory: "/src/my app"}
+	cmd := s.Command(t.Context(), "bash", "-c", "echo 'hi'")
+
+	args := strings.Join(cmd.Args, " ")
+	assert.Contains(t, args, "-o BatchMode=yes")
+	assert.Contains(t, args, "-p 2222 me@dev -- ")
+	assert.Equal(t, `cd '/src/my app' && bash -c 'echo '\''hi'\'''`, cmd.Args[len(cmd.Args)-1])
+	assert.Equal(t, "me@dev:/src/my app", s.String())
+}
+
+func TestDocker_Command(t *testing.T) {
+	d := &Docker{Container: "dev", Directory: "/workspace", User: "1000:1000"}
+	cmd := d.Command(t.Context(), "rg", "--json", "-e", "needle")
+
+	assert.Equal(t, []string{"docker", "exec", "--interactive", "--workdir", "/workspace", "--user", "1000:1000", "dev", "rg", "--json", "-e", "needle"}, cmd.Args)
+	assert.Equal(t, "container dev:/workspace", d.String())
+	assert.NoError(t, d.Close(), "containers not started by tinker are left running")
+}
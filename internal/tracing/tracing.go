@@ -0,0 +1,79 @@
+// Package tracing exports OpenTelemetry spans of agent turns, model calls,
+// tool executions and HTTP requests over OTLP. It stays off unless an OTLP
+// endpoint is configured with the standard OTEL_EXPORTER_OTLP_* variables.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/honganh1206/tinker"
+
+// Attributes of the spans, after the OpenTelemetry GenAI conventions
+const (
+	AttrModel        = attribute.Key("gen_ai.request.model")
+	AttrInputTokens  = attribute.Key("gen_ai.usage.input_tokens")
+	AttrOutputTokens = attribute.Key("gen_ai.usage.output_tokens")
+	AttrToolName     = attribute.Key("gen_ai.tool.name")
+	AttrSessionID    = attribute.Key("gen_ai.conversation.id")
+	// Estimated cost of a model call in USD
+	AttrCost = attribute.Key("tinker.cost_usd")
+)
+
+// Enabled reports whether an OTLP endpoint is configured.
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup exports the spans of the process as service to the configured OTLP
+// endpoint. Shutdown flushes the spans not exported yet and must be called
+// before the process exits.
+func Setup(ctx context.Context, service string) (shutdown func(context.Context) error, err error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp exporter: %w", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", service)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start starts a span under the span of ctx. Without Setup it is a no-op.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed with err.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,111 @@
+// Package trust records which folders the user trusts tinker to modify.
+// Repositories can carry instructions aimed at the agent, so folders the
+// user has not vouched for only get read-only tools.
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists trust decisions per folder. A decision covers the folder's
+// subdirectories unless one of them has its own.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+type storeFile struct {
+	Folders map[string]bool `json:"folders"`
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath is ~/.tinker/trusted-folders.json.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinker", "trusted-folders.json"), nil
+}
+
+// Lookup returns the decision for dir or its nearest decided parent.
+// known is false when no decision applies.
+func (s *Store) Lookup(dir string) (trusted, known bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false, false, err
+	}
+	f, err := s.load()
+	if err != nil {
+		return false, false, err
+	}
+
+	for {
+		if trusted, ok := f.Folders[dir]; ok {
+			return trusted, true, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false, false, nil
+		}
+		dir = parent
+	}
+}
+
+// Set records the decision for dir.
+func (s *Store) Set(dir string, trusted bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	f.Folders[dir] = trusted
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal trusted folders: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create trust dir: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("write trusted folders: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) load() (storeFile, error) {
+	f := storeFile{Folders: map[string]bool{}}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, fmt.Errorf("read trusted folders: %w", err)
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("parse trusted folders %s: %w", s.path, err)
+	}
+	if f.Folders == nil {
+		f.Folders = map[string]bool{}
+	}
+	return f, nil
+}
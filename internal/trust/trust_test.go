@@ -0,0 +1,49 @@
+package trust
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_LookupUnknown(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "trust.json"))
+
+	trusted, known, err := s.Lookup(t.TempDir())
+	require.NoError(t, err)
+	assert.False(t, known)
+	assert.False(t, trusted)
+}
+
+func TestStore_PersistsDecisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trust.json")
+	dir := t.TempDir()
+
+	require.NoError(t, NewStore(path).Set(dir, true))
+
+	trusted, known, err := NewStore(path).Lookup(dir)
+	require.NoError(t, err)
+	assert.True(t, known)
+	assert.True(t, trusted)
+}
+
+func TestStore_NearestParentDecides(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "trust.json"))
+	root := t.TempDir()
+	vendored := filepath.Join(root, "third_party", "lib")
+
+	require.NoError(t, s.Set(root, true))
+	require.NoError(t, s.Set(filepath.Join(root, "third_party"), false))
+
+	trusted, known, err := s.Lookup(filepath.Join(root, "cmd"))
+	require.NoError(t, err)
+	assert.True(t, known)
+	assert.True(t, trusted)
+
+	trusted, known, err = s.Lookup(vendored)
+	require.NoError(t, err)
+	assert.True(t, known)
+	assert.False(t, trusted)
+}
@@ -0,0 +1,68 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CheckInterval is how long a release lookup is reused before asking GitHub again
+const CheckInterval = 24 * time.Hour
+
+// lastCheck is the cached result of the last release lookup
+type lastCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Tag       string    `json:"tag"`
+	URL       string    `json:"url"`
+}
+
+// Available returns the latest release if it is newer than current, using the
+// lookup cached at cachePath when it is recent enough. It returns nil when
+// current is up to date.
+func (c *Client) Available(ctx context.Context, current, cachePath string) (*Release, error) {
+	check, err := readCheck(cachePath)
+	if err != nil || time.Since(check.CheckedAt) > CheckInterval {
+		rel, err := c.Latest(ctx)
+		if err != nil {
+			return nil, err
+		}
+		check = lastCheck{CheckedAt: time.Now(), Tag: rel.Tag, URL: rel.URL}
+		// A failed write only means checking again next time
+		_ = writeCheck(cachePath, check)
+	}
+
+	rel := &Release{Tag: check.Tag, URL: check.URL}
+	if !Newer(rel.Version(), current) {
+		return nil, nil
+	}
+	return rel, nil
+}
+
+// Notice is the one-line hint printed when a newer release exists.
+func Notice(current string, rel *Release) string {
+	return fmt.Sprintf("tinker %s is available (you have %s), run `tinker upgrade` to install it", rel.Version(), current)
+}
+
+func readCheck(path string) (lastCheck, error) {
+	var check lastCheck
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return check, err
+	}
+	err = json.Unmarshal(data, &check)
+	return check, err
+}
+
+func writeCheck(path string, check lastCheck) error {
+	data, err := json.Marshal(check)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,227 @@
+// Package update checks GitHub releases for newer tinker builds and
+// replaces the running executable with them.
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChecksumsAsset is the release asset listing the SHA-256 of every binary
+const ChecksumsAsset = "checksums.txt"
+
+// ErrNoAsset is returned when a release has no binary for this platform.
+var ErrNoAsset = errors.New("no release binary for this platform")
+
+// Release is the subset of a GitHub release used for updating
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+type Asset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+// Version is the release version without a leading v.
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+// Asset returns the release asset with the given name.
+func (r *Release) Asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Client talks to the GitHub releases API of a repository
+type Client struct {
+	// API base URL, overridden in tests
+	BaseURL string
+	Repo    string
+	HTTP    *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{
+		BaseURL: "https://api.github.com",
+		Repo:    "honganh1206/tinker",
+		HTTP:    &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Latest returns the newest published release.
+func (c *Client) Latest(ctx context.Context) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", c.BaseURL, c.Repo)
+	body, err := c.get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch latest release: %w", err)
+	}
+
+	var rel Release
+	if err := json.Unmarshal(body, &rel); err != nil {
+		return nil, fmt.Errorf("decode latest release: %w", err)
+	}
+	return &rel, nil
+}
+
+// Download fetches the binary for this platform from rel and verifies it
+// against the release checksums.
+func (c *Client) Download(ctx context.Context, rel *Release) ([]byte, error) {
+	name := BinaryName(rel.Version(), runtime.GOOS, runtime.GOARCH)
+	bin, ok := rel.Asset(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoAsset, name)
+	}
+	sums, ok := rel.Asset(ChecksumsAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s, refusing to install an unverified binary", rel.Tag, ChecksumsAsset)
+	}
+
+	sumsData, err := c.get(ctx, sums.DownloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("download checksums: %w", err)
+	}
+	want, err := ParseChecksum(sumsData, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.get(ctx, bin.DownloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", name, err)
+	}
+	if got := sha256.Sum256(data); hex.EncodeToString(got[:]) != want {
+		return nil, fmt.Errorf("checksum mismatch for %s", name)
+	}
+	return data, nil
+}
+
+func (c *Client) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// BinaryName is the asset name scripts/build.sh gives a release binary.
+func BinaryName(version, goos, goarch string) string {
+	name := fmt.Sprintf("tinker_%s_%s_%s", version, goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// ParseChecksum finds the hex SHA-256 of name in sha256sum output.
+func ParseChecksum(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks binary mode with a leading *
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
+
+// Newer reports whether version a is newer than b. Both are dotted numeric
+// versions with an optional leading v, anything else is never newer.
+func Newer(a, b string) bool {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return pa[i] > pb[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var out [3]int
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(parts) > len(out) {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
+
+// Replace atomically swaps the executable at path for data. The new binary is
+// written next to it first so the final rename stays on one filesystem.
+func Replace(path string, data []byte) error {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("resolve executable: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat executable: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tinker-upgrade-*")
+	if err != nil {
+		return fmt.Errorf("create temp binary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write temp binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("chmod temp binary: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace executable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"0.2.7", "0.2.6", true},
+		{"v0.3.0", "0.2.9", true},
+		{"0.10.0", "0.9.0", true},
+		{"1.0", "0.9.9", true},
+		{"0.2.6", "0.2.6", false},
+		{"0.2.5", "0.2.6", false},
+		{"0.3.0", "dev", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Newer(tt.a, tt.b), "%s > %s", tt.a, tt.b)
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	sums := []byte("abc123  tinker_0.2.7_linux_amd64\nDEF456 *tinker_0.2.7_linux_arm64\n")
+
+	sum, err := ParseChecksum(sums, "tinker_0.2.7_linux_arm64")
+	require.NoError(t, err)
+	assert.Equal(t, "def456", sum)
+
+	_, err = ParseChecksum(sums, "tinker_0.2.7_darwin_arm64")
+	assert.Error(t, err)
+}
+
+// releaseServer serves a latest release with a binary whose checksum
+// is sum, or the binary's real checksum when sum is empty.
+func releaseServer(t *testing.T, binary []byte, sum string) *httptest.Server {
+	t.Helper()
+	if sum == "" {
+		h := sha256.Sum256(binary)
+		sum = hex.EncodeToString(h[:])
+	}
+	name := BinaryName("0.3.0", runtime.GOOS, runtime.GOARCH)
+
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/repos/owner/tinker/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Release{
+			Tag: "v0.3.0",
+			Assets: []Asset{
+				{Name: name, DownloadURL: srv.URL + "/bin"},
+				{Name: ChecksumsAsset, DownloadURL: srv.URL + "/sums"},
+			},
+		})
+	})
+	mux.HandleFunc("/bin", func(w http.ResponseWriter, r *http.Request) { w.Write(binary) })
+	mux.HandleFunc("/sums", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  %s\n", sum, name)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testClient(srv *httptest.Server) *Client {
+	return &Client{BaseURL: srv.URL, Repo: "owner/tinker", HTTP: srv.Client()}
+}
+
+func TestDownload_VerifiesChecksum(t *testing.T) {
+	binary := []byte("new tinker")
+	c := testClient(releaseServer(t, binary, ""))
+
+	rel, err := c.Latest(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "0.3.0", rel.Version())
+
+	data, err := c.Download(context.Background(), rel)
+	require.NoError(t, err)
+	assert.Equal(t, binary, data)
+}
+
+func TestDownload_ChecksumMismatch(t *testing.T) {
+	c := testClient(releaseServer(t, []byte("tampered"), "00"))
+
+	rel, err := c.Latest(context.Background())
+	require.NoError(t, err)
+
+	_, err = c.Download(context.Background(), rel)
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestDownload_NoAssetForPlatform(t *testing.T) {
+	rel := &Release{Tag: "v0.3.0", Assets: []Asset{{Name: ChecksumsAsset}}}
+
+	_, err := NewClient().Download(context.Background(), rel)
+	assert.ErrorIs(t, err, ErrNoAsset)
+}
+
+func TestReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tinker")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o755))
+
+	require.NoError(t, Replace(path, []byte("new")))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+}
+
+func TestAvailable_UsesCache(t *testing.T) {
+	c := testClient(releaseServer(t, []byte("bin"), ""))
+	cache := filepath.Join(t.TempDir(), "update-check.json")
+
+	rel, err := c.Available(context.Background(), "0.2.6", cache)
+	require.NoError(t, err)
+	require.NotNil(t, rel)
+	assert.Equal(t, "0.3.0", rel.Version())
+
+	// A fresh cache answers without the API
+	offline := &Client{BaseURL: "http://127.0.0.1:0", Repo: "owner/tinker", HTTP: http.DefaultClient}
+	rel, err = offline.Available(context.Background(), "0.3.0", cache)
+	require.NoError(t, err)
+	assert.Nil(t, rel)
+}
@@ -0,0 +1,146 @@
+// Package users keeps the people one tinker server serves, so a shared
+// machine can run a single API server and runner without mixing histories.
+// Each user's sessions live in their own directory under the sessions
+// directory; the owner of the machine keeps the top-level one.
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// Dir is the directory inside a sessions directory holding one directory per user
+const Dir = "users"
+
+// User is one person identified by an API token
+type User struct {
+	Name string `json:"name"`
+	// SHA-256 of the API token, the token itself is only shown when created
+	TokenHash string `json:"token_hash"`
+	// Channel senders, as "channel:senderId", whose conversations belong to the user
+	Senders []string `json:"senders,omitempty"`
+}
+
+// Users is the table of known users
+type Users []User
+
+type usersFile struct {
+	Users Users `json:"users"`
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// DefaultPath is ~/.tinker/users.json.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".tinker", "users.json"), nil
+}
+
+// Load returns the users saved at path, none when there is no file.
+func Load(path string) (Users, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read users: %w", err)
+	}
+	var f usersFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse users %s: %w", path, err)
+	}
+	return f.Users, nil
+}
+
+func Save(path string, u Users) error {
+	data, err := json.MarshalIndent(usersFile{Users: u}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal users: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Add creates a user and returns their API token.
+func (u *Users) Add(name string, senders []string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid user name %q: use lowercase letters, digits, - and _", name)
+	}
+	if _, ok := u.Get(name); ok {
+		return "", fmt.Errorf("user %s already exists", name)
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	token := "tk_" + hex.EncodeToString(raw)
+	*u = append(*u, User{Name: name, TokenHash: hashToken(token), Senders: senders})
+	return token, nil
+}
+
+// Remove deletes the user named name and reports whether there was one.
+func (u *Users) Remove(name string) bool {
+	n := len(*u)
+	*u = slices.DeleteFunc(*u, func(user User) bool { return user.Name == name })
+	return len(*u) != n
+}
+
+func (u Users) Get(name string) (User, bool) {
+	for _, user := range u {
+		if user.Name == name {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+// ByToken returns the user token belongs to.
+func (u Users) ByToken(token string) (User, bool) {
+	if token == "" {
+		return User{}, false
+	}
+	hash := hashToken(token)
+	for _, user := range u {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(user.TokenHash)) == 1 {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+// BySender returns the user a channel sender belongs to.
+func (u Users) BySender(channel, senderID string) (User, bool) {
+	key := channel + ":" + senderID
+	for _, user := range u {
+		if slices.Contains(user.Senders, key) {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+// SessionsDir is where the sessions of the user named name are stored.
+func SessionsDir(sessionsDir, name string) string {
+	return filepath.Join(sessionsDir, Dir, name)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
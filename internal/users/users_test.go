@@ -0,0 +1,63 @@
+package users
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsers_AddAndIdentify(t *testing.T) {
+	var u Users
+	token, err := u.Add("alice", []string{"telegram:42"})
+	require.NoError(t, err)
+
+	user, ok := u.ByToken(token)
+	require.True(t, ok)
+	assert.Equal(t, "alice", user.Name)
+	assert.NotContains(t, user.TokenHash, token)
+
+	_, ok = u.ByToken("tk_wrong")
+	assert.False(t, ok)
+
+	user, ok = u.BySender("telegram", "42")
+	require.True(t, ok)
+	assert.Equal(t, "alice", user.Name)
+}
+
+func TestUsers_AddRejectsDuplicatesAndBadNames(t *testing.T) {
+	var u Users
+	_, err := u.Add("alice", nil)
+	require.NoError(t, err)
+
+	_, err = u.Add("alice", nil)
+	assert.ErrorContains(t, err, "already exists")
+
+	_, err = u.Add("../bob", nil)
+	assert.ErrorContains(t, err, "invalid user name")
+}
+
+func TestUsers_PersistAndRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	var u Users
+	token, err := u.Add("alice", nil)
+	require.NoError(t, err)
+	require.NoError(t, Save(path, u))
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	_, ok := loaded.ByToken(token)
+	assert.True(t, ok)
+
+	assert.True(t, loaded.Remove("alice"))
+	assert.False(t, loaded.Remove("alice"))
+	assert.Empty(t, loaded)
+}
+
+func TestLoad_Missing(t *testing.T) {
+	u, err := Load(filepath.Join(t.TempDir(), "users.json"))
+	require.NoError(t, err)
+	assert.Empty(t, u)
+}
@@ -1,4 +1,4 @@
-import type { Session } from './types'
+import type { Keymap, Session } from './types'
 
 export async function listSessions(): Promise<Session[]> {
   const res = await fetch('/api/sessions')
@@ -16,3 +16,17 @@ export async function deleteSession(id: string): Promise<void> {
   const res = await fetch(`/api/sessions/${id}`, { method: 'DELETE' })
   if (!res.ok) throw new Error(`Failed to delete session: ${res.status}`)
 }
+
+export async function getKeymap(): Promise<Keymap> {
+  const res = await fetch('/api/keymap')
+  if (!res.ok) throw new Error(`Failed to get keymap: ${res.status}`)
+  return res.json()
+}
+
+// getSessionFile reads a file of the directory session id works in, relative
+// paths resolving there.
+export async function getSessionFile(id: string, path: string): Promise<string> {
+  const res = await fetch(`/api/sessions/${id}/file?path=${encodeURIComponent(path)}`)
+  if (!res.ok) throw new Error((await res.text()).trim() || `Failed to read file: ${res.status}`)
+  return res.text()
+}
@@ -0,0 +1,63 @@
+import { writable, get } from 'svelte/store'
+import { getKeymap } from './api'
+import type { Keymap } from './types'
+
+// Effective bindings, action -> keys, loaded from the server config
+export const keymap = writable<Keymap>({})
+
+// Descriptions shown in the key help overlay, in display order
+export const actionLabels: { [action: string]: string } = {
+  next_session: 'Next session',
+  prev_session: 'Previous session',
+  focus_search: 'Search sessions',
+  focus_next: 'Focus next panel',
+  focus_prev: 'Focus previous panel',
+  toggle_sidebar: 'Hide or show the session list',
+  new_tab: 'Open a tab',
+  close_tab: 'Close the tab',
+  next_tab: 'Next tab',
+  prev_tab: 'Previous tab',
+  scroll_down: 'Scroll down',
+  scroll_up: 'Scroll up',
+  toggle_debug: 'Toggle tool inspection',
+  toggle_preview: 'Show or hide the file preview',
+  refresh: 'Refresh sessions',
+  close: 'Close dialog',
+  show_keys: 'Show key bindings',
+}
+
+export async function loadKeymap(): Promise<void> {
+  try {
+    keymap.set(await getKeymap())
+  } catch (e) {
+    console.error('Failed to load keymap:', e)
+  }
+}
+
+// keyName renders an event in keymap notation, e.g. "ctrl+d" or "shift+Tab".
+// Shift is implied for printable characters, so "?" rather than "shift+?".
+export function keyName(e: KeyboardEvent): string {
+  const mods: string[] = []
+  if (e.ctrlKey) mods.push('ctrl')
+  if (e.altKey) mods.push('alt')
+  if (e.metaKey) mods.push('meta')
+  if (e.shiftKey && e.key.length > 1) mods.push('shift')
+  return [...mods, e.key].join('+')
+}
+
+// matches reports whether e triggers action under the current keymap
+export function matches(e: KeyboardEvent, action: string): boolean {
+  return get(keymap)[action]?.includes(keyName(e)) ?? false
+}
+
+// hint is the first key bound to action, for button titles
+export function hint(map: Keymap, action: string): string {
+  const key = map[action]?.[0]
+  return key ? ` (${key})` : ''
+}
+
+// isTyping reports whether e comes from a text field, where plain keys type
+export function isTyping(e: KeyboardEvent): boolean {
+  const target = e.target as HTMLElement
+  return !!target.closest?.('input, textarea, [contenteditable]')
+}
@@ -1,4 +1,4 @@
-import { writable } from 'svelte/store'
+import { writable, get } from 'svelte/store'
 import type { Session } from '../types'
 import { listSessions, getSession, deleteSession} from '../api'
 
@@ -6,6 +6,28 @@ export const sessions = writable<Session[]>([])
 export const selectedId = writable<string | null>(null)
 export const selectedSession = writable<Session | null>(null)
 
+// Open tabs, each the ID of the session it shows or null until one is picked.
+// Picking a session shows it in the active tab.
+export const tabs = writable<(string | null)[]>(loadTabs())
+export const activeTab = writable(
+  Math.min(Number(localStorage.getItem('activeTab')) || 0, get(tabs).length - 1),
+)
+// Sessions of background tabs the agent streamed to since they were shown
+export const unread = writable<{ [id: string]: true }>({})
+
+function loadTabs(): (string | null)[] {
+  try {
+    const saved = JSON.parse(localStorage.getItem('tabs') ?? '[]')
+    if (Array.isArray(saved) && saved.length > 0) return saved
+  } catch {
+    // Start over from one empty tab
+  }
+  return [null]
+}
+
+tabs.subscribe((list) => localStorage.setItem('tabs', JSON.stringify(list)))
+activeTab.subscribe((i) => localStorage.setItem('activeTab', String(i)))
+
 export async function refreshSessions(): Promise<void> {
   try {
     const data = await listSessions()
@@ -17,21 +39,79 @@ export async function refreshSessions(): Promise<void> {
 
 export async function selectSession(id: string): Promise<void> {
   selectedId.set(id)
+  tabs.update((list) => list.with(get(activeTab), id))
+  unread.update(({ [id]: _, ...rest }) => rest)
   try {
     const data = await getSession(id)
-    selectedSession.set(data)
+    // A later pick or tab switch wins over a slow response
+    if (get(selectedId) === id) selectedSession.set(data)
   } catch (e) {
     console.error('Failed to fetch session detail:', e)
     selectedSession.set(null)
   }
 }
 
+// showTab makes tab i active, fetching its session again to catch up with
+// what the agent did in the background.
+export function showTab(i: number): void {
+  const list = get(tabs)
+  if (i < 0 || i >= list.length) return
+  activeTab.set(i)
+  const id = list[i]
+  if (id) {
+    selectSession(id)
+  } else {
+    selectedId.set(null)
+    selectedSession.set(null)
+  }
+}
+
+// openTab adds an empty tab after the others and shows it.
+export function openTab(): void {
+  tabs.update((list) => [...list, null])
+  showTab(get(tabs).length - 1)
+}
+
+// closeTab closes tab i and shows its neighbour. Closing the last tab leaves
+// an empty one.
+export function closeTab(i: number): void {
+  const list = get(tabs)
+  if (list.length === 1) {
+    tabs.set([null])
+    showTab(0)
+    return
+  }
+  tabs.set(list.toSpliced(i, 1))
+  const active = get(activeTab)
+  if (i < active) {
+    activeTab.set(active - 1)
+  } else if (i === active) {
+    showTab(Math.min(i, list.length - 2))
+  }
+}
+
+// cycleTab shows the tab step places away, wrapping around.
+export function cycleTab(step: number): void {
+  const n = get(tabs).length
+  showTab((get(activeTab) + step + n) % n)
+}
+
+// markUnread flags the session of a background tab the agent streamed to.
+export function markUnread(id: string): void {
+  const list = get(tabs)
+  if (list.includes(id) && list[get(activeTab)] !== id) {
+    unread.update((flags) => ({ ...flags, [id]: true }))
+  }
+}
+
 export async function removeSession(id: string): Promise<void> {
   try {
     await deleteSession(id)
     sessions.update((list) => list.filter((s) => s.id !== id))
     selectedId.update((current) => (current === id ? null : current))
     selectedSession.update((current) => (current?.id === id ? null : current))
+    // Tabs of the session stay open, empty
+    tabs.update((list) => list.map((t) => (t === id ? null : t)))
   } catch (e) {
     console.error('Failed to delete session:', e)
   }
@@ -0,0 +1,61 @@
+import { writable } from 'svelte/store'
+import type { FileEdit, ToolInputPreview } from './types'
+import { markUnread } from './stores/sessions'
+
+// Tool calls the model is still generating, keyed by thread (session) ID
+export const toolPreviews = writable<{ [threadId: string]: ToolInputPreview }>({})
+// Last file edit of each thread, with when it arrived
+export const fileEdits = writable<{ [threadId: string]: FileEdit & { at: number } }>({})
+
+// connectStream subscribes to agent stream events and returns a disconnect function.
+// The server drops clients that fall behind, so a closed socket reconnects.
+export function connectStream(): () => void {
+  let ws: WebSocket
+  let closed = false
+  let retry: ReturnType<typeof setTimeout> | undefined
+
+  const connect = () => {
+    const proto = location.protocol === 'https:' ? 'wss:' : 'ws:'
+    ws = new WebSocket(`${proto}//${location.host}/ws/stream`)
+    ws.onmessage = onMessage
+    ws.onclose = () => {
+      if (!closed) retry = setTimeout(connect, 2000)
+    }
+  }
+
+  const onMessage = (msg: MessageEvent) => {
+    let event: { topic: string; data: ToolInputPreview | FileEdit }
+    try {
+      event = JSON.parse(msg.data)
+    } catch (e) {
+      console.error('Failed to parse stream event:', e)
+      return
+    }
+    if (!event.data?.threadId) return
+    markUnread(event.data.threadId)
+
+    if (event.topic === 'agent.file.edit') {
+      const edit = event.data as FileEdit
+      fileEdits.update((edits) => ({ ...edits, [edit.threadId]: { ...edit, at: Date.now() } }))
+      return
+    }
+    const chunk = event.data as ToolInputPreview
+
+    toolPreviews.update((previews) => {
+      const next = { ...previews }
+      if (chunk.done) {
+        delete next[chunk.threadId]
+      } else {
+        next[chunk.threadId] = chunk
+      }
+      return next
+    })
+  }
+
+  connect()
+  return () => {
+    closed = true
+    clearTimeout(retry)
+    ws.close()
+  }
+}
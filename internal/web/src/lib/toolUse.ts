@@ -0,0 +1,14 @@
+// Tool-use content is a versioned envelope; older records stored `name(input)`
+export function parseToolUse(content: string): { name: string; input: string } {
+  try {
+    const env = JSON.parse(content)
+    if (env?.type === 'tool_use') {
+      return { name: env.data.name, input: JSON.stringify(env.data.input) }
+    }
+  } catch {
+    // legacy format
+  }
+  const open = content.indexOf('(')
+  if (open < 0) return { name: content, input: '' }
+  return { name: content.slice(0, open), input: content.slice(open + 1, -1) }
+}
@@ -4,12 +4,15 @@ export enum RecordType {
   ToolUse = 2,
   ToolResult = 3,
   SystemPrompt = 4,
+  SystemNote = 5,
+  Thinking = 6,
 }
 
 export interface Context {
   id: string
   name: string
   start_time: string
+  work_dir?: string
 }
 
 export interface Record {
@@ -20,6 +23,31 @@ export interface Record {
   live: boolean
   est_tokens: number
   context_id: string
+  status?: "ok" | "error"
+  summary?: string
+  output?: string
+}
+
+export interface ToolInputPreview {
+  threadId: string
+  toolUseId: string
+  tool: string
+  partialInput: string
+  done?: boolean
+}
+
+// FileEdit is a change the agent made to a file, see internal/tools/edits.go.
+// Lines and characters are zero-based, the range end excluded.
+export interface FileEdit {
+  threadId: string
+  tool: string
+  path: string
+  range: {
+    start: { line: number; character: number }
+    end: { line: number; character: number }
+  }
+  new_text: string
+  created?: boolean
 }
 
 export interface ContextTool {
@@ -41,3 +69,8 @@ export interface Session {
   context_tools?: ContextTool[]
 }
 
+
+// Keymap binds UI actions to keys, see internal/apiserver/keymap.go
+export interface Keymap {
+  [action: string]: string[]
+}
@@ -49,4 +49,7 @@ for os in "${targets[@]}"; do
   build $os $app_name $version "amd64"
 done
 
+# Checksums let `tinker upgrade` verify the binaries it downloads
+(cd "dist/${version}" && sha256sum tinker_* > checksums.txt)
+
 echo "Done!"